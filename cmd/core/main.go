@@ -30,6 +30,8 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/config"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/storage"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	_ "github.com/lib/pq"
 )
 
@@ -40,6 +42,9 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Sign list cursors with the shared key so every instance accepts them
+	pagination.SetSigningKey([]byte(cfg.Pagination.CursorSecretKey))
+
 	// Initialize OpenTelemetry
 	shutdown, err := middleware.InitOTEL()
 	if err != nil {
@@ -61,8 +66,14 @@ func main() {
 		log.Fatalf("Failed to initialize repositories: %v", err)
 	}
 
+	// Initialize blob storage for uploaded files
+	blobs, err := initializeBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize blob storage: %v", err)
+	}
+
 	// Initialize use cases
-	useCases := initializeUseCases(repos, cfg)
+	useCases := initializeUseCases(repos, blobs, cfg)
 
 	// Create middleware factory
 	middlewareFactory := middleware.NewFactory(cfg)
@@ -88,6 +99,9 @@ func main() {
 	docFS := http.FileServer(http.Dir("./docs"))
 	mux.Handle("/docs/", middlewareFactory.Apply(http.StripPrefix("/docs/", docFS), middleware.AuthTypePublic))
 
+	// Uploaded files such as product images
+	mux.Handle("/media/", middlewareFactory.Apply(http.StripPrefix("/media/", storage.NewHandler(blobs)), middleware.AuthTypePublic))
+
 	// Swagger UI handler
 	mux.Handle("/swagger/", middlewareFactory.WrapFunc(middleware.AuthTypePublic, func(w http.ResponseWriter, r *http.Request) {
 		// Read the HTML template from file
@@ -137,12 +151,34 @@ func main() {
 		}
 	}()
 
+	// Release inventory held by checkouts that were not paid in time
+	sweeperCtx, stopSweeper := context.WithCancel(context.Background())
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+		runReservationSweeper(sweeperCtx, useCases.checkoutUseCase, time.Duration(cfg.Checkout.ReservationSweepIntervalSeconds)*time.Second)
+	}()
+
+	// Bring stored product prices up to date as scheduled prices start and end
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		runPriceScheduler(schedulerCtx, useCases.productUseCase, time.Duration(cfg.Product.PriceScheduleIntervalSeconds)*time.Second)
+	}()
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	middleware.Logger.Info("Shutting down server...")
 
+	// Stop the reservation sweeper and price scheduler before the database is closed
+	stopSweeper()
+	stopScheduler()
+	<-sweeperDone
+	<-schedulerDone
+
 	// Create a timeout context for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -179,47 +215,129 @@ func initializeDatabase(cfg *config.Config) (*sql.DB, error) {
 	return db, nil
 }
 
+// initializeBlobStore creates the blob store selected by the storage driver
+func initializeBlobStore(cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.Storage.Driver {
+	case "local":
+		return storage.NewLocalBlobStore(cfg.Storage.LocalDir, cfg.Storage.PublicURL)
+	case "s3":
+		return storage.NewS3BlobStore(storage.S3Config{
+			Endpoint:        cfg.Storage.S3Endpoint,
+			Region:          cfg.Storage.S3Region,
+			Bucket:          cfg.Storage.S3Bucket,
+			AccessKeyID:     cfg.Storage.S3AccessKeyID,
+			SecretAccessKey: cfg.Storage.S3SecretAccessKey,
+			UsePathStyle:    cfg.Storage.S3UsePathStyle,
+		}, cfg.Storage.PublicURL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, use local or s3", cfg.Storage.Driver)
+	}
+}
+
+// runReservationSweeper periodically cancels checkouts whose inventory reservations expired until ctx is done
+func runReservationSweeper(ctx context.Context, uc checkoutUseCase.CheckoutUseCase, interval time.Duration) {
+	if interval <= 0 {
+		middleware.Logger.Warn("Reservation sweeper disabled", "interval", interval.String())
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := uc.ReleaseExpiredReservations(ctx); err != nil {
+				middleware.Logger.Error("Failed to release expired reservations", "error", err)
+			}
+		}
+	}
+}
+
+// runPriceScheduler periodically applies the product prices that started or ended until ctx is done
+func runPriceScheduler(ctx context.Context, uc productUseCase.ProductUseCase, interval time.Duration) {
+	if interval <= 0 {
+		middleware.Logger.Warn("Price scheduler disabled", "interval", interval.String())
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := uc.ApplyScheduledPrices(ctx); err != nil {
+				middleware.Logger.Error("Failed to apply scheduled prices", "error", err)
+			}
+		}
+	}
+}
+
 type repositories struct {
-	db            *sql.DB
-	productRepo   productRepo.ProductRepository
-	cartRepo      cartRepo.CartRepository
-	checkoutRepo  checkoutRepo.CheckoutRepository
-	promotionRepo promotionRepo.PromotionRepository
-	userRepo      userRepo.UserRepository
-	tokenRepo     userRepo.TokenRepository
+	db              *sql.DB
+	productRepo     productRepo.ProductRepository
+	categoryRepo    productRepo.CategoryRepository
+	imageRepo       productRepo.ProductImageRepository
+	reviewRepo      productRepo.ProductReviewRepository
+	productSearcher productRepo.ProductSearcher
+	cartRepo        cartRepo.CartRepository
+	checkoutRepo    checkoutRepo.CheckoutRepository
+	reservationRepo checkoutRepo.ReservationRepository
+	promotionRepo   promotionRepo.PromotionRepository
+	couponRepo      promotionRepo.CouponRepository
+	customerRepo    promotionRepo.CustomerRepository
+	userRepo        userRepo.UserRepository
+	tokenRepo       userRepo.TokenRepository
 }
 
 func initializeRepositories(db *sql.DB) (*repositories, error) {
 	// Initialize repositories from each domain
 
 	return &repositories{
-		db:            db,
-		productRepo:   productRepo.NewProductRepository(db),
-		cartRepo:      cartRepo.NewCartRepository(db),
-		checkoutRepo:  checkoutRepo.NewCheckoutRepository(db),
-		promotionRepo: promotionRepo.NewPromotionRepository(db),
-		userRepo:      userRepo.NewUserRepository(db),
-		tokenRepo:     userRepo.NewTokenRepository(db),
+		db:              db,
+		productRepo:     productRepo.NewProductRepository(db),
+		categoryRepo:    productRepo.NewCategoryRepository(db),
+		imageRepo:       productRepo.NewProductImageRepository(db),
+		reviewRepo:      productRepo.NewProductReviewRepository(db),
+		productSearcher: productRepo.NewProductSearcher(db),
+		cartRepo:        cartRepo.NewCartRepository(db),
+		checkoutRepo:    checkoutRepo.NewCheckoutRepository(db),
+		reservationRepo: checkoutRepo.NewReservationRepository(db),
+		promotionRepo:   promotionRepo.NewPromotionRepository(db),
+		couponRepo:      promotionRepo.NewCouponRepository(db),
+		customerRepo:    promotionRepo.NewCustomerRepository(db),
+		userRepo:        userRepo.NewUserRepository(db),
+		tokenRepo:       userRepo.NewTokenRepository(db),
 	}, nil
 }
 
 type useCases struct {
 	productUseCase   productUseCase.ProductUseCase
+	categoryUseCase  productUseCase.CategoryUseCase
+	reviewUseCase    productUseCase.ReviewUseCase
 	cartUseCase      cartUseCase.CartUseCase
 	checkoutUseCase  checkoutUseCase.CheckoutUseCase
 	promotionUseCase promotionUseCase.PromotionUseCase
 	userUseCase      userUseCase.UserUseCase
 }
 
-func initializeUseCases(repos *repositories, cfg *config.Config) *useCases {
+func initializeUseCases(repos *repositories, blobs storage.BlobStore, cfg *config.Config) *useCases {
 	// Initialize transaction manager
 	txManager := persistence.ProvideTransactionManager(repos.db)
 
 	// Initialize use cases with proper dependencies
-	productUC := productUseCase.NewProductUseCase(repos.productRepo)
-	promotionUC := promotionUseCase.NewPromotionUseCase(repos.promotionRepo)
+	productUC := productUseCase.NewProductUseCase(repos.productRepo, repos.categoryRepo, repos.imageRepo, repos.productSearcher, txManager, blobs)
+	categoryUC := productUseCase.NewCategoryUseCase(repos.categoryRepo, txManager)
+	reviewUC := productUseCase.NewReviewUseCase(repos.reviewRepo, repos.productRepo)
+	promotionUC := promotionUseCase.NewPromotionUseCase(repos.promotionRepo, repos.couponRepo, repos.customerRepo, repos.productRepo, repos.cartRepo, txManager)
 	cartUC := cartUseCase.NewCartUseCase(repos.cartRepo, repos.productRepo, promotionUC)
-	checkoutUC := checkoutUseCase.NewCheckoutUseCase(repos.checkoutRepo, repos.cartRepo, repos.promotionRepo, txManager)
+	reservationTTL := time.Duration(cfg.Checkout.ReservationTTLMinutes) * time.Minute
+	checkoutUC := checkoutUseCase.NewCheckoutUseCase(repos.checkoutRepo, repos.reservationRepo, repos.cartRepo, repos.productRepo, repos.promotionRepo, repos.couponRepo, repos.customerRepo, txManager, reservationTTL)
 
 	// Initialize user use case with JWT configuration from config
 	userUC := userUseCase.NewUserUseCase(
@@ -230,6 +348,8 @@ func initializeUseCases(repos *repositories, cfg *config.Config) *useCases {
 
 	return &useCases{
 		productUseCase:   productUC,
+		categoryUseCase:  categoryUC,
+		reviewUseCase:    reviewUC,
 		cartUseCase:      cartUC,
 		checkoutUseCase:  checkoutUC,
 		promotionUseCase: promotionUC,
@@ -277,28 +397,78 @@ func createAPIHandler(useCases *useCases, middlewareFactory *middleware.Factory)
 	mux.Handle("/api/v1/users/", userRBAC.Wrap(userBaseHandler))
 
 	// Product API with direct RBAC middleware
-	productBaseHandler := productPort.NewHTTPServer(useCases.productUseCase)
+	productBaseHandler := productPort.NewHTTPServer(useCases.productUseCase, useCases.categoryUseCase, useCases.reviewUseCase)
 	productRBAC := middleware.NewRBACMiddleware(middlewareFactory).
 		// List and Get operations are public
 		WithOperation("ListProducts", middleware.AuthTypePublic, middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin).
 		WithOperation("GetProduct", middleware.AuthTypePublic).
+		WithOperation("SearchProducts", middleware.AuthTypePublic).
 		// Write operations require admin role
 		WithOperation("CreateProduct", middleware.AuthTypeRoleAdmin).
 		WithOperation("UpdateProduct", middleware.AuthTypeRoleAdmin).
 		WithOperation("DeleteProduct", middleware.AuthTypeRoleAdmin).
+		WithOperation("SetProductCategories", middleware.AuthTypeRoleAdmin).
+		WithOperation("CreateProductVariant", middleware.AuthTypeRoleAdmin).
+		WithOperation("UpdateProductVariant", middleware.AuthTypeRoleAdmin).
+		WithOperation("DeleteProductVariant", middleware.AuthTypeRoleAdmin).
+		WithOperation("ImportProducts", middleware.AuthTypeRoleAdmin).
+		WithOperation("ExportProducts", middleware.AuthTypeRoleAdmin).
+		WithOperation("GetProductPrices", middleware.AuthTypeRoleAdmin).
+		WithOperation("ScheduleProductPrice", middleware.AuthTypeRoleAdmin).
+		WithOperation("UploadProductImage", middleware.AuthTypeRoleAdmin).
+		WithOperation("ReorderProductImages", middleware.AuthTypeRoleAdmin).
+		WithOperation("DeleteProductImage", middleware.AuthTypeRoleAdmin).
+		// Anyone can read published reviews; customers write them and admins moderate them
+		WithOperation("ListProductReviews", middleware.AuthTypePublic).
+		WithOperation("CreateProductReview", middleware.AuthTypeRoleCustomer).
+		WithOperation("ListReviews", middleware.AuthTypeRoleAdmin).
+		WithOperation("ModerateReview", middleware.AuthTypeRoleAdmin).
+		WithOperation("DeleteReview", middleware.AuthTypeRoleAdmin).
+		// The category tree is public; changing it requires admin role
+		WithOperation("ListCategories", middleware.AuthTypePublic).
+		WithOperation("GetCategory", middleware.AuthTypePublic).
+		WithOperation("CreateCategory", middleware.AuthTypeRoleAdmin).
+		WithOperation("UpdateCategory", middleware.AuthTypeRoleAdmin).
+		WithOperation("DeleteCategory", middleware.AuthTypeRoleAdmin).
 		// Set default access control (restrict by default)
 		WithDefaultRoles(middleware.AuthTypeRoleAdmin)
 
 	// Register product path patterns
 	productRBAC.RegisterPathPattern("GET", "/api/v1/products", "ListProducts")
 	productRBAC.RegisterPathPattern("POST", "/api/v1/products", "CreateProduct")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/products/search", "SearchProducts")
+	productRBAC.RegisterPathPattern("POST", "/api/v1/products/import", "ImportProducts")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/products/export", "ExportProducts")
 	productRBAC.RegisterPathPattern("GET", "/api/v1/products/{id}", "GetProduct")
 	productRBAC.RegisterPathPattern("PUT", "/api/v1/products/{id}", "UpdateProduct")
 	productRBAC.RegisterPathPattern("DELETE", "/api/v1/products/{id}", "DeleteProduct")
+	productRBAC.RegisterPathPattern("PUT", "/api/v1/products/{id}/categories", "SetProductCategories")
+	productRBAC.RegisterPathPattern("POST", "/api/v1/products/{id}/variants", "CreateProductVariant")
+	productRBAC.RegisterPathPattern("PUT", "/api/v1/products/{id}/variants/{variant_id}", "UpdateProductVariant")
+	productRBAC.RegisterPathPattern("DELETE", "/api/v1/products/{id}/variants/{variant_id}", "DeleteProductVariant")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/products/{id}/prices", "GetProductPrices")
+	productRBAC.RegisterPathPattern("POST", "/api/v1/products/{id}/prices", "ScheduleProductPrice")
+	productRBAC.RegisterPathPattern("POST", "/api/v1/products/{id}/images", "UploadProductImage")
+	productRBAC.RegisterPathPattern("PUT", "/api/v1/products/{id}/images/order", "ReorderProductImages")
+	productRBAC.RegisterPathPattern("DELETE", "/api/v1/products/{id}/images/{image_id}", "DeleteProductImage")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/products/{id}/reviews", "ListProductReviews")
+	productRBAC.RegisterPathPattern("POST", "/api/v1/products/{id}/reviews", "CreateProductReview")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/reviews", "ListReviews")
+	productRBAC.RegisterPathPattern("PATCH", "/api/v1/reviews/{id}", "ModerateReview")
+	productRBAC.RegisterPathPattern("DELETE", "/api/v1/reviews/{id}", "DeleteReview")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/categories", "ListCategories")
+	productRBAC.RegisterPathPattern("POST", "/api/v1/categories", "CreateCategory")
+	productRBAC.RegisterPathPattern("GET", "/api/v1/categories/{id}", "GetCategory")
+	productRBAC.RegisterPathPattern("PUT", "/api/v1/categories/{id}", "UpdateCategory")
+	productRBAC.RegisterPathPattern("DELETE", "/api/v1/categories/{id}", "DeleteCategory")
 
 	// Register product API endpoints
 	mux.Handle("/api/v1/products", productRBAC.Wrap(productBaseHandler))
 	mux.Handle("/api/v1/products/", productRBAC.Wrap(productBaseHandler))
+	mux.Handle("/api/v1/categories", productRBAC.Wrap(productBaseHandler))
+	mux.Handle("/api/v1/categories/", productRBAC.Wrap(productBaseHandler))
+	mux.Handle("/api/v1/reviews", productRBAC.Wrap(productBaseHandler))
+	mux.Handle("/api/v1/reviews/", productRBAC.Wrap(productBaseHandler))
 
 	// Cart API with operation-based RBAC
 	cartBaseHandler := cartPort.NewHTTPServer(useCases.cartUseCase, useCases.promotionUseCase)
@@ -313,6 +483,8 @@ func createAPIHandler(useCases *useCases, middlewareFactory *middleware.Factory)
 		WithOperation("RemoveItem", middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin).
 		WithOperation("GetCurrentUserCart", middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin).
 		WithOperation("AddItemToCurrentUserCart", middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin).
+		WithOperation("ApplyCouponToCurrentUserCart", middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin).
+		WithOperation("RemoveCouponFromCurrentUserCart", middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin).
 		// Default to customer access
 		WithDefaultRoles(middleware.AuthTypeRoleCustomer, middleware.AuthTypeRoleAdmin)
 
@@ -325,6 +497,8 @@ func createAPIHandler(useCases *useCases, middlewareFactory *middleware.Factory)
 	cartRBAC.RegisterPathPattern("DELETE", "/api/v1/carts/{id}/items/{item_id}", "RemoveItem")
 	cartRBAC.RegisterPathPattern("GET", "/api/v1/carts/me", "GetCurrentUserCart")
 	cartRBAC.RegisterPathPattern("POST", "/api/v1/carts/me", "AddItemToCurrentUserCart")
+	cartRBAC.RegisterPathPattern("PUT", "/api/v1/carts/me/coupon", "ApplyCouponToCurrentUserCart")
+	cartRBAC.RegisterPathPattern("DELETE", "/api/v1/carts/me/coupon", "RemoveCouponFromCurrentUserCart")
 
 	// Register cart API endpoints
 	mux.Handle("/api/v1/carts", cartRBAC.Wrap(cartBaseHandler))
@@ -359,7 +533,15 @@ func createAPIHandler(useCases *useCases, middlewareFactory *middleware.Factory)
 		// Modify promotions requires admin
 		WithOperation("CreatePromotion", middleware.AuthTypeRoleAdmin).
 		WithOperation("UpdatePromotion", middleware.AuthTypeRoleAdmin).
+		WithOperation("ListPromotionVersions", middleware.AuthTypeRoleAdmin).
 		WithOperation("DeletePromotion", middleware.AuthTypeRoleAdmin).
+		WithOperation("CreatePromotionCoupon", middleware.AuthTypeRoleAdmin).
+		WithOperation("PreviewPromotion", middleware.AuthTypeRoleAdmin).
+		WithOperation("GetPromotionsReport", middleware.AuthTypeRoleAdmin).
+		WithOperation("GetPromotionReport", middleware.AuthTypeRoleAdmin).
+		// Customer segments used by promotion eligibility
+		WithOperation("AddSegmentMember", middleware.AuthTypeRoleAdmin).
+		WithOperation("RemoveSegmentMember", middleware.AuthTypeRoleAdmin).
 		WithDefaultRoles(middleware.AuthTypeRoleAdmin)
 
 	// Register promotion path patterns
@@ -367,11 +549,19 @@ func createAPIHandler(useCases *useCases, middlewareFactory *middleware.Factory)
 	promotionRBAC.RegisterPathPattern("POST", "/api/v1/promotions", "CreatePromotion")
 	promotionRBAC.RegisterPathPattern("GET", "/api/v1/promotions/{id}", "GetPromotion")
 	promotionRBAC.RegisterPathPattern("PUT", "/api/v1/promotions/{id}", "UpdatePromotion")
+	promotionRBAC.RegisterPathPattern("GET", "/api/v1/promotions/{id}/versions", "ListPromotionVersions")
 	promotionRBAC.RegisterPathPattern("DELETE", "/api/v1/promotions/{id}", "DeletePromotion")
+	promotionRBAC.RegisterPathPattern("POST", "/api/v1/promotions/{id}/coupons", "CreatePromotionCoupon")
+	promotionRBAC.RegisterPathPattern("POST", "/api/v1/promotions/preview", "PreviewPromotion")
+	promotionRBAC.RegisterPathPattern("GET", "/api/v1/promotions/report", "GetPromotionsReport")
+	promotionRBAC.RegisterPathPattern("GET", "/api/v1/promotions/{id}/report", "GetPromotionReport")
+	promotionRBAC.RegisterPathPattern("PUT", "/api/v1/segments/{segment}/members/{user_id}", "AddSegmentMember")
+	promotionRBAC.RegisterPathPattern("DELETE", "/api/v1/segments/{segment}/members/{user_id}", "RemoveSegmentMember")
 
 	// Register promotion API endpoints
 	mux.Handle("/api/v1/promotions", promotionRBAC.Wrap(promotionBaseHandler))
 	mux.Handle("/api/v1/promotions/", promotionRBAC.Wrap(promotionBaseHandler))
+	mux.Handle("/api/v1/segments/", promotionRBAC.Wrap(promotionBaseHandler))
 
 	return mux
 }
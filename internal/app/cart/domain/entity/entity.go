@@ -3,6 +3,7 @@ package entity
 import (
 	"time"
 
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -137,24 +138,24 @@ func (c *Cart) TotalItems() int {
 }
 
 // Subtotal calculates the subtotal of all items in the cart (before promotions)
-func (c *Cart) Subtotal() float64 {
+func (c *Cart) Subtotal() money.Money {
 	// Since unit price is now stored in the product table, we can't calculate this directly
 	// This would need to be calculated at the repository level with product information
-	return 0.0
+	return money.Zero()
 }
 
 // CartItemInfo represents a cart item with product details for display purposes
 type CartItemInfo struct {
-	ID          uuid.UUID `json:"id"`
-	UserID      uuid.UUID `json:"user_id"`
-	ProductID   uuid.UUID `json:"product_id"`
-	Quantity    int       `json:"quantity"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	ProductSKU  string    `json:"product_sku"`
-	ProductName string    `json:"product_name"`
-	UnitPrice   float64   `json:"unit_price"`
-	Subtotal    float64   `json:"subtotal"`
+	ID          uuid.UUID   `json:"id"`
+	UserID      uuid.UUID   `json:"user_id"`
+	ProductID   uuid.UUID   `json:"product_id"`
+	Quantity    int         `json:"quantity"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	ProductSKU  string      `json:"product_sku"`
+	ProductName string      `json:"product_name"`
+	UnitPrice   money.Money `json:"unit_price"`
+	Subtotal    money.Money `json:"subtotal"`
 }
 
 // CartInfo represents a cart with product details for display purposes
@@ -163,16 +164,60 @@ type CartInfo struct {
 	Items                []*CartItemInfo       `json:"items"`
 	CreatedAt            time.Time             `json:"created_at"`
 	UpdatedAt            time.Time             `json:"updated_at"`
-	Subtotal             float64               `json:"subtotal"`
+	Subtotal             money.Money           `json:"subtotal"`
 	ApplicablePromotions []ApplicablePromotion `json:"applicable_promotions,omitempty"`
-	PotentialDiscount    float64               `json:"potential_discount,omitempty"`
-	PotentialTotal       float64               `json:"potential_total,omitempty"`
+	PotentialDiscount    money.Money           `json:"potential_discount,omitempty"`
+	PotentialTotal       money.Money           `json:"potential_total,omitempty"`
+	SkippedPromotions    []SkippedPromotion    `json:"skipped_promotions,omitempty"`
+	TierProgress         []TierProgress        `json:"tier_progress,omitempty"`
+	PromotionNotices     []PromotionNotice     `json:"promotion_notices,omitempty"`
+	CouponCode           string                `json:"coupon_code,omitempty"`
 }
 
 // ApplicablePromotion represents a promotion that can be applied to a cart
 type ApplicablePromotion struct {
-	ID          uuid.UUID `json:"id"`
-	Type        string    `json:"type"`
-	Description string    `json:"description"`
-	Discount    float64   `json:"discount"`
+	ID          uuid.UUID   `json:"id"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Discount    money.Money `json:"discount"`
+}
+
+// SkippedPromotion is a promotion for the cart's items that was not applied because a better
+// combination of promotions discounts the same units
+type SkippedPromotion struct {
+	ID                uuid.UUID   `json:"id"`
+	Type              string      `json:"type"`
+	Description       string      `json:"description"`
+	Reason            string      `json:"reason"`
+	BlockedBy         *uuid.UUID  `json:"blocked_by,omitempty"`
+	PotentialDiscount money.Money `json:"potential_discount"`
+}
+
+// DiscountTier is a quantity break of a tiered promotion
+type DiscountTier struct {
+	MinQuantity        int     `json:"min_quantity"`
+	DiscountPercentage float64 `json:"discount_percentage"`
+}
+
+// TierProgress shows which tier of a tiered promotion a cart line reached and how many more units
+// unlock the next one
+type TierProgress struct {
+	PromotionID     uuid.UUID     `json:"promotion_id"`
+	Description     string        `json:"description"`
+	ProductSKU      string        `json:"product_sku"`
+	Quantity        int           `json:"quantity"`
+	CurrentTier     *DiscountTier `json:"current_tier,omitempty"`
+	NextTier        *DiscountTier `json:"next_tier,omitempty"`
+	UnitsToNextTier int           `json:"units_to_next_tier"`
+}
+
+// PromotionNotice describes a promotion for items in the cart that is not running right now,
+// either because it has not started yet or because it has expired
+type PromotionNotice struct {
+	ID          uuid.UUID  `json:"id"`
+	Type        string     `json:"type"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
 }
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -8,14 +9,14 @@ import (
 func ApplyPromotionsToCart(
 	cart *CartInfo,
 	promotions []ApplicablePromotion,
-	totalDiscount float64,
+	totalDiscount money.Money,
 ) *CartInfo {
 	// Apply promotion data to cart
 	cart.ApplicablePromotions = promotions
 	cart.PotentialDiscount = totalDiscount
-	cart.PotentialTotal = cart.Subtotal - totalDiscount
-	if cart.PotentialTotal < 0 {
-		cart.PotentialTotal = 0
+	cart.PotentialTotal = cart.Subtotal.Sub(totalDiscount)
+	if cart.PotentialTotal.IsNegative() {
+		cart.PotentialTotal = money.Zero()
 	}
 	return cart
 }
@@ -25,7 +26,7 @@ func ConvertPromotionData(
 	promotionID uuid.UUID,
 	promotionType string,
 	description string,
-	discount float64,
+	discount money.Money,
 ) ApplicablePromotion {
 	return ApplicablePromotion{
 		ID:          promotionID,
@@ -37,10 +38,10 @@ func ConvertPromotionData(
 
 // PromotionDiscount represents the promotion type from promotion domain
 type PromotionDiscount struct {
-	PromotionID   uuid.UUID `json:"promotion_id"`
-	PromotionType string    `json:"promotion_type"`
-	Description   string    `json:"description"`
-	Discount      float64   `json:"discount"`
+	PromotionID   uuid.UUID   `json:"promotion_id"`
+	PromotionType string      `json:"promotion_type"`
+	Description   string      `json:"description"`
+	Discount      money.Money `json:"discount"`
 }
 
 // ConvertPromotionDiscounts converts a list of promotion discounts to cart applicable promotions
@@ -1,6 +1,7 @@
 package params
 
 import (
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -17,13 +18,13 @@ type UpdateItemQuantityParams struct {
 
 // CartItemResponse defines the response structure for a cart item
 type CartItemResponse struct {
-	ID        uuid.UUID `json:"id"`
-	ProductID uuid.UUID `json:"product_id"`
-	SKU       string    `json:"sku"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	Quantity  int       `json:"quantity"`
-	Subtotal  float64   `json:"subtotal"`
+	ID        uuid.UUID   `json:"id"`
+	ProductID uuid.UUID   `json:"product_id"`
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	Quantity  int         `json:"quantity"`
+	Subtotal  money.Money `json:"subtotal"`
 }
 
 // CartResponse defines the response structure for a cart
@@ -31,5 +32,5 @@ type CartResponse struct {
 	ID         uuid.UUID          `json:"id"`
 	Items      []CartItemResponse `json:"items"`
 	TotalItems int                `json:"total_items"`
-	Subtotal   float64            `json:"subtotal"`
+	Subtotal   money.Money        `json:"subtotal"`
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/common/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
 	"github.com/fanzru/e-commerce-be/pkg/errors"
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 
 	openapi_types "github.com/oapi-codegen/runtime/types"
@@ -35,6 +36,12 @@ type HTTPHandler interface {
 
 	// ClearUserCart handles the DELETE /carts/me/clear endpoint
 	ClearUserCart(w http.ResponseWriter, r *http.Request)
+
+	// ApplyCouponToCurrentUserCart handles the PUT /carts/me/coupon endpoint
+	ApplyCouponToCurrentUserCart(w http.ResponseWriter, r *http.Request)
+
+	// RemoveCouponFromCurrentUserCart handles the DELETE /carts/me/coupon endpoint
+	RemoveCouponFromCurrentUserCart(w http.ResponseWriter, r *http.Request)
 }
 
 // CartHandler handles HTTP requests for carts
@@ -115,7 +122,7 @@ func (h *CartHandler) GetCurrentUserCart(w http.ResponseWriter, r *http.Request)
 	// Debug: Log promotion application results
 	middleware.Logger.Info("Promotion application results",
 		"applicable_promotions_count", len(applicablePromotions),
-		"total_discount", totalDiscount)
+		"total_discount", totalDiscount.String())
 
 	// Log each applicable promotion in detail
 	for i, promo := range applicablePromotions {
@@ -123,7 +130,7 @@ func (h *CartHandler) GetCurrentUserCart(w http.ResponseWriter, r *http.Request)
 			"promotion_id", promo.PromotionID.String(),
 			"promotion_type", promo.PromotionType,
 			"description", promo.Description,
-			"discount", promo.Discount)
+			"discount", promo.Discount.String())
 	}
 
 	// Map to response
@@ -310,10 +317,82 @@ func (h *CartHandler) ClearUserCart(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// ApplyCouponToCurrentUserCart handles PUT /carts/me/coupon requests
+func (h *CartHandler) ApplyCouponToCurrentUserCart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Authenticate user
+	userClaims, ok := ctx.Value(middleware.ContextTokenClaimsKey).(*userParams.TokenClaims)
+	if !ok || userClaims == nil {
+		handleError(w, errs.NewUnauthorized("authentication required"))
+		return
+	}
+
+	// Parse user ID from claims
+	userID, err := uuid.Parse(userClaims.UserID)
+	if err != nil {
+		handleError(w, errs.NewBadRequest("invalid user ID"))
+		return
+	}
+
+	// Parse request body
+	var params struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errors.NewBadRequest("invalid request body"))
+		return
+	}
+
+	cartInfo, err := h.cartUseCase.ApplyCoupon(ctx, userID, params.Code)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	// Calculate promotions including the one unlocked by the coupon
+	applicablePromotions, totalDiscount, err := h.promotionUseCase.ApplyPromotions(ctx, cartInfo)
+	if err != nil {
+		// Log the error but don't fail the request
+		middleware.Logger.Error("Failed to calculate promotions", "error", err.Error())
+		// Continue without promotions
+	}
+
+	response := mapCartInfoToResponseWithPromotions(cartInfo, applicablePromotions, totalDiscount, "Coupon applied successfully")
+	respondJSON(w, http.StatusOK, response)
+}
+
+// RemoveCouponFromCurrentUserCart handles DELETE /carts/me/coupon requests
+func (h *CartHandler) RemoveCouponFromCurrentUserCart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Authenticate user
+	userClaims, ok := ctx.Value(middleware.ContextTokenClaimsKey).(*userParams.TokenClaims)
+	if !ok || userClaims == nil {
+		handleError(w, errs.NewUnauthorized("authentication required"))
+		return
+	}
+
+	// Parse user ID from claims
+	userID, err := uuid.Parse(userClaims.UserID)
+	if err != nil {
+		handleError(w, errs.NewBadRequest("invalid user ID"))
+		return
+	}
+
+	err = h.cartUseCase.RemoveCoupon(ctx, userID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Helper functions
 
 // mapCartInfoToResponseWithPromotions maps a cart entity to a cart response with promotions
-func mapCartInfoToResponseWithPromotions(cartInfo *entity.CartInfo, promotions []promotionUseCase.PromotionDiscount, totalDiscount float64, message string) genhttp.CartResponse {
+func mapCartInfoToResponseWithPromotions(cartInfo *entity.CartInfo, promotions []promotionUseCase.PromotionDiscount, totalDiscount money.Money, message string) genhttp.CartResponse {
 	// First convert the basic cart info
 	cartData := genhttp.Cart{}
 
@@ -328,7 +407,7 @@ func mapCartInfoToResponseWithPromotions(cartInfo *entity.CartInfo, promotions [
 	cartData.UpdatedAt = &updatedAt
 
 	// Prepare the items and calculate totals
-	subtotal := float32(cartInfo.Subtotal)
+	subtotal := cartInfo.Subtotal.Float64()
 	totalItems := 0
 
 	// Convert cart items
@@ -351,7 +430,7 @@ func mapCartInfoToResponseWithPromotions(cartInfo *entity.CartInfo, promotions [
 			id := openapi_types.UUID(promo.PromotionID)
 			promoType := promo.PromotionType
 			description := promo.Description
-			discount := float32(promo.Discount)
+			discount := promo.Discount.Float64()
 
 			applicablePromotions[i] = genhttp.ApplicablePromotion{
 				Id:          &id,
@@ -363,16 +442,89 @@ func mapCartInfoToResponseWithPromotions(cartInfo *entity.CartInfo, promotions [
 		cartData.ApplicablePromotions = &applicablePromotions
 
 		// Calculate potential discount and total
-		potentialDiscount := float32(totalDiscount)
+		potentialDiscount := totalDiscount.Float64()
 		cartData.PotentialDiscount = &potentialDiscount
 
-		potentialTotal := subtotal - potentialDiscount
-		if potentialTotal < 0 {
-			potentialTotal = 0
+		// Compute the total in Money so the response does not pick up float rounding
+		total := cartInfo.Subtotal.Sub(totalDiscount)
+		if total.IsNegative() {
+			total = money.Zero()
 		}
+		potentialTotal := total.Float64()
 		cartData.PotentialTotal = &potentialTotal
 	}
 
+	// Add the promotions that lost out to a better combination
+	if len(cartInfo.SkippedPromotions) > 0 {
+		skipped := make([]genhttp.SkippedPromotion, len(cartInfo.SkippedPromotions))
+		for i, promo := range cartInfo.SkippedPromotions {
+			id := openapi_types.UUID(promo.ID)
+			promoType := promo.Type
+			description := promo.Description
+			reason := genhttp.SkippedPromotionReason(promo.Reason)
+			potentialDiscount := promo.PotentialDiscount.Float64()
+
+			skipped[i] = genhttp.SkippedPromotion{
+				Id:                &id,
+				Type:              &promoType,
+				Description:       &description,
+				Reason:            &reason,
+				BlockedBy:         promo.BlockedBy,
+				PotentialDiscount: &potentialDiscount,
+			}
+		}
+		cartData.SkippedPromotions = &skipped
+	}
+
+	// Add how far each tiered promotion is from its next tier
+	if len(cartInfo.TierProgress) > 0 {
+		tiers := make([]genhttp.TierProgress, len(cartInfo.TierProgress))
+		for i, progress := range cartInfo.TierProgress {
+			id := openapi_types.UUID(progress.PromotionID)
+			description := progress.Description
+			sku := progress.ProductSKU
+			quantity := progress.Quantity
+			unitsToNextTier := progress.UnitsToNextTier
+
+			tiers[i] = genhttp.TierProgress{
+				PromotionId:     &id,
+				Description:     &description,
+				ProductSku:      &sku,
+				Quantity:        &quantity,
+				CurrentTier:     mapDiscountTier(progress.CurrentTier),
+				NextTier:        mapDiscountTier(progress.NextTier),
+				UnitsToNextTier: &unitsToNextTier,
+			}
+		}
+		cartData.TierProgress = &tiers
+	}
+
+	// Add notices about promotions that start later or have ended
+	if len(cartInfo.PromotionNotices) > 0 {
+		notices := make([]genhttp.PromotionNotice, len(cartInfo.PromotionNotices))
+		for i, notice := range cartInfo.PromotionNotices {
+			id := openapi_types.UUID(notice.ID)
+			promoType := notice.Type
+			description := notice.Description
+			status := genhttp.PromotionNoticeStatus(notice.Status)
+
+			notices[i] = genhttp.PromotionNotice{
+				Id:          &id,
+				Type:        &promoType,
+				Description: &description,
+				Status:      &status,
+				StartsAt:    notice.StartsAt,
+				EndsAt:      notice.EndsAt,
+			}
+		}
+		cartData.PromotionNotices = &notices
+	}
+
+	if cartInfo.CouponCode != "" {
+		couponCode := cartInfo.CouponCode
+		cartData.CouponCode = &couponCode
+	}
+
 	// Create the response
 	return genhttp.CartResponse{
 		Code:       "success",
@@ -382,6 +534,17 @@ func mapCartInfoToResponseWithPromotions(cartInfo *entity.CartInfo, promotions [
 	}
 }
 
+// mapDiscountTier maps a promotion tier to the response representation
+func mapDiscountTier(tier *entity.DiscountTier) *genhttp.DiscountTier {
+	if tier == nil {
+		return nil
+	}
+	return &genhttp.DiscountTier{
+		MinQuantity:        tier.MinQuantity,
+		DiscountPercentage: tier.DiscountPercentage,
+	}
+}
+
 // mapCartItemToResponse maps a cart item entity to a cart item response
 func mapCartItemToResponse(item *entity.CartItemInfo, message string) genhttp.CartItemResponse {
 	itemData := convertCartItemInfoToGenHTTP(item)
@@ -398,7 +561,7 @@ func convertCartItemInfoToGenHTTP(item *entity.CartItemInfo) genhttp.CartItem {
 	itemId := openapi_types.UUID(item.ID)
 	productId := openapi_types.UUID(item.ProductID)
 	userId := openapi_types.UUID(item.UserID)
-	unitPrice := float32(item.UnitPrice)
+	unitPrice := item.UnitPrice.Float64()
 	quantity := item.Quantity
 	createdAt := item.CreatedAt
 	updatedAt := item.UpdatedAt
@@ -415,7 +578,7 @@ func convertCartItemInfoToGenHTTP(item *entity.CartItemInfo) genhttp.CartItem {
 		UpdatedAt:   &updatedAt,
 	}
 
-	subtotal := float32(item.UnitPrice) * float32(item.Quantity)
+	subtotal := item.UnitPrice.Mul(item.Quantity).Float64()
 	cartItem.Subtotal = &subtotal
 
 	return cartItem
@@ -19,7 +19,8 @@ type CartRepository interface {
 	// GetByUserID retrieves all cart items for a user
 	GetByUserID(ctx context.Context, userID uuid.UUID) (*entity.Cart, error)
 
-	// GetCartInfo retrieves cart with product details for display
+	// GetCartInfo retrieves cart with product details for display. Inside a transaction the
+	// cart's rows stay locked until it ends.
 	GetCartInfo(ctx context.Context, userID uuid.UUID) (*entity.CartInfo, error)
 
 	// AddItem adds an item to a user's cart or updates its quantity if already exists
@@ -37,6 +38,12 @@ type CartRepository interface {
 	// GetItemByProductID gets a specific item by product ID from a user's cart
 	GetItemByProductID(ctx context.Context, userID, productID uuid.UUID) (*entity.CartItem, error)
 
-	// ClearUserCart removes all items from a user's cart
+	// ClearUserCart removes all items and the coupon from a user's cart
 	ClearUserCart(ctx context.Context, userID uuid.UUID) error
+
+	// SetCoupon attaches a coupon to a user's cart, replacing any coupon already attached
+	SetCoupon(ctx context.Context, userID, couponID uuid.UUID) error
+
+	// RemoveCoupon detaches the coupon from a user's cart
+	RemoveCoupon(ctx context.Context, userID uuid.UUID) error
 }
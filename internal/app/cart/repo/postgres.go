@@ -10,6 +10,8 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/app/cart/domain/entity"
 	domainErrors "github.com/fanzru/e-commerce-be/internal/app/cart/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -41,7 +43,7 @@ func (r *CartPostgresRepository) GetByUserID(ctx context.Context, userID uuid.UU
 	itemsQuery := `
 		SELECT 
 			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at, ci.updated_at,
-			p.sku, p.name, p.price
+			p.sku, p.name, product_price_at(p.id, NOW()) AS price
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
 		WHERE ci.user_id = $1 AND ci.deleted_at IS NULL
@@ -62,7 +64,7 @@ func (r *CartPostgresRepository) GetByUserID(ctx context.Context, userID uuid.UU
 		var item entity.CartItem
 		var productSKU string
 		var productName string
-		var unitPrice float64
+		var unitPrice money.Money
 
 		err := rows.Scan(
 			&item.ID,
@@ -254,7 +256,7 @@ func (r *CartPostgresRepository) GetItem(ctx context.Context, userID, itemID uui
 	query := `
 		SELECT 
 			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at, ci.updated_at,
-			p.sku, p.name, p.price
+			p.sku, p.name, product_price_at(p.id, NOW()) AS price
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
 		WHERE ci.id = $1 AND ci.user_id = $2 AND ci.deleted_at IS NULL
@@ -263,7 +265,7 @@ func (r *CartPostgresRepository) GetItem(ctx context.Context, userID, itemID uui
 	var item entity.CartItem
 	var productSKU string
 	var productName string
-	var unitPrice float64
+	var unitPrice money.Money
 
 	err := r.db.QueryRowContext(ctx, query, itemID, userID).Scan(
 		&item.ID,
@@ -306,7 +308,7 @@ func (r *CartPostgresRepository) GetItemByProductID(ctx context.Context, userID,
 	query := `
 		SELECT 
 			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at, ci.updated_at,
-			p.sku, p.name, p.price
+			p.sku, p.name, product_price_at(p.id, NOW()) AS price
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
 		WHERE ci.product_id = $1 AND ci.user_id = $2 AND ci.deleted_at IS NULL
@@ -315,7 +317,7 @@ func (r *CartPostgresRepository) GetItemByProductID(ctx context.Context, userID,
 	var item entity.CartItem
 	var productSKU string
 	var productName string
-	var unitPrice float64
+	var unitPrice money.Money
 
 	err := r.db.QueryRowContext(ctx, query, productID, userID).Scan(
 		&item.ID,
@@ -345,7 +347,7 @@ func (r *CartPostgresRepository) GetItemByProductID(ctx context.Context, userID,
 	return &item, nil
 }
 
-// ClearUserCart removes all items from a user's cart
+// ClearUserCart removes all items and the coupon from a user's cart
 func (r *CartPostgresRepository) ClearUserCart(ctx context.Context, userID uuid.UUID) error {
 	logger := middleware.Logger.With(
 		"method", "CartRepository.ClearUserCart",
@@ -360,7 +362,8 @@ func (r *CartPostgresRepository) ClearUserCart(ctx context.Context, userID uuid.
 		WHERE user_id = $1 AND deleted_at IS NULL
 	`
 
-	result, err := r.db.ExecContext(ctx, query, userID)
+	// Use the caller's transaction if there is one, so checkout can roll the cart back
+	result, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, query, userID)
 	if err != nil {
 		logger.Error("Failed to clear user cart", "error", err.Error())
 		return fmt.Errorf("error clearing user cart: %w", err)
@@ -372,6 +375,13 @@ func (r *CartPostgresRepository) ClearUserCart(ctx context.Context, userID uuid.
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
+	// A coupon is applied to one order only
+	_, err = persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, `DELETE FROM cart_coupons WHERE user_id = $1`, userID)
+	if err != nil {
+		logger.Error("Failed to remove cart coupon", "error", err.Error())
+		return fmt.Errorf("error removing cart coupon: %w", err)
+	}
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully cleared user cart",
 		"items_removed", rowsAffected,
@@ -395,21 +405,24 @@ func (r *CartPostgresRepository) GetCartInfo(ctx context.Context, userID uuid.UU
 		Items:     []*entity.CartItemInfo{},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-		Subtotal:  0,
+		Subtotal:  money.Zero(),
 	}
 
 	// Get all cart items with product details
 	itemsQuery := `
 		SELECT 
 			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at, ci.updated_at,
-			p.sku, p.name, p.price
+			p.sku, p.name, product_price_at(p.id, NOW()) AS price
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
 		WHERE ci.user_id = $1 AND ci.deleted_at IS NULL
 		ORDER BY ci.created_at
+		FOR UPDATE OF ci
 	`
 
-	rows, err := r.db.QueryContext(ctx, itemsQuery, userID)
+	// Lock the cart's rows so a checkout clears exactly the items it priced
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+	rows, err := queryable.QueryContext(ctx, itemsQuery, userID)
 	if err != nil {
 		logger.Error("Failed to query cart items", "error", err.Error())
 		return nil, fmt.Errorf("error querying cart items: %w", err)
@@ -417,7 +430,7 @@ func (r *CartPostgresRepository) GetCartInfo(ctx context.Context, userID uuid.UU
 	defer rows.Close()
 
 	itemCount := 0
-	var totalSubtotal float64 = 0
+	totalSubtotal := money.Zero()
 
 	for rows.Next() {
 		var item entity.CartItemInfo
@@ -438,8 +451,8 @@ func (r *CartPostgresRepository) GetCartInfo(ctx context.Context, userID uuid.UU
 		}
 
 		// Calculate subtotal for the item
-		item.Subtotal = item.UnitPrice * float64(item.Quantity)
-		totalSubtotal += item.Subtotal
+		item.Subtotal = item.UnitPrice.Mul(item.Quantity)
+		totalSubtotal = totalSubtotal.Add(item.Subtotal)
 
 		cartInfo.Items = append(cartInfo.Items, &item)
 		itemCount++
@@ -452,11 +465,83 @@ func (r *CartPostgresRepository) GetCartInfo(ctx context.Context, userID uuid.UU
 
 	cartInfo.Subtotal = totalSubtotal
 
+	// Load the coupon attached to the cart, if any
+	couponQuery := `
+		SELECT c.code
+		FROM cart_coupons cc
+		JOIN coupons c ON cc.coupon_id = c.id
+		WHERE cc.user_id = $1
+		FOR UPDATE OF cc
+	`
+	err = queryable.QueryRowContext(ctx, couponQuery, userID).Scan(&cartInfo.CouponCode)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Failed to query cart coupon", "error", err.Error())
+		return nil, fmt.Errorf("error querying cart coupon: %w", err)
+	}
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved cart info with product details",
 		"item_count", itemCount,
-		"subtotal", totalSubtotal,
+		"subtotal", totalSubtotal.String(),
 		"duration_ms", duration.Milliseconds())
 
 	return cartInfo, nil
 }
+
+// SetCoupon attaches a coupon to a user's cart, replacing any coupon already attached
+func (r *CartPostgresRepository) SetCoupon(ctx context.Context, userID, couponID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CartRepository.SetCoupon",
+		"user_id", userID.String(),
+		"coupon_id", couponID.String(),
+	)
+	logger.Debug("Setting cart coupon")
+	startTime := time.Now()
+
+	query := `
+		INSERT INTO cart_coupons (user_id, coupon_id, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET coupon_id = EXCLUDED.coupon_id, created_at = NOW()
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID, couponID)
+	if err != nil {
+		logger.Error("Failed to set cart coupon", "error", err.Error())
+		return fmt.Errorf("error setting cart coupon: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully set cart coupon",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// RemoveCoupon detaches the coupon from a user's cart
+func (r *CartPostgresRepository) RemoveCoupon(ctx context.Context, userID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CartRepository.RemoveCoupon",
+		"user_id", userID.String(),
+	)
+	logger.Debug("Removing cart coupon")
+	startTime := time.Now()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM cart_coupons WHERE user_id = $1`, userID)
+	if err != nil {
+		logger.Error("Failed to remove cart coupon", "error", err.Error())
+		return fmt.Errorf("error removing cart coupon: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully removed cart coupon",
+		"coupons_removed", rowsAffected,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
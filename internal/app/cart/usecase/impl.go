@@ -4,15 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	cartEntity "github.com/fanzru/e-commerce-be/internal/app/cart/domain/entity"
 	domainErrors "github.com/fanzru/e-commerce-be/internal/app/cart/domain/errs"
 	cartRepo "github.com/fanzru/e-commerce-be/internal/app/cart/repo"
 	productRepo "github.com/fanzru/e-commerce-be/internal/app/product/repo"
+	promotionEntity "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
 	promotionUseCase "github.com/fanzru/e-commerce-be/internal/app/promotion/usecase"
 	"github.com/fanzru/e-commerce-be/internal/common/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -63,7 +66,7 @@ func (u *cartUseCase) GetUserCart(ctx context.Context, userID uuid.UUID) (*cartE
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved cart",
 		"item_count", itemCount,
-		"subtotal", cart.Subtotal(),
+		"subtotal", cart.Subtotal().String(),
 		"duration_ms", duration.Milliseconds())
 
 	return cart, nil
@@ -107,6 +110,12 @@ func (u *cartUseCase) AddItemToUserCart(ctx context.Context, userID, productID u
 		return nil, fmt.Errorf("failed to get product: %w", err)
 	}
 
+	// A parent product is sold through its variants, which carry their own SKU and stock
+	if product.HasVariants() {
+		logger.Warn("Product has variants", "sku", product.SKU)
+		return nil, errs.NewBadRequest(fmt.Sprintf("product %s has variants; add one of its variants instead", product.SKU))
+	}
+
 	if existingItem != nil {
 
 		// Check if there's enough inventory
@@ -300,6 +309,72 @@ func (u *cartUseCase) ClearUserCart(ctx context.Context, userID uuid.UUID) error
 	return nil
 }
 
+// ApplyCoupon validates a coupon code and attaches it to a user's cart
+func (u *cartUseCase) ApplyCoupon(ctx context.Context, userID uuid.UUID, code string) (*cartEntity.CartInfo, error) {
+	logger := middleware.Logger.With(
+		"method", "CartUseCase.ApplyCoupon",
+		"user_id", userID.String(),
+		"code", code,
+	)
+	logger.Info("Applying coupon to cart")
+	startTime := time.Now()
+
+	if userID == uuid.Nil {
+		logger.Warn("Invalid user ID")
+		return nil, errors.New("invalid user ID")
+	}
+	if strings.TrimSpace(code) == "" {
+		logger.Warn("Empty coupon code")
+		return nil, errs.NewBadRequest("coupon code is required")
+	}
+
+	coupon, err := u.promotionUseCase.ValidateCoupon(ctx, code, userID)
+	if err != nil {
+		logger.Warn("Coupon cannot be applied", "error", err.Error())
+		return nil, err
+	}
+
+	err = u.cartRepo.SetCoupon(ctx, userID, coupon.ID)
+	if err != nil {
+		logger.Error("Failed to set cart coupon", "error", err.Error())
+		return nil, fmt.Errorf("failed to set cart coupon: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully applied coupon to cart",
+		"coupon_id", coupon.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return u.GetUserCartInfo(ctx, userID)
+}
+
+// RemoveCoupon detaches the coupon from a user's cart
+func (u *cartUseCase) RemoveCoupon(ctx context.Context, userID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CartUseCase.RemoveCoupon",
+		"user_id", userID.String(),
+	)
+	logger.Info("Removing coupon from cart")
+	startTime := time.Now()
+
+	if userID == uuid.Nil {
+		logger.Warn("Invalid user ID")
+		return errors.New("invalid user ID")
+	}
+
+	err := u.cartRepo.RemoveCoupon(ctx, userID)
+	if err != nil {
+		logger.Error("Failed to remove cart coupon", "error", err.Error())
+		return fmt.Errorf("failed to remove cart coupon: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully removed coupon from cart",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
 // GetUserCartInfo retrieves the cart with product details for a user
 func (u *cartUseCase) GetUserCartInfo(ctx context.Context, userID uuid.UUID) (*cartEntity.CartInfo, error) {
 	logger := middleware.Logger.With(
@@ -323,14 +398,16 @@ func (u *cartUseCase) GetUserCartInfo(ctx context.Context, userID uuid.UUID) (*c
 	// Apply promotions if cart is not empty
 	if len(cartInfo.Items) > 0 {
 		// Get applicable promotions from promotion service
-		promotions, totalDiscount, err := u.promotionUseCase.ApplyPromotions(ctx, cartInfo)
+		evaluation, err := u.promotionUseCase.EvaluatePromotions(ctx, cartInfo)
 		if err != nil {
 			logger.Error("Failed to apply promotions", "error", err.Error())
 			// Continue without promotions if there's an error
 		} else {
+			totalDiscount := evaluation.TotalDiscount
+
 			// Convert promotion discounts to cart's ApplicablePromotion type
-			applicablePromotions := make([]cartEntity.ApplicablePromotion, 0, len(promotions))
-			for _, p := range promotions {
+			applicablePromotions := make([]cartEntity.ApplicablePromotion, 0, len(evaluation.Discounts))
+			for _, p := range evaluation.Discounts {
 				applicablePromotions = append(applicablePromotions, cartEntity.ApplicablePromotion{
 					ID:          p.PromotionID,
 					Type:        p.PromotionType,
@@ -339,17 +416,66 @@ func (u *cartUseCase) GetUserCartInfo(ctx context.Context, userID uuid.UUID) (*c
 				})
 			}
 
+			// Keep the promotions that lost out to a better combination, with the reason
+			skippedPromotions := make([]cartEntity.SkippedPromotion, 0, len(evaluation.Skipped))
+			for _, p := range evaluation.Skipped {
+				skippedPromotions = append(skippedPromotions, cartEntity.SkippedPromotion{
+					ID:                p.PromotionID,
+					Type:              p.PromotionType,
+					Description:       p.Description,
+					Reason:            p.Reason,
+					BlockedBy:         p.BlockedBy,
+					PotentialDiscount: p.PotentialDiscount,
+				})
+			}
+
+			// Show how far each tiered promotion is from its next tier
+			tierProgress := make([]cartEntity.TierProgress, 0, len(evaluation.Tiers))
+			for _, t := range evaluation.Tiers {
+				tierProgress = append(tierProgress, cartEntity.TierProgress{
+					PromotionID:     t.PromotionID,
+					Description:     t.Description,
+					ProductSKU:      t.ProductSKU,
+					Quantity:        t.Quantity,
+					CurrentTier:     convertDiscountTier(t.CurrentTier),
+					NextTier:        convertDiscountTier(t.NextTier),
+					UnitsToNextTier: t.UnitsToNextTier,
+				})
+			}
+
 			// Apply promotions to cart
 			cartInfo.ApplicablePromotions = applicablePromotions
+			cartInfo.SkippedPromotions = skippedPromotions
+			cartInfo.TierProgress = tierProgress
 			cartInfo.PotentialDiscount = totalDiscount
-			cartInfo.PotentialTotal = cartInfo.Subtotal - totalDiscount
-			if cartInfo.PotentialTotal < 0 {
-				cartInfo.PotentialTotal = 0
+			cartInfo.PotentialTotal = cartInfo.Subtotal.Sub(totalDiscount)
+			if cartInfo.PotentialTotal.IsNegative() {
+				cartInfo.PotentialTotal = money.Zero()
 			}
 
 			logger.Info("Applied promotions to cart",
 				"applicable_promotions_count", len(applicablePromotions),
-				"total_discount", totalDiscount)
+				"skipped_promotions_count", len(skippedPromotions),
+				"total_discount", totalDiscount.String())
+		}
+
+		// Tell the shopper about promotions for these items that start later or have ended
+		notices, err := u.promotionUseCase.GetPromotionNotices(ctx, cartInfo)
+		if err != nil {
+			logger.Error("Failed to get promotion notices", "error", err.Error())
+			// Continue without notices if there's an error
+		} else {
+			cartInfo.PromotionNotices = make([]cartEntity.PromotionNotice, 0, len(notices))
+			for _, n := range notices {
+				cartInfo.PromotionNotices = append(cartInfo.PromotionNotices, cartEntity.PromotionNotice{
+					ID:          n.PromotionID,
+					Type:        n.PromotionType,
+					Description: n.Description,
+					Status:      string(n.Status),
+					StartsAt:    n.StartsAt,
+					EndsAt:      n.EndsAt,
+				})
+			}
 		}
 	}
 
@@ -357,9 +483,20 @@ func (u *cartUseCase) GetUserCartInfo(ctx context.Context, userID uuid.UUID) (*c
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved cart info",
 		"item_count", itemCount,
-		"subtotal", cartInfo.Subtotal,
-		"potential_total", cartInfo.PotentialTotal,
+		"subtotal", cartInfo.Subtotal.String(),
+		"potential_total", cartInfo.PotentialTotal.String(),
 		"duration_ms", duration.Milliseconds())
 
 	return cartInfo, nil
 }
+
+// convertDiscountTier converts a promotion tier to the cart's representation
+func convertDiscountTier(tier *promotionEntity.DiscountTier) *cartEntity.DiscountTier {
+	if tier == nil {
+		return nil
+	}
+	return &cartEntity.DiscountTier{
+		MinQuantity:        tier.MinQuantity,
+		DiscountPercentage: tier.DiscountPercentage,
+	}
+}
@@ -26,4 +26,10 @@ type CartUseCase interface {
 
 	// ClearUserCart removes all items from a user's cart
 	ClearUserCart(ctx context.Context, userID uuid.UUID) error
+
+	// ApplyCoupon validates a coupon code and attaches it to a user's cart
+	ApplyCoupon(ctx context.Context, userID uuid.UUID, code string) (*cartEntity.CartInfo, error)
+
+	// RemoveCoupon detaches the coupon from a user's cart
+	RemoveCoupon(ctx context.Context, userID uuid.UUID) error
 }
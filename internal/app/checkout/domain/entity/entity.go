@@ -3,6 +3,7 @@ package entity
 import (
 	"time"
 
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -33,9 +34,9 @@ type Checkout struct {
 	UserID           *uuid.UUID          `json:"user_id,omitempty"`
 	Items            []*CheckoutItem     `json:"items"`
 	Promotions       []*PromotionApplied `json:"promotions,omitempty"`
-	Subtotal         float64             `json:"subtotal"`
-	TotalDiscount    float64             `json:"total_discount"`
-	Total            float64             `json:"total"`
+	Subtotal         money.Money         `json:"subtotal"`
+	TotalDiscount    money.Money         `json:"total_discount"`
+	Total            money.Money         `json:"total"`
 	PaymentStatus    PaymentStatus       `json:"payment_status"`
 	PaymentMethod    *string             `json:"payment_method,omitempty"`
 	PaymentReference *string             `json:"payment_reference,omitempty"`
@@ -44,33 +45,108 @@ type Checkout struct {
 	CreatedAt        time.Time           `json:"created_at"`
 	UpdatedAt        time.Time           `json:"updated_at"`
 	CompletedAt      *time.Time          `json:"completed_at,omitempty"`
+
+	// InventoryRestockedAt is set once the ordered quantities have been returned to stock
+	InventoryRestockedAt *time.Time `json:"inventory_restocked_at,omitempty"`
+
+	// ReservationExpiresAt is when the stock held for an unpaid checkout is released
+	ReservationExpiresAt *time.Time `json:"reservation_expires_at,omitempty"`
 }
 
 // CheckoutItem represents an item in a checkout
 type CheckoutItem struct {
-	ID          uuid.UUID `json:"id"`
-	CheckoutID  uuid.UUID `json:"checkout_id"`
-	ProductID   uuid.UUID `json:"product_id"`
-	ProductSKU  string    `json:"product_sku"`
-	ProductName string    `json:"product_name"`
-	Quantity    int       `json:"quantity"`
-	UnitPrice   float64   `json:"unit_price"`
-	Subtotal    float64   `json:"subtotal"`
-	Discount    float64   `json:"discount"`
-	Total       float64   `json:"total"`
+	ID          uuid.UUID   `json:"id"`
+	CheckoutID  uuid.UUID   `json:"checkout_id"`
+	ProductID   uuid.UUID   `json:"product_id"`
+	ProductSKU  string      `json:"product_sku"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   money.Money `json:"unit_price"`
+	Subtotal    money.Money `json:"subtotal"`
+	Discount    money.Money `json:"discount"`
+	Total       money.Money `json:"total"`
 }
 
-// PromotionApplied represents a promotion applied to a checkout
+// PromotionStatus records whether a promotion evaluated at checkout discounted the order
+type PromotionStatus string
+
+const (
+	// PromotionStatusApplied means the promotion discounted the checkout
+	PromotionStatusApplied PromotionStatus = "APPLIED"
+	// PromotionStatusSkipped means the promotion applied on its own but lost to a better combination
+	PromotionStatusSkipped PromotionStatus = "SKIPPED"
+	// PromotionStatusNotReached means the order has not reached the first tier of a tiered promotion
+	PromotionStatusNotReached PromotionStatus = "NOT_REACHED"
+)
+
+// PromotionApplied represents a promotion evaluated for a checkout. Skipped promotions are kept
+// with a zero discount and the reason they were left out, and tiered promotions whose first tier
+// was not reached with a zero discount and the units that would unlock it.
 type PromotionApplied struct {
-	ID          uuid.UUID `json:"id"`
-	CheckoutID  uuid.UUID `json:"checkout_id"`
-	PromotionID uuid.UUID `json:"promotion_id"`
-	Description string    `json:"description"`
-	Discount    float64   `json:"discount"`
+	ID          uuid.UUID       `json:"id"`
+	CheckoutID  uuid.UUID       `json:"checkout_id"`
+	PromotionID uuid.UUID       `json:"promotion_id"`
+	Description string          `json:"description"`
+	Discount    money.Money     `json:"discount"`
+	Status      PromotionStatus `json:"status"`
+	SkipReason  *string         `json:"skip_reason,omitempty"`
+
+	// PromotionVersion is the version of the promotion terms the checkout was priced with
+	PromotionVersion *int `json:"promotion_version,omitempty"`
+
+	// The tier reached by a tiered promotion and what the next tier needs; nil for other promotions
+	TierMinQuantity        *int     `json:"tier_min_quantity,omitempty"`
+	TierDiscountPercentage *float64 `json:"tier_discount_percentage,omitempty"`
+	NextTierMinQuantity    *int     `json:"next_tier_min_quantity,omitempty"`
+	UnitsToNextTier        *int     `json:"units_to_next_tier,omitempty"`
+}
+
+// ReservationStatus represents the status of an inventory reservation
+type ReservationStatus string
+
+const (
+	// ReservationStatusReserved holds stock for a pending checkout until it expires
+	ReservationStatusReserved ReservationStatus = "RESERVED"
+	// ReservationStatusCommitted means the stock was taken out of inventory after payment
+	ReservationStatusCommitted ReservationStatus = "COMMITTED"
+	// ReservationStatusReleased means the stock is no longer held for the checkout
+	ReservationStatusReleased ReservationStatus = "RELEASED"
+)
+
+// InventoryReservation is stock held for a checkout line while the customer pays
+type InventoryReservation struct {
+	ID         uuid.UUID         `json:"id"`
+	CheckoutID uuid.UUID         `json:"checkout_id"`
+	ProductID  uuid.UUID         `json:"product_id"`
+	Quantity   int               `json:"quantity"`
+	Status     ReservationStatus `json:"status"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// NewInventoryReservation creates a reservation for a checkout line that expires after ttl
+func NewInventoryReservation(checkoutID, productID uuid.UUID, quantity int, ttl time.Duration) *InventoryReservation {
+	now := time.Now()
+	return &InventoryReservation{
+		ID:         uuid.New(),
+		CheckoutID: checkoutID,
+		ProductID:  productID,
+		Quantity:   quantity,
+		Status:     ReservationStatusReserved,
+		ExpiresAt:  now.Add(ttl),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// IsActive checks if the reservation still holds stock
+func (r *InventoryReservation) IsActive() bool {
+	return r.Status == ReservationStatusReserved && r.ExpiresAt.After(time.Now())
 }
 
 // NewCheckout creates a new checkout with the given parameters
-func NewCheckout(userID *uuid.UUID, items []*CheckoutItem, subtotal, totalDiscount, total float64) *Checkout {
+func NewCheckout(userID *uuid.UUID, items []*CheckoutItem, subtotal, totalDiscount, total money.Money) *Checkout {
 	return &Checkout{
 		ID:            uuid.New(),
 		UserID:        userID,
@@ -85,9 +161,9 @@ func NewCheckout(userID *uuid.UUID, items []*CheckoutItem, subtotal, totalDiscou
 }
 
 // AddItem adds an item to the checkout
-func (c *Checkout) AddItem(productID uuid.UUID, sku, name string, price float64, quantity int, discount float64) {
-	subtotal := price * float64(quantity)
-	total := subtotal - discount
+func (c *Checkout) AddItem(productID uuid.UUID, sku, name string, price money.Money, quantity int, discount money.Money) {
+	subtotal := price.Mul(quantity)
+	total := subtotal.Sub(discount)
 
 	item := &CheckoutItem{
 		ID:          uuid.New(),
@@ -103,24 +179,36 @@ func (c *Checkout) AddItem(productID uuid.UUID, sku, name string, price float64,
 	}
 
 	c.Items = append(c.Items, item)
-	c.Subtotal += subtotal
-	c.TotalDiscount += discount
-	c.Total += total
+	c.Subtotal = c.Subtotal.Add(subtotal)
+	c.TotalDiscount = c.TotalDiscount.Add(discount)
+	c.Total = c.Total.Add(total)
 }
 
 // CalculateTotal recalculates the checkout totals
 func (c *Checkout) CalculateTotal() {
-	subtotal := 0.0
-	totalDiscount := 0.0
+	subtotal := money.Zero()
+	totalDiscount := money.Zero()
 
 	for _, item := range c.Items {
-		subtotal += item.Subtotal
-		totalDiscount += item.Discount
+		subtotal = subtotal.Add(item.Subtotal)
+		totalDiscount = totalDiscount.Add(item.Discount)
 	}
 
 	c.Subtotal = subtotal
 	c.TotalDiscount = totalDiscount
-	c.Total = subtotal - totalDiscount
+	c.Total = subtotal.Sub(totalDiscount)
+}
+
+// ReleasesInventory reports whether moving a checkout to this payment status
+// returns its ordered quantities to product inventory
+func (s PaymentStatus) ReleasesInventory() bool {
+	return s == PaymentStatusFailed || s == PaymentStatusRefunded
+}
+
+// ReleasesInventory reports whether moving a checkout to this order status
+// returns its ordered quantities to product inventory
+func (s OrderStatus) ReleasesInventory() bool {
+	return s == OrderStatusCancelled
 }
 
 // SetPaymentStatus updates the payment status
@@ -13,4 +13,6 @@ var (
 	ErrInvalidOrderStatus      = errors.New("invalid order status")
 	ErrInvalidStatusTransition = errors.New("invalid status transition")
 	ErrPaymentRequired         = errors.New("payment required for this operation")
+	ErrReservationExpired      = errors.New("inventory reservation has expired")
+	ErrInventoryRestocked      = errors.New("checkout inventory has already been restocked")
 )
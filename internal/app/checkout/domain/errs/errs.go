@@ -2,7 +2,10 @@ package errs
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/fanzru/e-commerce-be/internal/common/errs"
 	appErrors "github.com/fanzru/e-commerce-be/pkg/errors"
 )
 
@@ -12,6 +15,8 @@ const (
 	ErrCheckoutFailedMsg        = "checkout failed"
 	ErrCartEmptyMsg             = "cart is empty"
 	ErrInsufficientInventoryMsg = "insufficient product inventory"
+	ErrReservationExpiredMsg    = "inventory reservation has expired"
+	ErrInventoryRestockedMsg    = "checkout inventory has already been restocked"
 )
 
 // NewCheckoutNotFoundError creates a new checkout not found error
@@ -41,3 +46,49 @@ func NewInsufficientInventoryError(productID, sku string, requested, available i
 		),
 	)
 }
+
+// StockShortage describes a checkout line that cannot be fulfilled from current inventory
+type StockShortage struct {
+	SKU       string `json:"sku"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// NewOutOfStockError creates an out of stock error listing every SKU that is short
+func NewOutOfStockError(shortages []StockShortage) error {
+	skus := make([]string, 0, len(shortages))
+	for _, shortage := range shortages {
+		skus = append(skus, shortage.SKU)
+	}
+
+	return errs.NewWithData(
+		ErrInsufficientStock,
+		errs.CodeOutOfStock,
+		http.StatusBadRequest,
+		fmt.Sprintf("%s: %s", ErrInsufficientInventoryMsg, strings.Join(skus, ", ")),
+		map[string]interface{}{
+			"skus":      skus,
+			"shortages": shortages,
+		},
+	)
+}
+
+// NewReservationExpiredError creates an error for a payment that arrives after the checkout's stock hold lapsed
+func NewReservationExpiredError(checkoutID string) error {
+	return errs.New(
+		ErrReservationExpired,
+		errs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("%s for checkout %s", ErrReservationExpiredMsg, checkoutID),
+	)
+}
+
+// NewInventoryRestockedError creates an error for a payment that arrives after the checkout's stock was given back
+func NewInventoryRestockedError(checkoutID string) error {
+	return errs.New(
+		ErrInventoryRestocked,
+		errs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("%s for checkout %s", ErrInventoryRestockedMsg, checkoutID),
+	)
+}
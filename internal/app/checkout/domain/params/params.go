@@ -1,6 +1,7 @@
 package params
 
 import (
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -11,20 +12,20 @@ type CheckoutRequest struct {
 
 // CheckoutItemResponse defines the response structure for a checkout item
 type CheckoutItemResponse struct {
-	ProductID uuid.UUID `json:"product_id"`
-	SKU       string    `json:"sku"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	Quantity  int       `json:"quantity"`
-	Subtotal  float64   `json:"subtotal"`
-	Discount  float64   `json:"discount"`
-	Total     float64   `json:"total"`
+	ProductID uuid.UUID   `json:"product_id"`
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	Quantity  int         `json:"quantity"`
+	Subtotal  money.Money `json:"subtotal"`
+	Discount  money.Money `json:"discount"`
+	Total     money.Money `json:"total"`
 }
 
 // PromotionAppliedResponse defines the response structure for an applied promotion
 type PromotionAppliedResponse struct {
-	Description string  `json:"description"`
-	Discount    float64 `json:"discount"`
+	Description string      `json:"description"`
+	Discount    money.Money `json:"discount"`
 }
 
 // CheckoutResponse defines the response structure for a checkout
@@ -32,8 +33,8 @@ type CheckoutResponse struct {
 	ID            uuid.UUID                  `json:"id"`
 	Items         []CheckoutItemResponse     `json:"items"`
 	Promotions    []PromotionAppliedResponse `json:"promotions"`
-	Subtotal      float64                    `json:"subtotal"`
-	TotalDiscount float64                    `json:"total_discount"`
-	Total         float64                    `json:"total"`
+	Subtotal      money.Money                `json:"subtotal"`
+	TotalDiscount money.Money                `json:"total_discount"`
+	Total         money.Money                `json:"total"`
 	CreatedAt     string                     `json:"created_at"`
 }
@@ -9,8 +9,10 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/app/checkout/port/genhttp"
 	"github.com/fanzru/e-commerce-be/internal/app/checkout/usecase"
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/params"
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
 	appmiddleware "github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
 	"github.com/fanzru/e-commerce-be/pkg/errors"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
@@ -85,8 +87,19 @@ func (h *CheckoutHandler) GetApiV1Checkouts(w http.ResponseWriter, r *http.Reque
 		limit = *params.Limit
 	}
 
+	var cursor string
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+
+	req, err := pagination.NewRequest(page, limit, cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid cursor"))
+		return
+	}
+
 	// Call the use case
-	checkouts, total, err := h.checkoutUseCase.ListCheckouts(ctx, page, limit)
+	checkouts, result, err := h.checkoutUseCase.ListCheckouts(ctx, req)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -95,9 +108,9 @@ func (h *CheckoutHandler) GetApiV1Checkouts(w http.ResponseWriter, r *http.Reque
 	// Convert to response format
 	checkoutSummaries := make([]genhttp.CheckoutSummary, len(checkouts))
 	for i, checkout := range checkouts {
-		subtotal := float32(checkout.Subtotal)
-		totalDiscount := float32(checkout.TotalDiscount)
-		total := float32(checkout.Total)
+		subtotal := checkout.Subtotal.Float64()
+		totalDiscount := checkout.TotalDiscount.Float64()
+		total := checkout.Total.Float64()
 
 		// Convert payment status and order status
 		paymentStatus := genhttp.CheckoutSummaryPaymentStatus(checkout.PaymentStatus)
@@ -115,14 +128,7 @@ func (h *CheckoutHandler) GetApiV1Checkouts(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	meta := genhttp.PaginationMeta{
-		CurrentPage: &page,
-		PerPage:     &limit,
-		Total:       &total,
-	}
-
-	totalPages := (total + limit - 1) / limit
-	meta.TotalPages = &totalPages
+	meta := mapPaginationMeta(req, result)
 
 	response := genhttp.CheckoutListResponse{
 		Code:    "success",
@@ -198,8 +204,19 @@ func (h *CheckoutHandler) GetApiV1UsersUserIdOrders(w http.ResponseWriter, r *ht
 		limit = *params.Limit
 	}
 
+	var cursor string
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+
+	req, err := pagination.NewRequest(page, limit, cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid cursor"))
+		return
+	}
+
 	// Call the use case
-	orders, total, err := h.checkoutUseCase.GetUserOrders(ctx, userID, page, limit)
+	orders, result, err := h.checkoutUseCase.GetUserOrders(ctx, userID, req)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -208,9 +225,9 @@ func (h *CheckoutHandler) GetApiV1UsersUserIdOrders(w http.ResponseWriter, r *ht
 	// Convert to response format
 	orderSummaries := make([]genhttp.OrderSummary, len(orders))
 	for i, order := range orders {
-		subtotal := float32(order.Subtotal)
-		totalDiscount := float32(order.TotalDiscount)
-		total := float32(order.Total)
+		subtotal := order.Subtotal.Float64()
+		totalDiscount := order.TotalDiscount.Float64()
+		total := order.Total.Float64()
 		itemCount := len(order.Items)
 
 		// Convert payment status and order status
@@ -230,14 +247,7 @@ func (h *CheckoutHandler) GetApiV1UsersUserIdOrders(w http.ResponseWriter, r *ht
 		}
 	}
 
-	meta := genhttp.PaginationMeta{
-		CurrentPage: &page,
-		PerPage:     &limit,
-		Total:       &total,
-	}
-
-	totalPages := (total + limit - 1) / limit
-	meta.TotalPages = &totalPages
+	meta := mapPaginationMeta(req, result)
 
 	response := genhttp.OrderListResponse{
 		Code:    "success",
@@ -390,37 +400,38 @@ func isValidOrderStatus(status entity.OrderStatus) bool {
 
 // mapCheckoutToResponse maps a checkout entity to a checkout response
 func mapCheckoutToResponse(checkout *entity.Checkout) genhttp.CheckoutResponse {
-	subtotal := float32(checkout.Subtotal)
-	totalDiscount := float32(checkout.TotalDiscount)
-	total := float32(checkout.Total)
+	subtotal := checkout.Subtotal.Float64()
+	totalDiscount := checkout.TotalDiscount.Float64()
+	total := checkout.Total.Float64()
 
 	// Convert payment status and order status
 	paymentStatus := genhttp.CheckoutPaymentStatus(checkout.PaymentStatus)
 	status := genhttp.CheckoutStatus(checkout.Status)
 
 	checkoutData := genhttp.Checkout{
-		Id:               &checkout.ID,
-		UserId:           checkout.UserID,
-		PaymentStatus:    &paymentStatus,
-		PaymentMethod:    checkout.PaymentMethod,
-		PaymentReference: checkout.PaymentReference,
-		Notes:            checkout.Notes,
-		Status:           &status,
-		Subtotal:         &subtotal,
-		TotalDiscount:    &totalDiscount,
-		Total:            &total,
-		CreatedAt:        &checkout.CreatedAt,
-		UpdatedAt:        &checkout.UpdatedAt,
-		CompletedAt:      checkout.CompletedAt,
+		Id:                   &checkout.ID,
+		UserId:               checkout.UserID,
+		PaymentStatus:        &paymentStatus,
+		PaymentMethod:        checkout.PaymentMethod,
+		PaymentReference:     checkout.PaymentReference,
+		Notes:                checkout.Notes,
+		Status:               &status,
+		Subtotal:             &subtotal,
+		TotalDiscount:        &totalDiscount,
+		Total:                &total,
+		CreatedAt:            &checkout.CreatedAt,
+		UpdatedAt:            &checkout.UpdatedAt,
+		CompletedAt:          checkout.CompletedAt,
+		ReservationExpiresAt: checkout.ReservationExpiresAt,
 	}
 
 	if len(checkout.Items) > 0 {
 		items := make([]genhttp.CheckoutItem, len(checkout.Items))
 		for i, item := range checkout.Items {
-			unitPrice := float32(item.UnitPrice)
-			subtotal := float32(item.Subtotal)
-			discount := float32(item.Discount)
-			total := float32(item.Total)
+			unitPrice := item.UnitPrice.Float64()
+			subtotal := item.Subtotal.Float64()
+			discount := item.Discount.Float64()
+			total := item.Total.Float64()
 			quantity := item.Quantity
 
 			items[i] = genhttp.CheckoutItem{
@@ -442,7 +453,9 @@ func mapCheckoutToResponse(checkout *entity.Checkout) genhttp.CheckoutResponse {
 	if len(checkout.Promotions) > 0 {
 		promotions := make([]genhttp.PromotionApplied, len(checkout.Promotions))
 		for i, promo := range checkout.Promotions {
-			discount := float32(promo.Discount)
+			discount := promo.Discount.Float64()
+
+			status := genhttp.PromotionAppliedStatus(promo.Status)
 
 			promotions[i] = genhttp.PromotionApplied{
 				Id:          &promo.ID,
@@ -450,6 +463,15 @@ func mapCheckoutToResponse(checkout *entity.Checkout) genhttp.CheckoutResponse {
 				PromotionId: &promo.PromotionID,
 				Description: &promo.Description,
 				Discount:    &discount,
+				Status:      &status,
+				SkipReason:  promo.SkipReason,
+
+				PromotionVersion: promo.PromotionVersion,
+
+				TierMinQuantity:        promo.TierMinQuantity,
+				TierDiscountPercentage: promo.TierDiscountPercentage,
+				NextTierMinQuantity:    promo.NextTierMinQuantity,
+				UnitsToNextTier:        promo.UnitsToNextTier,
 			}
 		}
 		checkoutData.Promotions = &promotions
@@ -476,8 +498,33 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
+// mapPaginationMeta maps the page returned for a request; the current page is only known in
+// page-number mode and the page count only when the total was counted
+func mapPaginationMeta(req pagination.Request, result pagination.Result) genhttp.PaginationMeta {
+	meta := genhttp.PaginationMeta{
+		PerPage:    &req.Limit,
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
+		PrevCursor: result.PrevCursor,
+	}
+	if req.Cursor == nil {
+		meta.CurrentPage = &req.Page
+	}
+	if result.Total != nil {
+		totalPages := (*result.Total + req.Limit - 1) / req.Limit
+		meta.TotalPages = &totalPages
+	}
+	return meta
+}
+
 // handleError handles errors and sends appropriate HTTP responses
 func handleError(w http.ResponseWriter, err error) {
+	// Errors carrying an application code (e.g. out_of_stock) keep their code and data
+	if commonErrs.IsAppError(err) {
+		appmiddleware.RespondWithError(w, err)
+		return
+	}
+
 	var status int
 	var message string
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/fanzru/e-commerce-be/internal/app/checkout/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -21,15 +22,38 @@ type CheckoutRepository interface {
 	// Create creates a new checkout
 	Create(ctx context.Context, checkout *entity.Checkout) error
 
-	// List retrieves a list of checkouts with pagination
-	List(ctx context.Context, page, limit int) ([]*entity.Checkout, int, error)
+	// List retrieves a page of checkouts, newest first, by page number or cursor
+	List(ctx context.Context, req pagination.Request) ([]*entity.Checkout, pagination.Result, error)
 
-	// GetByUserID retrieves a list of checkouts for a specific user
-	GetByUserID(ctx context.Context, userID uuid.UUID, page, limit int) ([]*entity.Checkout, int, error)
+	// GetByUserID retrieves a page of the checkouts of a specific user, newest first
+	GetByUserID(ctx context.Context, userID uuid.UUID, req pagination.Request) ([]*entity.Checkout, pagination.Result, error)
 
 	// UpdatePaymentStatus updates the payment status of a checkout
 	UpdatePaymentStatus(ctx context.Context, checkoutID uuid.UUID, status entity.PaymentStatus, paymentMethod, paymentReference string) error
 
 	// UpdateOrderStatus updates the order status of a checkout
 	UpdateOrderStatus(ctx context.Context, checkoutID uuid.UUID, status entity.OrderStatus) error
+
+	// MarkInventoryRestocked records that the checkout's items were returned to inventory.
+	// It returns false if the checkout had already been restocked.
+	MarkInventoryRestocked(ctx context.Context, checkoutID uuid.UUID) (bool, error)
+}
+
+// ReservationRepository defines the interface for inventory reservation repository
+type ReservationRepository interface {
+	// Create creates reservations for the lines of a checkout
+	Create(ctx context.Context, reservations []*entity.InventoryReservation) error
+
+	// GetReservedQuantities returns the quantity held by unexpired reservations, keyed by product ID
+	GetReservedQuantities(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID]int, error)
+
+	// GetByCheckoutIDForUpdate retrieves the reservations of a checkout and locks them
+	// until the surrounding transaction ends
+	GetByCheckoutIDForUpdate(ctx context.Context, checkoutID uuid.UUID) ([]*entity.InventoryReservation, error)
+
+	// UpdateStatus sets the status of every reservation of a checkout
+	UpdateStatus(ctx context.Context, checkoutID uuid.UUID, status entity.ReservationStatus) error
+
+	// ListExpiredCheckoutIDs returns checkouts that still have reservations past their expiry
+	ListExpiredCheckoutIDs(ctx context.Context, limit int) ([]uuid.UUID, error)
 }
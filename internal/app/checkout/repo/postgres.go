@@ -10,9 +10,21 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/app/checkout/domain/entity"
 	domainErrors "github.com/fanzru/e-commerce-be/internal/app/checkout/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// checkoutKeyset orders checkout listings newest first
+var checkoutKeyset = pagination.Keyset{
+	Name: "newest",
+	Columns: []pagination.Column{
+		{Expr: "checkouts.created_at", Desc: true},
+		{Expr: "checkouts.id", Desc: true},
+	},
+}
+
 // CheckoutPostgresRepository implements CheckoutRepository using PostgreSQL
 type CheckoutPostgresRepository struct {
 	db *sql.DB
@@ -34,19 +46,16 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 	logger.Debug("Fetching checkout by ID")
 	startTime := time.Now()
 
-	// Begin transaction
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		logger.Error("Failed to begin transaction", "error", err.Error())
-		return nil, fmt.Errorf("error beginning transaction: %w", err)
-	}
-	defer tx.Rollback()
+	// Join the caller's transaction so status checks see the rows it is changing
+	queryable := persistence.QueryableFromContext(ctx, r.db)
 
 	// Get the checkout
 	checkoutQuery := `
 		SELECT id, user_id, subtotal, total_discount, total, 
 		       payment_status, payment_method, payment_reference, notes, status, 
-		       created_at, updated_at, completed_at
+		       created_at, updated_at, completed_at, inventory_restocked_at,
+		       (SELECT MIN(expires_at) FROM inventory_reservations ir
+		        WHERE ir.checkout_id = checkouts.id AND ir.status = 'RESERVED') AS reservation_expires_at
 		FROM checkouts
 		WHERE id = $1
 	`
@@ -54,9 +63,9 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 	var checkout entity.Checkout
 	var userID sql.NullString
 	var paymentMethod, paymentReference, notes sql.NullString
-	var completedAt sql.NullTime
+	var completedAt, inventoryRestockedAt, reservationExpiresAt sql.NullTime
 
-	err = tx.QueryRowContext(ctx, checkoutQuery, id).Scan(
+	err := queryable.QueryRowContext(ctx, checkoutQuery, id).Scan(
 		&checkout.ID,
 		&userID,
 		&checkout.Subtotal,
@@ -70,6 +79,8 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 		&checkout.CreatedAt,
 		&checkout.UpdatedAt,
 		&completedAt,
+		&inventoryRestockedAt,
+		&reservationExpiresAt,
 	)
 
 	if err != nil {
@@ -100,6 +111,12 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 	if completedAt.Valid {
 		checkout.CompletedAt = &completedAt.Time
 	}
+	if inventoryRestockedAt.Valid {
+		checkout.InventoryRestockedAt = &inventoryRestockedAt.Time
+	}
+	if reservationExpiresAt.Valid {
+		checkout.ReservationExpiresAt = &reservationExpiresAt.Time
+	}
 
 	logger.Debug("Checkout found, fetching checkout items")
 
@@ -111,7 +128,7 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 		ORDER BY id
 	`
 
-	itemRows, err := tx.QueryContext(ctx, itemsQuery, id)
+	itemRows, err := queryable.QueryContext(ctx, itemsQuery, id)
 	if err != nil {
 		logger.Error("Failed to query checkout items", "error", err.Error())
 		return nil, fmt.Errorf("error querying checkout items: %w", err)
@@ -147,13 +164,14 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 
 	// Get applied promotions
 	promotionsQuery := `
-		SELECT id, checkout_id, promotion_id, description, discount
+		SELECT id, checkout_id, promotion_id, promotion_version, description, discount, status, skip_reason,
+			tier_min_quantity, tier_discount_percentage, next_tier_min_quantity, units_to_next_tier
 		FROM promotion_applied
 		WHERE checkout_id = $1
 		ORDER BY id
 	`
 
-	promotionRows, err := tx.QueryContext(ctx, promotionsQuery, id)
+	promotionRows, err := queryable.QueryContext(ctx, promotionsQuery, id)
 	if err != nil {
 		return nil, fmt.Errorf("error querying checkout promotions: %w", err)
 	}
@@ -166,8 +184,15 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 			&promotion.ID,
 			&promotion.CheckoutID,
 			&promotion.PromotionID,
+			&promotion.PromotionVersion,
 			&promotion.Description,
 			&promotion.Discount,
+			&promotion.Status,
+			&promotion.SkipReason,
+			&promotion.TierMinQuantity,
+			&promotion.TierDiscountPercentage,
+			&promotion.NextTierMinQuantity,
+			&promotion.UnitsToNextTier,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning checkout promotion: %w", err)
@@ -179,12 +204,6 @@ func (r *CheckoutPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 		return nil, fmt.Errorf("error iterating checkout promotions: %w", err)
 	}
 
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		logger.Error("Failed to commit transaction", "error", err.Error())
-		return nil, fmt.Errorf("error committing transaction: %w", err)
-	}
-
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved checkout with items",
 		"user_id", checkout.UserID,
@@ -210,13 +229,19 @@ func (r *CheckoutPostgresRepository) Create(ctx context.Context, checkout *entit
 	logger.Debug("Creating new checkout")
 	startTime := time.Now()
 
-	// Begin transaction
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		logger.Error("Failed to begin transaction", "error", err.Error())
-		return fmt.Errorf("error beginning transaction: %w", err)
+	// Join the caller's transaction if there is one, so the checkout is committed
+	// together with the inventory changes made during checkout
+	tx := persistence.TxFromContext(ctx)
+	ownTx := tx == nil
+	if ownTx {
+		var err error
+		tx, err = r.db.BeginTx(ctx, nil)
+		if err != nil {
+			logger.Error("Failed to begin transaction", "error", err.Error())
+			return fmt.Errorf("error beginning transaction: %w", err)
+		}
+		defer tx.Rollback()
 	}
-	defer tx.Rollback()
 
 	// Insert checkout
 	if checkout.ID == uuid.Nil {
@@ -240,7 +265,7 @@ func (r *CheckoutPostgresRepository) Create(ctx context.Context, checkout *entit
 		RETURNING created_at, updated_at
 	`
 
-	err = tx.QueryRowContext(ctx, checkoutQuery,
+	err := tx.QueryRowContext(ctx, checkoutQuery,
 		checkout.ID,
 		checkout.UserID,
 		checkout.Subtotal,
@@ -302,16 +327,26 @@ func (r *CheckoutPostgresRepository) Create(ctx context.Context, checkout *entit
 			promotion.CheckoutID = checkout.ID
 
 			promotionQuery := `
-				INSERT INTO promotion_applied (id, checkout_id, promotion_id, description, discount)
-				VALUES ($1, $2, $3, $4, $5)
+				INSERT INTO promotion_applied (
+					id, checkout_id, promotion_id, promotion_version, description, discount, status, skip_reason,
+					tier_min_quantity, tier_discount_percentage, next_tier_min_quantity, units_to_next_tier
+				)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 			`
 
 			_, err = tx.ExecContext(ctx, promotionQuery,
 				promotion.ID,
 				promotion.CheckoutID,
 				promotion.PromotionID,
+				promotion.PromotionVersion,
 				promotion.Description,
 				promotion.Discount,
+				promotion.Status,
+				promotion.SkipReason,
+				promotion.TierMinQuantity,
+				promotion.TierDiscountPercentage,
+				promotion.NextTierMinQuantity,
+				promotion.UnitsToNextTier,
 			)
 
 			if err != nil {
@@ -320,10 +355,12 @@ func (r *CheckoutPostgresRepository) Create(ctx context.Context, checkout *entit
 		}
 	}
 
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		logger.Error("Failed to commit transaction", "error", err.Error())
-		return fmt.Errorf("error committing transaction: %w", err)
+	// Commit the transaction unless the caller owns it
+	if ownTx {
+		if err = tx.Commit(); err != nil {
+			logger.Error("Failed to commit transaction", "error", err.Error())
+			return fmt.Errorf("error committing transaction: %w", err)
+		}
 	}
 
 	duration := time.Since(startTime)
@@ -341,52 +378,72 @@ func (r *CheckoutPostgresRepository) Create(ctx context.Context, checkout *entit
 	return nil
 }
 
-// List retrieves a list of checkouts with pagination
-func (r *CheckoutPostgresRepository) List(ctx context.Context, page, limit int) ([]*entity.Checkout, int, error) {
+// List retrieves a page of checkouts, newest first
+func (r *CheckoutPostgresRepository) List(ctx context.Context, req pagination.Request) ([]*entity.Checkout, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "CheckoutRepository.List",
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	logger.Debug("Listing checkouts")
 	startTime := time.Now()
 
-	offset := (page - 1) * limit
-
-	// Count total matches first
-	countQuery := `SELECT COUNT(*) FROM checkouts`
+	// Count total matches first, when asked for
 	var total int
-	err := r.db.QueryRowContext(ctx, countQuery).Scan(&total)
+	if req.IncludeTotal {
+		countQuery := `SELECT COUNT(*) FROM checkouts`
+		err := r.db.QueryRowContext(ctx, countQuery).Scan(&total)
+		if err != nil {
+			logger.Error("Failed to count checkouts", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("error counting checkouts: %w", err)
+		}
+	}
+
+	args := []interface{}{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	whereClause := ""
+	afterCursor, err := checkoutKeyset.Where(req, arg)
 	if err != nil {
-		logger.Error("Failed to count checkouts", "error", err.Error())
-		return nil, 0, fmt.Errorf("error counting checkouts: %w", err)
+		logger.Warn("Invalid input: cursor", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+	if afterCursor != "" {
+		whereClause = "WHERE " + afterCursor
 	}
 
 	// Now fetch the checkouts with pagination
-	query := `
+	query := fmt.Sprintf(`
 		SELECT id, user_id, subtotal, total_discount, total, 
 		       payment_status, payment_method, payment_reference, notes, status, 
-		       created_at, updated_at, completed_at
+		       created_at, updated_at, completed_at, %s
 		FROM checkouts
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, checkoutKeyset.Select(), whereClause, checkoutKeyset.OrderBy(req), arg(req.FetchLimit()), arg(req.Offset()))
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		logger.Error("Failed to query checkouts", "error", err.Error())
-		return nil, 0, fmt.Errorf("error querying checkouts: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error querying checkouts: %w", err)
 	}
 	defer rows.Close()
 
 	checkouts := []*entity.Checkout{}
+	keys := [][]*string{}
 	for rows.Next() {
 		var checkout entity.Checkout
 		var userID sql.NullString
 		var paymentMethod, paymentReference, notes sql.NullString
 		var completedAt sql.NullTime
+		keyDest, rowKeys := checkoutKeyset.ScanKeys()
 
-		err := rows.Scan(
+		err := rows.Scan(append([]interface{}{
 			&checkout.ID,
 			&userID,
 			&checkout.Subtotal,
@@ -400,10 +457,10 @@ func (r *CheckoutPostgresRepository) List(ctx context.Context, page, limit int)
 			&checkout.CreatedAt,
 			&checkout.UpdatedAt,
 			&completedAt,
-		)
+		}, keyDest...)...)
 		if err != nil {
 			logger.Error("Failed to scan checkout row", "error", err.Error())
-			return nil, 0, fmt.Errorf("error scanning checkout row: %w", err)
+			return nil, pagination.Result{}, fmt.Errorf("error scanning checkout row: %w", err)
 		}
 
 		// Handle nullable fields
@@ -429,70 +486,92 @@ func (r *CheckoutPostgresRepository) List(ctx context.Context, page, limit int)
 		// Set empty items slice, but don't fetch items to reduce load
 		checkout.Items = []*entity.CheckoutItem{}
 		checkouts = append(checkouts, &checkout)
+		keys = append(keys, rowKeys)
 	}
 
 	if err = rows.Err(); err != nil {
 		logger.Error("Failed to iterate checkout rows", "error", err.Error())
-		return nil, 0, fmt.Errorf("error iterating checkout rows: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error iterating checkout rows: %w", err)
 	}
 
+	checkouts, result := pagination.Page(checkouts, keys, req, checkoutKeyset, total)
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully listed checkouts",
 		"total_count", total,
 		"returned_count", len(checkouts),
 		"duration_ms", duration.Milliseconds())
 
-	return checkouts, total, nil
+	return checkouts, result, nil
 }
 
-// GetByUserID retrieves a list of checkouts by user ID
-func (r *CheckoutPostgresRepository) GetByUserID(ctx context.Context, userID uuid.UUID, page, limit int) ([]*entity.Checkout, int, error) {
+// GetByUserID retrieves a page of the checkouts of a user, newest first
+func (r *CheckoutPostgresRepository) GetByUserID(ctx context.Context, userID uuid.UUID, req pagination.Request) ([]*entity.Checkout, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "CheckoutRepository.GetByUserID",
 		"user_id", userID.String(),
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	logger.Debug("Fetching checkouts by user ID")
 	startTime := time.Now()
 
-	offset := (page - 1) * limit
-
-	// Count total matches for this user
-	countQuery := `SELECT COUNT(*) FROM checkouts WHERE user_id = $1`
+	// Count total matches for this user, when asked for
 	var total int
-	err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+	if req.IncludeTotal {
+		countQuery := `SELECT COUNT(*) FROM checkouts WHERE user_id = $1`
+		err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+		if err != nil {
+			logger.Error("Failed to count user checkouts", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("error counting user checkouts: %w", err)
+		}
+	}
+
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	whereClause := "WHERE user_id = $1"
+	afterCursor, err := checkoutKeyset.Where(req, arg)
 	if err != nil {
-		logger.Error("Failed to count user checkouts", "error", err.Error())
-		return nil, 0, fmt.Errorf("error counting user checkouts: %w", err)
+		logger.Warn("Invalid input: cursor", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+	if afterCursor != "" {
+		whereClause += " AND " + afterCursor
 	}
 
 	// Now fetch the checkouts with pagination
-	query := `
+	query := fmt.Sprintf(`
 		SELECT id, user_id, subtotal, total_discount, total, 
 		       payment_status, payment_method, payment_reference, notes, status, 
-		       created_at, updated_at, completed_at
+		       created_at, updated_at, completed_at, %s
 		FROM checkouts
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, checkoutKeyset.Select(), whereClause, checkoutKeyset.OrderBy(req), arg(req.FetchLimit()), arg(req.Offset()))
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		logger.Error("Failed to query user checkouts", "error", err.Error())
-		return nil, 0, fmt.Errorf("error querying user checkouts: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error querying user checkouts: %w", err)
 	}
 	defer rows.Close()
 
 	checkouts := []*entity.Checkout{}
+	keys := [][]*string{}
 	for rows.Next() {
 		var checkout entity.Checkout
 		var userIDNull sql.NullString
 		var paymentMethod, paymentReference, notes sql.NullString
 		var completedAt sql.NullTime
+		keyDest, rowKeys := checkoutKeyset.ScanKeys()
 
-		err := rows.Scan(
+		err := rows.Scan(append([]interface{}{
 			&checkout.ID,
 			&userIDNull,
 			&checkout.Subtotal,
@@ -506,10 +585,10 @@ func (r *CheckoutPostgresRepository) GetByUserID(ctx context.Context, userID uui
 			&checkout.CreatedAt,
 			&checkout.UpdatedAt,
 			&completedAt,
-		)
+		}, keyDest...)...)
 		if err != nil {
 			logger.Error("Failed to scan checkout row", "error", err.Error())
-			return nil, 0, fmt.Errorf("error scanning checkout row: %w", err)
+			return nil, pagination.Result{}, fmt.Errorf("error scanning checkout row: %w", err)
 		}
 
 		// Handle nullable fields
@@ -535,13 +614,16 @@ func (r *CheckoutPostgresRepository) GetByUserID(ctx context.Context, userID uui
 		// Set empty items slice, but don't fetch items to reduce load
 		checkout.Items = []*entity.CheckoutItem{}
 		checkouts = append(checkouts, &checkout)
+		keys = append(keys, rowKeys)
 	}
 
 	if err = rows.Err(); err != nil {
 		logger.Error("Failed to iterate checkout rows", "error", err.Error())
-		return nil, 0, fmt.Errorf("error iterating checkout rows: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error iterating checkout rows: %w", err)
 	}
 
+	checkouts, result := pagination.Page(checkouts, keys, req, checkoutKeyset, total)
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved user checkouts",
 		"user_id", userID,
@@ -549,7 +631,7 @@ func (r *CheckoutPostgresRepository) GetByUserID(ctx context.Context, userID uui
 		"returned_count", len(checkouts),
 		"duration_ms", duration.Milliseconds())
 
-	return checkouts, total, nil
+	return checkouts, result, nil
 }
 
 // UpdatePaymentStatus updates the payment status of a checkout
@@ -575,7 +657,7 @@ func (r *CheckoutPostgresRepository) UpdatePaymentStatus(ctx context.Context, id
 	`
 
 	var checkoutID uuid.UUID
-	err := r.db.QueryRowContext(ctx, query, status, paymentMethod, paymentReference, id).Scan(&checkoutID)
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, status, paymentMethod, paymentReference, id).Scan(&checkoutID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Warn("Checkout not found", "error", "ErrCheckoutNotFound")
@@ -618,7 +700,7 @@ func (r *CheckoutPostgresRepository) UpdateOrderStatus(ctx context.Context, id u
 	`
 
 	var checkoutID uuid.UUID
-	err := r.db.QueryRowContext(ctx, query, status, completedAt, id).Scan(&checkoutID)
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, status, completedAt, id).Scan(&checkoutID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Warn("Checkout not found", "error", "ErrCheckoutNotFound")
@@ -635,3 +717,290 @@ func (r *CheckoutPostgresRepository) UpdateOrderStatus(ctx context.Context, id u
 
 	return nil
 }
+
+// MarkInventoryRestocked records that a checkout's items were returned to inventory.
+// It returns false when the checkout was already restocked, which lets callers restock
+// at most once even when the same status update is repeated or runs concurrently.
+func (r *CheckoutPostgresRepository) MarkInventoryRestocked(ctx context.Context, id uuid.UUID) (bool, error) {
+	logger := middleware.Logger.With(
+		"method", "CheckoutRepository.MarkInventoryRestocked",
+		"checkout_id", id.String(),
+	)
+	logger.Debug("Marking checkout inventory as restocked")
+	startTime := time.Now()
+
+	query := `
+		UPDATE checkouts
+		SET inventory_restocked_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = $1 AND inventory_restocked_at IS NULL
+		RETURNING id
+	`
+
+	var checkoutID uuid.UUID
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, id).Scan(&checkoutID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Info("Checkout inventory already restocked")
+			return false, nil
+		}
+		logger.Error("Failed to mark checkout inventory as restocked", "error", err.Error())
+		return false, fmt.Errorf("error marking checkout inventory as restocked: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully marked checkout inventory as restocked",
+		"duration_ms", duration.Milliseconds())
+
+	return true, nil
+}
+
+// ReservationPostgresRepository implements ReservationRepository using PostgreSQL
+type ReservationPostgresRepository struct {
+	db *sql.DB
+}
+
+// NewReservationRepository creates a new inventory reservation repository
+func NewReservationRepository(db *sql.DB) ReservationRepository {
+	return &ReservationPostgresRepository{
+		db: db,
+	}
+}
+
+// Create creates reservations for the lines of a checkout
+func (r *ReservationPostgresRepository) Create(ctx context.Context, reservations []*entity.InventoryReservation) error {
+	logger := middleware.Logger.With(
+		"method", "ReservationRepository.Create",
+		"reservation_count", len(reservations),
+	)
+	logger.Debug("Creating inventory reservations")
+	startTime := time.Now()
+
+	query := `
+		INSERT INTO inventory_reservations (id, checkout_id, product_id, quantity, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+	for _, reservation := range reservations {
+		_, err := queryable.ExecContext(ctx, query,
+			reservation.ID,
+			reservation.CheckoutID,
+			reservation.ProductID,
+			reservation.Quantity,
+			reservation.Status,
+			reservation.ExpiresAt,
+			reservation.CreatedAt,
+			reservation.UpdatedAt,
+		)
+		if err != nil {
+			logger.Error("Failed to insert inventory reservation",
+				"product_id", reservation.ProductID.String(),
+				"error", err.Error())
+			return fmt.Errorf("error inserting inventory reservation: %w", err)
+		}
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created inventory reservations",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// GetReservedQuantities returns the quantity held by unexpired reservations, keyed by product ID
+func (r *ReservationPostgresRepository) GetReservedQuantities(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	logger := middleware.Logger.With(
+		"method", "ReservationRepository.GetReservedQuantities",
+		"product_count", len(productIDs),
+	)
+	logger.Debug("Getting reserved quantities")
+	startTime := time.Now()
+
+	reserved := make(map[uuid.UUID]int, len(productIDs))
+	if len(productIDs) == 0 {
+		return reserved, nil
+	}
+
+	idStrings := make([]string, 0, len(productIDs))
+	for _, id := range productIDs {
+		idStrings = append(idStrings, id.String())
+	}
+
+	query := `
+		SELECT product_id, COALESCE(SUM(quantity), 0)
+		FROM inventory_reservations
+		WHERE product_id = ANY($1::uuid[]) AND status = $2 AND expires_at > NOW()
+		GROUP BY product_id
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, pq.Array(idStrings), entity.ReservationStatusReserved)
+	if err != nil {
+		logger.Error("Failed to query reserved quantities", "error", err.Error())
+		return nil, fmt.Errorf("error querying reserved quantities: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID uuid.UUID
+		var quantity int
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			logger.Error("Failed to scan reserved quantity", "error", err.Error())
+			return nil, fmt.Errorf("error scanning reserved quantity: %w", err)
+		}
+		reserved[productID] = quantity
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate reserved quantities", "error", err.Error())
+		return nil, fmt.Errorf("error iterating reserved quantities: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Successfully retrieved reserved quantities",
+		"reserved_product_count", len(reserved),
+		"duration_ms", duration.Milliseconds())
+
+	return reserved, nil
+}
+
+// GetByCheckoutIDForUpdate retrieves the reservations of a checkout and locks them
+func (r *ReservationPostgresRepository) GetByCheckoutIDForUpdate(ctx context.Context, checkoutID uuid.UUID) ([]*entity.InventoryReservation, error) {
+	logger := middleware.Logger.With(
+		"method", "ReservationRepository.GetByCheckoutIDForUpdate",
+		"checkout_id", checkoutID.String(),
+	)
+	logger.Debug("Locking inventory reservations for checkout")
+	startTime := time.Now()
+
+	query := `
+		SELECT id, checkout_id, product_id, quantity, status, expires_at, created_at, updated_at
+		FROM inventory_reservations
+		WHERE checkout_id = $1
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, checkoutID)
+	if err != nil {
+		logger.Error("Failed to query inventory reservations", "error", err.Error())
+		return nil, fmt.Errorf("error querying inventory reservations: %w", err)
+	}
+	defer rows.Close()
+
+	reservations := []*entity.InventoryReservation{}
+	for rows.Next() {
+		var reservation entity.InventoryReservation
+		err := rows.Scan(
+			&reservation.ID,
+			&reservation.CheckoutID,
+			&reservation.ProductID,
+			&reservation.Quantity,
+			&reservation.Status,
+			&reservation.ExpiresAt,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+		)
+		if err != nil {
+			logger.Error("Failed to scan inventory reservation", "error", err.Error())
+			return nil, fmt.Errorf("error scanning inventory reservation: %w", err)
+		}
+		reservations = append(reservations, &reservation)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate inventory reservations", "error", err.Error())
+		return nil, fmt.Errorf("error iterating inventory reservations: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Successfully locked inventory reservations",
+		"reservation_count", len(reservations),
+		"duration_ms", duration.Milliseconds())
+
+	return reservations, nil
+}
+
+// UpdateStatus sets the status of every reservation of a checkout
+func (r *ReservationPostgresRepository) UpdateStatus(ctx context.Context, checkoutID uuid.UUID, status entity.ReservationStatus) error {
+	logger := middleware.Logger.With(
+		"method", "ReservationRepository.UpdateStatus",
+		"checkout_id", checkoutID.String(),
+		"reservation_status", status,
+	)
+	logger.Debug("Updating inventory reservation status")
+	startTime := time.Now()
+
+	query := `
+		UPDATE inventory_reservations
+		SET status = $1, updated_at = NOW()
+		WHERE checkout_id = $2
+	`
+
+	result, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, query, status, checkoutID)
+	if err != nil {
+		logger.Error("Failed to update inventory reservation status", "error", err.Error())
+		return fmt.Errorf("error updating inventory reservation status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated inventory reservation status",
+		"reservations_updated", rowsAffected,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// ListExpiredCheckoutIDs returns checkouts that still have reservations past their expiry
+func (r *ReservationPostgresRepository) ListExpiredCheckoutIDs(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	logger := middleware.Logger.With(
+		"method", "ReservationRepository.ListExpiredCheckoutIDs",
+		"limit", limit,
+	)
+	logger.Debug("Listing checkouts with expired reservations")
+	startTime := time.Now()
+
+	query := `
+		SELECT checkout_id
+		FROM inventory_reservations
+		WHERE status = $1 AND expires_at <= NOW()
+		GROUP BY checkout_id
+		ORDER BY MIN(expires_at)
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, entity.ReservationStatusReserved, limit)
+	if err != nil {
+		logger.Error("Failed to query expired reservations", "error", err.Error())
+		return nil, fmt.Errorf("error querying expired reservations: %w", err)
+	}
+	defer rows.Close()
+
+	checkoutIDs := []uuid.UUID{}
+	for rows.Next() {
+		var checkoutID uuid.UUID
+		if err := rows.Scan(&checkoutID); err != nil {
+			logger.Error("Failed to scan checkout ID", "error", err.Error())
+			return nil, fmt.Errorf("error scanning checkout ID: %w", err)
+		}
+		checkoutIDs = append(checkoutIDs, checkoutID)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate expired reservations", "error", err.Error())
+		return nil, fmt.Errorf("error iterating expired reservations: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Successfully listed checkouts with expired reservations",
+		"checkout_count", len(checkoutIDs),
+		"duration_ms", duration.Milliseconds())
+
+	return checkoutIDs, nil
+}
@@ -2,7 +2,7 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,10 +10,16 @@ import (
 	checkoutEntity "github.com/fanzru/e-commerce-be/internal/app/checkout/domain/entity"
 	checkoutErrors "github.com/fanzru/e-commerce-be/internal/app/checkout/domain/errs"
 	checkoutRepo "github.com/fanzru/e-commerce-be/internal/app/checkout/repo"
+	productEntity "github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	productRepo "github.com/fanzru/e-commerce-be/internal/app/product/repo"
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
+	promotionErrors "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/errs"
 	promotionRepo "github.com/fanzru/e-commerce-be/internal/app/promotion/repo"
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -22,24 +28,44 @@ var _ CheckoutUseCase = (*checkoutUseCase)(nil)
 
 // checkoutUseCase implements the CheckoutUseCase interface
 type checkoutUseCase struct {
-	checkoutRepo  checkoutRepo.CheckoutRepository
-	cartRepo      cartRepo.CartRepository
-	promotionRepo promotionRepo.PromotionRepository
-	txManager     *persistence.TransactionManager
+	checkoutRepo    checkoutRepo.CheckoutRepository
+	reservationRepo checkoutRepo.ReservationRepository
+	cartRepo        cartRepo.CartRepository
+	productRepo     productRepo.ProductRepository
+	promotionRepo   promotionRepo.PromotionRepository
+	couponRepo      promotionRepo.CouponRepository
+	customerRepo    promotionRepo.CustomerRepository
+	txManager       *persistence.TransactionManager
+	engine          *entity.Engine
+	reservationTTL  time.Duration
 }
 
+// expiredReservationBatchSize caps how many checkouts a single sweep releases
+const expiredReservationBatchSize = 100
+
 // NewCheckoutUseCase creates a new instance of checkoutUseCase
 func NewCheckoutUseCase(
 	checkoutRepo checkoutRepo.CheckoutRepository,
+	reservationRepo checkoutRepo.ReservationRepository,
 	cartRepo cartRepo.CartRepository,
+	productRepo productRepo.ProductRepository,
 	promotionRepo promotionRepo.PromotionRepository,
+	couponRepo promotionRepo.CouponRepository,
+	customerRepo promotionRepo.CustomerRepository,
 	txManager *persistence.TransactionManager,
+	reservationTTL time.Duration,
 ) CheckoutUseCase {
 	return &checkoutUseCase{
-		checkoutRepo:  checkoutRepo,
-		cartRepo:      cartRepo,
-		promotionRepo: promotionRepo,
-		txManager:     txManager,
+		checkoutRepo:    checkoutRepo,
+		reservationRepo: reservationRepo,
+		cartRepo:        cartRepo,
+		productRepo:     productRepo,
+		promotionRepo:   promotionRepo,
+		couponRepo:      couponRepo,
+		customerRepo:    customerRepo,
+		txManager:       txManager,
+		engine:          entity.NewEngine(),
+		reservationTTL:  reservationTTL,
 	}
 }
 
@@ -61,9 +87,9 @@ func (u *checkoutUseCase) GetByID(ctx context.Context, id uuid.UUID) (*checkoutE
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved checkout",
 		"user_id", checkout.UserID,
-		"subtotal", checkout.Subtotal,
-		"total_discount", checkout.TotalDiscount,
-		"total", checkout.Total,
+		"subtotal", checkout.Subtotal.String(),
+		"total_discount", checkout.TotalDiscount.String(),
+		"total", checkout.Total.String(),
 		"duration_ms", duration.Milliseconds())
 
 	return checkout, nil
@@ -103,15 +129,34 @@ func (u *checkoutUseCase) ProcessCart(ctx context.Context, userID uuid.UUID) (*c
 			return fmt.Errorf("error getting active promotions: %w", err)
 		}
 
+		// Re-validate the cart's coupon now that the order is being placed
+		var coupon *entity.Coupon
+		if cartInfo.CouponCode != "" {
+			var couponPromotion *entity.Promotion
+			coupon, couponPromotion, err = u.validateCoupon(txCtx, cartInfo.CouponCode, userID)
+			if err != nil {
+				logger.Warn("Coupon cannot be redeemed", "coupon_code", cartInfo.CouponCode, "error", err.Error())
+				return err
+			}
+			activePromotions = append(activePromotions, couponPromotion)
+		}
+
+		// Eligibility is judged on the order history as it stands before this order
+		customer, err := u.getCustomer(txCtx, userID)
+		if err != nil {
+			logger.Error("Failed to get customer", "error", err.Error())
+			return err
+		}
+
 		// Create checkout
 		checkout = &checkoutEntity.Checkout{
 			ID:            uuid.New(),
 			UserID:        getUserIDPointer(userID),
 			Items:         []*checkoutEntity.CheckoutItem{},
 			Promotions:    []*checkoutEntity.PromotionApplied{},
-			Subtotal:      0,
-			TotalDiscount: 0,
-			Total:         0,
+			Subtotal:      money.Zero(),
+			TotalDiscount: money.Zero(),
+			Total:         money.Zero(),
 			PaymentStatus: checkoutEntity.PaymentStatusPending,
 			Status:        checkoutEntity.OrderStatusCreated,
 		}
@@ -127,20 +172,26 @@ func (u *checkoutUseCase) ProcessCart(ctx context.Context, userID uuid.UUID) (*c
 				ProductName: cartItem.ProductName,
 				Quantity:    cartItem.Quantity,
 				UnitPrice:   cartItem.UnitPrice,
-				Subtotal:    cartItem.UnitPrice * float64(cartItem.Quantity),
-				Discount:    0, // Will be calculated later
-				Total:       cartItem.UnitPrice * float64(cartItem.Quantity),
+				Subtotal:    cartItem.UnitPrice.Mul(cartItem.Quantity),
+				Discount:    money.Zero(), // Will be calculated later
+				Total:       cartItem.UnitPrice.Mul(cartItem.Quantity),
 			}
 
 			checkout.Items = append(checkout.Items, checkoutItem)
-			checkout.Subtotal += checkoutItem.Subtotal
+			checkout.Subtotal = checkout.Subtotal.Add(checkoutItem.Subtotal)
 		}
 
 		// Apply promotions
-		u.applyPromotions(checkout, activePromotions)
+		u.applyPromotions(checkout, activePromotions, customer)
+
+		// A coupon that gives no discount on this cart is refused rather than silently consumed
+		if coupon != nil && !hasAppliedPromotion(checkout, coupon.PromotionID) {
+			logger.Warn("Coupon promotion does not apply to cart", "coupon_code", coupon.Code)
+			return promotionErrors.NewCouponNotApplicableError(coupon.Code, promotionErrors.ErrCouponNotApplicable)
+		}
 
 		// Calculate totals
-		checkout.Total = checkout.Subtotal - checkout.TotalDiscount
+		checkout.Total = checkout.Subtotal.Sub(checkout.TotalDiscount)
 
 		// Save checkout - this will be part of the transaction
 		err = u.checkoutRepo.Create(txCtx, checkout)
@@ -149,6 +200,27 @@ func (u *checkoutUseCase) ProcessCart(ctx context.Context, userID uuid.UUID) (*c
 			return fmt.Errorf("error creating checkout: %w", err)
 		}
 
+		// Record the redemption in the same transaction so the limits hold under concurrent checkouts
+		if coupon != nil {
+			err = u.couponRepo.Redeem(txCtx, coupon, userID, checkout.ID)
+			if err != nil {
+				if isCouponLimitError(err) {
+					logger.Warn("Coupon limit reached during checkout", "coupon_code", coupon.Code, "error", err.Error())
+					return promotionErrors.NewCouponNotApplicableError(coupon.Code, err)
+				}
+				logger.Error("Failed to redeem coupon", "error", err.Error())
+				return fmt.Errorf("error redeeming coupon: %w", err)
+			}
+		}
+
+		// Hold the ordered quantities until the checkout is paid or the hold expires.
+		// A shortage rolls back the checkout created above.
+		err = u.reserveInventory(txCtx, checkout)
+		if err != nil {
+			logger.Warn("Failed to reserve inventory", "error", err.Error())
+			return err
+		}
+
 		// Clear the user's cart after successful checkout
 		err = u.cartRepo.ClearUserCart(txCtx, userID)
 		if err != nil {
@@ -169,9 +241,9 @@ func (u *checkoutUseCase) ProcessCart(ctx context.Context, userID uuid.UUID) (*c
 		"user_id", checkout.UserID,
 		"payment_status", checkout.PaymentStatus,
 		"status", checkout.Status,
-		"subtotal", checkout.Subtotal,
-		"total_discount", checkout.TotalDiscount,
-		"total", checkout.Total,
+		"subtotal", checkout.Subtotal.String(),
+		"total_discount", checkout.TotalDiscount.String(),
+		"total", checkout.Total.String(),
 		"item_count", len(checkout.Items),
 		"promotion_count", len(checkout.Promotions),
 		"duration_ms", duration.Milliseconds())
@@ -179,73 +251,65 @@ func (u *checkoutUseCase) ProcessCart(ctx context.Context, userID uuid.UUID) (*c
 	return checkout, nil
 }
 
-// ListCheckouts retrieves a list of checkouts with pagination
-func (u *checkoutUseCase) ListCheckouts(ctx context.Context, page, limit int) ([]*checkoutEntity.Checkout, int, error) {
+// ListCheckouts retrieves a page of checkouts, by page number or cursor
+func (u *checkoutUseCase) ListCheckouts(ctx context.Context, req pagination.Request) ([]*checkoutEntity.Checkout, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "CheckoutUseCase.ListCheckouts",
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	logger.Info("Listing checkouts")
 	startTime := time.Now()
 
-	// Validate pagination parameters
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 {
-		limit = 10
-	}
-
-	checkouts, total, err := u.checkoutRepo.List(ctx, page, limit)
+	checkouts, page, err := u.checkoutRepo.List(ctx, req)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			logger.Warn("Invalid input: cursor", "error", err.Error())
+			return nil, pagination.Result{}, commonErrs.NewBadRequest("invalid cursor")
+		}
 		logger.Error("Failed to list checkouts", "error", err.Error())
-		return nil, 0, fmt.Errorf("error listing checkouts: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error listing checkouts: %w", err)
 	}
 
 	duration := time.Since(startTime)
 	logger.Info("Successfully listed checkouts",
-		"total", total,
 		"returned", len(checkouts),
 		"duration_ms", duration.Milliseconds())
 
-	return checkouts, total, nil
+	return checkouts, page, nil
 }
 
-// GetUserOrders retrieves a list of checkouts for a specific user
-func (u *checkoutUseCase) GetUserOrders(ctx context.Context, userID uuid.UUID, page, limit int) ([]*checkoutEntity.Checkout, int, error) {
+// GetUserOrders retrieves a page of the checkouts of a specific user
+func (u *checkoutUseCase) GetUserOrders(ctx context.Context, userID uuid.UUID, req pagination.Request) ([]*checkoutEntity.Checkout, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "CheckoutUseCase.GetUserOrders",
 		"user_id", userID.String(),
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	logger.Info("Getting user orders")
 	startTime := time.Now()
 
-	// Validate pagination parameters
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 {
-		limit = 10
-	}
-
 	// Get user orders from repository
-	orders, total, err := u.checkoutRepo.GetByUserID(ctx, userID, page, limit)
+	orders, page, err := u.checkoutRepo.GetByUserID(ctx, userID, req)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			logger.Warn("Invalid input: cursor", "error", err.Error())
+			return nil, pagination.Result{}, commonErrs.NewBadRequest("invalid cursor")
+		}
 		logger.Error("Failed to get user orders", "error", err.Error())
-		return nil, 0, fmt.Errorf("error getting user orders: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error getting user orders: %w", err)
 	}
 
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved user orders",
 		"user_id", userID,
-		"total", total,
 		"returned", len(orders),
 		"duration_ms", duration.Milliseconds())
 
-	return orders, total, nil
+	return orders, page, nil
 }
 
 // UpdatePaymentStatus updates the payment status of a checkout
@@ -268,18 +332,37 @@ func (u *checkoutUseCase) UpdatePaymentStatus(ctx context.Context, checkoutID uu
 		return fmt.Errorf("payment status cannot be empty")
 	}
 
-	// Check if checkout exists
-	_, err := u.checkoutRepo.GetByID(ctx, checkoutID)
-	if err != nil {
-		logger.Error("Failed to get checkout", "error", err.Error())
-		return fmt.Errorf("error getting checkout: %w", err)
-	}
+	err := u.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		// Read the checkout in the transaction so a restock committed just before is seen
+		checkout, err := u.checkoutRepo.GetByID(txCtx, checkoutID)
+		if err != nil {
+			logger.Error("Failed to get checkout", "error", err.Error())
+			return fmt.Errorf("error getting checkout: %w", err)
+		}
+
+		// A successful payment turns the stock hold into a sale
+		if status == checkoutEntity.PaymentStatusPaid {
+			if err := u.commitReservations(txCtx, checkout); err != nil {
+				return err
+			}
+		}
+
+		// Update payment status
+		err = u.checkoutRepo.UpdatePaymentStatus(txCtx, checkoutID, status, paymentMethod, paymentReference)
+		if err != nil {
+			logger.Error("Failed to update payment status", "error", err.Error())
+			return fmt.Errorf("error updating payment status: %w", err)
+		}
 
-	// Update payment status
-	err = u.checkoutRepo.UpdatePaymentStatus(ctx, checkoutID, status, paymentMethod, paymentReference)
+		// Failed or refunded payments give the stock back
+		if status.ReleasesInventory() {
+			return u.restockInventory(txCtx, checkout)
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error("Failed to update payment status", "error", err.Error())
-		return fmt.Errorf("error updating payment status: %w", err)
+		return err
 	}
 
 	duration := time.Since(startTime)
@@ -334,11 +417,23 @@ func (u *checkoutUseCase) UpdateOrderStatus(ctx context.Context, checkoutID uuid
 		return fmt.Errorf("order must be paid before changing status to %s", status)
 	}
 
-	// Update order status
-	err = u.checkoutRepo.UpdateOrderStatus(ctx, checkoutID, status)
+	err = u.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		// Update order status
+		err := u.checkoutRepo.UpdateOrderStatus(txCtx, checkoutID, status)
+		if err != nil {
+			logger.Error("Failed to update order status", "error", err.Error())
+			return fmt.Errorf("error updating order status: %w", err)
+		}
+
+		// Cancelled orders give the stock back
+		if status.ReleasesInventory() {
+			return u.restockInventory(txCtx, checkout)
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error("Failed to update order status", "error", err.Error())
-		return fmt.Errorf("error updating order status: %w", err)
+		return err
 	}
 
 	duration := time.Since(startTime)
@@ -350,255 +445,594 @@ func (u *checkoutUseCase) UpdateOrderStatus(ctx context.Context, checkoutID uuid
 	return nil
 }
 
+// ReleaseExpiredReservations cancels checkouts whose inventory reservations expired before payment,
+// which releases the held stock. It returns the number of checkouts cancelled.
+func (u *checkoutUseCase) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	logger := middleware.Logger.With(
+		"method", "CheckoutUseCase.ReleaseExpiredReservations",
+	)
+	logger.Debug("Releasing expired inventory reservations")
+	startTime := time.Now()
+
+	checkoutIDs, err := u.reservationRepo.ListExpiredCheckoutIDs(ctx, expiredReservationBatchSize)
+	if err != nil {
+		logger.Error("Failed to list expired reservations", "error", err.Error())
+		return 0, fmt.Errorf("error listing expired reservations: %w", err)
+	}
+
+	released := 0
+	for _, checkoutID := range checkoutIDs {
+		err := u.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+			// Re-check under lock: the checkout may have been paid or cancelled since it was listed
+			reservations, err := u.reservationRepo.GetByCheckoutIDForUpdate(txCtx, checkoutID)
+			if err != nil {
+				return fmt.Errorf("error locking inventory reservations: %w", err)
+			}
+
+			expired := false
+			for _, reservation := range reservations {
+				if reservation.Status == checkoutEntity.ReservationStatusReserved && !reservation.IsActive() {
+					expired = true
+					break
+				}
+			}
+			if !expired {
+				return nil
+			}
+
+			checkout, err := u.checkoutRepo.GetByID(txCtx, checkoutID)
+			if err != nil {
+				return fmt.Errorf("error getting checkout: %w", err)
+			}
+
+			// An order that can no longer be cancelled still must not hold stock, or the sweep would
+			// pick it up again every run
+			if !isValidOrderStatusTransition(checkout.Status, checkoutEntity.OrderStatusCancelled) {
+				logger.Warn("Expired reservations belong to an order that cannot be cancelled, releasing them",
+					"checkout_id", checkoutID.String(),
+					"order_status", checkout.Status)
+				err = u.reservationRepo.UpdateStatus(txCtx, checkoutID, checkoutEntity.ReservationStatusReleased)
+				if err != nil {
+					return fmt.Errorf("error releasing inventory reservations: %w", err)
+				}
+				return nil
+			}
+
+			err = u.UpdateOrderStatus(txCtx, checkoutID, checkoutEntity.OrderStatusCancelled)
+			if err != nil {
+				return err
+			}
+
+			released++
+			return nil
+		})
+		if err != nil {
+			// One bad checkout should not stop the rest of the batch
+			logger.Error("Failed to release expired reservation",
+				"checkout_id", checkoutID.String(),
+				"error", err.Error())
+		}
+	}
+
+	duration := time.Since(startTime)
+	if released > 0 {
+		logger.Info("Successfully released expired inventory reservations",
+			"released_count", released,
+			"duration_ms", duration.Milliseconds())
+	}
+
+	return released, nil
+}
+
 // Helper functions
 
 // getActivePromotions retrieves all active promotions
 func (u *checkoutUseCase) getActivePromotions(ctx context.Context) ([]*entity.Promotion, error) {
-	// Get active promotions
-	activeFlag := true
-	promotions, _, err := u.promotionRepo.List(ctx, 1, 100, &activeFlag)
+	promotions, err := u.promotionRepo.GetActive(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error getting active promotions: %w", err)
 	}
 	return promotions, nil
 }
 
-// applyPromotions applies promotions to the checkout
-func (u *checkoutUseCase) applyPromotions(checkout *checkoutEntity.Checkout, promotions []*entity.Promotion) {
+// getCustomer loads the promotion eligibility context of the user placing the order. An unknown
+// user has no customer, so only promotions open to everyone apply.
+func (u *checkoutUseCase) getCustomer(ctx context.Context, userID uuid.UUID) (*entity.Customer, error) {
+	customer, err := u.customerRepo.GetCustomer(ctx, userID)
+	if err != nil {
+		if errors.Is(err, promotionErrors.ErrCustomerNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting customer: %w", err)
+	}
+	return customer, nil
+}
+
+// validateCoupon loads a coupon and its promotion and checks that the user can still redeem it.
+// Failures that the shopper can act on are returned as promotion-not-applicable errors.
+func (u *checkoutUseCase) validateCoupon(ctx context.Context, code string, userID uuid.UUID) (*entity.Coupon, *entity.Promotion, error) {
+	coupon, err := u.couponRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, promotionErrors.ErrCouponNotFound) {
+			return nil, nil, promotionErrors.NewCouponNotApplicableError(code, err)
+		}
+		return nil, nil, fmt.Errorf("error getting coupon: %w", err)
+	}
+
+	promotion, err := u.promotionRepo.GetByID(ctx, coupon.PromotionID)
+	if err != nil && !errors.Is(err, promotionErrors.ErrPromotionNotFound) {
+		return nil, nil, fmt.Errorf("error getting coupon promotion: %w", err)
+	}
+
+	userRedemptions, err := u.couponRepo.CountUserRedemptions(ctx, coupon.ID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error counting coupon redemptions: %w", err)
+	}
+
+	if err := coupon.CheckRedeemable(promotion, userRedemptions, time.Now()); err != nil {
+		return nil, nil, promotionErrors.NewCouponNotApplicableError(coupon.Code, err)
+	}
+
+	return coupon, promotion, nil
+}
+
+// setTierProgress records the tier a tiered promotion reached on the checkout
+func setTierProgress(applied *checkoutEntity.PromotionApplied, tier entity.TierProgress) {
+	if tier.CurrentTier != nil {
+		minQuantity := tier.CurrentTier.MinQuantity
+		discountPercentage := tier.CurrentTier.DiscountPercentage
+		applied.TierMinQuantity = &minQuantity
+		applied.TierDiscountPercentage = &discountPercentage
+	}
+	if tier.NextTier != nil {
+		nextMinQuantity := tier.NextTier.MinQuantity
+		unitsToNextTier := tier.UnitsToNextTier
+		applied.NextTierMinQuantity = &nextMinQuantity
+		applied.UnitsToNextTier = &unitsToNextTier
+	}
+}
+
+// hasAppliedPromotion reports whether a promotion produced a discount on the checkout
+func hasAppliedPromotion(checkout *checkoutEntity.Checkout, promotionID uuid.UUID) bool {
+	for _, applied := range checkout.Promotions {
+		if applied.PromotionID == promotionID && applied.Status == checkoutEntity.PromotionStatusApplied {
+			return true
+		}
+	}
+	return false
+}
+
+// isCouponLimitError reports whether a redemption failed because a usage limit was reached
+func isCouponLimitError(err error) bool {
+	return errors.Is(err, promotionErrors.ErrCouponUsageLimitReached) ||
+		errors.Is(err, promotionErrors.ErrCouponUserLimitReached) ||
+		errors.Is(err, promotionErrors.ErrPromotionCapReached)
+}
+
+// reserveInventory locks the products of the checkout items, verifies that every line can be
+// fulfilled from the stock not already held by other checkouts and records a reservation per line.
+// Product inventory is not decremented until the checkout is paid. It must run inside the checkout
+// transaction so the product row locks serialise concurrent checkouts of the same products.
+func (u *checkoutUseCase) reserveInventory(ctx context.Context, checkout *checkoutEntity.Checkout) error {
 	logger := middleware.Logger.With(
-		"method", "CheckoutUseCase.applyPromotions",
+		"method", "CheckoutUseCase.reserveInventory",
 		"checkout_id", checkout.ID.String(),
+		"item_count", len(checkout.Items),
 	)
+	logger.Debug("Reserving inventory for checkout items")
+	startTime := time.Now()
 
-	// Create a map for quick lookup of items by SKU
-	itemsBySKU := make(map[string][]*checkoutEntity.CheckoutItem)
-	skuQuantities := make(map[string]int)
-
-	// Group items by SKU and calculate total quantities
+	productIDs := make([]uuid.UUID, 0, len(checkout.Items))
 	for _, item := range checkout.Items {
-		itemsBySKU[item.ProductSKU] = append(itemsBySKU[item.ProductSKU], item)
-		skuQuantities[item.ProductSKU] += item.Quantity
+		productIDs = append(productIDs, item.ProductID)
 	}
 
-	// Process each promotion
-	for _, promotion := range promotions {
-		var ruleMap map[string]interface{}
-		if err := json.Unmarshal(promotion.Rule, &ruleMap); err != nil {
-			logger.Error("Failed to parse promotion rule",
-				"promotion_id", promotion.ID.String(),
-				"error", err.Error())
+	products, err := u.productRepo.GetByIDsForUpdate(ctx, productIDs)
+	if err != nil {
+		logger.Error("Failed to lock products", "error", err.Error())
+		return fmt.Errorf("error locking products: %w", err)
+	}
+
+	reserved, err := u.reservationRepo.GetReservedQuantities(ctx, productIDs)
+	if err != nil {
+		logger.Error("Failed to get reserved quantities", "error", err.Error())
+		return fmt.Errorf("error getting reserved quantities: %w", err)
+	}
+
+	productsByID := make(map[uuid.UUID]*productEntity.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	// Check every line first so the error reports all short SKUs, not just the first one
+	var shortages []checkoutErrors.StockShortage
+	for _, item := range checkout.Items {
+		product, ok := productsByID[item.ProductID]
+		if !ok {
+			shortages = append(shortages, checkoutErrors.StockShortage{
+				SKU:       item.ProductSKU,
+				Requested: item.Quantity,
+				Available: 0,
+			})
 			continue
 		}
 
-		switch promotion.Type {
-		case entity.BuyOneGetOneFree:
-			// Apply buy one get one free promotion
-			triggerSKU, _ := ruleMap["trigger_sku"].(string)
-			freeSKU, _ := ruleMap["free_sku"].(string)
-			triggerQty, _ := ruleMap["trigger_quantity"].(float64)
-			freeQty, _ := ruleMap["free_quantity"].(float64)
+		available := product.Inventory - reserved[product.ID]
+		if available < 0 {
+			available = 0
+		}
+		if item.Quantity > available {
+			shortages = append(shortages, checkoutErrors.StockShortage{
+				SKU:       item.ProductSKU,
+				Requested: item.Quantity,
+				Available: available,
+			})
+		}
+	}
 
-			// Check if we have enough trigger items
-			if triggerSKU == "" || freeSKU == "" || triggerQty <= 0 || freeQty <= 0 {
-				logger.Warn("Invalid BuyOneGetOneFree promotion rule", "promotion_id", promotion.ID.String())
-				continue
-			}
+	if len(shortages) > 0 {
+		logger.Warn("Insufficient stock", "error", "ErrInsufficientStock", "short_sku_count", len(shortages))
+		return checkoutErrors.NewOutOfStockError(shortages)
+	}
 
-			triggerQuantity := int(triggerQty)
-			freeQuantity := int(freeQty)
+	reservations := make([]*checkoutEntity.InventoryReservation, 0, len(checkout.Items))
+	for _, item := range checkout.Items {
+		reservations = append(reservations, checkoutEntity.NewInventoryReservation(checkout.ID, item.ProductID, item.Quantity, u.reservationTTL))
+	}
 
-			// Check if we have both the trigger and free items in the cart
-			_, hasTriggerItems := itemsBySKU[triggerSKU]
-			freeItems, hasFreeItems := itemsBySKU[freeSKU]
+	err = u.reservationRepo.Create(ctx, reservations)
+	if err != nil {
+		logger.Error("Failed to create inventory reservations", "error", err.Error())
+		return fmt.Errorf("error creating inventory reservations: %w", err)
+	}
 
-			if !hasTriggerItems || !hasFreeItems {
-				continue // Skip this promotion if items not found
-			}
+	expiresAt := reservations[0].ExpiresAt
+	checkout.ReservationExpiresAt = &expiresAt
 
-			// Calculate how many free items to give
-			triggerItemsCount := skuQuantities[triggerSKU]
-			freeItemsCount := skuQuantities[freeSKU]
+	duration := time.Since(startTime)
+	logger.Info("Successfully reserved inventory",
+		"product_count", len(products),
+		"expires_at", expiresAt,
+		"duration_ms", duration.Milliseconds())
 
-			// Calculate how many free items we're entitled to
-			entitledFreeItems := (triggerItemsCount / triggerQuantity) * freeQuantity
+	return nil
+}
 
-			// Cap at the actual number of items
-			discountItemCount := min(entitledFreeItems, freeItemsCount)
+// commitReservations takes the held quantities of a paid checkout out of product inventory.
+// Checkouts placed before reservations existed had their stock taken at checkout and are left
+// alone, as are checkouts whose reservations are already committed. A checkout whose stock was
+// already given back by a cancellation or failed payment cannot be paid for.
+func (u *checkoutUseCase) commitReservations(ctx context.Context, checkout *checkoutEntity.Checkout) error {
+	logger := middleware.Logger.With(
+		"method", "CheckoutUseCase.commitReservations",
+		"checkout_id", checkout.ID.String(),
+	)
+	logger.Debug("Committing inventory reservations")
+	startTime := time.Now()
 
-			if discountItemCount <= 0 {
-				continue
-			}
+	// Reservations are locked before products, matching the release path, so the two cannot deadlock
+	reservations, err := u.reservationRepo.GetByCheckoutIDForUpdate(ctx, checkout.ID)
+	if err != nil {
+		logger.Error("Failed to lock inventory reservations", "error", err.Error())
+		return fmt.Errorf("error locking inventory reservations: %w", err)
+	}
 
-			// Find the total value of discounted items
-			var totalDiscount float64
-			remainingDiscountItems := discountItemCount
+	if checkout.InventoryRestockedAt != nil {
+		logger.Warn("Checkout inventory was restocked before payment",
+			"inventory_restocked_at", checkout.InventoryRestockedAt)
+		return checkoutErrors.NewInventoryRestockedError(checkout.ID.String())
+	}
+	if len(reservations) == 0 {
+		logger.Debug("Checkout has no inventory reservations, skipping")
+		return nil
+	}
 
-			// Apply discount to free items
-			for _, item := range freeItems {
-				if remainingDiscountItems <= 0 {
-					break
-				}
+	committed := 0
+	for _, reservation := range reservations {
+		switch {
+		case reservation.Status == checkoutEntity.ReservationStatusCommitted:
+			committed++
+		case !reservation.IsActive():
+			logger.Warn("Inventory reservation expired before payment",
+				"reservation_id", reservation.ID.String(),
+				"reservation_status", reservation.Status,
+				"expires_at", reservation.ExpiresAt)
+			return checkoutErrors.NewReservationExpiredError(checkout.ID.String())
+		}
+	}
+	if committed == len(reservations) {
+		logger.Info("Inventory reservations already committed, skipping")
+		return nil
+	}
 
-				itemDiscountQty := min(remainingDiscountItems, item.Quantity)
-				itemDiscount := item.UnitPrice * float64(itemDiscountQty)
+	productIDs := make([]uuid.UUID, 0, len(reservations))
+	for _, reservation := range reservations {
+		productIDs = append(productIDs, reservation.ProductID)
+	}
 
-				// Update the discount for this item
-				item.Discount += itemDiscount
-				item.Total = item.Subtotal - item.Discount
+	products, err := u.productRepo.GetByIDsForUpdate(ctx, productIDs)
+	if err != nil {
+		logger.Error("Failed to lock products", "error", err.Error())
+		return fmt.Errorf("error locking products: %w", err)
+	}
 
-				totalDiscount += itemDiscount
-				remainingDiscountItems -= itemDiscountQty
-			}
+	productsByID := make(map[uuid.UUID]*productEntity.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
 
-			if totalDiscount > 0 {
-				// Add to total discount
-				checkout.TotalDiscount += totalDiscount
-
-				// Record the applied promotion
-				appliedPromotion := &checkoutEntity.PromotionApplied{
-					ID:          uuid.New(),
-					CheckoutID:  checkout.ID,
-					PromotionID: promotion.ID,
-					Description: fmt.Sprintf("%s (Free: %d x %s)",
-						promotion.Description, discountItemCount, freeSKU),
-					Discount: totalDiscount,
-				}
-				checkout.Promotions = append(checkout.Promotions, appliedPromotion)
+	for _, reservation := range reservations {
+		product, ok := productsByID[reservation.ProductID]
+		if !ok {
+			logger.Warn("Product not found, skipping inventory commit",
+				"product_id", reservation.ProductID.String())
+			continue
+		}
 
-				logger.Info("Applied BuyOneGetOneFree promotion",
-					"promotion_id", promotion.ID.String(),
-					"discount_amount", totalDiscount,
-					"trigger_sku", triggerSKU,
-					"free_sku", freeSKU)
-			}
+		product.ReduceInventory(reservation.Quantity)
 
-		case entity.Buy3Pay2:
-			// Apply buy 3 pay 2 promotion
-			sku, _ := ruleMap["sku"].(string)
-			minQuantity, _ := ruleMap["min_quantity"].(float64)
-			paidDivisor, _ := ruleMap["paid_quantity_divisor"].(float64)
-			freeDivisor, _ := ruleMap["free_quantity_divisor"].(float64)
+		err = u.productRepo.UpdateInventory(ctx, product.ID, product.Inventory)
+		if err != nil {
+			logger.Error("Failed to update product inventory",
+				"product_id", product.ID.String(),
+				"error", err.Error())
+			return fmt.Errorf("error updating product inventory: %w", err)
+		}
+	}
 
-			// Validate rule
-			if sku == "" || minQuantity <= 0 || paidDivisor <= 0 || freeDivisor <= 0 {
-				logger.Warn("Invalid Buy3Pay2 promotion rule", "promotion_id", promotion.ID.String())
-				continue
-			}
+	err = u.reservationRepo.UpdateStatus(ctx, checkout.ID, checkoutEntity.ReservationStatusCommitted)
+	if err != nil {
+		logger.Error("Failed to commit inventory reservations", "error", err.Error())
+		return fmt.Errorf("error committing inventory reservations: %w", err)
+	}
 
-			// Check if we have the items and enough quantity
-			items, hasItems := itemsBySKU[sku]
-			totalQuantity := skuQuantities[sku]
+	duration := time.Since(startTime)
+	logger.Info("Successfully committed inventory reservations",
+		"reservation_count", len(reservations),
+		"duration_ms", duration.Milliseconds())
 
-			if !hasItems || totalQuantity < int(minQuantity) {
-				continue // Skip if we don't have enough items
-			}
+	return nil
+}
 
-			// Calculate discount rate (e.g., for 3 pay 2, the rate is 1/3 = 33.33%)
-			discountRate := freeDivisor / (paidDivisor + freeDivisor)
+// restockInventory gives a cancelled or failed checkout's stock back. Reservations that were never
+// paid for are simply released; committed reservations, and checkouts placed before reservations
+// existed, return their quantities to product inventory. The checkout is marked as restocked in
+// the same transaction before any stock is changed, so repeating a cancellation or payment failure
+// never restocks twice.
+func (u *checkoutUseCase) restockInventory(ctx context.Context, checkout *checkoutEntity.Checkout) error {
+	logger := middleware.Logger.With(
+		"method", "CheckoutUseCase.restockInventory",
+		"checkout_id", checkout.ID.String(),
+	)
+	logger.Debug("Restocking checkout inventory")
+	startTime := time.Now()
 
-			// Calculate total discount amount
-			var totalDiscount float64
+	reservations, err := u.reservationRepo.GetByCheckoutIDForUpdate(ctx, checkout.ID)
+	if err != nil {
+		logger.Error("Failed to lock inventory reservations", "error", err.Error())
+		return fmt.Errorf("error locking inventory reservations: %w", err)
+	}
 
-			// Apply discount proportionally to all items of this SKU
-			for _, item := range items {
-				itemDiscount := item.Subtotal * discountRate
-				item.Discount += itemDiscount
-				item.Total = item.Subtotal - item.Discount
-				totalDiscount += itemDiscount
-			}
+	restocked, err := u.checkoutRepo.MarkInventoryRestocked(ctx, checkout.ID)
+	if err != nil {
+		logger.Error("Failed to mark checkout inventory as restocked", "error", err.Error())
+		return fmt.Errorf("error marking checkout inventory as restocked: %w", err)
+	}
+	if !restocked {
+		logger.Info("Checkout inventory already restocked, skipping")
+		return nil
+	}
 
-			if totalDiscount > 0 {
-				// Add to total discount
-				checkout.TotalDiscount += totalDiscount
-
-				// Record the applied promotion
-				appliedPromotion := &checkoutEntity.PromotionApplied{
-					ID:          uuid.New(),
-					CheckoutID:  checkout.ID,
-					PromotionID: promotion.ID,
-					Description: fmt.Sprintf("%s (Discount: %.1f%%)",
-						promotion.Description, discountRate*100),
-					Discount: totalDiscount,
-				}
-				checkout.Promotions = append(checkout.Promotions, appliedPromotion)
+	// Without reservations the stock was taken at checkout, so every ordered line goes back.
+	// With reservations only committed quantities ever left product inventory.
+	restoreInventory := len(reservations) == 0
+	for _, reservation := range reservations {
+		if reservation.Status == checkoutEntity.ReservationStatusCommitted {
+			restoreInventory = true
+			break
+		}
+	}
 
-				logger.Info("Applied Buy3Pay2 promotion",
-					"promotion_id", promotion.ID.String(),
-					"discount_amount", totalDiscount,
-					"discount_rate", discountRate,
-					"sku", sku)
-			}
+	if len(reservations) > 0 {
+		err = u.reservationRepo.UpdateStatus(ctx, checkout.ID, checkoutEntity.ReservationStatusReleased)
+		if err != nil {
+			logger.Error("Failed to release inventory reservations", "error", err.Error())
+			return fmt.Errorf("error releasing inventory reservations: %w", err)
+		}
+	}
 
-		case entity.BulkDiscount:
-			// Apply bulk discount promotion
-			sku, _ := ruleMap["sku"].(string)
-			minQuantity, _ := ruleMap["min_quantity"].(float64)
-			discountPercentage, _ := ruleMap["discount_percentage"].(float64)
+	if !restoreInventory {
+		duration := time.Since(startTime)
+		logger.Info("Successfully released inventory reservations",
+			"reservation_count", len(reservations),
+			"duration_ms", duration.Milliseconds())
+		return nil
+	}
 
-			// Validate rule
-			if sku == "" || minQuantity <= 0 || discountPercentage <= 0 || discountPercentage > 100 {
-				logger.Warn("Invalid BulkDiscount promotion rule", "promotion_id", promotion.ID.String())
-				continue
-			}
+	productIDs := make([]uuid.UUID, 0, len(checkout.Items))
+	for _, item := range checkout.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
 
-			// Check if we have the items and enough quantity
-			items, hasItems := itemsBySKU[sku]
-			totalQuantity := skuQuantities[sku]
+	products, err := u.productRepo.GetByIDsForUpdate(ctx, productIDs)
+	if err != nil {
+		logger.Error("Failed to lock products", "error", err.Error())
+		return fmt.Errorf("error locking products: %w", err)
+	}
 
-			if !hasItems || totalQuantity < int(minQuantity) {
-				continue // Skip if we don't have enough items
-			}
+	productsByID := make(map[uuid.UUID]*productEntity.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
 
-			// Calculate discount rate
-			discountRate := discountPercentage / 100.0
+	for _, item := range checkout.Items {
+		product, ok := productsByID[item.ProductID]
+		if !ok {
+			// The product was deleted after the order was placed; there is no stock to return it to
+			logger.Warn("Product not found, skipping restock",
+				"product_id", item.ProductID.String(),
+				"sku", item.ProductSKU)
+			continue
+		}
 
-			// Calculate total discount amount
-			var totalDiscount float64
+		product.RestoreInventory(item.Quantity)
 
-			// Apply discount to all items of this SKU
-			for _, item := range items {
-				itemDiscount := item.Subtotal * discountRate
-				item.Discount += itemDiscount
-				item.Total = item.Subtotal - item.Discount
-				totalDiscount += itemDiscount
-			}
+		err = u.productRepo.UpdateInventory(ctx, product.ID, product.Inventory)
+		if err != nil {
+			logger.Error("Failed to update product inventory",
+				"product_id", product.ID.String(),
+				"error", err.Error())
+			return fmt.Errorf("error updating product inventory: %w", err)
+		}
+	}
 
-			if totalDiscount > 0 {
-				// Add to total discount
-				checkout.TotalDiscount += totalDiscount
-
-				// Record the applied promotion
-				appliedPromotion := &checkoutEntity.PromotionApplied{
-					ID:          uuid.New(),
-					CheckoutID:  checkout.ID,
-					PromotionID: promotion.ID,
-					Description: fmt.Sprintf("%s (%.1f%% off)",
-						promotion.Description, discountPercentage),
-					Discount: totalDiscount,
-				}
-				checkout.Promotions = append(checkout.Promotions, appliedPromotion)
+	duration := time.Since(startTime)
+	logger.Info("Successfully restocked checkout inventory",
+		"item_count", len(checkout.Items),
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// applyPromotions applies the promotions the customer is eligible for to the checkout using the
+// shared promotion engine
+func (u *checkoutUseCase) applyPromotions(checkout *checkoutEntity.Checkout, promotions []*entity.Promotion, customer *entity.Customer) {
+	logger := middleware.Logger.With(
+		"method", "CheckoutUseCase.applyPromotions",
+		"checkout_id", checkout.ID.String(),
+	)
+
+	// Create a map for quick lookup of items by SKU
+	itemsBySKU := make(map[string]*checkoutEntity.CheckoutItem, len(checkout.Items))
+	promotionItems := make([]entity.CartItem, 0, len(checkout.Items))
+	for _, item := range checkout.Items {
+		itemsBySKU[item.ProductSKU] = item
+		promotionItems = append(promotionItems, entity.CartItem{
+			ProductID:   item.ProductID,
+			ProductSKU:  item.ProductSKU,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		})
+	}
+
+	result := u.engine.Evaluate(promotions, promotionItems, customer)
+
+	// Malformed rules are left out of the order; they should have been rejected when the promotion was saved
+	for _, invalid := range result.Invalid {
+		logger.Error("Promotion with an invalid rule was not applied",
+			"promotion_id", invalid.ID.String(),
+			"promotion_type", invalid.Type,
+			"error", invalid.Error)
+	}
+	if result.Approximate {
+		logger.Warn("Promotion search was cut short; the best combination found so far was applied",
+			"active_promotions", len(promotions))
+	}
 
-				logger.Info("Applied BulkDiscount promotion",
-					"promotion_id", promotion.ID.String(),
-					"discount_amount", totalDiscount,
-					"discount_percentage", discountPercentage,
-					"sku", sku)
+	// Record which version of each promotion priced the order
+	versionsByPromotion := make(map[uuid.UUID]int, len(promotions))
+	for _, promotion := range promotions {
+		versionsByPromotion[promotion.ID] = promotion.Version
+	}
+
+	tiersByPromotion := make(map[uuid.UUID]entity.TierProgress, len(result.Tiers))
+	for _, tier := range result.Tiers {
+		tiersByPromotion[tier.PromotionID] = tier
+	}
+
+	for _, applied := range result.Promotions {
+		// Spread the promotion discount onto the lines it was allocated to
+		for _, allocation := range applied.Allocations {
+			item, ok := itemsBySKU[allocation.ProductSKU]
+			if !ok {
+				continue
 			}
+			item.Discount = item.Discount.Add(allocation.Discount)
+		}
+
+		checkout.TotalDiscount = checkout.TotalDiscount.Add(applied.Discount)
+		promotionApplied := &checkoutEntity.PromotionApplied{
+			ID:          uuid.New(),
+			CheckoutID:  checkout.ID,
+			PromotionID: applied.ID,
+			Description: applied.Description,
+			Discount:    applied.Discount,
+			Status:      checkoutEntity.PromotionStatusApplied,
 		}
+		if version, ok := versionsByPromotion[applied.ID]; ok {
+			promotionApplied.PromotionVersion = &version
+		}
+		if tier, ok := tiersByPromotion[applied.ID]; ok {
+			setTierProgress(promotionApplied, tier)
+		}
+		checkout.Promotions = append(checkout.Promotions, promotionApplied)
+
+		logger.Info("Applied promotion",
+			"promotion_id", applied.ID.String(),
+			"promotion_type", applied.Type,
+			"discount_amount", applied.Discount.String(),
+			"allocations", len(applied.Allocations))
 	}
 
-	// Update all item totals after all discounts are applied
-	for _, item := range checkout.Items {
-		item.Total = item.Subtotal - item.Discount
+	// Keep the promotions that lost out to a better combination so the order shows why
+	for _, skipped := range result.Skipped {
+		reason := string(skipped.Reason)
+		promotionSkipped := &checkoutEntity.PromotionApplied{
+			ID:          uuid.New(),
+			CheckoutID:  checkout.ID,
+			PromotionID: skipped.ID,
+			Description: skipped.Description,
+			Discount:    money.Zero(),
+			Status:      checkoutEntity.PromotionStatusSkipped,
+			SkipReason:  &reason,
+		}
+		if version, ok := versionsByPromotion[skipped.ID]; ok {
+			promotionSkipped.PromotionVersion = &version
+		}
+		if tier, ok := tiersByPromotion[skipped.ID]; ok {
+			setTierProgress(promotionSkipped, tier)
+		}
+		checkout.Promotions = append(checkout.Promotions, promotionSkipped)
+
+		logger.Info("Skipped promotion",
+			"promotion_id", skipped.ID.String(),
+			"promotion_type", skipped.Type,
+			"reason", reason,
+			"potential_discount", skipped.PotentialDiscount.String())
 	}
-}
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+	// Keep the tiered promotions whose first tier was not reached so the order shows how close it
+	// was; those that reached a tier were recorded as applied or skipped above
+	for _, tier := range result.Tiers {
+		if tier.CurrentTier != nil {
+			continue
+		}
+
+		promotionNotReached := &checkoutEntity.PromotionApplied{
+			ID:          uuid.New(),
+			CheckoutID:  checkout.ID,
+			PromotionID: tier.PromotionID,
+			Description: tier.Description,
+			Discount:    money.Zero(),
+			Status:      checkoutEntity.PromotionStatusNotReached,
+		}
+		if version, ok := versionsByPromotion[tier.PromotionID]; ok {
+			promotionNotReached.PromotionVersion = &version
+		}
+		setTierProgress(promotionNotReached, tier)
+		checkout.Promotions = append(checkout.Promotions, promotionNotReached)
+
+		logger.Info("Tiered promotion not reached",
+			"promotion_id", tier.PromotionID.String(),
+			"product_sku", tier.ProductSKU,
+			"units_to_next_tier", tier.UnitsToNextTier)
+	}
+
+	// Update all item totals after all discounts are applied
+	for _, item := range checkout.Items {
+		item.Total = item.Subtotal.Sub(item.Discount)
 	}
-	return b
 }
 
 // Helper functions for order status validation
@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	checkoutEntity "github.com/fanzru/e-commerce-be/internal/app/checkout/domain/entity"
+	checkoutErrors "github.com/fanzru/e-commerce-be/internal/app/checkout/domain/errs"
+	checkoutRepo "github.com/fanzru/e-commerce-be/internal/app/checkout/repo"
+	productEntity "github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	productRepo "github.com/fanzru/e-commerce-be/internal/app/product/repo"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/google/uuid"
+)
+
+// noopDriver lets the transaction manager begin and commit transactions without a database; the
+// fake repositories below keep their state in memory and ignore the transaction
+type noopDriver struct{}
+
+func (noopDriver) Open(string) (driver.Conn, error) { return noopConn{}, nil }
+
+type noopConn struct{}
+
+func (noopConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("statements are not supported")
+}
+func (noopConn) Close() error              { return nil }
+func (noopConn) Begin() (driver.Tx, error) { return noopTx{}, nil }
+
+type noopTx struct{}
+
+func (noopTx) Commit() error   { return nil }
+func (noopTx) Rollback() error { return nil }
+
+func init() {
+	sql.Register("checkout-usecase-noop", noopDriver{})
+}
+
+// fakeCheckoutRepo implements the checkout repository methods the payment flow uses
+type fakeCheckoutRepo struct {
+	checkoutRepo.CheckoutRepository
+	checkouts map[uuid.UUID]*checkoutEntity.Checkout
+}
+
+func (r *fakeCheckoutRepo) GetByID(ctx context.Context, id uuid.UUID) (*checkoutEntity.Checkout, error) {
+	checkout, ok := r.checkouts[id]
+	if !ok {
+		return nil, checkoutErrors.ErrCheckoutNotFound
+	}
+	copied := *checkout
+	return &copied, nil
+}
+
+func (r *fakeCheckoutRepo) UpdatePaymentStatus(ctx context.Context, checkoutID uuid.UUID, status checkoutEntity.PaymentStatus, paymentMethod, paymentReference string) error {
+	r.checkouts[checkoutID].PaymentStatus = status
+	return nil
+}
+
+func (r *fakeCheckoutRepo) MarkInventoryRestocked(ctx context.Context, checkoutID uuid.UUID) (bool, error) {
+	checkout := r.checkouts[checkoutID]
+	if checkout.InventoryRestockedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	checkout.InventoryRestockedAt = &now
+	return true, nil
+}
+
+// fakeReservationRepo implements the reservation repository methods the payment flow uses
+type fakeReservationRepo struct {
+	checkoutRepo.ReservationRepository
+	reservations map[uuid.UUID][]*checkoutEntity.InventoryReservation
+}
+
+func (r *fakeReservationRepo) GetByCheckoutIDForUpdate(ctx context.Context, checkoutID uuid.UUID) ([]*checkoutEntity.InventoryReservation, error) {
+	return r.reservations[checkoutID], nil
+}
+
+func (r *fakeReservationRepo) UpdateStatus(ctx context.Context, checkoutID uuid.UUID, status checkoutEntity.ReservationStatus) error {
+	for _, reservation := range r.reservations[checkoutID] {
+		reservation.Status = status
+	}
+	return nil
+}
+
+// fakeProductRepo implements the product repository methods the payment flow uses
+type fakeProductRepo struct {
+	productRepo.ProductRepository
+	products map[uuid.UUID]*productEntity.Product
+}
+
+func (r *fakeProductRepo) GetByIDsForUpdate(ctx context.Context, ids []uuid.UUID) ([]*productEntity.Product, error) {
+	products := make([]*productEntity.Product, 0, len(ids))
+	for _, id := range ids {
+		if product, ok := r.products[id]; ok {
+			copied := *product
+			products = append(products, &copied)
+		}
+	}
+	return products, nil
+}
+
+func (r *fakeProductRepo) UpdateInventory(ctx context.Context, id uuid.UUID, inventory int) error {
+	r.products[id].Inventory = inventory
+	return nil
+}
+
+func TestUpdatePaymentStatusInventory(t *testing.T) {
+	const (
+		stock    = 5
+		quantity = 2
+	)
+
+	tests := []struct {
+		name     string
+		reserved bool
+		// updates are applied in order; only the last may fail
+		updates           []checkoutEntity.PaymentStatus
+		wantErr           error
+		wantInventory     int
+		wantPaymentStatus checkoutEntity.PaymentStatus
+	}{
+		{
+			name:              "reserved checkout paid commits the reservation",
+			reserved:          true,
+			updates:           []checkoutEntity.PaymentStatus{checkoutEntity.PaymentStatusPaid},
+			wantInventory:     stock - quantity,
+			wantPaymentStatus: checkoutEntity.PaymentStatusPaid,
+		},
+		{
+			name:              "reserved checkout refunded after payment is restocked",
+			reserved:          true,
+			updates:           []checkoutEntity.PaymentStatus{checkoutEntity.PaymentStatusPaid, checkoutEntity.PaymentStatusRefunded},
+			wantInventory:     stock,
+			wantPaymentStatus: checkoutEntity.PaymentStatusRefunded,
+		},
+		{
+			name:              "reserved checkout paid after a failed payment is rejected",
+			reserved:          true,
+			updates:           []checkoutEntity.PaymentStatus{checkoutEntity.PaymentStatusFailed, checkoutEntity.PaymentStatusPaid},
+			wantErr:           checkoutErrors.ErrInventoryRestocked,
+			wantInventory:     stock,
+			wantPaymentStatus: checkoutEntity.PaymentStatusFailed,
+		},
+		{
+			name:              "legacy checkout paid keeps the stock taken at checkout",
+			updates:           []checkoutEntity.PaymentStatus{checkoutEntity.PaymentStatusPaid},
+			wantInventory:     stock,
+			wantPaymentStatus: checkoutEntity.PaymentStatusPaid,
+		},
+		{
+			name:              "legacy checkout failed is restocked once",
+			updates:           []checkoutEntity.PaymentStatus{checkoutEntity.PaymentStatusFailed, checkoutEntity.PaymentStatusFailed},
+			wantInventory:     stock + quantity,
+			wantPaymentStatus: checkoutEntity.PaymentStatusFailed,
+		},
+		{
+			name:              "legacy checkout paid after a failed payment is rejected",
+			updates:           []checkoutEntity.PaymentStatus{checkoutEntity.PaymentStatusFailed, checkoutEntity.PaymentStatusPaid},
+			wantErr:           checkoutErrors.ErrInventoryRestocked,
+			wantInventory:     stock + quantity,
+			wantPaymentStatus: checkoutEntity.PaymentStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := &productEntity.Product{ID: uuid.New(), SKU: "SKU-1", Inventory: stock}
+			checkout := &checkoutEntity.Checkout{
+				ID:            uuid.New(),
+				PaymentStatus: checkoutEntity.PaymentStatusPending,
+				Status:        checkoutEntity.OrderStatusCreated,
+				Items: []*checkoutEntity.CheckoutItem{
+					{ProductID: product.ID, ProductSKU: product.SKU, Quantity: quantity},
+				},
+			}
+
+			reservations := map[uuid.UUID][]*checkoutEntity.InventoryReservation{}
+			if tt.reserved {
+				reservations[checkout.ID] = []*checkoutEntity.InventoryReservation{
+					checkoutEntity.NewInventoryReservation(checkout.ID, product.ID, quantity, time.Hour),
+				}
+			}
+
+			checkouts := &fakeCheckoutRepo{checkouts: map[uuid.UUID]*checkoutEntity.Checkout{checkout.ID: checkout}}
+			products := &fakeProductRepo{products: map[uuid.UUID]*productEntity.Product{product.ID: product}}
+
+			db, err := sql.Open("checkout-usecase-noop", "")
+			if err != nil {
+				t.Fatalf("sql.Open returned error: %v", err)
+			}
+			defer db.Close()
+
+			u := NewCheckoutUseCase(
+				checkouts,
+				&fakeReservationRepo{reservations: reservations},
+				nil,
+				products,
+				nil,
+				nil,
+				nil,
+				persistence.NewTransactionManager(db),
+				time.Hour,
+			)
+
+			ctx := context.Background()
+			for i, status := range tt.updates {
+				err = u.UpdatePaymentStatus(ctx, checkout.ID, status, "card", "ref")
+				if i < len(tt.updates)-1 && err != nil {
+					t.Fatalf("UpdatePaymentStatus(%s) returned error: %v", status, err)
+				}
+			}
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("UpdatePaymentStatus returned error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdatePaymentStatus error = %v, want %v", err, tt.wantErr)
+			}
+			if product.Inventory != tt.wantInventory {
+				t.Errorf("inventory = %d, want %d", product.Inventory, tt.wantInventory)
+			}
+			if checkout.PaymentStatus != tt.wantPaymentStatus {
+				t.Errorf("payment status = %s, want %s", checkout.PaymentStatus, tt.wantPaymentStatus)
+			}
+		})
+	}
+}
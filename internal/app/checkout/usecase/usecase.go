@@ -4,6 +4,7 @@ import (
 	"context"
 
 	checkoutEntity "github.com/fanzru/e-commerce-be/internal/app/checkout/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -15,15 +16,18 @@ type CheckoutUseCase interface {
 	// ProcessCart processes a cart and creates a checkout
 	ProcessCart(ctx context.Context, userID uuid.UUID) (*checkoutEntity.Checkout, error)
 
-	// ListCheckouts retrieves a list of checkouts with pagination
-	ListCheckouts(ctx context.Context, page, limit int) ([]*checkoutEntity.Checkout, int, error)
+	// ListCheckouts retrieves a page of checkouts, newest first, by page number or cursor
+	ListCheckouts(ctx context.Context, req pagination.Request) ([]*checkoutEntity.Checkout, pagination.Result, error)
 
-	// GetUserOrders retrieves a list of checkouts for a specific user
-	GetUserOrders(ctx context.Context, userID uuid.UUID, page, limit int) ([]*checkoutEntity.Checkout, int, error)
+	// GetUserOrders retrieves a page of the checkouts of a specific user, newest first
+	GetUserOrders(ctx context.Context, userID uuid.UUID, req pagination.Request) ([]*checkoutEntity.Checkout, pagination.Result, error)
 
 	// UpdatePaymentStatus updates the payment status of a checkout
 	UpdatePaymentStatus(ctx context.Context, checkoutID uuid.UUID, status checkoutEntity.PaymentStatus, paymentMethod, paymentReference string) error
 
 	// UpdateOrderStatus updates the order status of a checkout
 	UpdateOrderStatus(ctx context.Context, checkoutID uuid.UUID, status checkoutEntity.OrderStatus) error
+
+	// ReleaseExpiredReservations cancels unpaid checkouts whose inventory reservations have expired
+	ReleaseExpiredReservations(ctx context.Context) (int, error)
 }
@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxSlugLength is the longest slug a category can have
+const MaxSlugLength = 100
+
+// slugPattern matches lower-case words of letters and digits joined by single hyphens
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Category is a node of the catalog tree. A product can be listed in any number of categories.
+type Category struct {
+	ID        uuid.UUID  `json:"id"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	Name      string     `json:"name"`
+	Slug      string     `json:"slug"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// NewCategory creates a new category under the given parent; a nil parent makes it a top-level category
+func NewCategory(parentID *uuid.UUID, name, slug string) *Category {
+	now := time.Now()
+	return &Category{
+		ID:        uuid.New(),
+		ParentID:  parentID,
+		Name:      name,
+		Slug:      slug,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IsValidSlug reports whether slug can be used as a category slug
+func IsValidSlug(slug string) bool {
+	return len(slug) <= MaxSlugLength && slugPattern.MatchString(slug)
+}
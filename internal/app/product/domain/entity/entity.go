@@ -3,23 +3,45 @@ package entity
 import (
 	"time"
 
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
 // Product represents a product entity
 type Product struct {
-	ID        uuid.UUID  `json:"id"`
-	SKU       string     `json:"sku"`
-	Name      string     `json:"name"`
-	Price     float64    `json:"price"`
-	Inventory int        `json:"inventory"`
+	ID          uuid.UUID   `json:"id"`
+	SKU         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Inventory   int         `json:"inventory"`
+
+	// CategoryIDs are the categories the product is listed in
+	CategoryIDs []uuid.UUID `json:"category_ids"`
+
+	// Options are the option definitions of a parent product; a parent is sold through Variants
+	Options  ProductOptions `json:"options,omitempty"`
+	Variants []*Product     `json:"variants,omitempty"`
+
+	// ParentID, OptionValues and PriceOverride are set on a variant. Without a PriceOverride the
+	// variant's Price follows the parent's.
+	ParentID      *uuid.UUID   `json:"parent_id,omitempty"`
+	OptionValues  OptionValues `json:"option_values,omitempty"`
+	PriceOverride *money.Money `json:"price_override,omitempty"`
+
+	// Images of a standalone or parent product in display order; variants show their parent's
+	Images []*ProductImage `json:"images,omitempty"`
+
+	// Rating summarises the published reviews of a standalone or parent product
+	Rating ProductRating `json:"rating"`
+
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // NewProduct creates a new product with the given parameters
-func NewProduct(sku, name string, price float64, inventory int) *Product {
+func NewProduct(sku, name string, price money.Money, inventory int) *Product {
 	now := time.Now()
 	return &Product{
 		ID:        uuid.New(),
@@ -0,0 +1,123 @@
+package entity
+
+import (
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/google/uuid"
+)
+
+// ProductSort is the order of a product list
+type ProductSort string
+
+const (
+	ProductSortNewest     ProductSort = "newest"
+	ProductSortPriceAsc   ProductSort = "price_asc"
+	ProductSortPriceDesc  ProductSort = "price_desc"
+	ProductSortNameAsc    ProductSort = "name_asc"
+	ProductSortNameDesc   ProductSort = "name_desc"
+	ProductSortPopularity ProductSort = "popularity"
+	ProductSortRating     ProductSort = "rating"
+)
+
+// IsValid reports whether the sort is a known product sort
+func (s ProductSort) IsValid() bool {
+	switch s {
+	case ProductSortNewest, ProductSortPriceAsc, ProductSortPriceDesc,
+		ProductSortNameAsc, ProductSortNameDesc, ProductSortPopularity, ProductSortRating:
+		return true
+	}
+	return false
+}
+
+// ProductFilter narrows a product list. Price, stock and attributes are matched against the units a
+// product is sold as: the product itself, or its variants for a parent. A product matches when one
+// unit satisfies all of them together.
+type ProductFilter struct {
+	SKU  string
+	Name string
+
+	// CategoryID limits the list to products in the category or any of its descendants
+	CategoryID *uuid.UUID
+
+	MinPrice *money.Money
+	MaxPrice *money.Money
+	InStock  bool
+
+	// Attributes maps an option name to the accepted values; a unit matches when its value of every
+	// named option is one of them
+	Attributes map[string][]string
+
+	// Sort defaults to ProductSortNewest
+	Sort ProductSort
+}
+
+// PriceFacetBounds are the lower bounds of the price ranges counted in the price facet, after the
+// range starting at zero
+var PriceFacetBounds = []money.Money{
+	money.FromFloat(25),
+	money.FromFloat(50),
+	money.FromFloat(100),
+	money.FromFloat(250),
+	money.FromFloat(500),
+	money.FromFloat(1000),
+}
+
+// ProductFacets counts the products matching a filter for every value of each filter. The counts of
+// a facet ignore the filter's own selection, so the other values still show what selecting them
+// would return.
+type ProductFacets struct {
+	Categories []CategoryFacet  `json:"categories"`
+	Prices     []PriceFacet     `json:"prices"`
+	Stock      StockFacet       `json:"stock"`
+	Attributes []AttributeFacet `json:"attributes"`
+}
+
+// CategoryFacet counts the products in a category or any of its descendants
+type CategoryFacet struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Slug  string    `json:"slug"`
+	Count int       `json:"count"`
+}
+
+// PriceFacet counts the products with a unit priced from Min up to, but not including, Max. The last
+// range has no Max.
+type PriceFacet struct {
+	Min   money.Money  `json:"min"`
+	Max   *money.Money `json:"max,omitempty"`
+	Count int          `json:"count"`
+}
+
+// StockFacet counts the products with and without a unit in stock
+type StockFacet struct {
+	InStock    int `json:"in_stock"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+// AttributeFacet counts the products with a variant for each value of an option
+type AttributeFacet struct {
+	Name   string                `json:"name"`
+	Values []AttributeValueFacet `json:"values"`
+}
+
+// AttributeValueFacet counts the products with a variant having the value
+type AttributeValueFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// NewPriceFacets spreads per-range counts over the ranges of PriceFacetBounds; counts[i] is the
+// number of products in the range starting at PriceFacetBounds[i-1], or at zero for i = 0
+func NewPriceFacets(counts map[int]int) []PriceFacet {
+	facets := make([]PriceFacet, 0, len(PriceFacetBounds)+1)
+	lower := money.Zero()
+	for i := 0; i <= len(PriceFacetBounds); i++ {
+		facet := PriceFacet{Min: lower, Count: counts[i]}
+		if i < len(PriceFacetBounds) {
+			upper := PriceFacetBounds[i]
+			facet.Max = &upper
+			lower = upper
+		}
+		facets = append(facets, facet)
+	}
+	return facets
+}
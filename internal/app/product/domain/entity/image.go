@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductImage is an image of a standalone or parent product. The image and its thumbnail are
+// stored in a blob store under their keys; the URLs are resolved from the keys when the product
+// is returned.
+type ProductImage struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+
+	// Position orders the images of a product from 0, the main image
+	Position int `json:"position"`
+
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+	AltText     string `json:"alt_text"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,68 @@
+package entity
+
+import "github.com/fanzru/e-commerce-be/pkg/money"
+
+// ProductFileFormat is the file format of a product import or export
+type ProductFileFormat string
+
+const (
+	// ProductFileFormatCSV is a CSV file with a header row naming the columns
+	ProductFileFormatCSV ProductFileFormat = "csv"
+	// ProductFileFormatNDJSON is one JSON object per line
+	ProductFileFormatNDJSON ProductFileFormat = "ndjson"
+)
+
+// IsValid reports whether the format is a known product file format
+func (f ProductFileFormat) IsValid() bool {
+	return f == ProductFileFormatCSV || f == ProductFileFormatNDJSON
+}
+
+// ProductImportMode decides what an import writes
+type ProductImportMode string
+
+const (
+	// ProductImportModePartial saves every valid row and reports the others
+	ProductImportModePartial ProductImportMode = "partial"
+	// ProductImportModeAtomic saves every row in one transaction, or none of them when a row fails
+	ProductImportModeAtomic ProductImportMode = "atomic"
+	// ProductImportModeDryRun validates the rows and saves none of them
+	ProductImportModeDryRun ProductImportMode = "dry_run"
+)
+
+// IsValid reports whether the mode is a known import mode
+func (m ProductImportMode) IsValid() bool {
+	switch m {
+	case ProductImportModePartial, ProductImportModeAtomic, ProductImportModeDryRun:
+		return true
+	}
+	return false
+}
+
+// ProductImportRow is a standalone product read from a line of an import file, keyed by SKU
+type ProductImportRow struct {
+	Line      int
+	SKU       string
+	Name      string
+	Price     money.Money
+	Inventory int
+
+	// Description is nil when the file has no description, which keeps the current one
+	Description *string
+}
+
+// ProductImportResult reports the outcome of an import. Created and Updated count the rows that
+// were saved, or would be for a dry run; a rejected atomic import saves none.
+type ProductImportResult struct {
+	Mode    ProductImportMode    `json:"mode"`
+	Rows    int                  `json:"rows"`
+	Created int                  `json:"created"`
+	Updated int                  `json:"updated"`
+	Errors  []ProductImportError `json:"errors"`
+}
+
+// ProductImportError is a row of an import file that cannot be saved
+type ProductImportError struct {
+	Line    int    `json:"line"`
+	SKU     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
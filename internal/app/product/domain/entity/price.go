@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/google/uuid"
+)
+
+// ProductPrice is the price of a product over a period. Periods may overlap: of the prices covering a
+// moment, the one starting last is in effect, so a bounded sale price overrides the regular price
+// until it ends.
+type ProductPrice struct {
+	ID            uuid.UUID   `json:"id"`
+	ProductID     uuid.UUID   `json:"product_id"`
+	Price         money.Money `json:"price"`
+	EffectiveFrom time.Time   `json:"effective_from"`
+
+	// EffectiveTo is nil for a price that lasts until a later one replaces it
+	EffectiveTo *time.Time `json:"effective_to,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProductPriceHistory is the price of a product at a moment with every past and scheduled price of
+// the product its price follows: the product itself, or the parent of a variant without a price
+// override
+type ProductPriceHistory struct {
+	ProductID uuid.UUID
+	At        time.Time
+	Price     money.Money
+	Prices    []*ProductPrice
+}
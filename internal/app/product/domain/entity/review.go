@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits of a review
+const (
+	MinReviewRating     = 1
+	MaxReviewRating     = 5
+	MaxReviewBodyLength = 5000
+)
+
+// ReviewStatus is the moderation state of a review
+type ReviewStatus string
+
+const (
+	// ReviewStatusPublished reviews are shown with their product and counted in its rating
+	ReviewStatusPublished ReviewStatus = "PUBLISHED"
+	// ReviewStatusHidden reviews were taken down by an admin; only admins see them
+	ReviewStatusHidden ReviewStatus = "HIDDEN"
+)
+
+// IsValid reports whether the status is a known review status
+func (s ReviewStatus) IsValid() bool {
+	return s == ReviewStatusPublished || s == ReviewStatusHidden
+}
+
+// ProductReview is the rating and review a customer gave a standalone or parent product they
+// received. A customer reviews a product once; reviews of a variant count for its parent.
+type ProductReview struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+	UserID    uuid.UUID `json:"user_id"`
+
+	// AuthorName is the name of the customer at the time the review is read
+	AuthorName string `json:"author_name"`
+
+	Rating int          `json:"rating"`
+	Body   string       `json:"body"`
+	Status ReviewStatus `json:"status"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewProductReview creates a published review of a product
+func NewProductReview(productID, userID uuid.UUID, rating int, body string) *ProductReview {
+	now := time.Now()
+	return &ProductReview{
+		ID:        uuid.New(),
+		ProductID: productID,
+		UserID:    userID,
+		Rating:    rating,
+		Body:      body,
+		Status:    ReviewStatusPublished,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// ProductRating summarises the published reviews of a product
+type ProductRating struct {
+	// Average is the mean rating rounded to two decimals; zero without reviews
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// ReviewFilter narrows a review list
+type ReviewFilter struct {
+	ProductID *uuid.UUID
+	Status    *ReviewStatus
+}
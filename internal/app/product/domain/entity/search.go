@@ -0,0 +1,19 @@
+package entity
+
+// Snippet highlight markers. Only the matched terms are marked up; the rest of the snippet is
+// the product text as stored, so clients must escape it before rendering it as HTML.
+const (
+	SnippetStartSel = "<mark>"
+	SnippetStopSel  = "</mark>"
+)
+
+// SearchResult is a product matching a search query
+type SearchResult struct {
+	Product *Product `json:"product"`
+
+	// Rank orders the results; a higher rank is more relevant
+	Rank float64 `json:"rank"`
+
+	// Snippet is an excerpt of the name and description with the matched terms highlighted
+	Snippet string `json:"snippet"`
+}
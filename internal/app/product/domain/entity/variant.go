@@ -0,0 +1,188 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+)
+
+// ProductOption is a dimension a product is sold in, such as size or colour, with its allowed values
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// ProductOptions are the option definitions of a parent product, in display order
+type ProductOptions []ProductOption
+
+// OptionValues picks one value of every option of the parent for a variant, keyed by option name
+type OptionValues map[string]string
+
+// IsVariant reports whether the product is a variant of a parent product
+func (p *Product) IsVariant() bool {
+	return p.ParentID != nil
+}
+
+// HasVariants reports whether the product is a parent sold through its variants. A parent cannot
+// be put in a cart itself.
+func (p *Product) HasVariants() bool {
+	return len(p.Options) > 0
+}
+
+// TotalInventory is the stock of the product; for a parent it is the stock of its variants
+func (p *Product) TotalInventory() int {
+	if !p.HasVariants() {
+		return p.Inventory
+	}
+	total := 0
+	for _, variant := range p.Variants {
+		total += variant.Inventory
+	}
+	return total
+}
+
+// ApplyParent sets the name and, unless the variant overrides it, the price the variant takes from
+// its parent
+func (p *Product) ApplyParent(parent *Product) {
+	p.Name = fmt.Sprintf("%s (%s)", parent.Name, parent.Options.Label(p.OptionValues))
+	p.Price = parent.Price
+	if p.PriceOverride != nil {
+		p.Price = *p.PriceOverride
+	}
+}
+
+// Validate checks that every option has a unique name and at least one value, without duplicates
+func (o ProductOptions) Validate() error {
+	names := make(map[string]bool, len(o))
+	for _, option := range o {
+		if strings.TrimSpace(option.Name) == "" {
+			return fmt.Errorf("option name is required")
+		}
+		if names[option.Name] {
+			return fmt.Errorf("option %s is defined twice", option.Name)
+		}
+		names[option.Name] = true
+
+		if len(option.Values) == 0 {
+			return fmt.Errorf("option %s needs at least one value", option.Name)
+		}
+		values := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("option %s has an empty value", option.Name)
+			}
+			if values[value] {
+				return fmt.Errorf("option %s lists %s twice", option.Name, value)
+			}
+			values[value] = true
+		}
+	}
+	return nil
+}
+
+// Allows checks that values picks exactly one allowed value of every option
+func (o ProductOptions) Allows(values OptionValues) error {
+	if len(values) != len(o) {
+		return fmt.Errorf("a variant needs a value for each of the %d options", len(o))
+	}
+	for _, option := range o {
+		value, ok := values[option.Name]
+		if !ok {
+			return fmt.Errorf("missing value for option %s", option.Name)
+		}
+		if !containsString(option.Values, value) {
+			return fmt.Errorf("%s is not a value of option %s", value, option.Name)
+		}
+	}
+	return nil
+}
+
+// Label joins the values in option order, such as "M / Red"
+func (o ProductOptions) Label(values OptionValues) string {
+	parts := make([]string, 0, len(o))
+	for _, option := range o {
+		if value, ok := values[option.Name]; ok {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+// Scan implements sql.Scanner
+func (o *ProductOptions) Scan(src interface{}) error {
+	return scanJSON(src, o, "product options")
+}
+
+// Value implements driver.Valuer
+func (o ProductOptions) Value() (driver.Value, error) {
+	if o == nil {
+		o = ProductOptions{}
+	}
+	return valueJSON(o, "product options")
+}
+
+// Scan implements sql.Scanner
+func (v *OptionValues) Scan(src interface{}) error {
+	return scanJSON(src, v, "option values")
+}
+
+// Value implements driver.Valuer
+func (v OptionValues) Value() (driver.Value, error) {
+	if v == nil {
+		v = OptionValues{}
+	}
+	return valueJSON(v, "option values")
+}
+
+// NewVariant creates a variant of the parent with the given option values. A nil priceOverride
+// makes the variant follow the parent's price.
+func NewVariant(parent *Product, sku string, values OptionValues, priceOverride *money.Money, inventory int) *Product {
+	parentID := parent.ID
+	variant := NewProduct(sku, "", parent.Price, inventory)
+	variant.ParentID = &parentID
+	variant.OptionValues = values
+	variant.PriceOverride = priceOverride
+	variant.ApplyParent(parent)
+	return variant
+}
+
+// scanJSON decodes a json or jsonb column into dest
+func scanJSON(src interface{}, dest interface{}, what string) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("scanning %s: unsupported type %T", what, src)
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("scanning %s: %w", what, err)
+	}
+	return nil
+}
+
+// valueJSON encodes value for a json or jsonb column
+func valueJSON(value interface{}, what string) (driver.Value, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", what, err)
+	}
+	return raw, nil
+}
+
+// containsString reports whether values includes value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -9,4 +9,19 @@ var (
 	ErrInvalidProductPrice     = errors.New("invalid product price")
 	ErrInvalidProductInventory = errors.New("invalid product inventory")
 	ErrInvalidInput            = errors.New("invalid input")
+
+	ErrVariantAlreadyExists = errors.New("a variant with these option values already exists")
+
+	ErrProductImageNotFound = errors.New("product image not found")
+	ErrImageTooLarge        = errors.New("image too large")
+	ErrUnsupportedImageType = errors.New("unsupported image type")
+
+	ErrReviewNotFound      = errors.New("review not found")
+	ErrReviewAlreadyExists = errors.New("product has already been reviewed by this customer")
+	ErrReviewNotAllowed    = errors.New("only customers who received the product can review it")
+
+	ErrCategoryNotFound          = errors.New("category not found")
+	ErrCategorySlugAlreadyExists = errors.New("category with this slug already exists")
+	ErrCategoryHasChildren       = errors.New("category has child categories")
+	ErrCategoryCycle             = errors.New("category cannot be moved under itself or one of its descendants")
 )
@@ -2,7 +2,9 @@ package errs
 
 import (
 	"fmt"
+	"net/http"
 
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
 	appErrors "github.com/fanzru/e-commerce-be/pkg/errors"
 )
 
@@ -11,6 +13,7 @@ const (
 	ErrProductNotFoundMsg       = "product not found"
 	ErrProductAlreadyExistsMsg  = "product with this SKU already exists"
 	ErrInsufficientInventoryMsg = "insufficient product inventory"
+	ErrCategoryNotFoundMsg      = "category not found"
 )
 
 // NewProductNotFoundError creates a new product not found error
@@ -34,3 +37,84 @@ func NewInsufficientInventoryError(sku string, requested, available int) error {
 		),
 	)
 }
+
+// NewCategoryNotFoundError creates a new category not found error
+func NewCategoryNotFoundError(idOrSlug string) error {
+	return commonErrs.New(
+		ErrCategoryNotFound,
+		commonErrs.CodeNotFound,
+		http.StatusNotFound,
+		fmt.Sprintf("%s: %s", ErrCategoryNotFoundMsg, idOrSlug),
+	)
+}
+
+// NewCategorySlugAlreadyExistsError creates an error for a slug that is already taken
+func NewCategorySlugAlreadyExistsError(slug string) error {
+	return commonErrs.New(
+		ErrCategorySlugAlreadyExists,
+		commonErrs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("%v: %s", ErrCategorySlugAlreadyExists, slug),
+	)
+}
+
+// NewCategoryHasChildrenError creates an error for deleting a category that still has children
+func NewCategoryHasChildrenError(id string) error {
+	return commonErrs.New(
+		ErrCategoryHasChildren,
+		commonErrs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("%v: move or delete the children of %s first", ErrCategoryHasChildren, id),
+	)
+}
+
+// NewImageTooLargeError creates an error for an uploaded image above the size limit
+func NewImageTooLargeError(maxBytes int64) error {
+	return commonErrs.New(
+		ErrImageTooLarge,
+		"payload_too_large",
+		http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("%v: images must be at most %d MB", ErrImageTooLarge, maxBytes>>20),
+	)
+}
+
+// NewUnsupportedImageTypeError creates an error for an uploaded file that is not a supported image
+func NewUnsupportedImageTypeError(contentType string) error {
+	return commonErrs.New(
+		ErrUnsupportedImageType,
+		"unsupported_media_type",
+		http.StatusUnsupportedMediaType,
+		fmt.Sprintf("%v %q, upload a JPEG, PNG or GIF image", ErrUnsupportedImageType, contentType),
+	)
+}
+
+// NewReviewNotFoundError creates a new review not found error
+func NewReviewNotFoundError(id string) error {
+	return commonErrs.New(
+		ErrReviewNotFound,
+		commonErrs.CodeNotFound,
+		http.StatusNotFound,
+		fmt.Sprintf("%v: %s", ErrReviewNotFound, id),
+	)
+}
+
+// NewReviewAlreadyExistsError creates an error for a second review of a product by one customer
+func NewReviewAlreadyExistsError(productID string) error {
+	return commonErrs.New(
+		ErrReviewAlreadyExists,
+		commonErrs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("%v: %s", ErrReviewAlreadyExists, productID),
+	)
+}
+
+// NewReviewNotAllowedError creates an error for a customer reviewing a product they have no
+// delivered order of
+func NewReviewNotAllowedError(productID string) error {
+	return commonErrs.New(
+		ErrReviewNotAllowed,
+		commonErrs.CodeForbidden,
+		http.StatusForbidden,
+		fmt.Sprintf("%v: no delivered order contains product %s", ErrReviewNotAllowed, productID),
+	)
+}
@@ -1,31 +1,32 @@
 package params
 
 import (
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
 // CreateProductParams defines the parameters for creating a product
 type CreateProductParams struct {
-	SKU       string  `json:"sku" binding:"required"`
-	Name      string  `json:"name" binding:"required"`
-	Price     float64 `json:"price" binding:"required,gt=0"`
-	Inventory int     `json:"inventory" binding:"required,gte=0"`
+	SKU       string      `json:"sku" binding:"required"`
+	Name      string      `json:"name" binding:"required"`
+	Price     money.Money `json:"price" binding:"required"`
+	Inventory int         `json:"inventory" binding:"required,gte=0"`
 }
 
 // UpdateProductParams defines the parameters for updating a product
 type UpdateProductParams struct {
-	Name      string  `json:"name" binding:"omitempty"`
-	Price     float64 `json:"price" binding:"omitempty,gt=0"`
-	Inventory int     `json:"inventory" binding:"omitempty,gte=0"`
+	Name      string      `json:"name" binding:"omitempty"`
+	Price     money.Money `json:"price" binding:"omitempty"`
+	Inventory int         `json:"inventory" binding:"omitempty,gte=0"`
 }
 
 // ProductResponse defines the response structure for a product
 type ProductResponse struct {
-	ID        uuid.UUID `json:"id"`
-	SKU       string    `json:"sku"`
-	Name      string    `json:"name"`
-	Price     float64   `json:"price"`
-	Inventory int       `json:"inventory"`
+	ID        uuid.UUID   `json:"id"`
+	SKU       string      `json:"sku"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	Inventory int         `json:"inventory"`
 }
 
 // ProductListResponse defines the response structure for a list of products
@@ -2,32 +2,46 @@ package port
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	productErrs "github.com/fanzru/e-commerce-be/internal/app/product/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/app/product/port/genhttp"
 	"github.com/fanzru/e-commerce-be/internal/app/product/usecase"
+	userParams "github.com/fanzru/e-commerce-be/internal/app/user/domain/params"
 	"github.com/fanzru/e-commerce-be/internal/common/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
-// ProductHandler handles HTTP requests for products
+// ProductHandler handles HTTP requests for products, categories and reviews
 type ProductHandler struct {
-	productUseCase usecase.ProductUseCase
+	productUseCase  usecase.ProductUseCase
+	categoryUseCase usecase.CategoryUseCase
+	reviewUseCase   usecase.ReviewUseCase
 }
 
 // NewProductHandler creates a new product HTTP handler
-func NewProductHandler(productUseCase usecase.ProductUseCase) *ProductHandler {
+func NewProductHandler(productUseCase usecase.ProductUseCase, categoryUseCase usecase.CategoryUseCase, reviewUseCase usecase.ReviewUseCase) *ProductHandler {
 	return &ProductHandler{
-		productUseCase: productUseCase,
+		productUseCase:  productUseCase,
+		categoryUseCase: categoryUseCase,
+		reviewUseCase:   reviewUseCase,
 	}
 }
 
-// NewHTTPServer creates a new HTTP server for products
-func NewHTTPServer(productUseCase usecase.ProductUseCase) http.Handler {
-	handler := NewProductHandler(productUseCase)
+// NewHTTPServer creates a new HTTP server for products, categories and reviews
+func NewHTTPServer(productUseCase usecase.ProductUseCase, categoryUseCase usecase.CategoryUseCase, reviewUseCase usecase.ReviewUseCase) http.Handler {
+	handler := NewProductHandler(productUseCase, categoryUseCase, reviewUseCase)
 	return genhttp.HandlerWithOptions(handler, genhttp.StdHTTPServerOptions{
 		BaseRouter: http.NewServeMux(),
 		ErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
@@ -52,63 +66,74 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request, pa
 		limit = *params.Limit
 	}
 
+	var cursor string
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+
+	req, err := pagination.NewRequest(page, limit, cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, errs.NewBadRequest("invalid cursor"))
+		return
+	}
+
 	// Get filter values if provided
-	var sku, name string
+	var category string
+	filter := entity.ProductFilter{}
 	if params.Sku != nil {
-		sku = *params.Sku
+		filter.SKU = *params.Sku
 	}
 	if params.Name != nil {
-		name = *params.Name
+		filter.Name = *params.Name
+	}
+	if params.Category != nil {
+		category = *params.Category
+	}
+	filter.MinPrice = mapPriceRequest(params.MinPrice)
+	filter.MaxPrice = mapPriceRequest(params.MaxPrice)
+	if params.InStock != nil {
+		filter.InStock = *params.InStock
+	}
+	if params.Sort != nil {
+		filter.Sort = entity.ProductSort(*params.Sort)
+	}
+	if params.Attribute != nil {
+		attributes, err := parseAttributeFilters(*params.Attribute)
+		if err != nil {
+			handleError(w, err)
+			return
+		}
+		filter.Attributes = attributes
 	}
 
 	// Call the use case
-	products, total, err := h.productUseCase.List(ctx, page, limit, sku, name)
+	list, err := h.productUseCase.List(ctx, req, category, filter)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
 	// Convert to response format
-	productsData := make([]struct {
-		Id        *openapi_types.UUID `json:"id,omitempty"`
-		Inventory *int                `json:"inventory,omitempty"`
-		Name      *string             `json:"name,omitempty"`
-		Price     *float32            `json:"price,omitempty"`
-		Sku       *string             `json:"sku,omitempty"`
-	}, len(products))
-
-	for i, product := range products {
-		id := openapi_types.UUID(product.ID)
-		price := float32(product.Price)
-		productsData[i] = struct {
-			Id        *openapi_types.UUID `json:"id,omitempty"`
-			Inventory *int                `json:"inventory,omitempty"`
-			Name      *string             `json:"name,omitempty"`
-			Price     *float32            `json:"price,omitempty"`
-			Sku       *string             `json:"sku,omitempty"`
-		}{
-			Id:        &id,
-			Sku:       &product.SKU,
-			Name:      &product.Name,
-			Price:     &price,
-			Inventory: &product.Inventory,
-		}
+	productsData := make([]genhttp.Product, len(list.Products))
+	for i, product := range list.Products {
+		productsData[i] = mapProductToResponse(product)
 	}
 
 	response := genhttp.ProductListResponse{
 		Code: "success",
 		Data: struct {
-			Products *[]struct {
-				Id        *openapi_types.UUID `json:"id,omitempty"`
-				Inventory *int                `json:"inventory,omitempty"`
-				Name      *string             `json:"name,omitempty"`
-				Price     *float32            `json:"price,omitempty"`
-				Sku       *string             `json:"sku,omitempty"`
-			} `json:"products,omitempty"`
-			Total *int `json:"total,omitempty"`
+			NextCursor *string            `json:"next_cursor,omitempty"`
+			PrevCursor *string            `json:"prev_cursor,omitempty"`
+			Products   *[]genhttp.Product `json:"products,omitempty"`
+			Total      *int               `json:"total,omitempty"`
 		}{
-			Products: &productsData,
-			Total:    &total,
+			NextCursor: list.Page.NextCursor,
+			PrevCursor: list.Page.PrevCursor,
+			Products:   &productsData,
+			Total:      list.Page.Total,
+		},
+		Meta: genhttp.ProductListMeta{
+			Facets: mapFacetsToResponse(list.Facets),
 		},
 		Message:    "Products retrieved successfully",
 		ServerTime: time.Now(),
@@ -117,6 +142,65 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request, pa
 	respondJSON(w, http.StatusOK, response)
 }
 
+// parseAttributeFilters groups name:value attribute filters by option name
+func parseAttributeFilters(values []string) (map[string][]string, error) {
+	attributes := make(map[string][]string, len(values))
+	for _, value := range values {
+		name, optionValue, found := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		optionValue = strings.TrimSpace(optionValue)
+		if !found || name == "" || optionValue == "" {
+			return nil, errs.NewBadRequest(fmt.Sprintf("invalid attribute filter %q; use name:value", value))
+		}
+		attributes[name] = append(attributes[name], optionValue)
+	}
+	return attributes, nil
+}
+
+// SearchProducts handles GET /products/search requests
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request, params genhttp.SearchProductsParams) {
+	ctx := r.Context()
+
+	page := 1
+	limit := 10
+	if params.Page != nil {
+		page = *params.Page
+	}
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	results, total, err := h.productUseCase.Search(ctx, params.Q, page, limit)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	resultsData := make([]genhttp.ProductSearchResult, len(results))
+	for i, result := range results {
+		resultsData[i] = genhttp.ProductSearchResult{
+			Product: mapProductToResponse(result.Product),
+			Rank:    result.Rank,
+			Snippet: result.Snippet,
+		}
+	}
+
+	response := genhttp.ProductSearchResponse{
+		Code: "success",
+		Data: struct {
+			Results *[]genhttp.ProductSearchResult `json:"results,omitempty"`
+			Total   *int                           `json:"total,omitempty"`
+		}{
+			Results: &resultsData,
+			Total:   &total,
+		},
+		Message:    "Products found successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
 // GetProduct handles GET /products/{id} requests
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
 	ctx := r.Context()
@@ -133,25 +217,9 @@ func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request, id o
 		return
 	}
 
-	// Map to response
-	productId := openapi_types.UUID(product.ID)
-	price := float32(product.Price)
-
 	response := genhttp.ProductResponse{
-		Code: "success",
-		Data: struct {
-			Id        *openapi_types.UUID `json:"id,omitempty"`
-			Inventory *int                `json:"inventory,omitempty"`
-			Name      *string             `json:"name,omitempty"`
-			Price     *float32            `json:"price,omitempty"`
-			Sku       *string             `json:"sku,omitempty"`
-		}{
-			Id:        &productId,
-			Sku:       &product.SKU,
-			Name:      &product.Name,
-			Price:     &price,
-			Inventory: &product.Inventory,
-		},
+		Code:       "success",
+		Data:       mapProductToResponse(product),
 		Message:    "Product retrieved successfully",
 		ServerTime: time.Now(),
 	}
@@ -169,31 +237,20 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err := h.productUseCase.Create(ctx, params.Sku, params.Name, float64(params.Price), params.Inventory)
+	var description string
+	if params.Description != nil {
+		description = *params.Description
+	}
+
+	product, err := h.productUseCase.Create(ctx, params.Sku, params.Name, description, money.FromFloat(params.Price), params.Inventory, mapOptionsRequest(params.Options))
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	// Map to response
-	productId := openapi_types.UUID(product.ID)
-	price := float32(product.Price)
-
 	response := genhttp.ProductResponse{
-		Code: "success",
-		Data: struct {
-			Id        *openapi_types.UUID `json:"id,omitempty"`
-			Inventory *int                `json:"inventory,omitempty"`
-			Name      *string             `json:"name,omitempty"`
-			Price     *float32            `json:"price,omitempty"`
-			Sku       *string             `json:"sku,omitempty"`
-		}{
-			Id:        &productId,
-			Sku:       &product.SKU,
-			Name:      &product.Name,
-			Price:     &price,
-			Inventory: &product.Inventory,
-		},
+		Code:       "success",
+		Data:       mapProductToResponse(product),
 		Message:    "Product created successfully",
 		ServerTime: time.Now(),
 	}
@@ -219,7 +276,7 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request, i
 
 	// Extract values from pointers
 	var name string
-	var price float64
+	var price money.Money
 	var inventory int
 
 	if params.Name != nil {
@@ -227,38 +284,22 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request, i
 	}
 
 	if params.Price != nil {
-		price = float64(*params.Price)
+		price = money.FromFloat(*params.Price)
 	}
 
 	if params.Inventory != nil {
 		inventory = *params.Inventory
 	}
 
-	product, err := h.productUseCase.Update(ctx, productID, name, price, inventory)
+	product, err := h.productUseCase.Update(ctx, productID, name, params.Description, price, inventory, mapOptionsRequest(params.Options))
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	// Map to response
-	productId := openapi_types.UUID(product.ID)
-	responsePrice := float32(product.Price)
-
 	response := genhttp.ProductResponse{
-		Code: "success",
-		Data: struct {
-			Id        *openapi_types.UUID `json:"id,omitempty"`
-			Inventory *int                `json:"inventory,omitempty"`
-			Name      *string             `json:"name,omitempty"`
-			Price     *float32            `json:"price,omitempty"`
-			Sku       *string             `json:"sku,omitempty"`
-		}{
-			Id:        &productId,
-			Sku:       &product.SKU,
-			Name:      &product.Name,
-			Price:     &responsePrice,
-			Inventory: &product.Inventory,
-		},
+		Code:       "success",
+		Data:       mapProductToResponse(product),
 		Message:    "Product updated successfully",
 		ServerTime: time.Now(),
 	}
@@ -293,7 +334,881 @@ func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request, i
 	respondJSON(w, http.StatusOK, response)
 }
 
-// Helper functions
+// CreateProductVariant handles POST /products/{id}/variants requests
+func (h *ProductHandler) CreateProductVariant(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	var params genhttp.CreateProductVariantJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	variant, err := h.productUseCase.CreateVariant(ctx, id, params.Sku, params.OptionValues, mapPriceRequest(params.Price), params.Inventory)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductResponse{
+		Code:       "success",
+		Data:       mapProductToResponse(variant),
+		Message:    "Product variant created successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// UpdateProductVariant handles PUT /products/{id}/variants/{variant_id} requests
+func (h *ProductHandler) UpdateProductVariant(w http.ResponseWriter, r *http.Request, id openapi_types.UUID, variantId openapi_types.UUID) {
+	ctx := r.Context()
+
+	var params genhttp.UpdateProductVariantJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	variant, err := h.productUseCase.UpdateVariant(ctx, id, variantId, params.OptionValues, mapPriceRequest(params.Price), params.Inventory)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductResponse{
+		Code:       "success",
+		Data:       mapProductToResponse(variant),
+		Message:    "Product variant updated successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// DeleteProductVariant handles DELETE /products/{id}/variants/{variant_id} requests
+func (h *ProductHandler) DeleteProductVariant(w http.ResponseWriter, r *http.Request, id openapi_types.UUID, variantId openapi_types.UUID) {
+	if err := h.productUseCase.DeleteVariant(r.Context(), id, variantId); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.StandardResponse{
+		Code:       "success",
+		Data:       map[string]interface{}{},
+		Message:    "Product variant deleted successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// SetProductCategories handles PUT /products/{id}/categories requests
+func (h *ProductHandler) SetProductCategories(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	var params genhttp.SetProductCategoriesJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	product, err := h.productUseCase.SetCategories(ctx, id, params.CategoryIds)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductResponse{
+		Code:       "success",
+		Data:       mapProductToResponse(product),
+		Message:    "Product categories updated successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetProductPrices handles GET /products/{id}/prices requests
+func (h *ProductHandler) GetProductPrices(w http.ResponseWriter, r *http.Request, id openapi_types.UUID, params genhttp.GetProductPricesParams) {
+	ctx := r.Context()
+
+	at := time.Now()
+	if params.At != nil {
+		at = *params.At
+	}
+
+	history, err := h.productUseCase.GetPriceHistory(ctx, id, at)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	prices := make([]genhttp.ProductPrice, len(history.Prices))
+	for i, price := range history.Prices {
+		prices[i] = mapPriceToResponse(price)
+	}
+
+	response := genhttp.ProductPriceHistoryResponse{
+		Code: "success",
+		Data: genhttp.ProductPriceHistory{
+			ProductId: history.ProductID,
+			At:        history.At,
+			Price:     history.Price.Float64(),
+			Prices:    prices,
+		},
+		Message:    "Product prices retrieved successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ScheduleProductPrice handles POST /products/{id}/prices requests
+func (h *ProductHandler) ScheduleProductPrice(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	var params genhttp.ScheduleProductPriceJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	price, err := h.productUseCase.SchedulePrice(ctx, id, money.FromFloat(params.Price), params.EffectiveFrom, params.EffectiveTo)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductPriceResponse{
+		Code:       "success",
+		Data:       mapPriceToResponse(price),
+		Message:    "Product price scheduled successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// UploadProductImage handles POST /products/{id}/images requests
+func (h *ProductHandler) UploadProductImage(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageUploadSize)
+	if err := r.ParseMultipartForm(maxImageUploadMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			handleError(w, productErrs.NewImageTooLargeError(usecase.MaxImageSize))
+			return
+		}
+		handleError(w, errs.NewBadRequest("Invalid multipart form"))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		handleError(w, errs.NewBadRequest("An image file is required in the file field"))
+		return
+	}
+	defer file.Close()
+
+	image, err := h.productUseCase.UploadImage(ctx, id, limitedBody{file, productErrs.ErrImageTooLarge}, r.FormValue("alt_text"))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductImageResponse{
+		Code:       "success",
+		Data:       mapImageToResponse(image),
+		Message:    "Product image uploaded successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// DeleteProductImage handles DELETE /products/{id}/images/{image_id} requests
+func (h *ProductHandler) DeleteProductImage(w http.ResponseWriter, r *http.Request, id openapi_types.UUID, imageId openapi_types.UUID) {
+	if err := h.productUseCase.DeleteImage(r.Context(), id, imageId); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.StandardResponse{
+		Code:       "success",
+		Data:       map[string]interface{}{},
+		Message:    "Product image deleted successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ReorderProductImages handles PUT /products/{id}/images/order requests
+func (h *ProductHandler) ReorderProductImages(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	var params genhttp.ReorderProductImagesJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	images, err := h.productUseCase.ReorderImages(ctx, id, params.ImageIds)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductImageListResponse{
+		Code:       "success",
+		Data:       mapImagesToResponse(images),
+		Message:    "Product images reordered successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ListProductReviews handles GET /products/{id}/reviews requests
+func (h *ProductHandler) ListProductReviews(w http.ResponseWriter, r *http.Request, id openapi_types.UUID, params genhttp.ListProductReviewsParams) {
+	req, err := mapReviewPageRequest(params.Page, params.Limit, params.Cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	reviews, page, err := h.reviewUseCase.ListByProduct(r.Context(), id, req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapReviewListToResponse(reviews, page))
+}
+
+// CreateProductReview handles POST /products/{id}/reviews requests
+func (h *ProductHandler) CreateProductReview(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	userClaims, ok := ctx.Value(middleware.ContextTokenClaimsKey).(*userParams.TokenClaims)
+	if !ok || userClaims == nil {
+		handleError(w, errs.NewUnauthorized("authentication required"))
+		return
+	}
+
+	userID, err := uuid.Parse(userClaims.UserID)
+	if err != nil {
+		handleError(w, errs.NewBadRequest("invalid user ID"))
+		return
+	}
+
+	var params genhttp.CreateProductReviewJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	var body string
+	if params.Body != nil {
+		body = *params.Body
+	}
+
+	review, err := h.reviewUseCase.Create(ctx, id, userID, params.Rating, body)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductReviewResponse{
+		Code:       "success",
+		Data:       mapReviewToResponse(review),
+		Message:    "Review published successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// ListReviews handles GET /reviews requests
+func (h *ProductHandler) ListReviews(w http.ResponseWriter, r *http.Request, params genhttp.ListReviewsParams) {
+	req, err := mapReviewPageRequest(params.Page, params.Limit, params.Cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	filter := entity.ReviewFilter{ProductID: params.ProductId}
+	if params.Status != nil {
+		status := entity.ReviewStatus(*params.Status)
+		filter.Status = &status
+	}
+
+	reviews, page, err := h.reviewUseCase.List(r.Context(), req, filter)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapReviewListToResponse(reviews, page))
+}
+
+// ModerateReview handles PATCH /reviews/{id} requests
+func (h *ProductHandler) ModerateReview(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	var params genhttp.ModerateReviewJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	review, err := h.reviewUseCase.SetStatus(r.Context(), id, entity.ReviewStatus(params.Status))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.ProductReviewResponse{
+		Code:       "success",
+		Data:       mapReviewToResponse(review),
+		Message:    "Review moderated successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// DeleteReview handles DELETE /reviews/{id} requests
+func (h *ProductHandler) DeleteReview(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	if err := h.reviewUseCase.Delete(r.Context(), id); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.StandardResponse{
+		Code:       "success",
+		Data:       map[string]interface{}{},
+		Message:    "Review deleted successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ImportProducts handles POST /products/import requests
+func (h *ProductHandler) ImportProducts(w http.ResponseWriter, r *http.Request, params genhttp.ImportProductsParams) {
+	ctx := r.Context()
+
+	format, err := importFileFormat(r.Header.Get("Content-Type"))
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	mode := entity.ProductImportModePartial
+	if params.Mode != nil {
+		mode = entity.ProductImportMode(*params.Mode)
+	}
+
+	file := http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	result, err := h.productUseCase.Import(ctx, format, file, mode)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	message := "Products imported successfully"
+	switch {
+	case mode == entity.ProductImportModeDryRun:
+		message = "Products validated, nothing was saved"
+	case mode == entity.ProductImportModeAtomic && len(result.Errors) > 0:
+		message = "Import rejected, nothing was saved"
+	case len(result.Errors) > 0:
+		message = "Products imported with errors"
+	}
+
+	importErrors := make([]genhttp.ProductImportError, len(result.Errors))
+	for i, importError := range result.Errors {
+		importErrors[i] = genhttp.ProductImportError{
+			Line:    importError.Line,
+			Message: importError.Message,
+		}
+		if importError.SKU != "" {
+			sku := importError.SKU
+			importErrors[i].Sku = &sku
+		}
+	}
+
+	response := genhttp.ProductImportResponse{
+		Code: "success",
+		Data: genhttp.ProductImportResult{
+			Mode:    genhttp.ProductImportResultMode(result.Mode),
+			Rows:    result.Rows,
+			Created: result.Created,
+			Updated: result.Updated,
+			Errors:  importErrors,
+		},
+		Message:    message,
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// ExportProducts handles GET /products/export requests
+func (h *ProductHandler) ExportProducts(w http.ResponseWriter, r *http.Request, params genhttp.ExportProductsParams) {
+	ctx := r.Context()
+
+	format := entity.ProductFileFormatCSV
+	if params.Format != nil {
+		format = entity.ProductFileFormat(*params.Format)
+	}
+	if !format.IsValid() {
+		handleError(w, errs.NewBadRequest(fmt.Sprintf("unsupported file format %q, use csv or ndjson", format)))
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == entity.ProductFileFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// The status is only sent with the first rows, so an export failing before them still gets an
+	// error response
+	stream := &exportWriter{w: w}
+	if err := h.productUseCase.Export(ctx, format, stream); err != nil {
+		if stream.written {
+			middleware.Logger.Error("Product export stopped", "error", err.Error())
+			return
+		}
+		w.Header().Del("Content-Disposition")
+		handleError(w, err)
+	}
+}
+
+// ListCategories handles GET /categories requests
+func (h *ProductHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoryUseCase.List(r.Context())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	categoriesData := make([]genhttp.Category, len(categories))
+	for i, category := range categories {
+		categoriesData[i] = mapCategoryToResponse(category)
+	}
+
+	response := genhttp.CategoryListResponse{
+		Code: "success",
+		Data: struct {
+			Categories *[]genhttp.Category `json:"categories,omitempty"`
+		}{
+			Categories: &categoriesData,
+		},
+		Message:    "Categories retrieved successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetCategory handles GET /categories/{id} requests
+func (h *ProductHandler) GetCategory(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	category, err := h.categoryUseCase.GetByID(r.Context(), id)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.CategoryResponse{
+		Code:       "success",
+		Data:       mapCategoryToResponse(category),
+		Message:    "Category retrieved successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// CreateCategory handles POST /categories requests
+func (h *ProductHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var params genhttp.CreateCategoryJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	category, err := h.categoryUseCase.Create(ctx, params.ParentId, params.Name, params.Slug)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.CategoryResponse{
+		Code:       "success",
+		Data:       mapCategoryToResponse(category),
+		Message:    "Category created successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// UpdateCategory handles PUT /categories/{id} requests
+func (h *ProductHandler) UpdateCategory(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	var params genhttp.UpdateCategoryJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		handleError(w, errs.NewBadRequest("Invalid request body"))
+		return
+	}
+
+	category, err := h.categoryUseCase.Update(ctx, id, params.ParentId, params.Name, params.Slug)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.CategoryResponse{
+		Code:       "success",
+		Data:       mapCategoryToResponse(category),
+		Message:    "Category updated successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// DeleteCategory handles DELETE /categories/{id} requests
+func (h *ProductHandler) DeleteCategory(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	if err := h.categoryUseCase.Delete(r.Context(), id); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.StandardResponse{
+		Code:       "success",
+		Data:       map[string]interface{}{},
+		Message:    "Category deleted successfully",
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// Helper functions
+
+// mapProductToResponse maps a product entity to its API representation. A parent comes with its
+// options and variant matrix, a variant with its option values and price override.
+func mapProductToResponse(product *entity.Product) genhttp.Product {
+	price := product.Price.Float64()
+	inventory := product.TotalInventory()
+	categoryIDs := product.CategoryIDs
+	if categoryIDs == nil {
+		categoryIDs = []uuid.UUID{}
+	}
+
+	response := genhttp.Product{
+		Id:          &product.ID,
+		Sku:         &product.SKU,
+		Name:        &product.Name,
+		Description: &product.Description,
+		Price:       &price,
+		Inventory:   &inventory,
+		CategoryIds: &categoryIDs,
+	}
+
+	if !product.IsVariant() {
+		images := mapImagesToResponse(product.Images)
+		reviewCount := product.Rating.Count
+		response.Images = &images
+		response.ReviewCount = &reviewCount
+		if reviewCount > 0 {
+			averageRating := product.Rating.Average
+			response.AverageRating = &averageRating
+		}
+	}
+
+	if product.HasVariants() {
+		options := make([]genhttp.ProductOption, len(product.Options))
+		for i, option := range product.Options {
+			options[i] = genhttp.ProductOption{
+				Name:   option.Name,
+				Values: option.Values,
+			}
+		}
+		variants := make([]genhttp.Product, len(product.Variants))
+		for i, variant := range product.Variants {
+			variants[i] = mapProductToResponse(variant)
+		}
+		response.Options = &options
+		response.Variants = &variants
+	}
+
+	if product.IsVariant() {
+		optionValues := map[string]string(product.OptionValues)
+		response.ParentId = product.ParentID
+		response.OptionValues = &optionValues
+		if product.PriceOverride != nil {
+			priceOverride := product.PriceOverride.Float64()
+			response.PriceOverride = &priceOverride
+		}
+	}
+
+	return response
+}
+
+// mapImagesToResponse maps the images of a product to their API representation, in order
+func mapImagesToResponse(images []*entity.ProductImage) []genhttp.ProductImage {
+	response := make([]genhttp.ProductImage, len(images))
+	for i, image := range images {
+		response[i] = mapImageToResponse(image)
+	}
+	return response
+}
+
+// mapImageToResponse maps a product image to its API representation
+func mapImageToResponse(image *entity.ProductImage) genhttp.ProductImage {
+	return genhttp.ProductImage{
+		Id:           image.ID,
+		Position:     image.Position,
+		Url:          image.URL,
+		ThumbnailUrl: image.ThumbnailURL,
+		ContentType:  image.ContentType,
+		Width:        image.Width,
+		Height:       image.Height,
+		Size:         image.Size,
+		AltText:      image.AltText,
+		CreatedAt:    image.CreatedAt,
+	}
+}
+
+// mapPriceToResponse maps a product price to its API representation
+func mapPriceToResponse(price *entity.ProductPrice) genhttp.ProductPrice {
+	return genhttp.ProductPrice{
+		Id:            price.ID,
+		ProductId:     price.ProductID,
+		Price:         price.Price.Float64(),
+		EffectiveFrom: price.EffectiveFrom,
+		EffectiveTo:   price.EffectiveTo,
+		CreatedAt:     price.CreatedAt,
+	}
+}
+
+// mapReviewPageRequest builds the page request of a review list, 10 reviews from the first page
+// by default
+func mapReviewPageRequest(page, limit *int, cursor *string, includeTotal *bool) (pagination.Request, error) {
+	pageNumber := 1
+	if page != nil {
+		pageNumber = *page
+	}
+	pageLimit := 10
+	if limit != nil {
+		pageLimit = *limit
+	}
+	var pageCursor string
+	if cursor != nil {
+		pageCursor = *cursor
+	}
+
+	req, err := pagination.NewRequest(pageNumber, pageLimit, pageCursor, includeTotal)
+	if err != nil {
+		return pagination.Request{}, errs.NewBadRequest("invalid cursor")
+	}
+	return req, nil
+}
+
+// mapReviewListToResponse maps a page of reviews to its API representation
+func mapReviewListToResponse(reviews []*entity.ProductReview, page pagination.Result) genhttp.ProductReviewListResponse {
+	reviewsData := make([]genhttp.ProductReview, len(reviews))
+	for i, review := range reviews {
+		reviewsData[i] = mapReviewToResponse(review)
+	}
+
+	return genhttp.ProductReviewListResponse{
+		Code: "success",
+		Data: struct {
+			NextCursor *string                  `json:"next_cursor,omitempty"`
+			PrevCursor *string                  `json:"prev_cursor,omitempty"`
+			Reviews    *[]genhttp.ProductReview `json:"reviews,omitempty"`
+			Total      *int                     `json:"total,omitempty"`
+		}{
+			NextCursor: page.NextCursor,
+			PrevCursor: page.PrevCursor,
+			Reviews:    &reviewsData,
+			Total:      page.Total,
+		},
+		Message:    "Reviews retrieved successfully",
+		ServerTime: time.Now(),
+	}
+}
+
+// mapReviewToResponse maps a product review to its API representation
+func mapReviewToResponse(review *entity.ProductReview) genhttp.ProductReview {
+	return genhttp.ProductReview{
+		Id:         review.ID,
+		ProductId:  review.ProductID,
+		AuthorName: review.AuthorName,
+		Rating:     review.Rating,
+		Body:       review.Body,
+		Status:     string(review.Status),
+		CreatedAt:  review.CreatedAt,
+		UpdatedAt:  review.UpdatedAt,
+	}
+}
+
+// mapOptionsRequest maps requested option definitions; nil when none were given
+func mapOptionsRequest(options *[]genhttp.ProductOption) entity.ProductOptions {
+	if options == nil {
+		return nil
+	}
+	mapped := make(entity.ProductOptions, len(*options))
+	for i, option := range *options {
+		mapped[i] = entity.ProductOption{
+			Name:   option.Name,
+			Values: option.Values,
+		}
+	}
+	return mapped
+}
+
+// mapPriceRequest maps an optional requested price, such as a variant price that follows the
+// parent's when nil
+func mapPriceRequest(price *float64) *money.Money {
+	if price == nil {
+		return nil
+	}
+	amount := money.FromFloat(*price)
+	return &amount
+}
+
+// mapFacetsToResponse maps the facet counts of a product list to their API representation
+func mapFacetsToResponse(facets *entity.ProductFacets) genhttp.ProductFacets {
+	response := genhttp.ProductFacets{
+		Categories: make([]genhttp.CategoryFacet, len(facets.Categories)),
+		Prices:     make([]genhttp.PriceFacet, len(facets.Prices)),
+		Stock: genhttp.StockFacet{
+			InStock:    facets.Stock.InStock,
+			OutOfStock: facets.Stock.OutOfStock,
+		},
+		Attributes: make([]genhttp.AttributeFacet, len(facets.Attributes)),
+	}
+
+	for i, category := range facets.Categories {
+		response.Categories[i] = genhttp.CategoryFacet{
+			Id:    category.ID,
+			Name:  category.Name,
+			Slug:  category.Slug,
+			Count: category.Count,
+		}
+	}
+
+	for i, price := range facets.Prices {
+		response.Prices[i] = genhttp.PriceFacet{
+			Min:   price.Min.Float64(),
+			Count: price.Count,
+		}
+		if price.Max != nil {
+			upper := price.Max.Float64()
+			response.Prices[i].Max = &upper
+		}
+	}
+
+	for i, attribute := range facets.Attributes {
+		values := make([]genhttp.AttributeValueFacet, len(attribute.Values))
+		for j, value := range attribute.Values {
+			values[j] = genhttp.AttributeValueFacet{
+				Value: value.Value,
+				Count: value.Count,
+			}
+		}
+		response.Attributes[i] = genhttp.AttributeFacet{
+			Name:   attribute.Name,
+			Values: values,
+		}
+	}
+
+	return response
+}
+
+// mapCategoryToResponse maps a category entity to its API representation
+func mapCategoryToResponse(category *entity.Category) genhttp.Category {
+	return genhttp.Category{
+		Id:        &category.ID,
+		ParentId:  category.ParentID,
+		Name:      &category.Name,
+		Slug:      &category.Slug,
+		CreatedAt: &category.CreatedAt,
+		UpdatedAt: &category.UpdatedAt,
+	}
+}
+
+// maxImportFileSize is the largest product import file accepted, in bytes
+const maxImportFileSize = 20 << 20
+
+// maxImageUploadSize is the largest image upload request accepted, in bytes: the largest image
+// with room for the rest of the multipart form
+const maxImageUploadSize = usecase.MaxImageSize + 1<<20
+
+// maxImageUploadMemory is the part of an image upload held in memory; the rest goes to a
+// temporary file
+const maxImageUploadMemory = 1 << 20
+
+// importFileFormat returns the file format of an import request from its content type
+func importFileFormat(contentType string) (entity.ProductFileFormat, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		switch mediaType {
+		case "text/csv":
+			return entity.ProductFileFormatCSV, nil
+		case "application/x-ndjson":
+			return entity.ProductFileFormatNDJSON, nil
+		}
+	}
+	return "", errs.New(
+		errors.New("unsupported media type"),
+		"unsupported_media_type",
+		http.StatusUnsupportedMediaType,
+		fmt.Sprintf("unsupported content type %q, use text/csv or application/x-ndjson", contentType),
+	)
+}
+
+// limitedBody reads from a request body capped by http.MaxBytesReader and reports a body over the
+// cap with a domain error, so use cases reading it need not know about net/http
+type limitedBody struct {
+	r       io.Reader
+	tooLong error
+}
+
+// Read implements io.Reader
+func (l limitedBody) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return n, l.tooLong
+	}
+	return n, err
+}
+
+// exportWriter records whether any part of an export reached the response
+type exportWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+// Write implements io.Writer
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.written = true
+	return e.w.Write(p)
+}
 
 // respondJSON sends a JSON response
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -2,8 +2,11 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -12,15 +15,136 @@ type ProductRepository interface {
 	// GetByID retrieves a product by its ID
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Product, error)
 
-	// List retrieves a list of products with pagination and filtering
-	List(ctx context.Context, page, limit int, sku, name string) ([]*entity.Product, int, error)
+	// GetBySKUs retrieves the products with the given SKUs; SKUs without a product are left out
+	GetBySKUs(ctx context.Context, skus []string) ([]*entity.Product, error)
+
+	// List retrieves a page of products matching the filter, in the order of its sort. The page is
+	// selected by number or by a cursor issued for the same sort.
+	List(ctx context.Context, req pagination.Request, filter entity.ProductFilter) ([]*entity.Product, pagination.Result, error)
+
+	// Facets counts the products matching the filter for every value of each filter
+	Facets(ctx context.Context, filter entity.ProductFilter) (*entity.ProductFacets, error)
 
 	// Create creates a new product
 	Create(ctx context.Context, product *entity.Product) error
 
-	// Update updates an existing product
+	// Update updates an existing product, its price history and the variants loaded with a parent.
+	// Callers run it in a transaction so they are saved together.
 	Update(ctx context.Context, product *entity.Product) error
 
 	// Delete deletes a product by its ID
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// GetByIDsForUpdate retrieves products by their IDs and locks the rows until the
+	// surrounding transaction ends. It must be called inside RunInTransaction.
+	GetByIDsForUpdate(ctx context.Context, ids []uuid.UUID) ([]*entity.Product, error)
+
+	// UpdateInventory sets the inventory of a product
+	UpdateInventory(ctx context.Context, id uuid.UUID, inventory int) error
+
+	// SetCategories replaces the categories a product is listed in. It locks the product row, so
+	// callers run it in a transaction.
+	SetCategories(ctx context.Context, productID uuid.UUID, categoryIDs []uuid.UUID) error
+
+	// UpsertBySKU creates a standalone product from an import row, or updates the standalone product
+	// with its SKU, and reports whether it was created. It returns ErrProductSKUAlreadyExists when
+	// the SKU belongs to a deleted product, a parent or a variant.
+	UpsertBySKU(ctx context.Context, row *entity.ProductImportRow) (bool, error)
+
+	// GetPriceAt returns the price of a product in effect at a moment
+	GetPriceAt(ctx context.Context, id uuid.UUID, at time.Time) (money.Money, error)
+
+	// ListPrices returns the past and scheduled prices of a standalone or parent product, latest
+	// start first
+	ListPrices(ctx context.Context, productID uuid.UUID) ([]*entity.ProductPrice, error)
+
+	// SchedulePrice saves a price of a standalone or parent product. An open-ended price ends the
+	// open-ended prices starting before it.
+	SchedulePrice(ctx context.Context, price *entity.ProductPrice) error
+
+	// ApplyScheduledPrices sets the price column of every product to its price in effect and
+	// returns the number of products changed
+	ApplyScheduledPrices(ctx context.Context) (int, error)
+
+	// ForEachStandalone calls fn with every standalone product, in SKU order, as the rows are read;
+	// an error from fn stops it and is returned
+	ForEachStandalone(ctx context.Context, fn func(*entity.Product) error) error
+}
+
+// ProductSearcher finds products matching a free-text query
+type ProductSearcher interface {
+	// Search returns the products matching query, most relevant first, with the total number of
+	// matches. Every word matches by prefix, and close misspellings of a product name still match.
+	Search(ctx context.Context, query string, page, limit int) ([]*entity.SearchResult, int, error)
+}
+
+// CategoryRepository defines the interface for the category tree
+type CategoryRepository interface {
+	// GetByID retrieves a category by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Category, error)
+
+	// GetBySlug retrieves a category by its slug
+	GetBySlug(ctx context.Context, slug string) (*entity.Category, error)
+
+	// GetByIDs retrieves the categories with the given IDs; IDs without a category are left out
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.Category, error)
+
+	// List retrieves every category, parents before their children
+	List(ctx context.Context) ([]*entity.Category, error)
+
+	// GetDescendantIDs retrieves the IDs of every category below the given one
+	GetDescendantIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+
+	// LockForMove locks a category, its new parent and the ancestors of the parent until the
+	// end of the transaction
+	LockForMove(ctx context.Context, id, parentID uuid.UUID) error
+
+	// Create creates a new category
+	Create(ctx context.Context, category *entity.Category) error
+
+	// Update updates the parent, name and slug of a category
+	Update(ctx context.Context, category *entity.Category) error
+
+	// Delete deletes a category without children and unlinks its products
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// ProductImageRepository defines the interface for the images of products. It stores the image
+// records only; the files live in a blob store.
+type ProductImageRepository interface {
+	// ListByProduct retrieves the images of a product in display order
+	ListByProduct(ctx context.Context, productID uuid.UUID) ([]*entity.ProductImage, error)
+
+	// Create saves an image after the other images of its product and sets its position
+	Create(ctx context.Context, image *entity.ProductImage) error
+
+	// Delete deletes an image of a product, moving the images after it up one position, and
+	// returns the deleted image
+	Delete(ctx context.Context, productID, imageID uuid.UUID) (*entity.ProductImage, error)
+
+	// Reorder puts the images of a product in the order of imageIDs, which lists every one of them
+	Reorder(ctx context.Context, productID uuid.UUID, imageIDs []uuid.UUID) error
+}
+
+// ProductReviewRepository defines the interface for the reviews of products
+type ProductReviewRepository interface {
+	// GetByID retrieves a review by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.ProductReview, error)
+
+	// List retrieves a page of reviews matching the filter, newest first, by page number or cursor
+	List(ctx context.Context, req pagination.Request, filter entity.ReviewFilter) ([]*entity.ProductReview, pagination.Result, error)
+
+	// Create saves a review. It fails with ErrReviewAlreadyExists when the customer has reviewed the
+	// product before.
+	Create(ctx context.Context, review *entity.ProductReview) error
+
+	// UpdateStatus sets the moderation status of a review and returns the updated review
+	UpdateStatus(ctx context.Context, id uuid.UUID, status entity.ReviewStatus) (*entity.ProductReview, error)
+
+	// Delete deletes a review
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// HasDelivered reports whether a delivered order of the user contains the product or one of its
+	// variants
+	HasDelivered(ctx context.Context, userID, productID uuid.UUID) (bool, error)
 }
@@ -0,0 +1,221 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+)
+
+// Weights of a matched term, mirroring the tsvector weights of the Postgres searcher: the SKU and
+// name weigh more than the description, and a misspelt name word weighs least
+const (
+	memoryNameWeight        = 1.0
+	memoryDescriptionWeight = 0.4
+	memoryTypoWeight        = 0.3
+)
+
+// snippetWords is the number of words a snippet shows
+const snippetWords = 25
+
+var _ ProductSearcher = (*InMemoryProductSearcher)(nil)
+
+// InMemoryProductSearcher implements ProductSearcher over a fixed set of products, for tests and
+// tools that run without a database. It follows the rules of the Postgres searcher: every term
+// must match a word of the SKU, name or description by prefix, or be one or two edits away from a
+// name word. Ranks are comparable between results of one searcher only.
+type InMemoryProductSearcher struct {
+	mu       sync.RWMutex
+	products []*entity.Product
+}
+
+// NewInMemoryProductSearcher creates an in-memory searcher over the given products
+func NewInMemoryProductSearcher(products ...*entity.Product) *InMemoryProductSearcher {
+	searcher := &InMemoryProductSearcher{}
+	for _, product := range products {
+		searcher.Put(product)
+	}
+	return searcher
+}
+
+// Put adds a product, replacing the product with the same ID
+func (s *InMemoryProductSearcher) Put(product *entity.Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.products {
+		if existing.ID == product.ID {
+			s.products[i] = product
+			return
+		}
+	}
+	s.products = append(s.products, product)
+}
+
+// Search returns the products matching query, most relevant first
+func (s *InMemoryProductSearcher) Search(ctx context.Context, query string, page, limit int) ([]*entity.SearchResult, int, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*entity.SearchResult{}, 0, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := []*entity.SearchResult{}
+	for _, product := range s.products {
+		if product.DeletedAt != nil || product.IsVariant() {
+			continue
+		}
+		rank, ok := rankProduct(product, terms)
+		if !ok {
+			continue
+		}
+		matches = append(matches, &entity.SearchResult{
+			Product: product,
+			Rank:    rank,
+			Snippet: highlight(snippetText(product), terms),
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].Product.Name < matches[j].Product.Name
+	})
+
+	total := len(matches)
+	start := (page - 1) * limit
+	if start < 0 || start >= total {
+		return []*entity.SearchResult{}, total, nil
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	return matches[start:end], total, nil
+}
+
+// rankProduct scores a product against every term; ok is false when a term does not match
+func rankProduct(product *entity.Product, terms []string) (rank float64, ok bool) {
+	nameWords := searchWords(product.SKU + " " + product.Name)
+	for _, variant := range product.Variants {
+		nameWords = append(nameWords, searchWords(variant.SKU)...)
+	}
+	descriptionWords := searchWords(product.Description)
+
+	for _, term := range terms {
+		switch {
+		case hasPrefixWord(nameWords, term):
+			rank += memoryNameWeight
+		case hasPrefixWord(descriptionWords, term):
+			rank += memoryDescriptionWeight
+		case hasSimilarWord(nameWords, term):
+			rank += memoryTypoWeight
+		default:
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+// searchWords splits text into lower-case words the way searchTerms splits a query
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// hasPrefixWord reports whether a word starts with term
+func hasPrefixWord(words []string, term string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSimilarWord reports whether a word is close enough to term to be a typo of it: one edit for
+// words of up to five letters, two for longer ones, and none for very short words
+func hasSimilarWord(words []string, term string) bool {
+	maxEdits := 1
+	switch length := len([]rune(term)); {
+	case length < 3:
+		return false
+	case length > 5:
+		maxEdits = 2
+	}
+	for _, word := range words {
+		if editDistance(word, term) <= maxEdits {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// snippetText is the text a snippet is taken from, like the Postgres headline document
+func snippetText(product *entity.Product) string {
+	if product.Description == "" {
+		return product.Name
+	}
+	return product.Name + ". " + product.Description
+}
+
+// highlight marks the words of text that start with a term, showing at most snippetWords words
+// from the first marked one
+func highlight(text string, terms []string) string {
+	words := strings.Fields(text)
+	first := -1
+	for i, word := range words {
+		normalized := searchWords(word)
+		for _, term := range terms {
+			if hasPrefixWord(normalized, term) {
+				words[i] = entity.SnippetStartSel + word + entity.SnippetStopSel
+				if first < 0 {
+					first = i
+				}
+				break
+			}
+		}
+	}
+
+	start := 0
+	if first > 0 && len(words) > snippetWords {
+		start = first
+		if start+snippetWords > len(words) {
+			start = len(words) - snippetWords
+		}
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+	return strings.Join(words[start:end], " ")
+}
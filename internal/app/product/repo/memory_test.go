@@ -0,0 +1,257 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	"github.com/google/uuid"
+)
+
+func searchProduct(sku, name, description string) *entity.Product {
+	return &entity.Product{
+		ID:          uuid.New(),
+		SKU:         sku,
+		Name:        name,
+		Description: description,
+	}
+}
+
+// searchNames runs a search and returns the names of the products found, in order
+func searchNames(t *testing.T, searcher *InMemoryProductSearcher, query string) []string {
+	t.Helper()
+
+	results, total, err := searcher.Search(context.Background(), query, 1, 50)
+	if err != nil {
+		t.Fatalf("Search(%q) returned error: %v", query, err)
+	}
+	if total != len(results) {
+		t.Fatalf("Search(%q) total = %d, want %d", query, total, len(results))
+	}
+
+	names := make([]string, 0, len(results))
+	for _, result := range results {
+		names = append(names, result.Product.Name)
+	}
+	return names
+}
+
+func TestInMemoryProductSearcherRanking(t *testing.T) {
+	deletedAt := time.Now()
+	deleted := searchProduct("MBP-OLD", "MacBook Pro 2015", "Discontinued laptop")
+	deleted.DeletedAt = &deletedAt
+
+	parent := searchProduct("TEE", "Laptop Sleeve", "Padded sleeve")
+	variant := searchProduct("TEE-13", "Laptop Sleeve 13 inch", "Padded sleeve")
+	variant.ParentID = &parent.ID
+	parent.Variants = []*entity.Product{variant}
+
+	searcher := NewInMemoryProductSearcher(
+		searchProduct("CASE-01", "Hard Case", "Fits every MacBook Pro and MacBook Air"),
+		searchProduct("MBP-14", "MacBook Pro", "Apple laptop with an M3 chip"),
+		searchProduct("MBA-13", "MacBook Air", "Thin and light laptop"),
+		deleted,
+		parent,
+		variant,
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "name matches rank above description matches",
+			query: "macbook pro",
+			want:  []string{"MacBook Pro", "Hard Case"},
+		},
+		{
+			name:  "ties are ordered by name",
+			query: "macbook",
+			want:  []string{"MacBook Air", "MacBook Pro", "Hard Case"},
+		},
+		{
+			name:  "every term must match",
+			query: "macbook banana",
+			want:  []string{},
+		},
+		{
+			name:  "sku words match like name words",
+			query: "mba-13",
+			want:  []string{"MacBook Air"},
+		},
+		{
+			name:  "variant skus find their parent",
+			query: "tee 13",
+			want:  []string{"Laptop Sleeve"},
+		},
+		{
+			name:  "deleted products and variants are left out",
+			query: "laptop",
+			want:  []string{"Laptop Sleeve", "MacBook Air", "MacBook Pro"},
+		},
+		{
+			name:  "query without words finds nothing",
+			query: "!!! --",
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchNames(t, searcher, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryProductSearcherPrefixAndTypos(t *testing.T) {
+	searcher := NewInMemoryProductSearcher(
+		searchProduct("PH-15", "iPhone 15", "Smartphone with a titanium frame"),
+		searchProduct("CBL-1", "USB Cable", "Braided charging cable"),
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "prefix of a name word", query: "iph", want: []string{"iPhone 15"}},
+		{name: "prefix of a description word", query: "titan", want: []string{"iPhone 15"}},
+		{name: "prefix is case insensitive", query: "USB CAB", want: []string{"USB Cable"}},
+		{name: "one edit in a short word", query: "cablr", want: []string{"USB Cable"}},
+		{name: "two edits in a long word", query: "ipohne", want: []string{"iPhone 15"}},
+		{name: "three edits are too many", query: "ipxxxe", want: []string{}},
+		{name: "two edits in a short word are too many", query: "cabxx", want: []string{}},
+		{name: "very short words need an exact prefix", query: "ub", want: []string{}},
+		{name: "typos only match name words", query: "smartphoen", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchNames(t, searcher, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// A typo ranks below an exact prefix
+	searcher.Put(searchProduct("CBL-2", "Cables Organizer", "Keeps desks tidy"))
+	if got, want := searchNames(t, searcher, "cables"), []string{"Cables Organizer", "USB Cable"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Search(%q) = %q, want %q", "cables", got, want)
+	}
+}
+
+func TestInMemoryProductSearcherPaging(t *testing.T) {
+	searcher := NewInMemoryProductSearcher(
+		searchProduct("A", "Mug A", ""),
+		searchProduct("B", "Mug B", ""),
+		searchProduct("C", "Mug C", ""),
+	)
+
+	results, total, err := searcher.Search(context.Background(), "mug", 2, 2)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if total != 3 || len(results) != 1 || results[0].Product.Name != "Mug C" {
+		t.Errorf("page 2 = %d results of %d, want Mug C of 3", len(results), total)
+	}
+
+	results, total, err = searcher.Search(context.Background(), "mug", 3, 2)
+	if err != nil {
+		t.Fatalf("Search returned error: %v", err)
+	}
+	if total != 3 || len(results) != 0 {
+		t.Errorf("page 3 = %d results of %d, want none of 3", len(results), total)
+	}
+}
+
+func TestInMemoryProductSearcherSnippet(t *testing.T) {
+	longDescription := strings.Repeat("filler ", 40) + "waterproof shell " + strings.Repeat("more ", 40)
+
+	tests := []struct {
+		name    string
+		product *entity.Product
+		query   string
+		want    string
+	}{
+		{
+			name:    "matched words are marked",
+			product: searchProduct("J-1", "Rain Jacket", "Light and packable"),
+			query:   "rain pack",
+			want:    "<mark>Rain</mark> Jacket. Light and <mark>packable</mark>",
+		},
+		{
+			name:    "punctuation stays inside the marker",
+			product: searchProduct("J-2", "Down Jacket", "Warm"),
+			query:   "jacket",
+			want:    "Down <mark>Jacket.</mark> Warm",
+		},
+		{
+			name:    "typo matches are not marked",
+			product: searchProduct("J-3", "Fleece", "Soft"),
+			query:   "fleese",
+			want:    "Fleece. Soft",
+		},
+		{
+			name:    "long text starts at the first match",
+			product: searchProduct("J-4", "Shell", longDescription),
+			query:   "waterproof",
+			want:    "<mark>waterproof</mark> shell " + strings.TrimSpace(strings.Repeat("more ", snippetWords-2)),
+		},
+		{
+			name:    "a match near the end keeps a full snippet",
+			product: searchProduct("J-5", "Parka", strings.Repeat("filler ", 40)+"hooded"),
+			query:   "hood",
+			want:    strings.Repeat("filler ", snippetWords-1) + "<mark>hooded</mark>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searcher := NewInMemoryProductSearcher(tt.product)
+			results, _, err := searcher.Search(context.Background(), tt.query, 1, 10)
+			if err != nil {
+				t.Fatalf("Search returned error: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("Search(%q) found %d products, want 1", tt.query, len(results))
+			}
+			if results[0].Snippet != tt.want {
+				t.Errorf("snippet = %q, want %q", results[0].Snippet, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "MacBook Pro", want: []string{"macbook", "pro"}},
+		{query: "  iPhone-15  Pro!! ", want: []string{"iphone", "15", "pro"}},
+		{query: "Café Crème", want: []string{"café", "crème"}},
+		{query: "red & blue | !green", want: []string{"red", "blue", "green"}},
+		{query: "' OR 1=1; --", want: []string{"or", "1", "1"}},
+		{query: "a:* <-> b", want: []string{"a", "b"}},
+		{query: "one two three four five six seven eight nine ten eleven twelve",
+			want: []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten"}},
+		{query: "", want: []string{}},
+		{query: "?!", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		got := searchTerms(tt.query)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -5,15 +5,76 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
 	domainErrors "github.com/fanzru/e-commerce-be/internal/app/product/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// productColumns are the columns scanned by scanProduct. The price is the one in effect, which the
+// price column only catches up with when the scheduler applies it.
+const productColumns = `id, sku, name, description, product_price_at(products.id, NOW()) AS price, inventory, parent_id, options, option_values, price_override, ` + productCategoryIDsColumn
+
+// recordProductPriceQuery starts the price $2 of product $1 now when it differs from the price in
+// effect, ending the open-ended prices that started before
+const recordProductPriceQuery = `
+		WITH current AS (
+			SELECT price FROM product_prices
+			WHERE product_id = $1 AND effective_from <= NOW() AND (effective_to IS NULL OR effective_to > NOW())
+			ORDER BY effective_from DESC, created_at DESC
+			LIMIT 1
+		), changed AS (
+			SELECT 1 WHERE NOT EXISTS (SELECT 1 FROM current WHERE price = $2)
+		), ended AS (
+			UPDATE product_prices SET effective_to = NOW()
+			WHERE product_id = $1 AND effective_to IS NULL AND effective_from < NOW() AND EXISTS (SELECT 1 FROM changed)
+		)
+		INSERT INTO product_prices (product_id, price, effective_from)
+		SELECT $1, $2, NOW() FROM changed
+	`
+
+// productImageColumns are the columns scanned by scanProductImage
+const productImageColumns = `id, product_id, position, blob_key, thumbnail_key, content_type, width, height, size_bytes, alt_text, created_at`
+
+// productReviewColumns are the columns scanned by scanProductReview, from product_reviews aliased
+// reviews joined with the users who wrote them
+const productReviewColumns = `reviews.id, reviews.product_id, reviews.user_id, COALESCE(users.name, ''), reviews.rating, reviews.body, reviews.status, reviews.created_at, reviews.updated_at`
+
+// productReviewKeyset orders review listings newest first
+var productReviewKeyset = pagination.Keyset{
+	Name: "newest",
+	Columns: []pagination.Column{
+		{Expr: "reviews.created_at", Desc: true},
+		{Expr: "reviews.id", Desc: true},
+	},
+}
+
+// productCategoryIDsColumn selects the categories of the product row as a uuid array
+const productCategoryIDsColumn = `ARRAY(
+			SELECT category_id FROM product_categories
+			WHERE product_id = products.id
+			ORDER BY category_id
+		) AS category_ids`
+
+// categorySubtreeQuery selects the ID of a category and of every category below it; the
+// placeholder is formatted with the position of the category ID argument. UNION drops categories
+// already visited, so the query ends even on a tree corrupted into a cycle.
+const categorySubtreeQuery = `WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = $%d
+			UNION
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT id FROM subtree`
+
 // ProductPostgresRepository implements ProductRepository using PostgreSQL
 type ProductPostgresRepository struct {
 	db *sql.DB
@@ -36,19 +97,12 @@ func (r *ProductPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (
 	startTime := time.Now()
 
 	query := `
-		SELECT id, sku, name, price, inventory
+		SELECT ` + productColumns + `
 		FROM products
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	var product entity.Product
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&product.ID,
-		&product.SKU,
-		&product.Name,
-		&product.Price,
-		&product.Inventory,
-	)
+	product, err := scanProduct(r.db.QueryRowContext(ctx, query, id))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -59,6 +113,25 @@ func (r *ProductPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (
 		return nil, fmt.Errorf("error querying product by ID: %w", err)
 	}
 
+	if product.HasVariants() {
+		variants, err := r.getVariants(ctx, []uuid.UUID{product.ID})
+		if err != nil {
+			logger.Error("Failed to query product variants", "error", err.Error())
+			return nil, err
+		}
+		product.Variants = variants[product.ID]
+	}
+
+	if err := r.attachImages(ctx, []*entity.Product{product}); err != nil {
+		logger.Error("Failed to query product images", "error", err.Error())
+		return nil, err
+	}
+
+	if err := r.attachRatings(ctx, []*entity.Product{product}); err != nil {
+		logger.Error("Failed to query product ratings", "error", err.Error())
+		return nil, err
+	}
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved product",
 		"sku", product.SKU,
@@ -67,88 +140,144 @@ func (r *ProductPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (
 		"inventory", product.Inventory,
 		"duration_ms", duration.Milliseconds())
 
-	return &product, nil
+	return product, nil
 }
 
-// List retrieves a list of products with pagination and filtering
-func (r *ProductPostgresRepository) List(ctx context.Context, page, limit int, sku, name string) ([]*entity.Product, int, error) {
+// GetBySKUs retrieves the products with the given SKUs
+func (r *ProductPostgresRepository) GetBySKUs(ctx context.Context, skus []string) ([]*entity.Product, error) {
 	logger := middleware.Logger.With(
-		"method", "ProductRepository.List",
-		"page", page,
-		"limit", limit,
+		"method", "ProductRepository.GetBySKUs",
+		"sku_count", len(skus),
 	)
-	logger.Debug("Listing products with filters")
+	logger.Debug("Fetching products by SKU")
 	startTime := time.Now()
 
-	offset := (page - 1) * limit
+	if len(skus) == 0 {
+		return []*entity.Product{}, nil
+	}
+
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE sku = ANY($1) AND deleted_at IS NULL
+		ORDER BY sku
+	`
 
-	// Base query for filtering
-	whereClause := "WHERE deleted_at IS NULL"
-	args := []interface{}{}
-	argPos := 1
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, pq.Array(skus))
+	if err != nil {
+		logger.Error("Failed to query products by SKU", "error", err.Error())
+		return nil, fmt.Errorf("error querying products by SKU: %w", err)
+	}
+	defer rows.Close()
 
-	// Add filters if provided
-	if sku != "" {
-		whereClause += fmt.Sprintf(" AND sku ILIKE $%d", argPos)
-		args = append(args, "%"+sku+"%")
-		argPos++
-		logger = logger.With("filter_sku", sku)
+	products := []*entity.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			logger.Error("Failed to scan product row", "error", err.Error())
+			return nil, fmt.Errorf("error scanning product row: %w", err)
+		}
+		products = append(products, product)
 	}
 
-	if name != "" {
-		whereClause += fmt.Sprintf(" AND name ILIKE $%d", argPos)
-		args = append(args, "%"+name+"%")
-		argPos++
-		logger = logger.With("filter_name", name)
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate product rows", "error", err.Error())
+		return nil, fmt.Errorf("error iterating product rows: %w", err)
 	}
 
-	// Count total matches first
-	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM products %s`, whereClause)
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved products by SKU",
+		"found_count", len(products),
+		"duration_ms", duration.Milliseconds())
+
+	return products, nil
+}
+
+// List retrieves a page of products matching the filter, in the order of its sort
+func (r *ProductPostgresRepository) List(ctx context.Context, req pagination.Request, filter entity.ProductFilter) ([]*entity.Product, pagination.Result, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.List",
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
+		"sort", string(filter.Sort),
+	)
+	logger.Debug("Listing products with filters")
+	startTime := time.Now()
+
+	filterQuery := newProductFilterQuery(filter, noProductFacet)
+	whereClause := filterQuery.where()
+
+	// Count total matches first, when asked for
 	var total int
-	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if req.IncludeTotal {
+		countQuery := `SELECT COUNT(*) FROM products ` + whereClause
+		err := r.db.QueryRowContext(ctx, countQuery, filterQuery.args...).Scan(&total)
+		if err != nil {
+			logger.Error("Failed to count products", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("error counting products: %w", err)
+		}
+	}
+
+	keyset := productKeyset(filter.Sort)
+	afterCursor, err := keyset.Where(req, filterQuery.arg)
 	if err != nil {
-		logger.Error("Failed to count products", "error", err.Error())
-		return nil, 0, fmt.Errorf("error counting products: %w", err)
+		logger.Warn("Invalid input: cursor", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+	if afterCursor != "" {
+		whereClause += " AND " + afterCursor
 	}
 
 	// Now fetch the actual data with pagination
 	query := fmt.Sprintf(`
-		SELECT id, sku, name, price, inventory
+		SELECT %s, %s
 		FROM products
 		%s
-		ORDER BY created_at DESC
-		LIMIT $%d OFFSET $%d
-	`, whereClause, argPos, argPos+1)
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, productColumns, keyset.Select(), whereClause, keyset.OrderBy(req), filterQuery.arg(req.FetchLimit()), filterQuery.arg(req.Offset()))
 
-	args = append(args, limit, offset)
-
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	rows, err := r.db.QueryContext(ctx, query, filterQuery.args...)
 	if err != nil {
 		logger.Error("Failed to query products", "error", err.Error())
-		return nil, 0, fmt.Errorf("error querying products: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error querying products: %w", err)
 	}
 	defer rows.Close()
 
 	products := []*entity.Product{}
+	keys := [][]*string{}
 	for rows.Next() {
-		var product entity.Product
-		err := rows.Scan(
-			&product.ID,
-			&product.SKU,
-			&product.Name,
-			&product.Price,
-			&product.Inventory,
-		)
+		keyDest, rowKeys := keyset.ScanKeys()
+		product, err := scanProduct(rows, keyDest...)
 		if err != nil {
 			logger.Error("Failed to scan product row", "error", err.Error())
-			return nil, 0, fmt.Errorf("error scanning product row: %w", err)
+			return nil, pagination.Result{}, fmt.Errorf("error scanning product row: %w", err)
 		}
-		products = append(products, &product)
+		products = append(products, product)
+		keys = append(keys, rowKeys)
 	}
 
 	if err = rows.Err(); err != nil {
 		logger.Error("Failed to iterate product rows", "error", err.Error())
-		return nil, 0, fmt.Errorf("error iterating product rows: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error iterating product rows: %w", err)
+	}
+
+	products, result := pagination.Page(products, keys, req, keyset, total)
+
+	if err := r.attachVariants(ctx, products); err != nil {
+		logger.Error("Failed to query product variants", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+
+	if err := r.attachImages(ctx, products); err != nil {
+		logger.Error("Failed to query product images", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+
+	if err := r.attachRatings(ctx, products); err != nil {
+		logger.Error("Failed to query product ratings", "error", err.Error())
+		return nil, pagination.Result{}, err
 	}
 
 	duration := time.Since(startTime)
@@ -157,7 +286,45 @@ func (r *ProductPostgresRepository) List(ctx context.Context, page, limit int, s
 		"returned_count", len(products),
 		"duration_ms", duration.Milliseconds())
 
-	return products, total, nil
+	return products, result, nil
+}
+
+// Facets counts the products matching the filter for every category, price range, stock state and
+// option value
+func (r *ProductPostgresRepository) Facets(ctx context.Context, filter entity.ProductFilter) (*entity.ProductFacets, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.Facets",
+	)
+	logger.Debug("Counting product facets")
+	startTime := time.Now()
+
+	facets := &entity.ProductFacets{}
+	var err error
+
+	if facets.Categories, err = r.categoryFacets(ctx, filter); err != nil {
+		logger.Error("Failed to count category facets", "error", err.Error())
+		return nil, err
+	}
+	if facets.Prices, err = r.priceFacets(ctx, filter); err != nil {
+		logger.Error("Failed to count price facets", "error", err.Error())
+		return nil, err
+	}
+	if facets.Stock, err = r.stockFacet(ctx, filter); err != nil {
+		logger.Error("Failed to count stock facet", "error", err.Error())
+		return nil, err
+	}
+	if facets.Attributes, err = r.attributeFacets(ctx, filter); err != nil {
+		logger.Error("Failed to count attribute facets", "error", err.Error())
+		return nil, err
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully counted product facets",
+		"category_count", len(facets.Categories),
+		"attribute_count", len(facets.Attributes),
+		"duration_ms", duration.Milliseconds())
+
+	return facets, nil
 }
 
 // Create creates a new product
@@ -170,41 +337,46 @@ func (r *ProductPostgresRepository) Create(ctx context.Context, product *entity.
 	logger.Debug("Creating new product")
 	startTime := time.Now()
 
-	// Check if SKU already exists
-	var exists bool
-	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE sku = $1 AND deleted_at IS NULL)", product.SKU).Scan(&exists)
-	if err != nil {
-		logger.Error("Failed to check SKU existence", "error", err.Error())
-		return fmt.Errorf("error checking SKU existence: %w", err)
-	}
-
-	if exists {
-		logger.Warn("Product SKU already exists", "error", "ErrProductSKUAlreadyExists")
-		return domainErrors.ErrProductSKUAlreadyExists
-	}
-
 	// Generate a new UUID if not provided
 	if product.ID == uuid.Nil {
 		product.ID = uuid.New()
 	}
 
+	// The first price of a standalone or parent product starts its price history; a variant's price
+	// is its parent's or its override
 	query := `
-		INSERT INTO products (id, sku, name, price, inventory)
-		VALUES ($1, $2, $3, $4, $5)
+		WITH created AS (
+			INSERT INTO products (id, sku, name, description, price, inventory, parent_id, options, option_values, price_override)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id, price, parent_id
+		)
+		INSERT INTO product_prices (product_id, price, effective_from)
+		SELECT id, price, NOW() FROM created WHERE parent_id IS NULL
 	`
 
-	_, err = r.db.ExecContext(ctx, query,
+	// SKU uniqueness is left to the products_sku_key constraint so concurrent creates can't both pass a
+	// pre-check
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+	_, err := queryable.ExecContext(ctx, query,
 		product.ID,
 		product.SKU,
 		product.Name,
+		product.Description,
 		product.Price,
 		product.Inventory,
+		product.ParentID,
+		product.Options,
+		product.OptionValues,
+		product.PriceOverride,
 	)
 
 	if err != nil {
-		// Check for unique constraint violation on SKU
-		if strings.Contains(err.Error(), "unique constraint") && strings.Contains(err.Error(), "sku") {
-			logger.Warn("Product SKU already exists (constraint violation)", "error", "ErrProductSKUAlreadyExists")
+		if isUniqueViolation(err, "idx_products_variant_option_values") {
+			logger.Warn("Variant option values already taken", "error", "ErrVariantAlreadyExists")
+			return domainErrors.ErrVariantAlreadyExists
+		}
+		if isUniqueViolation(err, "products_sku_key") {
+			logger.Warn("Product SKU already exists", "error", "ErrProductSKUAlreadyExists")
 			return domainErrors.ErrProductSKUAlreadyExists
 		}
 		logger.Error("Failed to create product", "error", err.Error())
@@ -221,7 +393,8 @@ func (r *ProductPostgresRepository) Create(ctx context.Context, product *entity.
 	return nil
 }
 
-// Update updates an existing product
+// Update updates an existing product. The name and price of the variants loaded with a parent are
+// saved in the caller's transaction, so they never disagree with the parent.
 func (r *ProductPostgresRepository) Update(ctx context.Context, product *entity.Product) error {
 	logger := middleware.Logger.With(
 		"method", "ProductRepository.Update",
@@ -230,20 +403,31 @@ func (r *ProductPostgresRepository) Update(ctx context.Context, product *entity.
 	logger.Debug("Updating product")
 	startTime := time.Now()
 
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
 	query := `
 		UPDATE products
-		SET name = $1, price = $2, inventory = $3, updated_at = NOW()
-		WHERE id = $4 AND deleted_at IS NULL
+		SET name = $1, description = $2, price = $3, inventory = $4, options = $5, option_values = $6,
+			price_override = $7, updated_at = NOW()
+		WHERE id = $8 AND deleted_at IS NULL
 	`
 
-	result, err := r.db.ExecContext(ctx, query,
+	result, err := queryable.ExecContext(ctx, query,
 		product.Name,
+		product.Description,
 		product.Price,
 		product.Inventory,
+		product.Options,
+		product.OptionValues,
+		product.PriceOverride,
 		product.ID,
 	)
 
 	if err != nil {
+		if isUniqueViolation(err, "idx_products_variant_option_values") {
+			logger.Warn("Variant option values already taken", "error", "ErrVariantAlreadyExists")
+			return domainErrors.ErrVariantAlreadyExists
+		}
 		logger.Error("Failed to update product", "error", err.Error())
 		return fmt.Errorf("error updating product: %w", err)
 	}
@@ -259,17 +443,38 @@ func (r *ProductPostgresRepository) Update(ctx context.Context, product *entity.
 		return domainErrors.ErrProductNotFound
 	}
 
+	if product.ParentID == nil {
+		if _, err := queryable.ExecContext(ctx, recordProductPriceQuery, product.ID, product.Price); err != nil {
+			logger.Error("Failed to record product price", "error", err.Error())
+			return fmt.Errorf("error recording product price: %w", err)
+		}
+	}
+
+	for _, variant := range product.Variants {
+		_, err := queryable.ExecContext(ctx,
+			"UPDATE products SET name = $1, price = $2, updated_at = NOW() WHERE id = $3 AND deleted_at IS NULL",
+			variant.Name,
+			variant.Price,
+			variant.ID,
+		)
+		if err != nil {
+			logger.Error("Failed to update variant", "variant_id", variant.ID.String(), "error", err.Error())
+			return fmt.Errorf("error updating variant: %w", err)
+		}
+	}
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully updated product",
 		"name", product.Name,
 		"price", product.Price,
 		"inventory", product.Inventory,
+		"variant_count", len(product.Variants),
 		"duration_ms", duration.Milliseconds())
 
 	return nil
 }
 
-// Delete deletes a product by its ID (soft delete)
+// Delete deletes a product by its ID (soft delete); the variants of a parent are deleted with it
 func (r *ProductPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	logger := middleware.Logger.With(
 		"method", "ProductRepository.Delete",
@@ -281,7 +486,7 @@ func (r *ProductPostgresRepository) Delete(ctx context.Context, id uuid.UUID) er
 	query := `
 		UPDATE products
 		SET deleted_at = NOW()
-		WHERE id = $1 AND deleted_at IS NULL
+		WHERE (id = $1 OR parent_id = $1) AND deleted_at IS NULL
 	`
 
 	result, err := r.db.ExecContext(ctx, query, id)
@@ -307,3 +512,2041 @@ func (r *ProductPostgresRepository) Delete(ctx context.Context, id uuid.UUID) er
 
 	return nil
 }
+
+// GetByIDsForUpdate retrieves products by their IDs and locks the rows with SELECT ... FOR UPDATE.
+// Rows are locked in ID order so concurrent checkouts of overlapping carts cannot deadlock.
+func (r *ProductPostgresRepository) GetByIDsForUpdate(ctx context.Context, ids []uuid.UUID) ([]*entity.Product, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.GetByIDsForUpdate",
+		"product_count", len(ids),
+	)
+	logger.Debug("Locking products for update")
+	startTime := time.Now()
+
+	if len(ids) == 0 {
+		return []*entity.Product{}, nil
+	}
+
+	idStrings := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idStrings = append(idStrings, id.String())
+	}
+
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, pq.Array(idStrings))
+	if err != nil {
+		logger.Error("Failed to lock products", "error", err.Error())
+		return nil, fmt.Errorf("error locking products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []*entity.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			logger.Error("Failed to scan product row", "error", err.Error())
+			return nil, fmt.Errorf("error scanning product row: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate product rows", "error", err.Error())
+		return nil, fmt.Errorf("error iterating product rows: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully locked products",
+		"locked_count", len(products),
+		"duration_ms", duration.Milliseconds())
+
+	return products, nil
+}
+
+// UpdateInventory sets the inventory of a product
+func (r *ProductPostgresRepository) UpdateInventory(ctx context.Context, id uuid.UUID, inventory int) error {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.UpdateInventory",
+		"product_id", id.String(),
+		"inventory", inventory,
+	)
+	logger.Debug("Updating product inventory")
+	startTime := time.Now()
+
+	if inventory < 0 {
+		logger.Warn("Invalid inventory: cannot be negative", "error", "ErrInvalidProductInventory")
+		return domainErrors.ErrInvalidProductInventory
+	}
+
+	query := `
+		UPDATE products
+		SET inventory = $1, updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, query, inventory, id)
+	if err != nil {
+		logger.Error("Failed to update product inventory", "error", err.Error())
+		return fmt.Errorf("error updating product inventory: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		logger.Warn("Product not found", "error", "ErrProductNotFound")
+		return domainErrors.ErrProductNotFound
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated product inventory",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// SetCategories replaces the categories a product is listed in, in the caller's transaction
+func (r *ProductPostgresRepository) SetCategories(ctx context.Context, productID uuid.UUID, categoryIDs []uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.SetCategories",
+		"product_id", productID.String(),
+		"category_count", len(categoryIDs),
+	)
+	logger.Debug("Setting product categories")
+	startTime := time.Now()
+
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
+	// Lock the product so concurrent updates of its categories are applied one after the other
+	var lockedID uuid.UUID
+	err := queryable.QueryRowContext(ctx,
+		"SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE",
+		productID,
+	).Scan(&lockedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Product not found", "error", "ErrProductNotFound")
+			return domainErrors.ErrProductNotFound
+		}
+		logger.Error("Failed to lock product", "error", err.Error())
+		return fmt.Errorf("error locking product: %w", err)
+	}
+
+	if _, err := queryable.ExecContext(ctx, "DELETE FROM product_categories WHERE product_id = $1", productID); err != nil {
+		logger.Error("Failed to remove product categories", "error", err.Error())
+		return fmt.Errorf("error removing product categories: %w", err)
+	}
+
+	if len(categoryIDs) > 0 {
+		idStrings := make([]string, 0, len(categoryIDs))
+		for _, id := range categoryIDs {
+			idStrings = append(idStrings, id.String())
+		}
+
+		query := `
+			INSERT INTO product_categories (product_id, category_id)
+			SELECT $1, category_id FROM unnest($2::uuid[]) AS category_id
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := queryable.ExecContext(ctx, query, productID, pq.Array(idStrings)); err != nil {
+			logger.Error("Failed to add product categories", "error", err.Error())
+			return fmt.Errorf("error adding product categories: %w", err)
+		}
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully set product categories",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// UpsertBySKU creates or updates a standalone product from an import row in one statement. A SKU
+// taken by a row that is deleted, a parent or a variant is left alone and reported as taken.
+func (r *ProductPostgresRepository) UpsertBySKU(ctx context.Context, row *entity.ProductImportRow) (bool, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.UpsertBySKU",
+		"sku", row.SKU,
+		"line", row.Line,
+	)
+	startTime := time.Now()
+
+	// A changed price starts now in the price history, as for Update
+	query := `
+		WITH upserted AS (
+			INSERT INTO products (id, sku, name, description, price, inventory)
+			VALUES ($1, $2, $3, COALESCE($4::text, ''), $5, $6)
+			ON CONFLICT (sku) DO UPDATE
+			SET name = EXCLUDED.name,
+				description = COALESCE($4::text, products.description),
+				price = EXCLUDED.price,
+				inventory = EXCLUDED.inventory,
+				updated_at = NOW()
+			WHERE products.deleted_at IS NULL AND products.parent_id IS NULL AND products.options = '[]'::jsonb
+			RETURNING id, price, xmax = 0 AS created
+		), current AS (
+			SELECT pp.price FROM product_prices pp JOIN upserted ON pp.product_id = upserted.id
+			WHERE pp.effective_from <= NOW() AND (pp.effective_to IS NULL OR pp.effective_to > NOW())
+			ORDER BY pp.effective_from DESC, pp.created_at DESC
+			LIMIT 1
+		), changed AS (
+			SELECT id, price FROM upserted
+			WHERE NOT EXISTS (SELECT 1 FROM current WHERE current.price = upserted.price)
+		), ended AS (
+			UPDATE product_prices SET effective_to = NOW()
+			FROM changed
+			WHERE product_prices.product_id = changed.id
+				AND product_prices.effective_to IS NULL AND product_prices.effective_from < NOW()
+		), recorded AS (
+			INSERT INTO product_prices (product_id, price, effective_from)
+			SELECT id, price, NOW() FROM changed
+		)
+		SELECT created FROM upserted
+	`
+
+	var description sql.NullString
+	if row.Description != nil {
+		description = sql.NullString{String: *row.Description, Valid: true}
+	}
+
+	var created bool
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query,
+		uuid.New(),
+		row.SKU,
+		row.Name,
+		description,
+		row.Price,
+		row.Inventory,
+	).Scan(&created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Product SKU taken by a product that cannot be imported", "error", "ErrProductSKUAlreadyExists")
+			return false, domainErrors.ErrProductSKUAlreadyExists
+		}
+		logger.Error("Failed to upsert product", "error", err.Error())
+		return false, fmt.Errorf("error upserting product: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Successfully upserted product",
+		"created", created,
+		"duration_ms", duration.Milliseconds())
+
+	return created, nil
+}
+
+// ForEachStandalone streams the standalone products, neither parents nor variants, to fn
+func (r *ProductPostgresRepository) ForEachStandalone(ctx context.Context, fn func(*entity.Product) error) error {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.ForEachStandalone",
+	)
+	logger.Debug("Streaming standalone products")
+	startTime := time.Now()
+
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE deleted_at IS NULL AND parent_id IS NULL AND options = '[]'::jsonb
+		ORDER BY sku
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Error("Failed to query products", "error", err.Error())
+		return fmt.Errorf("error querying products: %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			logger.Error("Failed to scan product row", "error", err.Error())
+			return fmt.Errorf("error scanning product row: %w", err)
+		}
+		if err := fn(product); err != nil {
+			logger.Warn("Product stream stopped", "streamed_count", count, "error", err.Error())
+			return err
+		}
+		count++
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate product rows", "error", err.Error())
+		return fmt.Errorf("error iterating product rows: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully streamed products",
+		"streamed_count", count,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// GetPriceAt returns the price of a product in effect at a moment
+func (r *ProductPostgresRepository) GetPriceAt(ctx context.Context, id uuid.UUID, at time.Time) (money.Money, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.GetPriceAt",
+		"product_id", id.String(),
+		"at", at,
+	)
+	logger.Debug("Fetching product price at a moment")
+
+	var price money.Money
+	err := r.db.QueryRowContext(ctx, "SELECT product_price_at(id, $2) FROM products WHERE id = $1 AND deleted_at IS NULL", id, at).Scan(&price)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Product not found", "error", "ErrProductNotFound")
+			return money.Money{}, domainErrors.ErrProductNotFound
+		}
+		logger.Error("Failed to query product price", "error", err.Error())
+		return money.Money{}, fmt.Errorf("error querying product price: %w", err)
+	}
+
+	return price, nil
+}
+
+// ListPrices returns the past and scheduled prices of a product, latest start first
+func (r *ProductPostgresRepository) ListPrices(ctx context.Context, productID uuid.UUID) ([]*entity.ProductPrice, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.ListPrices",
+		"product_id", productID.String(),
+	)
+	logger.Debug("Listing product prices")
+	startTime := time.Now()
+
+	query := `
+		SELECT id, product_id, price, effective_from, effective_to, created_at
+		FROM product_prices
+		WHERE product_id = $1
+		ORDER BY effective_from DESC, created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, productID)
+	if err != nil {
+		logger.Error("Failed to query product prices", "error", err.Error())
+		return nil, fmt.Errorf("error querying product prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []*entity.ProductPrice{}
+	for rows.Next() {
+		var price entity.ProductPrice
+		var effectiveTo sql.NullTime
+		err := rows.Scan(
+			&price.ID,
+			&price.ProductID,
+			&price.Price,
+			&price.EffectiveFrom,
+			&effectiveTo,
+			&price.CreatedAt,
+		)
+		if err != nil {
+			logger.Error("Failed to scan product price row", "error", err.Error())
+			return nil, fmt.Errorf("error scanning product price row: %w", err)
+		}
+		if effectiveTo.Valid {
+			price.EffectiveTo = &effectiveTo.Time
+		}
+		prices = append(prices, &price)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate product price rows", "error", err.Error())
+		return nil, fmt.Errorf("error iterating product price rows: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed product prices",
+		"price_count", len(prices),
+		"duration_ms", duration.Milliseconds())
+
+	return prices, nil
+}
+
+// SchedulePrice saves a price period of a product in one statement, ending the open-ended prices
+// that start before an open-ended one
+func (r *ProductPostgresRepository) SchedulePrice(ctx context.Context, price *entity.ProductPrice) error {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.SchedulePrice",
+		"product_id", price.ProductID.String(),
+		"price", price.Price,
+		"effective_from", price.EffectiveFrom,
+	)
+	logger.Debug("Scheduling product price")
+	startTime := time.Now()
+
+	if price.ID == uuid.Nil {
+		price.ID = uuid.New()
+	}
+
+	query := `
+		WITH ended AS (
+			UPDATE product_prices SET effective_to = $4
+			WHERE product_id = $2 AND effective_to IS NULL AND effective_from < $4 AND $5::timestamptz IS NULL
+		)
+		INSERT INTO product_prices (id, product_id, price, effective_from, effective_to)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query,
+		price.ID,
+		price.ProductID,
+		price.Price,
+		price.EffectiveFrom,
+		price.EffectiveTo,
+	).Scan(&price.CreatedAt)
+	if err != nil {
+		logger.Error("Failed to schedule product price", "error", err.Error())
+		return fmt.Errorf("error scheduling product price: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully scheduled product price",
+		"price_id", price.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// ApplyScheduledPrices catches the price column up with the prices in effect. Every product is
+// compared, so prices that started while the scheduler was not running are applied too.
+func (r *ProductPostgresRepository) ApplyScheduledPrices(ctx context.Context) (int, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductRepository.ApplyScheduledPrices",
+	)
+	logger.Debug("Applying scheduled product prices")
+	startTime := time.Now()
+
+	query := `
+		UPDATE products
+		SET price = effective.price, updated_at = NOW()
+		FROM (
+			SELECT id, product_price_at(id, NOW()) AS price
+			FROM products
+			WHERE deleted_at IS NULL
+		) AS effective
+		WHERE products.id = effective.id AND products.price <> effective.price
+	`
+
+	result, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, query)
+	if err != nil {
+		logger.Error("Failed to apply scheduled prices", "error", err.Error())
+		return 0, fmt.Errorf("error applying scheduled prices: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return 0, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Applied scheduled product prices",
+		"changed_count", rowsAffected,
+		"duration_ms", duration.Milliseconds())
+
+	return int(rowsAffected), nil
+}
+
+// attachVariants loads the variants of the parents among products
+func (r *ProductPostgresRepository) attachVariants(ctx context.Context, products []*entity.Product) error {
+	parentIDs := []uuid.UUID{}
+	for _, product := range products {
+		if product.HasVariants() {
+			parentIDs = append(parentIDs, product.ID)
+		}
+	}
+	if len(parentIDs) == 0 {
+		return nil
+	}
+
+	variants, err := r.getVariants(ctx, parentIDs)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		if product.HasVariants() {
+			product.Variants = variants[product.ID]
+		}
+	}
+	return nil
+}
+
+// attachImages loads the images of the standalone and parent products among products
+func (r *ProductPostgresRepository) attachImages(ctx context.Context, products []*entity.Product) error {
+	productIDs := make([]string, 0, len(products))
+	for _, product := range products {
+		if !product.IsVariant() {
+			productIDs = append(productIDs, product.ID.String())
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT ` + productImageColumns + `
+		FROM product_images
+		WHERE product_id = ANY($1::uuid[])
+		ORDER BY product_id, position, created_at
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return fmt.Errorf("error querying product images: %w", err)
+	}
+	defer rows.Close()
+
+	images := make(map[uuid.UUID][]*entity.ProductImage, len(productIDs))
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			return fmt.Errorf("error scanning product image row: %w", err)
+		}
+		images[image.ProductID] = append(images[image.ProductID], image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating product image rows: %w", err)
+	}
+
+	for _, product := range products {
+		if !product.IsVariant() {
+			product.Images = images[product.ID]
+		}
+	}
+	return nil
+}
+
+// attachRatings loads the rating of the standalone and parent products among products from their
+// published reviews
+func (r *ProductPostgresRepository) attachRatings(ctx context.Context, products []*entity.Product) error {
+	productIDs := make([]string, 0, len(products))
+	for _, product := range products {
+		if !product.IsVariant() {
+			productIDs = append(productIDs, product.ID.String())
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT product_id, ROUND(AVG(rating), 2)::float8, COUNT(*)
+		FROM product_reviews
+		WHERE product_id = ANY($1::uuid[]) AND status = 'PUBLISHED'
+		GROUP BY product_id
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, pq.Array(productIDs))
+	if err != nil {
+		return fmt.Errorf("error querying product ratings: %w", err)
+	}
+	defer rows.Close()
+
+	ratings := make(map[uuid.UUID]entity.ProductRating, len(productIDs))
+	for rows.Next() {
+		var productID uuid.UUID
+		var rating entity.ProductRating
+		if err := rows.Scan(&productID, &rating.Average, &rating.Count); err != nil {
+			return fmt.Errorf("error scanning product rating row: %w", err)
+		}
+		ratings[productID] = rating
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating product rating rows: %w", err)
+	}
+
+	for _, product := range products {
+		if !product.IsVariant() {
+			product.Rating = ratings[product.ID]
+		}
+	}
+	return nil
+}
+
+// getVariants retrieves the variants of the given parents, keyed by parent ID
+func (r *ProductPostgresRepository) getVariants(ctx context.Context, parentIDs []uuid.UUID) (map[uuid.UUID][]*entity.Product, error) {
+	idStrings := make([]string, 0, len(parentIDs))
+	for _, id := range parentIDs {
+		idStrings = append(idStrings, id.String())
+	}
+
+	query := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE parent_id = ANY($1::uuid[]) AND deleted_at IS NULL
+		ORDER BY created_at, sku
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, pq.Array(idStrings))
+	if err != nil {
+		return nil, fmt.Errorf("error querying variants: %w", err)
+	}
+	defer rows.Close()
+
+	variants := make(map[uuid.UUID][]*entity.Product, len(parentIDs))
+	for rows.Next() {
+		variant, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning variant row: %w", err)
+		}
+		variants[*variant.ParentID] = append(variants[*variant.ParentID], variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating variant rows: %w", err)
+	}
+
+	return variants, nil
+}
+
+// categoryFacets counts the matching products in every category, including those of its descendants
+func (r *ProductPostgresRepository) categoryFacets(ctx context.Context, filter entity.ProductFilter) ([]entity.CategoryFacet, error) {
+	filterQuery := newProductFilterQuery(filter, categoryProductFacet)
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id AS ancestor_id, id FROM categories
+			UNION ALL
+			SELECT tree.ancestor_id, c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+		)
+		SELECT categories.id, categories.name, categories.slug, COUNT(DISTINCT products.id)
+		FROM products
+		JOIN product_categories ON product_categories.product_id = products.id
+		JOIN tree ON tree.id = product_categories.category_id
+		JOIN categories ON categories.id = tree.ancestor_id
+		` + filterQuery.where() + `
+		GROUP BY categories.id, categories.name, categories.slug
+		ORDER BY categories.name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, filterQuery.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting category facets: %w", err)
+	}
+	defer rows.Close()
+
+	facets := []entity.CategoryFacet{}
+	for rows.Next() {
+		var facet entity.CategoryFacet
+		if err := rows.Scan(&facet.ID, &facet.Name, &facet.Slug, &facet.Count); err != nil {
+			return nil, fmt.Errorf("error scanning category facet row: %w", err)
+		}
+		facets = append(facets, facet)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category facet rows: %w", err)
+	}
+
+	return facets, nil
+}
+
+// priceFacets counts the matching products by the price range of their cheapest matching unit
+func (r *ProductPostgresRepository) priceFacets(ctx context.Context, filter entity.ProductFilter) ([]entity.PriceFacet, error) {
+	filterQuery := newProductFilterQuery(filter, priceProductFacet)
+
+	bounds := make([]string, 0, len(entity.PriceFacetBounds))
+	for _, bound := range entity.PriceFacetBounds {
+		bounds = append(bounds, bound.String())
+	}
+
+	query := `
+		SELECT width_bucket(price, ` + filterQuery.arg(pq.Array(bounds)) + `::numeric[]) AS bucket, COUNT(*)
+		FROM (
+			SELECT products.id, MIN(` + productUnitPrice + `) AS price
+			FROM products
+			JOIN products units ON ` + productUnitCondition + `
+			` + filterQuery.unitWhere() + `
+			GROUP BY products.id
+		) matched
+		GROUP BY bucket
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, filterQuery.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting price facets: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("error scanning price facet row: %w", err)
+		}
+		counts[bucket] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating price facet rows: %w", err)
+	}
+
+	return entity.NewPriceFacets(counts), nil
+}
+
+// stockFacet counts the matching products with and without a matching unit in stock
+func (r *ProductPostgresRepository) stockFacet(ctx context.Context, filter entity.ProductFilter) (entity.StockFacet, error) {
+	filterQuery := newProductFilterQuery(filter, stockProductFacet)
+
+	query := `
+		SELECT COUNT(*) FILTER (WHERE in_stock), COUNT(*) FILTER (WHERE NOT in_stock)
+		FROM (
+			SELECT products.id, bool_or(units.inventory > 0) AS in_stock
+			FROM products
+			JOIN products units ON ` + productUnitCondition + `
+			` + filterQuery.unitWhere() + `
+			GROUP BY products.id
+		) matched
+	`
+
+	var facet entity.StockFacet
+	err := r.db.QueryRowContext(ctx, query, filterQuery.args...).Scan(&facet.InStock, &facet.OutOfStock)
+	if err != nil {
+		return entity.StockFacet{}, fmt.Errorf("error counting stock facet: %w", err)
+	}
+
+	return facet, nil
+}
+
+// attributeFacets counts the matching products with a matching variant for every option value
+func (r *ProductPostgresRepository) attributeFacets(ctx context.Context, filter entity.ProductFilter) ([]entity.AttributeFacet, error) {
+	filterQuery := newProductFilterQuery(filter, attributeProductFacet)
+
+	query := `
+		SELECT attribute.key, attribute.value, COUNT(DISTINCT products.id)
+		FROM products
+		JOIN products units ON ` + productUnitCondition + `
+		CROSS JOIN LATERAL jsonb_each_text(units.option_values) AS attribute
+		` + filterQuery.unitWhere() + `
+		GROUP BY attribute.key, attribute.value
+		ORDER BY attribute.key, attribute.value
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, filterQuery.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error counting attribute facets: %w", err)
+	}
+	defer rows.Close()
+
+	facets := []entity.AttributeFacet{}
+	for rows.Next() {
+		var name string
+		var value entity.AttributeValueFacet
+		if err := rows.Scan(&name, &value.Value, &value.Count); err != nil {
+			return nil, fmt.Errorf("error scanning attribute facet row: %w", err)
+		}
+		if len(facets) == 0 || facets[len(facets)-1].Name != name {
+			facets = append(facets, entity.AttributeFacet{Name: name})
+		}
+		last := &facets[len(facets)-1]
+		last.Values = append(last.Values, value)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attribute facet rows: %w", err)
+	}
+
+	return facets, nil
+}
+
+// productUnitCondition joins a listed product row with the units it is sold as, aliased units: the
+// product itself, or the variants of a parent
+const productUnitCondition = `units.deleted_at IS NULL AND (
+				units.parent_id = products.id OR (units.id = products.id AND units.options = '[]'::jsonb)
+			)`
+
+// productUnitPrice is the price in effect of a unit of a listed product
+const productUnitPrice = `product_price_at(units.id, NOW())`
+
+// productPriceColumn is the price in effect of the cheapest unit of a listed product
+const productPriceColumn = `COALESCE((
+			SELECT MIN(` + productUnitPrice + `) FROM products units WHERE ` + productUnitCondition + `
+		), product_price_at(products.id, NOW()))`
+
+// productPopularityColumn is the number of units of a listed product sold in paid orders that were
+// not cancelled
+const productPopularityColumn = `(
+			SELECT COALESCE(SUM(ci.quantity), 0)
+			FROM checkout_items ci
+			JOIN checkouts c ON c.id = ci.checkout_id
+			JOIN products units ON units.id = ci.product_id
+			WHERE ` + productUnitCondition + `
+				AND c.status <> 'CANCELLED' AND c.payment_status = 'PAID'
+		)`
+
+// productRatingColumn is the average rating of a listed product from its published reviews, with
+// unreviewed products last
+const productRatingColumn = `COALESCE((
+			SELECT AVG(reviews.rating) FROM product_reviews reviews
+			WHERE reviews.product_id = products.id AND reviews.status = 'PUBLISHED'
+		), 0)`
+
+// productReviewCountColumn is the number of published reviews of a listed product
+const productReviewCountColumn = `(
+			SELECT COUNT(*) FROM product_reviews reviews
+			WHERE reviews.product_id = products.id AND reviews.status = 'PUBLISHED'
+		)`
+
+// productKeyset returns the ordering of a product sort; the ID breaks ties so every product has a
+// distinct position
+func productKeyset(order entity.ProductSort) pagination.Keyset {
+	id := pagination.Column{Expr: "products.id"}
+	newest := pagination.Column{Expr: "products.created_at", Desc: true}
+
+	columns := []pagination.Column{newest, id}
+	switch order {
+	case entity.ProductSortPriceAsc:
+		columns = []pagination.Column{{Expr: productPriceColumn}, id}
+	case entity.ProductSortPriceDesc:
+		columns = []pagination.Column{{Expr: productPriceColumn, Desc: true}, id}
+	case entity.ProductSortNameAsc:
+		columns = []pagination.Column{{Expr: "products.name"}, id}
+	case entity.ProductSortNameDesc:
+		columns = []pagination.Column{{Expr: "products.name", Desc: true}, id}
+	case entity.ProductSortPopularity:
+		columns = []pagination.Column{{Expr: productPopularityColumn, Desc: true}, newest, id}
+	case entity.ProductSortRating:
+		// Between equal averages, the one backed by more reviews ranks higher
+		columns = []pagination.Column{{Expr: productRatingColumn, Desc: true}, {Expr: productReviewCountColumn, Desc: true}, id}
+	}
+
+	return pagination.Keyset{Name: string(order), Columns: columns}
+}
+
+// productFacet names the filter left out while counting its own facet
+type productFacet int
+
+const (
+	noProductFacet productFacet = iota
+	categoryProductFacet
+	priceProductFacet
+	stockProductFacet
+	attributeProductFacet
+)
+
+// productFilterQuery holds the conditions of a filtered product list and their arguments. Product
+// conditions apply to the listed rows, unit conditions to the units they are sold as.
+type productFilterQuery struct {
+	productConditions []string
+	unitConditions    []string
+	args              []interface{}
+}
+
+// newProductFilterQuery builds the conditions of filter, leaving out the filter of the facet being
+// counted. While counting attributes, the selected values of an option only constrain the values
+// counted for the other options.
+func newProductFilterQuery(filter entity.ProductFilter, except productFacet) *productFilterQuery {
+	q := &productFilterQuery{}
+
+	// Variants are listed under their parent
+	q.productConditions = append(q.productConditions, "products.deleted_at IS NULL", "products.parent_id IS NULL")
+
+	if filter.SKU != "" {
+		sku := q.arg("%" + filter.SKU + "%")
+		q.productConditions = append(q.productConditions, fmt.Sprintf(`(products.sku ILIKE %[1]s OR EXISTS (
+				SELECT 1 FROM products v
+				WHERE v.parent_id = products.id AND v.deleted_at IS NULL AND v.sku ILIKE %[1]s
+			))`, sku))
+	}
+
+	if filter.Name != "" {
+		q.productConditions = append(q.productConditions, "products.name ILIKE "+q.arg("%"+filter.Name+"%"))
+	}
+
+	if filter.CategoryID != nil && except != categoryProductFacet {
+		subtree := strings.Replace(categorySubtreeQuery, "$%d", q.arg(*filter.CategoryID), 1)
+		q.productConditions = append(q.productConditions, `products.id IN (
+				SELECT product_id FROM product_categories
+				WHERE category_id IN (`+subtree+`)
+			)`)
+	}
+
+	if except != priceProductFacet {
+		if filter.MinPrice != nil {
+			q.unitConditions = append(q.unitConditions, productUnitPrice+" >= "+q.arg(*filter.MinPrice))
+		}
+		if filter.MaxPrice != nil {
+			q.unitConditions = append(q.unitConditions, productUnitPrice+" <= "+q.arg(*filter.MaxPrice))
+		}
+	}
+
+	if filter.InStock && except != stockProductFacet {
+		q.unitConditions = append(q.unitConditions, "units.inventory > 0")
+	}
+
+	names := make([]string, 0, len(filter.Attributes))
+	for name := range filter.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		nameArg := q.arg(name)
+		condition := fmt.Sprintf("units.option_values ->> %s = ANY(%s::text[])", nameArg, q.arg(pq.Array(filter.Attributes[name])))
+		if except == attributeProductFacet {
+			condition = fmt.Sprintf("(attribute.key = %s OR %s)", nameArg, condition)
+		}
+		q.unitConditions = append(q.unitConditions, condition)
+	}
+
+	return q
+}
+
+// arg adds an argument and returns its placeholder
+func (q *productFilterQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where returns the WHERE clause of a query over listed product rows, matching units in a subquery
+func (q *productFilterQuery) where() string {
+	conditions := q.productConditions
+	if len(q.unitConditions) > 0 {
+		conditions = append(conditions[:len(conditions):len(conditions)], `EXISTS (
+				SELECT 1 FROM products units
+				WHERE `+productUnitCondition+` AND `+strings.Join(q.unitConditions, " AND ")+`
+			)`)
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// unitWhere returns the WHERE clause of a query joining listed product rows with their units
+func (q *productFilterQuery) unitWhere() string {
+	conditions := append(q.productConditions[:len(q.productConditions):len(q.productConditions)], q.unitConditions...)
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// scanProduct scans a row selecting productColumns, followed by any extra columns
+func scanProduct(row rowScanner, extra ...interface{}) (*entity.Product, error) {
+	var product entity.Product
+	var parentID uuid.NullUUID
+	dest := []interface{}{
+		&product.ID,
+		&product.SKU,
+		&product.Name,
+		&product.Description,
+		&product.Price,
+		&product.Inventory,
+		&parentID,
+		&product.Options,
+		&product.OptionValues,
+		&product.PriceOverride,
+		pq.Array(&product.CategoryIDs),
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		product.ParentID = &parentID.UUID
+	}
+	return &product, nil
+}
+
+// maxSearchTerms caps the number of words of a search query that are used
+const maxSearchTerms = 10
+
+// productSearchQuery matches every term of $1 by prefix, as a word of the English text or as a
+// SKU, which the simple configuration keeps whole
+const productSearchQuery = `WITH search AS (
+		SELECT to_tsquery('english', $1) || to_tsquery('simple', $1) AS query
+	)`
+
+// productSearchCondition matches products whose document matches the query, whose variants'
+// SKUs do, or whose name contains a word similar to the plain query in $2 to tolerate typos
+const productSearchCondition = `deleted_at IS NULL AND parent_id IS NULL AND (
+			search_vector @@ search.query
+			OR $2 <% name
+			OR EXISTS (
+				SELECT 1 FROM products v
+				WHERE v.parent_id = products.id AND v.deleted_at IS NULL AND v.search_vector @@ search.query
+			)
+		)`
+
+// ProductPostgresSearcher implements ProductSearcher with Postgres full-text search and pg_trgm
+type ProductPostgresSearcher struct {
+	db       *sql.DB
+	products *ProductPostgresRepository
+}
+
+// NewProductSearcher creates a new Postgres product searcher
+func NewProductSearcher(db *sql.DB) ProductSearcher {
+	return &ProductPostgresSearcher{
+		db:       db,
+		products: &ProductPostgresRepository{db: db},
+	}
+}
+
+// Search returns the products matching query, most relevant first. The rank adds the full-text
+// rank of the document to the trigram similarity of the name, so exact words beat typos.
+func (s *ProductPostgresSearcher) Search(ctx context.Context, query string, page, limit int) ([]*entity.SearchResult, int, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductSearcher.Search",
+		"query", query,
+		"page", page,
+		"limit", limit,
+	)
+	logger.Debug("Searching products")
+	startTime := time.Now()
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []*entity.SearchResult{}, 0, nil
+	}
+
+	prefixTerms := make([]string, len(terms))
+	for i, term := range terms {
+		prefixTerms[i] = term + ":*"
+	}
+	tsQuery := strings.Join(prefixTerms, " & ")
+	plainQuery := strings.Join(terms, " ")
+
+	countQuery := productSearchQuery + `
+		SELECT COUNT(*) FROM products, search
+		WHERE ` + productSearchCondition
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, countQuery, tsQuery, plainQuery).Scan(&total); err != nil {
+		logger.Error("Failed to count search results", "error", err.Error())
+		return nil, 0, fmt.Errorf("error counting search results: %w", err)
+	}
+
+	headlineOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=25, MinWords=8, ShortWord=2",
+		entity.SnippetStartSel, entity.SnippetStopSel)
+
+	searchQuery := productSearchQuery + `
+		SELECT ` + productColumns + `,
+			ts_rank_cd(search_vector, search.query) + word_similarity($2, name) AS rank,
+			ts_headline('english',
+				CASE WHEN description = '' THEN name ELSE name || '. ' || description END,
+				search.query, $3) AS snippet
+		FROM products, search
+		WHERE ` + productSearchCondition + `
+		ORDER BY rank DESC, name
+		LIMIT $4 OFFSET $5
+	`
+
+	rows, err := s.db.QueryContext(ctx, searchQuery, tsQuery, plainQuery, headlineOptions, limit, (page-1)*limit)
+	if err != nil {
+		logger.Error("Failed to search products", "error", err.Error())
+		return nil, 0, fmt.Errorf("error searching products: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*entity.SearchResult{}
+	products := []*entity.Product{}
+	for rows.Next() {
+		var result entity.SearchResult
+		product, err := scanProduct(rows, &result.Rank, &result.Snippet)
+		if err != nil {
+			logger.Error("Failed to scan search result", "error", err.Error())
+			return nil, 0, fmt.Errorf("error scanning search result: %w", err)
+		}
+		result.Product = product
+		results = append(results, &result)
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate search results", "error", err.Error())
+		return nil, 0, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	if err := s.products.attachVariants(ctx, products); err != nil {
+		logger.Error("Failed to query product variants", "error", err.Error())
+		return nil, 0, err
+	}
+
+	if err := s.products.attachImages(ctx, products); err != nil {
+		logger.Error("Failed to query product images", "error", err.Error())
+		return nil, 0, err
+	}
+
+	if err := s.products.attachRatings(ctx, products); err != nil {
+		logger.Error("Failed to query product ratings", "error", err.Error())
+		return nil, 0, err
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully searched products",
+		"total_count", total,
+		"returned_count", len(results),
+		"duration_ms", duration.Milliseconds())
+
+	return results, total, nil
+}
+
+// searchTerms splits a query into lower-case words of letters and digits. Everything else is
+// dropped, which also keeps the words safe to join into a tsquery.
+func searchTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// CategoryPostgresRepository implements CategoryRepository using PostgreSQL
+type CategoryPostgresRepository struct {
+	db *sql.DB
+}
+
+// NewCategoryRepository creates a new category repository
+func NewCategoryRepository(db *sql.DB) CategoryRepository {
+	return &CategoryPostgresRepository{
+		db: db,
+	}
+}
+
+// GetByID retrieves a category by its ID
+func (r *CategoryPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Category, error) {
+	return r.getOne(ctx, "CategoryRepository.GetByID", "id", id)
+}
+
+// GetBySlug retrieves a category by its slug
+func (r *CategoryPostgresRepository) GetBySlug(ctx context.Context, slug string) (*entity.Category, error) {
+	return r.getOne(ctx, "CategoryRepository.GetBySlug", "slug", slug)
+}
+
+// getOne retrieves the category whose column equals value
+func (r *CategoryPostgresRepository) getOne(ctx context.Context, method, column string, value interface{}) (*entity.Category, error) {
+	logger := middleware.Logger.With(
+		"method", method,
+		column, value,
+	)
+	logger.Debug("Fetching category")
+	startTime := time.Now()
+
+	query := fmt.Sprintf(`
+		SELECT id, parent_id, name, slug, created_at, updated_at
+		FROM categories
+		WHERE %s = $1
+	`, column)
+
+	category, err := scanCategory(persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, value))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Category not found", "error", "ErrCategoryNotFound")
+			return nil, domainErrors.ErrCategoryNotFound
+		}
+		logger.Error("Failed to query category", "error", err.Error())
+		return nil, fmt.Errorf("error querying category: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved category",
+		"category_id", category.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return category, nil
+}
+
+// GetByIDs retrieves the categories with the given IDs
+func (r *CategoryPostgresRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*entity.Category, error) {
+	logger := middleware.Logger.With(
+		"method", "CategoryRepository.GetByIDs",
+		"category_count", len(ids),
+	)
+	logger.Debug("Fetching categories by ID")
+	startTime := time.Now()
+
+	if len(ids) == 0 {
+		return []*entity.Category{}, nil
+	}
+
+	idStrings := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idStrings = append(idStrings, id.String())
+	}
+
+	query := `
+		SELECT id, parent_id, name, slug, created_at, updated_at
+		FROM categories
+		WHERE id = ANY($1::uuid[])
+		ORDER BY name
+	`
+
+	categories, err := r.query(ctx, query, pq.Array(idStrings))
+	if err != nil {
+		logger.Error("Failed to query categories by ID", "error", err.Error())
+		return nil, fmt.Errorf("error querying categories by ID: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved categories by ID",
+		"found_count", len(categories),
+		"duration_ms", duration.Milliseconds())
+
+	return categories, nil
+}
+
+// List retrieves every category. Categories are ordered by their path of names from the root,
+// so a parent always comes before its children.
+func (r *CategoryPostgresRepository) List(ctx context.Context) ([]*entity.Category, error) {
+	logger := middleware.Logger.With("method", "CategoryRepository.List")
+	logger.Debug("Listing categories")
+	startTime := time.Now()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, parent_id, name, slug, created_at, updated_at, ARRAY[name::text] AS path
+			FROM categories
+			WHERE parent_id IS NULL
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.slug, c.created_at, c.updated_at, t.path || c.name::text
+			FROM categories c
+			JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id, parent_id, name, slug, created_at, updated_at
+		FROM tree
+		ORDER BY path
+	`
+
+	categories, err := r.query(ctx, query)
+	if err != nil {
+		logger.Error("Failed to list categories", "error", err.Error())
+		return nil, fmt.Errorf("error listing categories: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed categories",
+		"returned_count", len(categories),
+		"duration_ms", duration.Milliseconds())
+
+	return categories, nil
+}
+
+// GetDescendantIDs retrieves the IDs of every category below the given one
+func (r *CategoryPostgresRepository) GetDescendantIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	logger := middleware.Logger.With(
+		"method", "CategoryRepository.GetDescendantIDs",
+		"category_id", id.String(),
+	)
+	logger.Debug("Fetching category descendants")
+	startTime := time.Now()
+
+	query := fmt.Sprintf(`
+		SELECT id FROM (`+categorySubtreeQuery+`) AS descendants
+		WHERE id <> $1
+	`, 1)
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, id)
+	if err != nil {
+		logger.Error("Failed to query category descendants", "error", err.Error())
+		return nil, fmt.Errorf("error querying category descendants: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var descendantID uuid.UUID
+		if err := rows.Scan(&descendantID); err != nil {
+			logger.Error("Failed to scan category descendant", "error", err.Error())
+			return nil, fmt.Errorf("error scanning category descendant: %w", err)
+		}
+		ids = append(ids, descendantID)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate category descendants", "error", err.Error())
+		return nil, fmt.Errorf("error iterating category descendants: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved category descendants",
+		"descendant_count", len(ids),
+		"duration_ms", duration.Milliseconds())
+
+	return ids, nil
+}
+
+// LockForMove locks a category, the parent it is being moved under and every ancestor of that
+// parent until the end of the transaction. Two moves that could together form a cycle lock a
+// category in common, so they run one after the other; rows are locked in ID order so they cannot
+// deadlock.
+func (r *CategoryPostgresRepository) LockForMove(ctx context.Context, id, parentID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CategoryRepository.LockForMove",
+		"category_id", id.String(),
+		"parent_id", parentID.String(),
+	)
+	logger.Debug("Locking categories for move")
+	startTime := time.Now()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $2
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id FROM categories
+		WHERE id = $1 OR id IN (SELECT id FROM ancestors)
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, id, parentID)
+	if err != nil {
+		logger.Error("Failed to lock categories", "error", err.Error())
+		return fmt.Errorf("error locking categories: %w", err)
+	}
+	defer rows.Close()
+
+	locked := 0
+	for rows.Next() {
+		locked++
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate locked categories", "error", err.Error())
+		return fmt.Errorf("error iterating locked categories: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully locked categories",
+		"locked_count", locked,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// Create creates a new category
+func (r *CategoryPostgresRepository) Create(ctx context.Context, category *entity.Category) error {
+	logger := middleware.Logger.With(
+		"method", "CategoryRepository.Create",
+		"slug", category.Slug,
+	)
+	logger.Debug("Creating new category")
+	startTime := time.Now()
+
+	if category.ID == uuid.Nil {
+		category.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO categories (id, parent_id, name, slug, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, query,
+		category.ID,
+		category.ParentID,
+		category.Name,
+		category.Slug,
+		category.CreatedAt,
+		category.UpdatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err, "categories_slug_key") {
+			logger.Warn("Category slug already exists", "error", "ErrCategorySlugAlreadyExists")
+			return domainErrors.ErrCategorySlugAlreadyExists
+		}
+		logger.Error("Failed to create category", "error", err.Error())
+		return fmt.Errorf("error creating category: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created category",
+		"category_id", category.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// Update updates the parent, name and slug of a category
+func (r *CategoryPostgresRepository) Update(ctx context.Context, category *entity.Category) error {
+	logger := middleware.Logger.With(
+		"method", "CategoryRepository.Update",
+		"category_id", category.ID.String(),
+	)
+	logger.Debug("Updating category")
+	startTime := time.Now()
+
+	query := `
+		UPDATE categories
+		SET parent_id = $1, name = $2, slug = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at
+	`
+
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query,
+		category.ParentID,
+		category.Name,
+		category.Slug,
+		category.ID,
+	).Scan(&category.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Category not found", "error", "ErrCategoryNotFound")
+			return domainErrors.ErrCategoryNotFound
+		}
+		if isUniqueViolation(err, "categories_slug_key") {
+			logger.Warn("Category slug already exists", "error", "ErrCategorySlugAlreadyExists")
+			return domainErrors.ErrCategorySlugAlreadyExists
+		}
+		logger.Error("Failed to update category", "error", err.Error())
+		return fmt.Errorf("error updating category: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated category",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// Delete deletes a category without children; the links to its products are removed with it
+func (r *CategoryPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CategoryRepository.Delete",
+		"category_id", id.String(),
+	)
+	logger.Debug("Deleting category")
+	startTime := time.Now()
+
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
+	var hasChildren bool
+	err := queryable.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)", id).Scan(&hasChildren)
+	if err != nil {
+		logger.Error("Failed to check category children", "error", err.Error())
+		return fmt.Errorf("error checking category children: %w", err)
+	}
+	if hasChildren {
+		logger.Warn("Category has children", "error", "ErrCategoryHasChildren")
+		return domainErrors.ErrCategoryHasChildren
+	}
+
+	result, err := queryable.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		// A child added since the check above still blocks the delete through the parent_id key
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			logger.Warn("Category has children (constraint violation)", "error", "ErrCategoryHasChildren")
+			return domainErrors.ErrCategoryHasChildren
+		}
+		logger.Error("Failed to delete category", "error", err.Error())
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		logger.Warn("Category not found", "error", "ErrCategoryNotFound")
+		return domainErrors.ErrCategoryNotFound
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted category",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// query runs a query that selects category rows
+func (r *CategoryPostgresRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entity.Category, error) {
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*entity.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning category row: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category rows: %w", err)
+	}
+
+	return categories, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCategory scans a row selecting id, parent_id, name, slug, created_at and updated_at
+func scanCategory(row rowScanner) (*entity.Category, error) {
+	var category entity.Category
+	var parentID uuid.NullUUID
+	err := row.Scan(
+		&category.ID,
+		&parentID,
+		&category.Name,
+		&category.Slug,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		category.ParentID = &parentID.UUID
+	}
+	return &category, nil
+}
+
+// scanProductImage scans a row selecting productImageColumns
+func scanProductImage(row rowScanner) (*entity.ProductImage, error) {
+	var image entity.ProductImage
+	err := row.Scan(
+		&image.ID,
+		&image.ProductID,
+		&image.Position,
+		&image.Key,
+		&image.ThumbnailKey,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.Size,
+		&image.AltText,
+		&image.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// scanProductReview scans a row selecting productReviewColumns
+func scanProductReview(row rowScanner) (*entity.ProductReview, error) {
+	var review entity.ProductReview
+	err := row.Scan(
+		&review.ID,
+		&review.ProductID,
+		&review.UserID,
+		&review.AuthorName,
+		&review.Rating,
+		&review.Body,
+		&review.Status,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation of the named constraint
+func isUniqueViolation(err error, constraint string) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// ProductImagePostgresRepository implements ProductImageRepository using PostgreSQL
+type ProductImagePostgresRepository struct {
+	db *sql.DB
+}
+
+// NewProductImageRepository creates a new product image repository
+func NewProductImageRepository(db *sql.DB) ProductImageRepository {
+	return &ProductImagePostgresRepository{
+		db: db,
+	}
+}
+
+// ListByProduct retrieves the images of a product in display order
+func (r *ProductImagePostgresRepository) ListByProduct(ctx context.Context, productID uuid.UUID) ([]*entity.ProductImage, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductImageRepository.ListByProduct",
+		"product_id", productID.String(),
+	)
+	logger.Debug("Listing product images")
+
+	query := `
+		SELECT ` + productImageColumns + `
+		FROM product_images
+		WHERE product_id = $1
+		ORDER BY position, created_at
+	`
+
+	rows, err := persistence.QueryableFromContext(ctx, r.db).QueryContext(ctx, query, productID)
+	if err != nil {
+		logger.Error("Failed to query product images", "error", err.Error())
+		return nil, fmt.Errorf("error querying product images: %w", err)
+	}
+	defer rows.Close()
+
+	images := []*entity.ProductImage{}
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			logger.Error("Failed to scan product image row", "error", err.Error())
+			return nil, fmt.Errorf("error scanning product image row: %w", err)
+		}
+		images = append(images, image)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate product image rows", "error", err.Error())
+		return nil, fmt.Errorf("error iterating product image rows: %w", err)
+	}
+
+	return images, nil
+}
+
+// Create saves an image after the other images of its product
+func (r *ProductImagePostgresRepository) Create(ctx context.Context, image *entity.ProductImage) error {
+	logger := middleware.Logger.With(
+		"method", "ProductImageRepository.Create",
+		"product_id", image.ProductID.String(),
+		"image_id", image.ID.String(),
+	)
+	logger.Debug("Creating product image")
+	startTime := time.Now()
+
+	if image.ID == uuid.Nil {
+		image.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO product_images (id, product_id, position, blob_key, thumbnail_key, content_type, width, height, size_bytes, alt_text)
+		SELECT $1, $2, COALESCE(MAX(position) + 1, 0), $3, $4, $5, $6, $7, $8, $9
+		FROM product_images
+		WHERE product_id = $2
+		RETURNING position, created_at
+	`
+
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query,
+		image.ID,
+		image.ProductID,
+		image.Key,
+		image.ThumbnailKey,
+		image.ContentType,
+		image.Width,
+		image.Height,
+		image.Size,
+		image.AltText,
+	).Scan(&image.Position, &image.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			logger.Warn("Product not found", "error", "ErrProductNotFound")
+			return domainErrors.ErrProductNotFound
+		}
+		logger.Error("Failed to create product image", "error", err.Error())
+		return fmt.Errorf("error creating product image: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created product image",
+		"position", image.Position,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// Delete deletes an image and closes the gap it leaves in one statement
+func (r *ProductImagePostgresRepository) Delete(ctx context.Context, productID, imageID uuid.UUID) (*entity.ProductImage, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductImageRepository.Delete",
+		"product_id", productID.String(),
+		"image_id", imageID.String(),
+	)
+	logger.Debug("Deleting product image")
+	startTime := time.Now()
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM product_images
+			WHERE id = $1 AND product_id = $2
+			RETURNING ` + productImageColumns + `
+		), shifted AS (
+			UPDATE product_images SET position = product_images.position - 1
+			FROM deleted
+			WHERE product_images.product_id = deleted.product_id AND product_images.position > deleted.position
+		)
+		SELECT ` + productImageColumns + ` FROM deleted
+	`
+
+	image, err := scanProductImage(persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, imageID, productID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Product image not found", "error", "ErrProductImageNotFound")
+			return nil, domainErrors.ErrProductImageNotFound
+		}
+		logger.Error("Failed to delete product image", "error", err.Error())
+		return nil, fmt.Errorf("error deleting product image: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted product image",
+		"duration_ms", duration.Milliseconds())
+
+	return image, nil
+}
+
+// Reorder sets the position of every image to its index in imageIDs
+func (r *ProductImagePostgresRepository) Reorder(ctx context.Context, productID uuid.UUID, imageIDs []uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "ProductImageRepository.Reorder",
+		"product_id", productID.String(),
+		"image_count", len(imageIDs),
+	)
+	logger.Debug("Reordering product images")
+	startTime := time.Now()
+
+	idStrings := make([]string, len(imageIDs))
+	for i, id := range imageIDs {
+		idStrings[i] = id.String()
+	}
+
+	query := `
+		UPDATE product_images
+		SET position = ordered.ordinality - 1
+		FROM unnest($2::uuid[]) WITH ORDINALITY AS ordered(id, ordinality)
+		WHERE product_images.id = ordered.id AND product_images.product_id = $1
+	`
+
+	result, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, query, productID, pq.Array(idStrings))
+	if err != nil {
+		logger.Error("Failed to reorder product images", "error", err.Error())
+		return fmt.Errorf("error reordering product images: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if int(rowsAffected) != len(imageIDs) {
+		logger.Warn("Product image not found", "error", "ErrProductImageNotFound", "updated", rowsAffected)
+		return domainErrors.ErrProductImageNotFound
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully reordered product images",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// ProductReviewPostgresRepository implements ProductReviewRepository using PostgreSQL
+type ProductReviewPostgresRepository struct {
+	db *sql.DB
+}
+
+// NewProductReviewRepository creates a new product review repository
+func NewProductReviewRepository(db *sql.DB) ProductReviewRepository {
+	return &ProductReviewPostgresRepository{
+		db: db,
+	}
+}
+
+// GetByID retrieves a review by its ID
+func (r *ProductReviewPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.ProductReview, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductReviewRepository.GetByID",
+		"review_id", id.String(),
+	)
+	logger.Debug("Fetching review by ID")
+
+	query := `
+		SELECT ` + productReviewColumns + `
+		FROM product_reviews reviews
+		LEFT JOIN users ON users.id = reviews.user_id
+		WHERE reviews.id = $1
+	`
+
+	review, err := scanProductReview(persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Review not found", "error", "ErrReviewNotFound")
+			return nil, domainErrors.ErrReviewNotFound
+		}
+		logger.Error("Failed to query review by ID", "error", err.Error())
+		return nil, fmt.Errorf("error querying review by ID: %w", err)
+	}
+
+	return review, nil
+}
+
+// List retrieves a page of reviews matching the filter, newest first
+func (r *ProductReviewPostgresRepository) List(ctx context.Context, req pagination.Request, filter entity.ReviewFilter) ([]*entity.ProductReview, pagination.Result, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductReviewRepository.List",
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
+	)
+	if filter.ProductID != nil {
+		logger = logger.With("product_id", filter.ProductID.String())
+	}
+	if filter.Status != nil {
+		logger = logger.With("status", string(*filter.Status))
+	}
+	logger.Debug("Listing reviews with filters")
+	startTime := time.Now()
+
+	whereClause := "WHERE TRUE"
+	args := []interface{}{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.ProductID != nil {
+		whereClause += " AND reviews.product_id = " + arg(*filter.ProductID)
+	}
+	if filter.Status != nil {
+		whereClause += " AND reviews.status = " + arg(string(*filter.Status))
+	}
+
+	db := persistence.QueryableFromContext(ctx, r.db)
+
+	// Count total matches first, when asked for
+	var total int
+	if req.IncludeTotal {
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM product_reviews reviews %s`, whereClause)
+		if err := db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+			logger.Error("Failed to count reviews", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("error counting reviews: %w", err)
+		}
+	}
+
+	afterCursor, err := productReviewKeyset.Where(req, arg)
+	if err != nil {
+		logger.Warn("Invalid input: cursor", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+	if afterCursor != "" {
+		whereClause += " AND " + afterCursor
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM product_reviews reviews
+		LEFT JOIN users ON users.id = reviews.user_id
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, productReviewColumns, productReviewKeyset.Select(), whereClause, productReviewKeyset.OrderBy(req), arg(req.FetchLimit()), arg(req.Offset()))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Failed to query reviews", "error", err.Error())
+		return nil, pagination.Result{}, fmt.Errorf("error querying reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []*entity.ProductReview{}
+	keys := [][]*string{}
+	for rows.Next() {
+		var review entity.ProductReview
+		keyDest, rowKeys := productReviewKeyset.ScanKeys()
+		err := rows.Scan(append([]interface{}{
+			&review.ID,
+			&review.ProductID,
+			&review.UserID,
+			&review.AuthorName,
+			&review.Rating,
+			&review.Body,
+			&review.Status,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+		}, keyDest...)...)
+		if err != nil {
+			logger.Error("Failed to scan review row", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("error scanning review row: %w", err)
+		}
+		reviews = append(reviews, &review)
+		keys = append(keys, rowKeys)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate review rows", "error", err.Error())
+		return nil, pagination.Result{}, fmt.Errorf("error iterating review rows: %w", err)
+	}
+
+	reviews, result := pagination.Page(reviews, keys, req, productReviewKeyset, total)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed reviews",
+		"total_count", total,
+		"returned_count", len(reviews),
+		"duration_ms", duration.Milliseconds())
+
+	return reviews, result, nil
+}
+
+// Create saves a review and reads back the name of its author
+func (r *ProductReviewPostgresRepository) Create(ctx context.Context, review *entity.ProductReview) error {
+	logger := middleware.Logger.With(
+		"method", "ProductReviewRepository.Create",
+		"product_id", review.ProductID.String(),
+		"user_id", review.UserID.String(),
+	)
+	logger.Debug("Creating product review")
+	startTime := time.Now()
+
+	if review.ID == uuid.Nil {
+		review.ID = uuid.New()
+	}
+
+	query := `
+		WITH reviews AS (
+			INSERT INTO product_reviews (id, product_id, user_id, rating, body, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING *
+		)
+		SELECT ` + productReviewColumns + `
+		FROM reviews
+		LEFT JOIN users ON users.id = reviews.user_id
+	`
+
+	created, err := scanProductReview(persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query,
+		review.ID,
+		review.ProductID,
+		review.UserID,
+		review.Rating,
+		review.Body,
+		string(review.Status),
+		review.CreatedAt,
+		review.UpdatedAt,
+	))
+	if err != nil {
+		if isUniqueViolation(err, "product_reviews_product_id_user_id_key") {
+			logger.Warn("Product already reviewed", "error", "ErrReviewAlreadyExists")
+			return domainErrors.ErrReviewAlreadyExists
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" && pqErr.Constraint == "product_reviews_product_id_fkey" {
+			logger.Warn("Product not found", "error", "ErrProductNotFound")
+			return domainErrors.ErrProductNotFound
+		}
+		logger.Error("Failed to create product review", "error", err.Error())
+		return fmt.Errorf("error creating product review: %w", err)
+	}
+	*review = *created
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created product review",
+		"review_id", review.ID.String(),
+		"rating", review.Rating,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// UpdateStatus sets the moderation status of a review
+func (r *ProductReviewPostgresRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status entity.ReviewStatus) (*entity.ProductReview, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductReviewRepository.UpdateStatus",
+		"review_id", id.String(),
+		"status", string(status),
+	)
+	logger.Debug("Updating review status")
+	startTime := time.Now()
+
+	query := `
+		WITH reviews AS (
+			UPDATE product_reviews
+			SET status = $2, updated_at = NOW()
+			WHERE id = $1
+			RETURNING *
+		)
+		SELECT ` + productReviewColumns + `
+		FROM reviews
+		LEFT JOIN users ON users.id = reviews.user_id
+	`
+
+	review, err := scanProductReview(persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, id, string(status)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Review not found", "error", "ErrReviewNotFound")
+			return nil, domainErrors.ErrReviewNotFound
+		}
+		logger.Error("Failed to update review status", "error", err.Error())
+		return nil, fmt.Errorf("error updating review status: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated review status",
+		"duration_ms", duration.Milliseconds())
+
+	return review, nil
+}
+
+// Delete deletes a review
+func (r *ProductReviewPostgresRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "ProductReviewRepository.Delete",
+		"review_id", id.String(),
+	)
+	logger.Debug("Deleting review")
+	startTime := time.Now()
+
+	result, err := persistence.QueryableFromContext(ctx, r.db).ExecContext(ctx, `DELETE FROM product_reviews WHERE id = $1`, id)
+	if err != nil {
+		logger.Error("Failed to delete review", "error", err.Error())
+		return fmt.Errorf("error deleting review: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("Failed to get rows affected", "error", err.Error())
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		logger.Warn("Review not found", "error", "ErrReviewNotFound")
+		return domainErrors.ErrReviewNotFound
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted review",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// HasDelivered reports whether a delivered order of the user contains the product itself or, for
+// a parent, one of its variants
+func (r *ProductReviewPostgresRepository) HasDelivered(ctx context.Context, userID, productID uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM checkouts c
+			JOIN checkout_items ci ON ci.checkout_id = c.id
+			JOIN products p ON p.id = ci.product_id
+			WHERE c.user_id = $1 AND c.status = 'DELIVERED'
+				AND (p.id = $2 OR p.parent_id = $2)
+		)
+	`
+
+	var delivered bool
+	if err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, userID, productID).Scan(&delivered); err != nil {
+		middleware.Logger.Error("Failed to query delivered orders",
+			"method", "ProductReviewRepository.HasDelivered",
+			"user_id", userID.String(),
+			"product_id", productID.String(),
+			"error", err.Error())
+		return false, fmt.Errorf("error querying delivered orders: %w", err)
+	}
+	return delivered, nil
+}
@@ -1,45 +1,188 @@
 package usecase
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
 	"github.com/fanzru/e-commerce-be/internal/app/product/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/app/product/repo"
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/storage"
+	"github.com/fanzru/e-commerce-be/pkg/imaging"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
+// maxSearchQueryLength is the longest search query accepted
+const maxSearchQueryLength = 200
+
+// maxFilterAttributes is the largest number of options a product list can be filtered on
+const maxFilterAttributes = 10
+
 // productUseCase implements the ProductUseCase interface
 type productUseCase struct {
-	productRepo repo.ProductRepository
+	productRepo  repo.ProductRepository
+	categoryRepo repo.CategoryRepository
+	imageRepo    repo.ProductImageRepository
+	searcher     repo.ProductSearcher
+	txManager    *persistence.TransactionManager
+	blobs        storage.BlobStore
 }
 
-// NewProductUseCase creates a new instance of productUseCase
-func NewProductUseCase(productRepo repo.ProductRepository) ProductUseCase {
+// NewProductUseCase creates a new instance of productUseCase. Product images are stored in blobs.
+func NewProductUseCase(productRepo repo.ProductRepository, categoryRepo repo.CategoryRepository, imageRepo repo.ProductImageRepository, searcher repo.ProductSearcher, txManager *persistence.TransactionManager, blobs storage.BlobStore) ProductUseCase {
 	return &productUseCase{
-		productRepo: productRepo,
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		imageRepo:    imageRepo,
+		searcher:     searcher,
+		txManager:    txManager,
+		blobs:        blobs,
 	}
 }
 
-// List returns a list of products with pagination and filtering
-func (u *productUseCase) List(ctx context.Context, page, limit int, sku, name string) ([]*entity.Product, int, error) {
+// List returns a page of products matching the filter with the facet counts of the filter
+func (u *productUseCase) List(ctx context.Context, req pagination.Request, category string, filter entity.ProductFilter) (*ProductList, error) {
 	logger := middleware.Logger.With(
 		"method", "ProductUseCase.List",
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
-	if sku != "" {
-		logger = logger.With("sku", sku)
+	if filter.SKU != "" {
+		logger = logger.With("sku", filter.SKU)
+	}
+	if filter.Name != "" {
+		logger = logger.With("name", filter.Name)
+	}
+	if category != "" {
+		logger = logger.With("category", category)
 	}
-	if name != "" {
-		logger = logger.With("name", name)
+	if filter.Sort != "" {
+		logger = logger.With("sort", string(filter.Sort))
 	}
 	logger.Info("Listing products with filters")
 	startTime := time.Now()
 
+	if err := validateProductFilter(&filter); err != nil {
+		logger.Warn("Invalid input: product filter", "error", err.Error())
+		return nil, err
+	}
+
+	if category != "" {
+		found, err := u.findCategory(ctx, category)
+		if err != nil {
+			logger.Warn("Failed to resolve category filter", "error", err.Error())
+			return nil, err
+		}
+		filter.CategoryID = &found.ID
+	}
+
+	products, page, err := u.productRepo.List(ctx, req, filter)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			logger.Warn("Invalid input: cursor does not match the sort", "error", err.Error())
+			return nil, commonErrs.NewBadRequest("invalid cursor for this sort")
+		}
+		logger.Error("Failed to list products", "error", err.Error())
+		return nil, fmt.Errorf("error listing products: %w", err)
+	}
+
+	facets, err := u.productRepo.Facets(ctx, filter)
+	if err != nil {
+		logger.Error("Failed to count product facets", "error", err.Error())
+		return nil, fmt.Errorf("error counting product facets: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed products",
+		"returned", len(products),
+		"duration_ms", duration.Milliseconds())
+
+	u.resolveImageURLs(products...)
+
+	return &ProductList{
+		Products: products,
+		Page:     page,
+		Facets:   facets,
+	}, nil
+}
+
+// validateProductFilter checks the price range, sort and attributes of a filter and defaults its
+// sort to newest first
+func validateProductFilter(filter *entity.ProductFilter) error {
+	if filter.Sort == "" {
+		filter.Sort = entity.ProductSortNewest
+	}
+	if !filter.Sort.IsValid() {
+		return commonErrs.NewBadRequest(fmt.Sprintf("invalid sort: %s", filter.Sort))
+	}
+
+	if filter.MinPrice != nil && filter.MinPrice.IsNegative() {
+		return commonErrs.NewBadRequest("min_price cannot be negative")
+	}
+	if filter.MaxPrice != nil && filter.MaxPrice.IsNegative() {
+		return commonErrs.NewBadRequest("max_price cannot be negative")
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && filter.MaxPrice.LessThan(*filter.MinPrice) {
+		return commonErrs.NewBadRequest("max_price must not be below min_price")
+	}
+
+	if len(filter.Attributes) > maxFilterAttributes {
+		return commonErrs.NewBadRequest(fmt.Sprintf("at most %d attributes can be filtered on", maxFilterAttributes))
+	}
+	for name, values := range filter.Attributes {
+		if name == "" || len(values) == 0 {
+			return commonErrs.NewBadRequest("attribute filters must be given as name:value")
+		}
+	}
+
+	return nil
+}
+
+// Search returns the products matching a free-text query, most relevant first
+func (u *productUseCase) Search(ctx context.Context, query string, page, limit int) ([]*entity.SearchResult, int, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.Search",
+		"query", query,
+		"page", page,
+		"limit", limit,
+	)
+	logger.Info("Searching products")
+	startTime := time.Now()
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		logger.Warn("Invalid input: Empty search query")
+		return nil, 0, commonErrs.NewBadRequest("search query is required")
+	}
+	if len(query) > maxSearchQueryLength {
+		logger.Warn("Invalid input: Search query too long")
+		return nil, 0, commonErrs.NewBadRequest(fmt.Sprintf("search query must be at most %d characters", maxSearchQueryLength))
+	}
+
 	// Validate pagination parameters
 	if page < 1 {
 		page = 1
@@ -48,19 +191,23 @@ func (u *productUseCase) List(ctx context.Context, page, limit int, sku, name st
 		limit = 10
 	}
 
-	products, total, err := u.productRepo.List(ctx, page, limit, sku, name)
+	results, total, err := u.searcher.Search(ctx, query, page, limit)
 	if err != nil {
-		logger.Error("Failed to list products", "error", err.Error())
-		return nil, 0, fmt.Errorf("error listing products: %w", err)
+		logger.Error("Failed to search products", "error", err.Error())
+		return nil, 0, fmt.Errorf("error searching products: %w", err)
+	}
+
+	for _, result := range results {
+		u.resolveImageURLs(result.Product)
 	}
 
 	duration := time.Since(startTime)
-	logger.Info("Successfully listed products",
+	logger.Info("Successfully searched products",
 		"total", total,
-		"returned", len(products),
+		"returned", len(results),
 		"duration_ms", duration.Milliseconds())
 
-	return products, total, nil
+	return results, total, nil
 }
 
 // GetByID returns a product by its ID
@@ -77,6 +224,7 @@ func (u *productUseCase) GetByID(ctx context.Context, id uuid.UUID) (*entity.Pro
 		logger.Error("Failed to get product", "error", err.Error())
 		return nil, fmt.Errorf("error getting product: %w", err)
 	}
+	u.resolveImageURLs(product)
 
 	duration := time.Since(startTime)
 	logger.Info("Successfully retrieved product",
@@ -90,7 +238,7 @@ func (u *productUseCase) GetByID(ctx context.Context, id uuid.UUID) (*entity.Pro
 }
 
 // Create creates a new product
-func (u *productUseCase) Create(ctx context.Context, sku, name string, price float64, inventory int) (*entity.Product, error) {
+func (u *productUseCase) Create(ctx context.Context, sku, name, description string, price money.Money, inventory int, options entity.ProductOptions) (*entity.Product, error) {
 	logger := middleware.Logger.With(
 		"method", "ProductUseCase.Create",
 		"sku", sku,
@@ -110,7 +258,7 @@ func (u *productUseCase) Create(ctx context.Context, sku, name string, price flo
 		logger.Warn("Invalid input: Empty name", "error", "ErrInvalidInput")
 		return nil, errs.ErrInvalidInput
 	}
-	if price <= 0 {
+	if !price.IsPositive() {
 		logger.Warn("Invalid input: Price must be positive", "error", "ErrInvalidInput")
 		return nil, errs.ErrInvalidInput
 	}
@@ -118,14 +266,25 @@ func (u *productUseCase) Create(ctx context.Context, sku, name string, price flo
 		logger.Warn("Invalid input: Inventory must be non-negative", "error", "ErrInvalidInput")
 		return nil, errs.ErrInvalidInput
 	}
+	if err := options.Validate(); err != nil {
+		logger.Warn("Invalid input: Invalid options", "error", err.Error())
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("invalid options: %v", err))
+	}
 
 	// Create product entity
 	product := &entity.Product{
-		ID:        uuid.New(),
-		SKU:       sku,
-		Name:      name,
-		Price:     price,
-		Inventory: inventory,
+		ID:          uuid.New(),
+		SKU:         sku,
+		Name:        name,
+		Description: description,
+		Price:       price,
+		Inventory:   inventory,
+		Options:     options,
+	}
+
+	// A parent's stock is the stock of its variants
+	if product.HasVariants() {
+		product.Inventory = 0
 	}
 
 	// Save to repository
@@ -144,7 +303,7 @@ func (u *productUseCase) Create(ctx context.Context, sku, name string, price flo
 }
 
 // Update updates an existing product
-func (u *productUseCase) Update(ctx context.Context, id uuid.UUID, name string, price float64, inventory int) (*entity.Product, error) {
+func (u *productUseCase) Update(ctx context.Context, id uuid.UUID, name string, description *string, price money.Money, inventory int, options entity.ProductOptions) (*entity.Product, error) {
 	logger := middleware.Logger.With(
 		"method", "ProductUseCase.Update",
 		"product_id", id.String(),
@@ -160,7 +319,7 @@ func (u *productUseCase) Update(ctx context.Context, id uuid.UUID, name string,
 		logger.Warn("Invalid input: Empty name", "error", "ErrInvalidInput")
 		return nil, errs.ErrInvalidInput
 	}
-	if price <= 0 {
+	if !price.IsPositive() {
 		logger.Warn("Invalid input: Price must be positive", "error", "ErrInvalidInput")
 		return nil, errs.ErrInvalidInput
 	}
@@ -176,18 +335,57 @@ func (u *productUseCase) Update(ctx context.Context, id uuid.UUID, name string,
 		return nil, fmt.Errorf("error getting product for update: %w", err)
 	}
 
+	if product.IsVariant() {
+		logger.Warn("Invalid input: Product is a variant")
+		return nil, commonErrs.NewBadRequest("product is a variant; update it through its parent's variants")
+	}
+
+	if options != nil {
+		if err := options.Validate(); err != nil {
+			logger.Warn("Invalid input: Invalid options", "error", err.Error())
+			return nil, commonErrs.NewBadRequest(fmt.Sprintf("invalid options: %v", err))
+		}
+		// The existing variants have to stay valid under the new definitions
+		if len(options) == 0 && len(product.Variants) > 0 {
+			logger.Warn("Invalid input: Removing options of a product with variants")
+			return nil, commonErrs.NewBadRequest("delete the variants before removing the options")
+		}
+		for _, variant := range product.Variants {
+			if err := options.Allows(variant.OptionValues); err != nil {
+				logger.Warn("Invalid input: Options exclude a variant", "variant_sku", variant.SKU, "error", err.Error())
+				return nil, commonErrs.NewBadRequest(fmt.Sprintf("variant %s: %v", variant.SKU, err))
+			}
+		}
+		product.Options = options
+	}
+
 	// Update fields
 	product.Name = name
 	product.Price = price
 	product.Inventory = inventory
+	if description != nil {
+		product.Description = *description
+	}
 
-	// Save to repository
-	err = u.productRepo.Update(ctx, product)
+	// A parent's stock is the stock of its variants, which take their name and price from it
+	if product.HasVariants() {
+		product.Inventory = 0
+		for _, variant := range product.Variants {
+			variant.ApplyParent(product)
+		}
+	}
+
+	// Save the product, its price history and its variants together
+	err = u.txManager.RunInTransaction(ctx, func(ctx context.Context) error {
+		return u.productRepo.Update(ctx, product)
+	})
 	if err != nil {
 		logger.Error("Failed to update product", "error", err.Error())
 		return nil, fmt.Errorf("error updating product: %w", err)
 	}
 
+	u.resolveImageURLs(product)
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully updated product",
 		"duration_ms", duration.Milliseconds())
@@ -216,3 +414,1539 @@ func (u *productUseCase) Delete(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// SetCategories replaces the categories a product is listed in
+func (u *productUseCase) SetCategories(ctx context.Context, id uuid.UUID, categoryIDs []uuid.UUID) (*entity.Product, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.SetCategories",
+		"product_id", id.String(),
+		"category_count", len(categoryIDs),
+	)
+	logger.Info("Setting product categories")
+	startTime := time.Now()
+
+	categories, err := u.categoryRepo.GetByIDs(ctx, categoryIDs)
+	if err != nil {
+		logger.Error("Failed to get categories", "error", err.Error())
+		return nil, fmt.Errorf("error getting categories: %w", err)
+	}
+	found := make(map[uuid.UUID]bool, len(categories))
+	for _, category := range categories {
+		found[category.ID] = true
+	}
+	for _, categoryID := range categoryIDs {
+		if !found[categoryID] {
+			logger.Warn("Invalid input: Unknown category", "category_id", categoryID.String())
+			return nil, errs.NewCategoryNotFoundError(categoryID.String())
+		}
+	}
+
+	// The product stays locked until its old categories are replaced
+	err = u.txManager.RunInTransaction(ctx, func(ctx context.Context) error {
+		return u.productRepo.SetCategories(ctx, id, categoryIDs)
+	})
+	if err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			logger.Warn("Product not found", "error", err.Error())
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, id))
+		}
+		logger.Error("Failed to set product categories", "error", err.Error())
+		return nil, fmt.Errorf("error setting product categories: %w", err)
+	}
+
+	product, err := u.productRepo.GetByID(ctx, id)
+	if err != nil {
+		logger.Error("Failed to get product", "error", err.Error())
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+
+	u.resolveImageURLs(product)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully set product categories",
+		"duration_ms", duration.Milliseconds())
+
+	return product, nil
+}
+
+// findCategory resolves a category given as an ID or a slug
+func (u *productUseCase) findCategory(ctx context.Context, idOrSlug string) (*entity.Category, error) {
+	var category *entity.Category
+	var err error
+	if id, parseErr := uuid.Parse(idOrSlug); parseErr == nil {
+		category, err = u.categoryRepo.GetByID(ctx, id)
+	} else {
+		category, err = u.categoryRepo.GetBySlug(ctx, idOrSlug)
+	}
+	if err != nil {
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			return nil, errs.NewCategoryNotFoundError(idOrSlug)
+		}
+		return nil, fmt.Errorf("error getting category: %w", err)
+	}
+	return category, nil
+}
+
+// maxCategoryNameLength is the longest name a category can have
+const maxCategoryNameLength = 255
+
+// categoryUseCase implements the CategoryUseCase interface
+type categoryUseCase struct {
+	categoryRepo repo.CategoryRepository
+	txManager    *persistence.TransactionManager
+}
+
+// NewCategoryUseCase creates a new instance of categoryUseCase
+func NewCategoryUseCase(categoryRepo repo.CategoryRepository, txManager *persistence.TransactionManager) CategoryUseCase {
+	return &categoryUseCase{
+		categoryRepo: categoryRepo,
+		txManager:    txManager,
+	}
+}
+
+// List returns every category, parents before their children
+func (u *categoryUseCase) List(ctx context.Context) ([]*entity.Category, error) {
+	logger := middleware.Logger.With("method", "CategoryUseCase.List")
+	logger.Info("Listing categories")
+	startTime := time.Now()
+
+	categories, err := u.categoryRepo.List(ctx)
+	if err != nil {
+		logger.Error("Failed to list categories", "error", err.Error())
+		return nil, fmt.Errorf("error listing categories: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed categories",
+		"returned", len(categories),
+		"duration_ms", duration.Milliseconds())
+
+	return categories, nil
+}
+
+// GetByID returns a category by its ID
+func (u *categoryUseCase) GetByID(ctx context.Context, id uuid.UUID) (*entity.Category, error) {
+	logger := middleware.Logger.With(
+		"method", "CategoryUseCase.GetByID",
+		"category_id", id.String(),
+	)
+	logger.Info("Getting category by ID")
+	startTime := time.Now()
+
+	category, err := u.categoryRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			logger.Warn("Category not found", "error", err.Error())
+			return nil, errs.NewCategoryNotFoundError(id.String())
+		}
+		logger.Error("Failed to get category", "error", err.Error())
+		return nil, fmt.Errorf("error getting category: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved category",
+		"slug", category.Slug,
+		"duration_ms", duration.Milliseconds())
+
+	return category, nil
+}
+
+// Create creates a new category
+func (u *categoryUseCase) Create(ctx context.Context, parentID *uuid.UUID, name, slug string) (*entity.Category, error) {
+	logger := middleware.Logger.With(
+		"method", "CategoryUseCase.Create",
+		"name", name,
+		"slug", slug,
+	)
+	logger.Info("Creating new category")
+	startTime := time.Now()
+
+	if err := validateCategory(name, slug); err != nil {
+		logger.Warn("Invalid input: Invalid category", "error", err.Error())
+		return nil, err
+	}
+
+	if parentID != nil {
+		if _, err := u.GetByID(ctx, *parentID); err != nil {
+			logger.Warn("Failed to get parent category", "error", err.Error())
+			return nil, err
+		}
+	}
+
+	category := entity.NewCategory(parentID, name, slug)
+	if err := u.categoryRepo.Create(ctx, category); err != nil {
+		if errors.Is(err, errs.ErrCategorySlugAlreadyExists) {
+			return nil, errs.NewCategorySlugAlreadyExistsError(slug)
+		}
+		logger.Error("Failed to create category", "error", err.Error())
+		return nil, fmt.Errorf("error creating category: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created category",
+		"category_id", category.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return category, nil
+}
+
+// Update moves and renames a category. A category cannot be moved under itself or one of its
+// descendants, which would cut its subtree off from the root. The check and the move run in one
+// transaction holding locks on the categories involved, so concurrent moves cannot form a cycle
+// between them.
+func (u *categoryUseCase) Update(ctx context.Context, id uuid.UUID, parentID *uuid.UUID, name, slug string) (*entity.Category, error) {
+	logger := middleware.Logger.With(
+		"method", "CategoryUseCase.Update",
+		"category_id", id.String(),
+		"name", name,
+		"slug", slug,
+	)
+	logger.Info("Updating category")
+	startTime := time.Now()
+
+	if err := validateCategory(name, slug); err != nil {
+		logger.Warn("Invalid input: Invalid category", "error", err.Error())
+		return nil, err
+	}
+
+	if parentID != nil && *parentID == id {
+		logger.Warn("Invalid input: Category under itself", "error", "ErrCategoryCycle")
+		return nil, commonErrs.NewBadRequest(errs.ErrCategoryCycle.Error())
+	}
+
+	var category *entity.Category
+	err := u.txManager.RunInTransaction(ctx, func(ctx context.Context) error {
+		if parentID != nil {
+			if err := u.categoryRepo.LockForMove(ctx, id, *parentID); err != nil {
+				logger.Error("Failed to lock categories", "error", err.Error())
+				return fmt.Errorf("error locking categories: %w", err)
+			}
+		}
+
+		var err error
+		category, err = u.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			if _, err := u.GetByID(ctx, *parentID); err != nil {
+				logger.Warn("Failed to get parent category", "error", err.Error())
+				return err
+			}
+
+			descendantIDs, err := u.categoryRepo.GetDescendantIDs(ctx, id)
+			if err != nil {
+				logger.Error("Failed to get category descendants", "error", err.Error())
+				return fmt.Errorf("error getting category descendants: %w", err)
+			}
+			for _, descendantID := range descendantIDs {
+				if descendantID == *parentID {
+					logger.Warn("Invalid input: Category under a descendant", "error", "ErrCategoryCycle")
+					return commonErrs.NewBadRequest(errs.ErrCategoryCycle.Error())
+				}
+			}
+		}
+
+		category.ParentID = parentID
+		category.Name = name
+		category.Slug = slug
+
+		if err := u.categoryRepo.Update(ctx, category); err != nil {
+			switch {
+			case errors.Is(err, errs.ErrCategoryNotFound):
+				return errs.NewCategoryNotFoundError(id.String())
+			case errors.Is(err, errs.ErrCategorySlugAlreadyExists):
+				return errs.NewCategorySlugAlreadyExistsError(slug)
+			}
+			logger.Error("Failed to update category", "error", err.Error())
+			return fmt.Errorf("error updating category: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated category",
+		"duration_ms", duration.Milliseconds())
+
+	return category, nil
+}
+
+// Delete deletes a category without children
+func (u *categoryUseCase) Delete(ctx context.Context, id uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CategoryUseCase.Delete",
+		"category_id", id.String(),
+	)
+	logger.Info("Deleting category")
+	startTime := time.Now()
+
+	if err := u.categoryRepo.Delete(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrCategoryNotFound):
+			return errs.NewCategoryNotFoundError(id.String())
+		case errors.Is(err, errs.ErrCategoryHasChildren):
+			return errs.NewCategoryHasChildrenError(id.String())
+		}
+		logger.Error("Failed to delete category", "error", err.Error())
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted category",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// validateCategory checks the name and slug of a category
+func validateCategory(name, slug string) error {
+	if name == "" {
+		return commonErrs.NewBadRequest("category name is required")
+	}
+	if len(name) > maxCategoryNameLength {
+		return commonErrs.NewBadRequest(fmt.Sprintf("category name must be at most %d characters", maxCategoryNameLength))
+	}
+	if !entity.IsValidSlug(slug) {
+		return commonErrs.NewBadRequest(fmt.Sprintf(
+			"invalid category slug %q: use lower-case letters, digits and single hyphens, at most %d characters",
+			slug, entity.MaxSlugLength,
+		))
+	}
+	return nil
+}
+
+// CreateVariant adds a variant to a parent product
+func (u *productUseCase) CreateVariant(ctx context.Context, parentID uuid.UUID, sku string, values entity.OptionValues, priceOverride *money.Money, inventory int) (*entity.Product, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.CreateVariant",
+		"parent_id", parentID.String(),
+		"sku", sku,
+		"inventory", inventory,
+	)
+	logger.Info("Creating product variant")
+	startTime := time.Now()
+
+	if sku == "" {
+		logger.Warn("Invalid input: Empty SKU")
+		return nil, commonErrs.NewBadRequest("sku is required")
+	}
+	if err := validateVariant(priceOverride, inventory); err != nil {
+		logger.Warn("Invalid input: Invalid variant", "error", err.Error())
+		return nil, err
+	}
+
+	parent, err := u.getParent(ctx, parentID)
+	if err != nil {
+		logger.Warn("Failed to get parent product", "error", err.Error())
+		return nil, err
+	}
+	if err := parent.Options.Allows(values); err != nil {
+		logger.Warn("Invalid input: Invalid option values", "error", err.Error())
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("invalid option values: %v", err))
+	}
+
+	variant := entity.NewVariant(parent, sku, values, priceOverride, inventory)
+	if err := u.productRepo.Create(ctx, variant); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrProductSKUAlreadyExists):
+			return nil, commonErrs.NewConflict(fmt.Sprintf("%s: %s", errs.ErrProductAlreadyExistsMsg, sku))
+		case errors.Is(err, errs.ErrVariantAlreadyExists):
+			return nil, commonErrs.NewConflict(fmt.Sprintf("%v: %s", errs.ErrVariantAlreadyExists, parent.Options.Label(values)))
+		}
+		logger.Error("Failed to create variant", "error", err.Error())
+		return nil, fmt.Errorf("error creating variant: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created product variant",
+		"variant_id", variant.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return variant, nil
+}
+
+// UpdateVariant changes the option values, price override and inventory of a variant
+func (u *productUseCase) UpdateVariant(ctx context.Context, parentID, variantID uuid.UUID, values entity.OptionValues, priceOverride *money.Money, inventory int) (*entity.Product, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.UpdateVariant",
+		"parent_id", parentID.String(),
+		"variant_id", variantID.String(),
+		"inventory", inventory,
+	)
+	logger.Info("Updating product variant")
+	startTime := time.Now()
+
+	if err := validateVariant(priceOverride, inventory); err != nil {
+		logger.Warn("Invalid input: Invalid variant", "error", err.Error())
+		return nil, err
+	}
+
+	parent, err := u.getParent(ctx, parentID)
+	if err != nil {
+		logger.Warn("Failed to get parent product", "error", err.Error())
+		return nil, err
+	}
+	variant := findVariant(parent, variantID)
+	if variant == nil {
+		logger.Warn("Variant not found")
+		return nil, commonErrs.NewNotFound(fmt.Sprintf("variant %s not found under product %s", variantID, parentID))
+	}
+	if err := parent.Options.Allows(values); err != nil {
+		logger.Warn("Invalid input: Invalid option values", "error", err.Error())
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("invalid option values: %v", err))
+	}
+
+	variant.OptionValues = values
+	variant.PriceOverride = priceOverride
+	variant.Inventory = inventory
+	variant.ApplyParent(parent)
+
+	if err := u.productRepo.Update(ctx, variant); err != nil {
+		if errors.Is(err, errs.ErrVariantAlreadyExists) {
+			return nil, commonErrs.NewConflict(fmt.Sprintf("%v: %s", errs.ErrVariantAlreadyExists, parent.Options.Label(values)))
+		}
+		logger.Error("Failed to update variant", "error", err.Error())
+		return nil, fmt.Errorf("error updating variant: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated product variant",
+		"duration_ms", duration.Milliseconds())
+
+	return variant, nil
+}
+
+// DeleteVariant deletes a variant of a parent product
+func (u *productUseCase) DeleteVariant(ctx context.Context, parentID, variantID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.DeleteVariant",
+		"parent_id", parentID.String(),
+		"variant_id", variantID.String(),
+	)
+	logger.Info("Deleting product variant")
+	startTime := time.Now()
+
+	parent, err := u.getParent(ctx, parentID)
+	if err != nil {
+		logger.Warn("Failed to get parent product", "error", err.Error())
+		return err
+	}
+	if findVariant(parent, variantID) == nil {
+		logger.Warn("Variant not found")
+		return commonErrs.NewNotFound(fmt.Sprintf("variant %s not found under product %s", variantID, parentID))
+	}
+
+	if err := u.productRepo.Delete(ctx, variantID); err != nil {
+		logger.Error("Failed to delete variant", "error", err.Error())
+		return fmt.Errorf("error deleting variant: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted product variant",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// getParent returns a product that can have variants, with the variants it has
+func (u *productUseCase) getParent(ctx context.Context, id uuid.UUID) (*entity.Product, error) {
+	parent, err := u.productRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, id))
+		}
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+	if !parent.HasVariants() {
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("product %s has no options; define options before adding variants", parent.SKU))
+	}
+	return parent, nil
+}
+
+// findVariant returns the variant of the parent with the given ID, or nil
+func findVariant(parent *entity.Product, id uuid.UUID) *entity.Product {
+	for _, variant := range parent.Variants {
+		if variant.ID == id {
+			return variant
+		}
+	}
+	return nil
+}
+
+// validateVariant checks the price override and inventory of a variant
+func validateVariant(priceOverride *money.Money, inventory int) error {
+	if priceOverride != nil && !priceOverride.IsPositive() {
+		return commonErrs.NewBadRequest("price must be positive")
+	}
+	if inventory < 0 {
+		return commonErrs.NewBadRequest("inventory must be non-negative")
+	}
+	return nil
+}
+
+// maxImportRows is the largest number of rows an import file can have
+const maxImportRows = 10000
+
+// Column limits of the products table
+const (
+	maxProductSKULength  = 50
+	maxProductNameLength = 255
+	maxProductInventory  = math.MaxInt32
+)
+
+// maxProductPrice is the largest price the products table holds
+var maxProductPrice = money.FromMinor(9999999999)
+
+// productFileColumns are the columns of product import and export files, in export order
+var productFileColumns = []string{"sku", "name", "description", "price", "inventory"}
+
+// requiredImportColumns must be present in every import file
+var requiredImportColumns = []string{"sku", "name", "price", "inventory"}
+
+// errImportRolledBack rolls back the transaction of a dry run or a rejected atomic import
+var errImportRolledBack = errors.New("product import rolled back")
+
+// importRecord is a line of an import file, with the value of every column it has. err is set
+// when the line cannot be read.
+type importRecord struct {
+	line   int
+	values map[string]string
+	err    error
+}
+
+// productFileRecord is a product as written to an NDJSON export
+type productFileRecord struct {
+	SKU         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Inventory   int         `json:"inventory"`
+}
+
+// Import creates or updates standalone products keyed by SKU from a CSV or NDJSON file
+func (u *productUseCase) Import(ctx context.Context, format entity.ProductFileFormat, file io.Reader, mode entity.ProductImportMode) (*entity.ProductImportResult, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.Import",
+		"format", string(format),
+		"mode", string(mode),
+	)
+	logger.Info("Importing products")
+	startTime := time.Now()
+
+	if !format.IsValid() {
+		logger.Warn("Invalid input: Unsupported format")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("unsupported file format %q, use csv or ndjson", format))
+	}
+	if !mode.IsValid() {
+		logger.Warn("Invalid input: Unsupported mode")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("unsupported import mode %q, use partial, atomic or dry_run", mode))
+	}
+
+	var records []importRecord
+	var err error
+	if format == entity.ProductFileFormatCSV {
+		records, err = readCSVImportRecords(file)
+	} else {
+		records, err = readNDJSONImportRecords(file)
+	}
+	if err != nil {
+		logger.Warn("Invalid input: Unreadable import file", "error", err.Error())
+		return nil, err
+	}
+
+	result := &entity.ProductImportResult{
+		Mode:   mode,
+		Rows:   len(records),
+		Errors: []entity.ProductImportError{},
+	}
+
+	// Validate every row before saving any, so a dry run reports the same errors as a real import
+	rows := make([]*entity.ProductImportRow, 0, len(records))
+	firstLines := map[string]int{}
+	for _, record := range records {
+		row, err := parseImportRecord(record)
+		if err != nil {
+			result.Errors = append(result.Errors, entity.ProductImportError{
+				Line:    record.line,
+				SKU:     record.values["sku"],
+				Message: err.Error(),
+			})
+			continue
+		}
+		if firstLine, ok := firstLines[row.SKU]; ok {
+			result.Errors = append(result.Errors, entity.ProductImportError{
+				Line:    row.Line,
+				SKU:     row.SKU,
+				Message: fmt.Sprintf("duplicate SKU, first listed on line %d", firstLine),
+			})
+			continue
+		}
+		firstLines[row.SKU] = row.Line
+		rows = append(rows, row)
+	}
+
+	save := func(ctx context.Context) error {
+		for _, row := range rows {
+			created, err := u.productRepo.UpsertBySKU(ctx, row)
+			if errors.Is(err, errs.ErrProductSKUAlreadyExists) {
+				result.Errors = append(result.Errors, entity.ProductImportError{
+					Line:    row.Line,
+					SKU:     row.SKU,
+					Message: "SKU belongs to a deleted product or a product with variants; only standalone products can be imported",
+				})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if created {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+		}
+
+		if mode == entity.ProductImportModeDryRun || len(result.Errors) > 0 && mode == entity.ProductImportModeAtomic {
+			return errImportRolledBack
+		}
+		return nil
+	}
+
+	// A dry run saves in a transaction that is always rolled back, so it also finds the rows the
+	// database would refuse
+	if mode == entity.ProductImportModePartial {
+		err = save(ctx)
+	} else {
+		err = u.txManager.RunInTransaction(ctx, save)
+	}
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		logger.Error("Failed to import products", "error", err.Error())
+		return nil, fmt.Errorf("error importing products: %w", err)
+	}
+	if mode == entity.ProductImportModeAtomic && len(result.Errors) > 0 {
+		result.Created = 0
+		result.Updated = 0
+	}
+
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully imported products",
+		"rows", result.Rows,
+		"created", result.Created,
+		"updated", result.Updated,
+		"error_count", len(result.Errors),
+		"duration_ms", duration.Milliseconds())
+
+	return result, nil
+}
+
+// Export writes every standalone product to w as CSV or NDJSON
+func (u *productUseCase) Export(ctx context.Context, format entity.ProductFileFormat, w io.Writer) error {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.Export",
+		"format", string(format),
+	)
+	logger.Info("Exporting products")
+	startTime := time.Now()
+
+	if !format.IsValid() {
+		logger.Warn("Invalid input: Unsupported format")
+		return commonErrs.NewBadRequest(fmt.Sprintf("unsupported file format %q, use csv or ndjson", format))
+	}
+
+	// Nothing reaches w before the first rows are read, so a failing query can still be reported
+	buffered := bufio.NewWriter(w)
+	var write func(*entity.Product) error
+	var flush func() error
+	if format == entity.ProductFileFormatCSV {
+		writer := csv.NewWriter(buffered)
+		if err := writer.Write(productFileColumns); err != nil {
+			return fmt.Errorf("error writing export: %w", err)
+		}
+		write = func(product *entity.Product) error {
+			return writer.Write([]string{
+				product.SKU,
+				product.Name,
+				product.Description,
+				product.Price.String(),
+				strconv.Itoa(product.Inventory),
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		encoder := json.NewEncoder(buffered)
+		write = func(product *entity.Product) error {
+			return encoder.Encode(productFileRecord{
+				SKU:         product.SKU,
+				Name:        product.Name,
+				Description: product.Description,
+				Price:       product.Price,
+				Inventory:   product.Inventory,
+			})
+		}
+		flush = func() error { return nil }
+	}
+
+	count := 0
+	err := u.productRepo.ForEachStandalone(ctx, func(product *entity.Product) error {
+		count++
+		return write(product)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		logger.Error("Failed to export products", "exported_count", count, "error", err.Error())
+		return fmt.Errorf("error exporting products: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully exported products",
+		"exported_count", count,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// readCSVImportRecords reads a CSV import file whose header row names the columns
+func readCSVImportRecords(file io.Reader) ([]importRecord, error) {
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, commonErrs.NewBadRequest("the import file is empty")
+	}
+	if err != nil {
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("malformed CSV header: %v", err))
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		// Spreadsheets often save CSV with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+	if err := validateImportColumns(columns); err != nil {
+		return nil, err
+	}
+
+	records := []importRecord{}
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(records) == maxImportRows {
+			return nil, commonErrs.NewBadRequest(fmt.Sprintf("the import file has more than %d rows", maxImportRows))
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			record := importRecord{
+				line:   parseErr.StartLine,
+				values: map[string]string{},
+				err:    fmt.Errorf("expected %d fields, found %d", len(columns), len(fields)),
+			}
+			for i := 0; i < len(columns) && i < len(fields); i++ {
+				record.values[columns[i]] = strings.TrimSpace(fields[i])
+			}
+			records = append(records, record)
+			continue
+		}
+		if err != nil {
+			return nil, commonErrs.NewBadRequest(fmt.Sprintf("malformed CSV: %v", err))
+		}
+
+		line, _ := reader.FieldPos(0)
+		values := make(map[string]string, len(columns))
+		for i, column := range columns {
+			values[column] = strings.TrimSpace(fields[i])
+		}
+		records = append(records, importRecord{line: line, values: values})
+	}
+	return records, nil
+}
+
+// readNDJSONImportRecords reads an NDJSON import file, one object per line; blank lines are skipped
+func readNDJSONImportRecords(file io.Reader) ([]importRecord, error) {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	records := []importRecord{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(records) == maxImportRows {
+			return nil, commonErrs.NewBadRequest(fmt.Sprintf("the import file has more than %d rows", maxImportRows))
+		}
+
+		var object map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			records = append(records, importRecord{line: line, err: fmt.Errorf("invalid JSON object: %v", err)})
+			continue
+		}
+
+		record := importRecord{line: line, values: make(map[string]string, len(object))}
+		for key, value := range object {
+			if !slices.Contains(productFileColumns, key) {
+				record.err = fmt.Errorf("unknown field %q", key)
+				break
+			}
+			switch value := value.(type) {
+			case nil:
+			case string:
+				record.values[key] = strings.TrimSpace(value)
+			case json.Number:
+				record.values[key] = value.String()
+			default:
+				record.err = fmt.Errorf("field %q must be a string or a number", key)
+			}
+			if record.err != nil {
+				break
+			}
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("unreadable NDJSON on line %d: %v", line+1, err))
+	}
+	if line == 0 {
+		return nil, commonErrs.NewBadRequest("the import file is empty")
+	}
+	return records, nil
+}
+
+// validateImportColumns checks the header of a CSV import file
+func validateImportColumns(columns []string) error {
+	seen := map[string]bool{}
+	for _, column := range columns {
+		if !slices.Contains(productFileColumns, column) {
+			return commonErrs.NewBadRequest(fmt.Sprintf("unknown column %q, use %s", column, strings.Join(productFileColumns, ", ")))
+		}
+		if seen[column] {
+			return commonErrs.NewBadRequest(fmt.Sprintf("duplicate column %q", column))
+		}
+		seen[column] = true
+	}
+	for _, column := range requiredImportColumns {
+		if !seen[column] {
+			return commonErrs.NewBadRequest(fmt.Sprintf("missing column %q", column))
+		}
+	}
+	return nil
+}
+
+// parseImportRecord validates a line of an import file the way Create validates a product
+func parseImportRecord(record importRecord) (*entity.ProductImportRow, error) {
+	if record.err != nil {
+		return nil, record.err
+	}
+
+	row := &entity.ProductImportRow{
+		Line: record.line,
+		SKU:  record.values["sku"],
+		Name: record.values["name"],
+	}
+	if row.SKU == "" {
+		return nil, errors.New("sku is required")
+	}
+	if len(row.SKU) > maxProductSKULength {
+		return nil, fmt.Errorf("sku must be at most %d characters", maxProductSKULength)
+	}
+	if row.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(row.Name) > maxProductNameLength {
+		return nil, fmt.Errorf("name must be at most %d characters", maxProductNameLength)
+	}
+	if description, ok := record.values["description"]; ok {
+		row.Description = &description
+	}
+
+	price, err := money.Parse(record.values["price"])
+	if err != nil {
+		return nil, fmt.Errorf("price %q is not a decimal amount", record.values["price"])
+	}
+	if !price.IsPositive() {
+		return nil, errors.New("price must be positive")
+	}
+	if price.GreaterThan(maxProductPrice) {
+		return nil, fmt.Errorf("price must be at most %s", maxProductPrice)
+	}
+	row.Price = price
+
+	inventory, err := strconv.Atoi(record.values["inventory"])
+	if err != nil {
+		return nil, fmt.Errorf("inventory %q is not a whole number", record.values["inventory"])
+	}
+	if inventory < 0 || inventory > maxProductInventory {
+		return nil, fmt.Errorf("inventory must be between 0 and %d", maxProductInventory)
+	}
+	row.Inventory = inventory
+
+	return row, nil
+}
+
+// GetPriceHistory returns the price of a product at a moment with its past and scheduled prices
+func (u *productUseCase) GetPriceHistory(ctx context.Context, id uuid.UUID, at time.Time) (*entity.ProductPriceHistory, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.GetPriceHistory",
+		"product_id", id.String(),
+		"at", at,
+	)
+	logger.Info("Getting product price history")
+	startTime := time.Now()
+
+	product, err := u.productRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			logger.Warn("Product not found")
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, id))
+		}
+		logger.Error("Failed to get product", "error", err.Error())
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+
+	// A variant without a price override has its parent's prices; one with an override has none
+	owner := product.ID
+	if product.IsVariant() && product.PriceOverride == nil {
+		owner = *product.ParentID
+	}
+
+	prices, err := u.productRepo.ListPrices(ctx, owner)
+	if err != nil {
+		logger.Error("Failed to list product prices", "error", err.Error())
+		return nil, fmt.Errorf("error listing product prices: %w", err)
+	}
+
+	price, err := u.productRepo.GetPriceAt(ctx, id, at)
+	if err != nil {
+		logger.Error("Failed to get product price", "error", err.Error())
+		return nil, fmt.Errorf("error getting product price: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved product price history",
+		"price", price,
+		"price_count", len(prices),
+		"duration_ms", duration.Milliseconds())
+
+	return &entity.ProductPriceHistory{
+		ProductID: product.ID,
+		At:        at,
+		Price:     price,
+		Prices:    prices,
+	}, nil
+}
+
+// SchedulePrice schedules a future price of a standalone or parent product
+func (u *productUseCase) SchedulePrice(ctx context.Context, id uuid.UUID, price money.Money, effectiveFrom time.Time, effectiveTo *time.Time) (*entity.ProductPrice, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.SchedulePrice",
+		"product_id", id.String(),
+		"price", price,
+		"effective_from", effectiveFrom,
+	)
+	logger.Info("Scheduling product price")
+	startTime := time.Now()
+
+	if !price.IsPositive() {
+		logger.Warn("Invalid input: Price must be positive")
+		return nil, commonErrs.NewBadRequest("price must be positive")
+	}
+	if price.GreaterThan(maxProductPrice) {
+		logger.Warn("Invalid input: Price too large")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("price must be at most %s", maxProductPrice))
+	}
+	if !effectiveFrom.After(time.Now()) {
+		logger.Warn("Invalid input: Price does not start in the future")
+		return nil, commonErrs.NewBadRequest("effective_from must be in the future; update the product to change its price now")
+	}
+	if effectiveTo != nil && !effectiveTo.After(effectiveFrom) {
+		logger.Warn("Invalid input: Price ends before it starts")
+		return nil, commonErrs.NewBadRequest("effective_to must be after effective_from")
+	}
+
+	product, err := u.productRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			logger.Warn("Product not found")
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, id))
+		}
+		logger.Error("Failed to get product", "error", err.Error())
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+	if product.IsVariant() {
+		logger.Warn("Invalid input: Product is a variant")
+		return nil, commonErrs.NewBadRequest("product is a variant; its price follows its parent's or its price override")
+	}
+
+	scheduled := &entity.ProductPrice{
+		ID:            uuid.New(),
+		ProductID:     product.ID,
+		Price:         price,
+		EffectiveFrom: effectiveFrom,
+		EffectiveTo:   effectiveTo,
+	}
+	if err := u.productRepo.SchedulePrice(ctx, scheduled); err != nil {
+		logger.Error("Failed to schedule product price", "error", err.Error())
+		return nil, fmt.Errorf("error scheduling product price: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully scheduled product price",
+		"price_id", scheduled.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return scheduled, nil
+}
+
+// ApplyScheduledPrices copies the prices in effect to the price column that lists are sorted and
+// filtered on. It returns the number of products whose price changed.
+func (u *productUseCase) ApplyScheduledPrices(ctx context.Context) (int, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.ApplyScheduledPrices",
+	)
+	logger.Debug("Applying scheduled product prices")
+	startTime := time.Now()
+
+	changed, err := u.productRepo.ApplyScheduledPrices(ctx)
+	if err != nil {
+		logger.Error("Failed to apply scheduled prices", "error", err.Error())
+		return 0, fmt.Errorf("error applying scheduled prices: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	if changed > 0 {
+		logger.Info("Successfully applied scheduled product prices",
+			"changed_count", changed,
+			"duration_ms", duration.Milliseconds())
+	}
+
+	return changed, nil
+}
+
+// Product image limits
+const (
+	// MaxImageSize is the largest image file accepted, in bytes
+	MaxImageSize = 10 << 20
+
+	// maxImagePixels bounds the decoded size of an image, which a small compressed file can make
+	// far larger than the file itself
+	maxImagePixels = 40_000_000
+
+	// maxProductImages is the number of images a product can have
+	maxProductImages = 20
+
+	// maxImageAltTextLength is the longest alt text of an image
+	maxImageAltTextLength = 255
+
+	// thumbnailSize is the width and height thumbnails fit within
+	thumbnailSize = 400
+
+	// thumbnailJPEGQuality is the JPEG quality thumbnails of opaque images are encoded at
+	thumbnailJPEGQuality = 85
+)
+
+// imageExtensions are the accepted image content types, sniffed from the file, with the file
+// extension of their blobs
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// UploadImage stores an image of a standalone or parent product with its thumbnail and adds it
+// after the product's other images
+func (u *productUseCase) UploadImage(ctx context.Context, productID uuid.UUID, file io.Reader, altText string) (*entity.ProductImage, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.UploadImage",
+		"product_id", productID.String(),
+	)
+	logger.Info("Uploading product image")
+	startTime := time.Now()
+
+	altText = strings.TrimSpace(altText)
+	if len(altText) > maxImageAltTextLength {
+		logger.Warn("Invalid input: Alt text too long")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("alt_text must be at most %d characters", maxImageAltTextLength))
+	}
+
+	product, err := u.productRepo.GetByID(ctx, productID)
+	if err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			logger.Warn("Product not found")
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, productID))
+		}
+		logger.Error("Failed to get product", "error", err.Error())
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+	if product.IsVariant() {
+		logger.Warn("Invalid input: Product is a variant")
+		return nil, commonErrs.NewBadRequest("product is a variant; upload images to its parent")
+	}
+	if len(product.Images) >= maxProductImages {
+		logger.Warn("Invalid input: Too many images", "image_count", len(product.Images))
+		return nil, commonErrs.NewConflict(fmt.Sprintf("a product can have at most %d images", maxProductImages))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		if errors.Is(err, errs.ErrImageTooLarge) {
+			logger.Warn("Invalid input: Image too large")
+			return nil, errs.NewImageTooLargeError(MaxImageSize)
+		}
+		logger.Error("Failed to read image", "error", err.Error())
+		return nil, fmt.Errorf("error reading image: %w", err)
+	}
+	if len(data) > MaxImageSize {
+		logger.Warn("Invalid input: Image too large")
+		return nil, errs.NewImageTooLargeError(MaxImageSize)
+	}
+	if len(data) == 0 {
+		logger.Warn("Invalid input: Empty image")
+		return nil, commonErrs.NewBadRequest("image file is empty")
+	}
+
+	// The content type comes from the file itself, not from what the client declared
+	contentType := http.DetectContentType(data)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		logger.Warn("Invalid input: Unsupported image type", "content_type", contentType)
+		return nil, errs.NewUnsupportedImageTypeError(contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		logger.Warn("Invalid input: Unreadable image", "error", err.Error())
+		return nil, commonErrs.NewBadRequest("image is corrupt or truncated")
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		logger.Warn("Invalid input: Image dimensions out of range", "width", config.Width, "height", config.Height)
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("images must be at most %d megapixels", maxImagePixels/1_000_000))
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		logger.Warn("Invalid input: Unreadable image", "error", err.Error())
+		return nil, commonErrs.NewBadRequest("image is corrupt or truncated")
+	}
+
+	thumbnail, thumbnailType, thumbnailExtension, err := encodeThumbnail(decoded)
+	if err != nil {
+		logger.Error("Failed to encode thumbnail", "error", err.Error())
+		return nil, fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+
+	img := &entity.ProductImage{
+		ID:          uuid.New(),
+		ProductID:   product.ID,
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Size:        int64(len(data)),
+		AltText:     altText,
+	}
+	img.Key = fmt.Sprintf("products/%s/%s%s", product.ID, img.ID, extension)
+	img.ThumbnailKey = fmt.Sprintf("products/%s/%s_thumb%s", product.ID, img.ID, thumbnailExtension)
+
+	if err := u.blobs.Put(ctx, img.Key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		logger.Error("Failed to store image", "error", err.Error())
+		return nil, fmt.Errorf("error storing image: %w", err)
+	}
+	if err := u.blobs.Put(ctx, img.ThumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), thumbnailType); err != nil {
+		logger.Error("Failed to store thumbnail", "error", err.Error())
+		u.deleteImageBlobs(ctx, img)
+		return nil, fmt.Errorf("error storing thumbnail: %w", err)
+	}
+
+	if err := u.imageRepo.Create(ctx, img); err != nil {
+		u.deleteImageBlobs(ctx, img)
+		if errors.Is(err, errs.ErrProductNotFound) {
+			logger.Warn("Product not found")
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, productID))
+		}
+		logger.Error("Failed to create product image", "error", err.Error())
+		return nil, fmt.Errorf("error creating product image: %w", err)
+	}
+	u.resolveImageURL(img)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully uploaded product image",
+		"image_id", img.ID.String(),
+		"content_type", contentType,
+		"size", img.Size,
+		"position", img.Position,
+		"duration_ms", duration.Milliseconds())
+
+	return img, nil
+}
+
+// DeleteImage deletes an image of a product with its stored files
+func (u *productUseCase) DeleteImage(ctx context.Context, productID, imageID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.DeleteImage",
+		"product_id", productID.String(),
+		"image_id", imageID.String(),
+	)
+	logger.Info("Deleting product image")
+	startTime := time.Now()
+
+	img, err := u.imageRepo.Delete(ctx, productID, imageID)
+	if err != nil {
+		if errors.Is(err, errs.ErrProductImageNotFound) {
+			logger.Warn("Product image not found")
+			return commonErrs.NewNotFound(fmt.Sprintf("%v: %s", errs.ErrProductImageNotFound, imageID))
+		}
+		logger.Error("Failed to delete product image", "error", err.Error())
+		return fmt.Errorf("error deleting product image: %w", err)
+	}
+
+	// The image is gone once its record is; files left behind by a failure here are only orphaned
+	u.deleteImageBlobs(ctx, img)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted product image",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// ReorderImages puts the images of a product in the given order
+func (u *productUseCase) ReorderImages(ctx context.Context, productID uuid.UUID, imageIDs []uuid.UUID) ([]*entity.ProductImage, error) {
+	logger := middleware.Logger.With(
+		"method", "ProductUseCase.ReorderImages",
+		"product_id", productID.String(),
+		"image_count", len(imageIDs),
+	)
+	logger.Info("Reordering product images")
+	startTime := time.Now()
+
+	if _, err := u.productRepo.GetByID(ctx, productID); err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			logger.Warn("Product not found")
+			return nil, commonErrs.NewNotFound(fmt.Sprintf("%s: %s", errs.ErrProductNotFoundMsg, productID))
+		}
+		logger.Error("Failed to get product", "error", err.Error())
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+
+	var images []*entity.ProductImage
+	err := u.txManager.RunInTransaction(ctx, func(ctx context.Context) error {
+		current, err := u.imageRepo.ListByProduct(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		listed := make(map[uuid.UUID]bool, len(imageIDs))
+		for _, id := range imageIDs {
+			listed[id] = true
+		}
+		complete := len(listed) == len(imageIDs) && len(imageIDs) == len(current)
+		for _, img := range current {
+			complete = complete && listed[img.ID]
+		}
+		if !complete {
+			return commonErrs.NewBadRequest("image_ids must list every image of the product exactly once")
+		}
+
+		if err := u.imageRepo.Reorder(ctx, productID, imageIDs); err != nil {
+			return err
+		}
+
+		images, err = u.imageRepo.ListByProduct(ctx, productID)
+		return err
+	})
+	if err != nil {
+		var appErr *commonErrs.AppError
+		if errors.As(err, &appErr) {
+			logger.Warn("Invalid input: Image order", "error", err.Error())
+			return nil, err
+		}
+		logger.Error("Failed to reorder product images", "error", err.Error())
+		return nil, fmt.Errorf("error reordering product images: %w", err)
+	}
+	u.resolveImageURL(images...)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully reordered product images",
+		"duration_ms", duration.Milliseconds())
+
+	return images, nil
+}
+
+// encodeThumbnail scales an image down to a thumbnail. Opaque thumbnails are JPEG; those with
+// transparency are PNG to keep it. It returns the encoded thumbnail with its content type and
+// file extension.
+func encodeThumbnail(src image.Image) ([]byte, string, string, error) {
+	thumbnail := imaging.Thumbnail(src, thumbnailSize, thumbnailSize)
+
+	var buf bytes.Buffer
+	if thumbnail.Opaque() {
+		if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	}
+	if err := png.Encode(&buf, thumbnail); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", ".png", nil
+}
+
+// deleteImageBlobs deletes the stored files of an image, logging rather than returning failures
+func (u *productUseCase) deleteImageBlobs(ctx context.Context, img *entity.ProductImage) {
+	for _, key := range []string{img.Key, img.ThumbnailKey} {
+		if err := u.blobs.Delete(ctx, key); err != nil {
+			middleware.Logger.Warn("Failed to delete image file", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// resolveImageURLs sets the URLs of the images of products from their blob keys
+func (u *productUseCase) resolveImageURLs(products ...*entity.Product) {
+	for _, product := range products {
+		u.resolveImageURL(product.Images...)
+	}
+}
+
+// resolveImageURL sets the URLs of images from their blob keys
+func (u *productUseCase) resolveImageURL(images ...*entity.ProductImage) {
+	for _, img := range images {
+		img.URL = u.blobs.URL(img.Key)
+		img.ThumbnailURL = u.blobs.URL(img.ThumbnailKey)
+	}
+}
+
+// reviewUseCase implements the ReviewUseCase interface
+type reviewUseCase struct {
+	reviewRepo  repo.ProductReviewRepository
+	productRepo repo.ProductRepository
+}
+
+// NewReviewUseCase creates a new instance of reviewUseCase
+func NewReviewUseCase(reviewRepo repo.ProductReviewRepository, productRepo repo.ProductRepository) ReviewUseCase {
+	return &reviewUseCase{
+		reviewRepo:  reviewRepo,
+		productRepo: productRepo,
+	}
+}
+
+// ListByProduct returns a page of the published reviews of a product
+func (u *reviewUseCase) ListByProduct(ctx context.Context, productID uuid.UUID, req pagination.Request) ([]*entity.ProductReview, pagination.Result, error) {
+	logger := middleware.Logger.With(
+		"method", "ReviewUseCase.ListByProduct",
+		"product_id", productID.String(),
+		"page", req.Page,
+		"limit", req.Limit,
+	)
+	logger.Info("Listing product reviews")
+	startTime := time.Now()
+
+	if _, err := u.getReviewableProduct(ctx, productID); err != nil {
+		logger.Warn("Failed to get product", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+
+	published := entity.ReviewStatusPublished
+	reviews, page, err := u.reviewRepo.List(ctx, req, entity.ReviewFilter{ProductID: &productID, Status: &published})
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			logger.Warn("Invalid input: cursor", "error", err.Error())
+			return nil, pagination.Result{}, commonErrs.NewBadRequest("invalid cursor")
+		}
+		logger.Error("Failed to list reviews", "error", err.Error())
+		return nil, pagination.Result{}, fmt.Errorf("error listing reviews: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed reviews",
+		"returned", len(reviews),
+		"duration_ms", duration.Milliseconds())
+
+	return reviews, page, nil
+}
+
+// List returns a page of reviews of any status matching the filter
+func (u *reviewUseCase) List(ctx context.Context, req pagination.Request, filter entity.ReviewFilter) ([]*entity.ProductReview, pagination.Result, error) {
+	logger := middleware.Logger.With(
+		"method", "ReviewUseCase.List",
+		"page", req.Page,
+		"limit", req.Limit,
+	)
+	logger.Info("Listing reviews")
+	startTime := time.Now()
+
+	if filter.Status != nil && !filter.Status.IsValid() {
+		logger.Warn("Invalid input: review status", "status", string(*filter.Status))
+		return nil, pagination.Result{}, commonErrs.NewBadRequest(fmt.Sprintf("invalid review status: %s", *filter.Status))
+	}
+
+	reviews, page, err := u.reviewRepo.List(ctx, req, filter)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			logger.Warn("Invalid input: cursor", "error", err.Error())
+			return nil, pagination.Result{}, commonErrs.NewBadRequest("invalid cursor")
+		}
+		logger.Error("Failed to list reviews", "error", err.Error())
+		return nil, pagination.Result{}, fmt.Errorf("error listing reviews: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed reviews",
+		"returned", len(reviews),
+		"duration_ms", duration.Milliseconds())
+
+	return reviews, page, nil
+}
+
+// Create publishes the review of a customer who received the product
+func (u *reviewUseCase) Create(ctx context.Context, productID, userID uuid.UUID, rating int, body string) (*entity.ProductReview, error) {
+	logger := middleware.Logger.With(
+		"method", "ReviewUseCase.Create",
+		"product_id", productID.String(),
+		"user_id", userID.String(),
+		"rating", rating,
+	)
+	logger.Info("Creating product review")
+	startTime := time.Now()
+
+	if rating < entity.MinReviewRating || rating > entity.MaxReviewRating {
+		logger.Warn("Invalid input: Rating out of range")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("rating must be between %d and %d", entity.MinReviewRating, entity.MaxReviewRating))
+	}
+	body = strings.TrimSpace(body)
+	if utf8.RuneCountInString(body) > entity.MaxReviewBodyLength {
+		logger.Warn("Invalid input: Review too long")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("body must be at most %d characters", entity.MaxReviewBodyLength))
+	}
+
+	if _, err := u.getReviewableProduct(ctx, productID); err != nil {
+		logger.Warn("Failed to get product", "error", err.Error())
+		return nil, err
+	}
+
+	delivered, err := u.reviewRepo.HasDelivered(ctx, userID, productID)
+	if err != nil {
+		logger.Error("Failed to check delivered orders", "error", err.Error())
+		return nil, fmt.Errorf("error checking delivered orders: %w", err)
+	}
+	if !delivered {
+		logger.Warn("Customer has no delivered order of the product")
+		return nil, errs.NewReviewNotAllowedError(productID.String())
+	}
+
+	review := entity.NewProductReview(productID, userID, rating, body)
+	if err := u.reviewRepo.Create(ctx, review); err != nil {
+		switch {
+		case errors.Is(err, errs.ErrReviewAlreadyExists):
+			logger.Warn("Product already reviewed by customer")
+			return nil, errs.NewReviewAlreadyExistsError(productID.String())
+		case errors.Is(err, errs.ErrProductNotFound):
+			logger.Warn("Product not found")
+			return nil, errs.NewProductNotFoundError(productID.String())
+		}
+		logger.Error("Failed to create review", "error", err.Error())
+		return nil, fmt.Errorf("error creating review: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created product review",
+		"review_id", review.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return review, nil
+}
+
+// SetStatus publishes or hides a review
+func (u *reviewUseCase) SetStatus(ctx context.Context, id uuid.UUID, status entity.ReviewStatus) (*entity.ProductReview, error) {
+	logger := middleware.Logger.With(
+		"method", "ReviewUseCase.SetStatus",
+		"review_id", id.String(),
+		"status", string(status),
+	)
+	logger.Info("Moderating review")
+	startTime := time.Now()
+
+	if !status.IsValid() {
+		logger.Warn("Invalid input: review status")
+		return nil, commonErrs.NewBadRequest(fmt.Sprintf("invalid review status: %s", status))
+	}
+
+	review, err := u.reviewRepo.UpdateStatus(ctx, id, status)
+	if err != nil {
+		if errors.Is(err, errs.ErrReviewNotFound) {
+			logger.Warn("Review not found")
+			return nil, errs.NewReviewNotFoundError(id.String())
+		}
+		logger.Error("Failed to update review status", "error", err.Error())
+		return nil, fmt.Errorf("error updating review status: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully moderated review",
+		"duration_ms", duration.Milliseconds())
+
+	return review, nil
+}
+
+// Delete deletes a review
+func (u *reviewUseCase) Delete(ctx context.Context, id uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "ReviewUseCase.Delete",
+		"review_id", id.String(),
+	)
+	logger.Info("Deleting review")
+	startTime := time.Now()
+
+	if err := u.reviewRepo.Delete(ctx, id); err != nil {
+		if errors.Is(err, errs.ErrReviewNotFound) {
+			logger.Warn("Review not found")
+			return errs.NewReviewNotFoundError(id.String())
+		}
+		logger.Error("Failed to delete review", "error", err.Error())
+		return fmt.Errorf("error deleting review: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully deleted review",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// getReviewableProduct returns a standalone or parent product; variants are reviewed through
+// their parent
+func (u *reviewUseCase) getReviewableProduct(ctx context.Context, id uuid.UUID) (*entity.Product, error) {
+	product, err := u.productRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrProductNotFound) {
+			return nil, errs.NewProductNotFoundError(id.String())
+		}
+		return nil, fmt.Errorf("error getting product: %w", err)
+	}
+	if product.IsVariant() {
+		return nil, commonErrs.NewBadRequest("product is a variant; reviews belong to its parent")
+	}
+	return product, nil
+}
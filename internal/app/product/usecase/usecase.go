@@ -2,25 +2,122 @@ package usecase
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
+// ProductList is a page of products with the facet counts of the whole filtered list
+type ProductList struct {
+	Products []*entity.Product
+	Page     pagination.Result
+	Facets   *entity.ProductFacets
+}
+
 // ProductUseCase defines the interface for product use cases
 type ProductUseCase interface {
-	// List returns a list of products with pagination and filtering
-	List(ctx context.Context, page, limit int, sku, name string) ([]*entity.Product, int, error)
+	// List returns a page of products matching the filter, with facet counts for every filter
+	// value. A non-empty category, given as an ID or a slug, limits the list to products in that
+	// category or any of its descendants.
+	List(ctx context.Context, req pagination.Request, category string, filter entity.ProductFilter) (*ProductList, error)
 
-	// GetByID returns a product by its ID
+	// Search returns the products matching a free-text query, most relevant first
+	Search(ctx context.Context, query string, page, limit int) ([]*entity.SearchResult, int, error)
+
+	// GetByID returns a product by its ID; a parent product comes with its variants
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Product, error)
 
-	// Create creates a new product
-	Create(ctx context.Context, sku, name string, price float64, inventory int) (*entity.Product, error)
+	// Create creates a new product. A product with options is a parent sold through its variants.
+	Create(ctx context.Context, sku, name, description string, price money.Money, inventory int, options entity.ProductOptions) (*entity.Product, error)
 
-	// Update updates an existing product
-	Update(ctx context.Context, id uuid.UUID, name string, price float64, inventory int) (*entity.Product, error)
+	// Update updates an existing product; a nil description or nil options are left unchanged
+	Update(ctx context.Context, id uuid.UUID, name string, description *string, price money.Money, inventory int, options entity.ProductOptions) (*entity.Product, error)
 
 	// Delete deletes a product
 	Delete(ctx context.Context, id uuid.UUID) error
+
+	// SetCategories replaces the categories a product is listed in
+	SetCategories(ctx context.Context, id uuid.UUID, categoryIDs []uuid.UUID) (*entity.Product, error)
+
+	// CreateVariant adds a variant to a parent product; a nil priceOverride follows the parent's price
+	CreateVariant(ctx context.Context, parentID uuid.UUID, sku string, values entity.OptionValues, priceOverride *money.Money, inventory int) (*entity.Product, error)
+
+	// UpdateVariant changes the option values, price override and inventory of a variant
+	UpdateVariant(ctx context.Context, parentID, variantID uuid.UUID, values entity.OptionValues, priceOverride *money.Money, inventory int) (*entity.Product, error)
+
+	// DeleteVariant deletes a variant of a parent product
+	DeleteVariant(ctx context.Context, parentID, variantID uuid.UUID) error
+
+	// GetPriceHistory returns the price of a product in effect at a moment, with the past and
+	// scheduled prices of the product its price follows
+	GetPriceHistory(ctx context.Context, id uuid.UUID, at time.Time) (*entity.ProductPriceHistory, error)
+
+	// SchedulePrice schedules a price of a standalone or parent product starting in the future. A
+	// nil effectiveTo keeps the price until a later one replaces it; otherwise the earlier price
+	// returns when it ends.
+	SchedulePrice(ctx context.Context, id uuid.UUID, price money.Money, effectiveFrom time.Time, effectiveTo *time.Time) (*entity.ProductPrice, error)
+
+	// ApplyScheduledPrices brings the stored prices of products up to date with the prices in effect
+	// and returns the number of products changed; the scheduler calls it periodically
+	ApplyScheduledPrices(ctx context.Context) (int, error)
+
+	// UploadImage stores an image of a standalone or parent product, read from file, with a
+	// thumbnail and adds it after the product's other images. JPEG, PNG and GIF images of at most
+	// MaxImageSize bytes are accepted.
+	UploadImage(ctx context.Context, productID uuid.UUID, file io.Reader, altText string) (*entity.ProductImage, error)
+
+	// DeleteImage deletes an image of a product with its files
+	DeleteImage(ctx context.Context, productID, imageID uuid.UUID) error
+
+	// ReorderImages puts the images of a product in the order of imageIDs, which must list every
+	// one of them, and returns them in that order
+	ReorderImages(ctx context.Context, productID uuid.UUID, imageIDs []uuid.UUID) ([]*entity.ProductImage, error)
+
+	// Import creates or updates standalone products keyed by SKU from a CSV or NDJSON file. Rows
+	// that cannot be saved are reported in the result; the mode decides whether the others are.
+	Import(ctx context.Context, format entity.ProductFileFormat, file io.Reader, mode entity.ProductImportMode) (*entity.ProductImportResult, error)
+
+	// Export writes every standalone product to w in the import file format, as the rows are read
+	Export(ctx context.Context, format entity.ProductFileFormat, w io.Writer) error
+}
+
+// CategoryUseCase defines the interface for category use cases
+type CategoryUseCase interface {
+	// List returns every category, parents before their children
+	List(ctx context.Context) ([]*entity.Category, error)
+
+	// GetByID returns a category by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Category, error)
+
+	// Create creates a new category; a nil parentID makes it a top-level category
+	Create(ctx context.Context, parentID *uuid.UUID, name, slug string) (*entity.Category, error)
+
+	// Update moves and renames a category
+	Update(ctx context.Context, id uuid.UUID, parentID *uuid.UUID, name, slug string) (*entity.Category, error)
+
+	// Delete deletes a category without children
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// ReviewUseCase defines the interface for product review use cases
+type ReviewUseCase interface {
+	// ListByProduct returns a page of the published reviews of a product, newest first
+	ListByProduct(ctx context.Context, productID uuid.UUID, req pagination.Request) ([]*entity.ProductReview, pagination.Result, error)
+
+	// List returns a page of reviews of any status matching the filter, newest first, for moderation
+	List(ctx context.Context, req pagination.Request, filter entity.ReviewFilter) ([]*entity.ProductReview, pagination.Result, error)
+
+	// Create publishes the rating and review of a customer for a standalone or parent product. The
+	// customer must have a delivered order containing the product and may review it once.
+	Create(ctx context.Context, productID, userID uuid.UUID, rating int, body string) (*entity.ProductReview, error)
+
+	// SetStatus publishes or hides a review
+	SetStatus(ctx context.Context, id uuid.UUID, status entity.ReviewStatus) (*entity.ProductReview, error)
+
+	// Delete deletes a review, which lets its author review the product again
+	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"strings"
+	"time"
+
+	promotionErrors "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/errs"
+	"github.com/google/uuid"
+)
+
+// Coupon is a code that unlocks a promotion. Promotions with coupons are only applied to carts
+// that carry one of their codes.
+type Coupon struct {
+	ID                    uuid.UUID  `json:"id"`
+	Code                  string     `json:"code"`
+	PromotionID           uuid.UUID  `json:"promotion_id"`
+	MaxRedemptions        *int       `json:"max_redemptions,omitempty"`
+	MaxRedemptionsPerUser *int       `json:"max_redemptions_per_user,omitempty"`
+	RedemptionCount       int        `json:"redemption_count"`
+	ExpiresAt             *time.Time `json:"expires_at,omitempty"`
+	Active                bool       `json:"active"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// CouponRedemption records a checkout that redeemed a coupon
+type CouponRedemption struct {
+	ID          uuid.UUID `json:"id"`
+	CouponID    uuid.UUID `json:"coupon_id"`
+	PromotionID uuid.UUID `json:"promotion_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	CheckoutID  uuid.UUID `json:"checkout_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NormalizeCouponCode returns the canonical form of a coupon code. Codes are matched case-insensitively.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NewCoupon creates a new active coupon for a promotion
+func NewCoupon(promotionID uuid.UUID, code string, maxRedemptions, maxRedemptionsPerUser *int, expiresAt *time.Time) *Coupon {
+	now := time.Now()
+	return &Coupon{
+		ID:                    uuid.New(),
+		Code:                  NormalizeCouponCode(code),
+		PromotionID:           promotionID,
+		MaxRedemptions:        maxRedemptions,
+		MaxRedemptionsPerUser: maxRedemptionsPerUser,
+		ExpiresAt:             expiresAt,
+		Active:                true,
+		CreatedAt:             now,
+		UpdatedAt:             now,
+	}
+}
+
+// IsExpired reports whether the coupon's expiry has passed
+func (c *Coupon) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// CheckRedeemable returns the reason the coupon cannot be redeemed for its promotion by a user who
+// has already redeemed it userRedemptions times, or nil if it can be
+func (c *Coupon) CheckRedeemable(promotion *Promotion, userRedemptions int, now time.Time) error {
+	switch {
+	case !c.Active:
+		return promotionErrors.ErrCouponInactive
+	case c.IsExpired(now):
+		return promotionErrors.ErrCouponExpired
+	case c.MaxRedemptions != nil && c.RedemptionCount >= *c.MaxRedemptions:
+		return promotionErrors.ErrCouponUsageLimitReached
+	case c.MaxRedemptionsPerUser != nil && userRedemptions >= *c.MaxRedemptionsPerUser:
+		return promotionErrors.ErrCouponUserLimitReached
+	case promotion == nil || !promotion.IsEffective(now):
+		return promotionErrors.ErrCouponPromotionInactive
+	case promotion.MaxRedemptions != nil && promotion.RedemptionCount >= *promotion.MaxRedemptions:
+		return promotionErrors.ErrPromotionCapReached
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Eligibility restricts a promotion to some customers. Every predicate that is set must hold;
+// a promotion without predicates applies to everyone.
+type Eligibility struct {
+	// FirstOrderOnly limits the promotion to customers without a previous order
+	FirstOrderOnly bool `json:"first_order_only,omitempty"`
+
+	// Roles limits the promotion to customers with one of the user roles
+	Roles []string `json:"roles,omitempty"`
+
+	// Segments limits the promotion to customers in at least one of the named segments
+	Segments []string `json:"segments,omitempty"`
+
+	// MinLifetimeSpend limits the promotion to customers whose paid orders add up to at least
+	// this amount
+	MinLifetimeSpend *money.Money `json:"min_lifetime_spend,omitempty"`
+}
+
+// Customer is what the promotion engine knows about the user a cart belongs to
+type Customer struct {
+	UserID   uuid.UUID
+	Role     string
+	Segments []string
+
+	// OrderCount is the number of orders the user placed that were not cancelled
+	OrderCount int
+
+	// LifetimeSpend is the total of the user's paid orders
+	LifetimeSpend money.Money
+}
+
+// IsRestricted reports whether any predicate is set
+func (e Eligibility) IsRestricted() bool {
+	return e.FirstOrderOnly || len(e.Roles) > 0 || len(e.Segments) > 0 || e.MinLifetimeSpend != nil
+}
+
+// Allows reports whether the customer satisfies every predicate. Without a customer only an
+// unrestricted promotion is allowed.
+func (e Eligibility) Allows(customer *Customer) bool {
+	if !e.IsRestricted() {
+		return true
+	}
+	if customer == nil {
+		return false
+	}
+
+	if e.FirstOrderOnly && customer.OrderCount > 0 {
+		return false
+	}
+	if len(e.Roles) > 0 && !containsString(e.Roles, customer.Role) {
+		return false
+	}
+	if len(e.Segments) > 0 && !sharesString(e.Segments, customer.Segments) {
+		return false
+	}
+	if e.MinLifetimeSpend != nil && customer.LifetimeSpend.LessThan(*e.MinLifetimeSpend) {
+		return false
+	}
+	return true
+}
+
+// IsEligible reports whether the customer may receive the promotion
+func (p *Promotion) IsEligible(customer *Customer) bool {
+	return p.Eligibility.Allows(customer)
+}
+
+// Scan implements sql.Scanner
+func (e *Eligibility) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*e = Eligibility{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("scanning eligibility: unsupported type %T", src)
+	}
+
+	var parsed Eligibility
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return fmt.Errorf("scanning eligibility: %w", err)
+	}
+	*e = parsed
+	return nil
+}
+
+// Value implements driver.Valuer
+func (e Eligibility) Value() (driver.Value, error) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("encoding eligibility: %w", err)
+	}
+	return raw, nil
+}
+
+// containsString reports whether values includes value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesString reports whether the two lists have a value in common
+func sharesString(a, b []string) bool {
+	for _, value := range b {
+		if containsString(a, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,453 @@
+package entity
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/google/uuid"
+)
+
+// maxSearchSteps bounds the search for the best promotion combination. Pruning keeps real carts far
+// below it; a search that reaches it keeps the best combination found so far and marks the result
+// approximate.
+const maxSearchSteps = 1 << 16
+
+// SkipReason explains why a promotion that applies to the cart on its own was not applied
+type SkipReason string
+
+const (
+	// SkipReasonExclusive means the promotion is exclusive, or an exclusive promotion was applied instead
+	SkipReasonExclusive SkipReason = "EXCLUSIVE"
+	// SkipReasonNotStackable means the promotion discounts the same units as an applied promotion
+	// and neither lists the other in stackable_with
+	SkipReasonNotStackable SkipReason = "NOT_STACKABLE"
+	// SkipReasonNoRemainingValue means the promotion stacks but the lines it discounts were already
+	// fully discounted by promotions with a higher priority
+	SkipReasonNoRemainingValue SkipReason = "NO_REMAINING_VALUE"
+)
+
+// SkippedPromotion is a promotion that would have given a discount on its own but was left out of
+// the combination that gives the customer the best price
+type SkippedPromotion struct {
+	ID          uuid.UUID     `json:"id"`
+	Type        PromotionType `json:"type"`
+	Description string        `json:"description"`
+	Reason      SkipReason    `json:"reason"`
+
+	// BlockedBy is the applied promotion that took the units this promotion would have discounted
+	BlockedBy *uuid.UUID `json:"blocked_by,omitempty"`
+
+	// PotentialDiscount is the discount the promotion would have given on its own
+	PotentialDiscount money.Money `json:"potential_discount"`
+}
+
+// InvalidPromotion is an effective promotion whose rule does not match the schema of its type.
+// It is never applied.
+type InvalidPromotion struct {
+	ID          uuid.UUID     `json:"id"`
+	Type        PromotionType `json:"type"`
+	Description string        `json:"description"`
+	Error       string        `json:"error"`
+}
+
+// EvaluationResult is the outcome of running the promotion engine against a set of cart items
+type EvaluationResult struct {
+	// Promotions are the promotions that produced a discount, with their per-line allocations
+	Promotions []ApplicablePromotion `json:"promotions"`
+
+	// Skipped are the promotions that apply to the cart but were not chosen, with the reason
+	Skipped []SkippedPromotion `json:"skipped,omitempty"`
+
+	// Invalid are the promotions that were left out because their rule is malformed
+	Invalid []InvalidPromotion `json:"invalid,omitempty"`
+
+	// Tiers is the progress through each tiered promotion for a product in the cart, including
+	// promotions whose first tier has not been reached yet
+	Tiers []TierProgress `json:"tiers,omitempty"`
+
+	// LineDiscounts is the total discount allocated to each line, keyed by product SKU
+	LineDiscounts map[string]money.Money `json:"line_discounts"`
+
+	// TotalDiscount is the sum of all applied promotion discounts
+	TotalDiscount money.Money `json:"total_discount"`
+
+	// Approximate is set when the search for the best combination was cut short. The promotions
+	// applied are still compatible, but a better combination may exist.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// Engine evaluates promotions against cart items. It is the single place where
+// promotion rules are applied, so the cart preview and checkout always agree.
+type Engine struct{}
+
+// NewEngine creates a new promotion engine
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// candidate is an effective promotion together with the discount its rule gives on its own
+type candidate struct {
+	promotion *Promotion
+	lines     []LineDiscount
+
+	// units is the number of units the promotion uses of each line, keyed by product SKU
+	units      map[string]int
+	standalone money.Money
+}
+
+// Evaluate applies the active promotions whose validity window includes the current time to the
+// items and returns the applied promotions together with the discount allocated to each line.
+//
+// A promotion claims the units it depends on or discounts. Each unit goes to at most one promotion
+// unless the promotions claiming it list each other in stackable_with, so two promotions that do
+// not stack only both apply to a line with enough units for both. An exclusive promotion never
+// applies together with any other. Among the combinations that respect these
+// rules the engine picks the one with the largest total discount, preferring higher-priority
+// promotions on ties. Stacked promotions are applied in priority order and a line is never
+// discounted below zero.
+//
+// Promotions whose eligibility the customer does not meet are left out entirely. Without a
+// customer only promotions open to everyone are applied.
+func (e *Engine) Evaluate(promotions []*Promotion, items []CartItem, customer *Customer) *EvaluationResult {
+	result := &EvaluationResult{
+		Promotions:    []ApplicablePromotion{},
+		LineDiscounts: make(map[string]money.Money),
+		TotalDiscount: money.Zero(),
+	}
+
+	if len(items) == 0 {
+		return result
+	}
+
+	promotions = eligiblePromotions(promotions, customer)
+	result.Tiers = collectTierProgress(promotions, items)
+
+	// Track the discountable value and the units per line
+	lineTotals := make(map[string]money.Money, len(items))
+	lineQuantities := make(map[string]int, len(items))
+	for _, item := range items {
+		lineTotals[item.ProductSKU] = lineTotals[item.ProductSKU].Add(item.UnitPrice.Mul(item.Quantity))
+		lineQuantities[item.ProductSKU] += item.Quantity
+	}
+
+	candidates, invalid := collectCandidates(promotions, items, lineTotals)
+	result.Invalid = invalid
+	if len(candidates) == 0 {
+		return result
+	}
+
+	chosen, exhaustive := bestCombination(candidates, lineTotals, lineQuantities)
+	result.Approximate = !exhaustive
+
+	remaining := copyLineTotals(lineTotals)
+	applied := make([]*candidate, 0, len(chosen))
+	for _, c := range chosen {
+		allocations := allocate(c.lines, remaining)
+		discount := SumLineDiscounts(allocations)
+		if !discount.IsPositive() {
+			result.Skipped = append(result.Skipped, skipped(c, SkipReasonNoRemainingValue, nil))
+			continue
+		}
+
+		for _, line := range allocations {
+			result.LineDiscounts[line.ProductSKU] = result.LineDiscounts[line.ProductSKU].Add(line.Discount)
+		}
+
+		result.Promotions = append(result.Promotions, ApplicablePromotion{
+			ID:          c.promotion.ID,
+			Type:        c.promotion.Type,
+			Description: c.promotion.Description,
+			Discount:    discount,
+			Allocations: allocations,
+		})
+		result.TotalDiscount = result.TotalDiscount.Add(discount)
+		applied = append(applied, c)
+	}
+
+	inChosen := make(map[*candidate]bool, len(chosen))
+	for _, c := range chosen {
+		inChosen[c] = true
+	}
+	for _, c := range candidates {
+		if inChosen[c] {
+			continue
+		}
+		reason, blockedBy := skipReason(c, applied, lineQuantities)
+		result.Skipped = append(result.Skipped, skipped(c, reason, blockedBy))
+	}
+
+	return result
+}
+
+// collectCandidates returns the effective promotions that give a discount on their own, ordered by
+// priority with the original order kept for equal priorities, together with the effective
+// promotions whose rule is malformed
+func collectCandidates(promotions []*Promotion, items []CartItem, lineTotals map[string]money.Money) ([]*candidate, []InvalidPromotion) {
+	skuMap := BuildSKUMap(items)
+	now := time.Now()
+
+	candidates := make([]*candidate, 0, len(promotions))
+	var invalid []InvalidPromotion
+	for _, promotion := range promotions {
+		if !promotion.IsEffective(now) {
+			continue
+		}
+
+		rule, err := promotion.ParseRule()
+		if err != nil {
+			invalid = append(invalid, InvalidPromotion{
+				ID:          promotion.ID,
+				Type:        promotion.Type,
+				Description: promotion.Description,
+				Error:       err.Error(),
+			})
+			continue
+		}
+
+		if !hasRequiredSKUs(rule, skuMap) {
+			continue
+		}
+
+		lines := rule.Apply(items)
+		standalone := SumLineDiscounts(allocate(lines, copyLineTotals(lineTotals)))
+		if !standalone.IsPositive() {
+			continue
+		}
+
+		candidates = append(candidates, &candidate{
+			promotion:  promotion,
+			lines:      lines,
+			units:      claimedUnits(rule, items, lines),
+			standalone: standalone,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].promotion.Priority > candidates[j].promotion.Priority
+	})
+
+	return candidates, invalid
+}
+
+// collectTierProgress returns the tier progress of every effective tiered promotion for a product
+// in the cart
+func collectTierProgress(promotions []*Promotion, items []CartItem) []TierProgress {
+	now := time.Now()
+
+	var tiers []TierProgress
+	for _, promotion := range promotions {
+		if !promotion.IsEffective(now) {
+			continue
+		}
+
+		rule, err := promotion.ParseRule()
+		if err != nil {
+			continue
+		}
+
+		tiered, ok := rule.(TieredRule)
+		if !ok {
+			continue
+		}
+
+		progress := tiered.TierProgress(items)
+		if progress == nil {
+			continue
+		}
+		progress.PromotionID = promotion.ID
+		progress.Description = promotion.Description
+		tiers = append(tiers, *progress)
+	}
+	return tiers
+}
+
+// eligiblePromotions returns the promotions the customer may receive, keeping their order
+func eligiblePromotions(promotions []*Promotion, customer *Customer) []*Promotion {
+	eligible := make([]*Promotion, 0, len(promotions))
+	for _, promotion := range promotions {
+		if promotion.IsEligible(customer) {
+			eligible = append(eligible, promotion)
+		}
+	}
+	return eligible
+}
+
+// bestCombination returns the compatible set of candidates with the largest total discount, in
+// priority order, and whether the search was exhaustive. Sets are explored including
+// higher-priority promotions first and only a strictly better total replaces the best so far, so
+// ties go to the higher-priority promotions.
+//
+// The search is a branch and bound: a branch is abandoned once the discount already allocated plus
+// the standalone discounts of every candidate still to decide cannot beat the best total. The first
+// combination reached is the greedy one in priority order, so a search cut short by maxSearchSteps
+// still returns a combination at least as good as it.
+func bestCombination(candidates []*candidate, lineTotals map[string]money.Money, lineQuantities map[string]int) ([]*candidate, bool) {
+	// potential[i] is the most the candidates from i on can add
+	potential := make([]money.Money, len(candidates)+1)
+	potential[len(candidates)] = money.Zero()
+	for i := len(candidates) - 1; i >= 0; i-- {
+		potential[i] = potential[i+1].Add(candidates[i].standalone)
+	}
+
+	var best []*candidate
+	bestTotal := money.Zero()
+	current := make([]*candidate, 0, len(candidates))
+	steps := 0
+
+	var search func(i int, total money.Money, remaining map[string]money.Money)
+	search = func(i int, total money.Money, remaining map[string]money.Money) {
+		steps++
+		if best != nil && (steps > maxSearchSteps || !total.Add(potential[i]).Sub(bestTotal).IsPositive()) {
+			return
+		}
+		if i == len(candidates) {
+			if best == nil || total.Sub(bestTotal).IsPositive() {
+				best = append([]*candidate(nil), current...)
+				bestTotal = total
+			}
+			return
+		}
+
+		if compatibleWithAll(candidates[i], current, lineQuantities) {
+			left := copyLineTotals(remaining)
+			discount := SumLineDiscounts(allocate(candidates[i].lines, left))
+			current = append(current, candidates[i])
+			search(i+1, total.Add(discount), left)
+			current = current[:len(current)-1]
+		}
+		search(i+1, total, remaining)
+	}
+	search(0, money.Zero(), copyLineTotals(lineTotals))
+
+	return best, steps <= maxSearchSteps
+}
+
+// claimedUnits returns the units a promotion uses of each line: the units its rule reports, or
+// else the units it discounts and every unit of a required line it does not discount
+func claimedUnits(rule PromotionRule, items []CartItem, lines []LineDiscount) map[string]int {
+	if claimer, ok := rule.(UnitClaimer); ok {
+		return claimer.ClaimedUnits(items)
+	}
+
+	units := make(map[string]int)
+	for _, line := range lines {
+		units[line.ProductSKU] += line.Quantity
+	}
+	for _, sku := range rule.RequiredSKUs() {
+		if _, ok := units[sku]; ok {
+			continue
+		}
+		for _, item := range items {
+			if item.ProductSKU == sku {
+				units[sku] += item.Quantity
+			}
+		}
+	}
+	return units
+}
+
+// compatibleWithAll reports whether a candidate can be applied together with every chosen one.
+// The units of a line the candidate uses, together with those used by the chosen promotions it
+// does not stack with, must fit in the line. Promotions that stack with each other are counted as
+// if they used separate units, which may turn down a combination but never gives a unit to two
+// promotions that do not stack.
+func compatibleWithAll(c *candidate, chosen []*candidate, lineQuantities map[string]int) bool {
+	for _, other := range chosen {
+		if c.promotion.Exclusive || other.promotion.Exclusive {
+			return false
+		}
+	}
+
+	for sku, units := range c.units {
+		used := units
+		for _, other := range chosen {
+			if !c.promotion.CanStackWith(other.promotion) {
+				used += other.units[sku]
+			}
+		}
+		if used > lineQuantities[sku] {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts reports whether two candidates cannot both be applied
+func conflicts(a, b *candidate, lineQuantities map[string]int) bool {
+	return !compatibleWithAll(a, []*candidate{b}, lineQuantities)
+}
+
+// skipReason finds the applied promotion that blocked a candidate: the first one it conflicts with,
+// or else the first one it does not stack with on a line left without enough units for both
+func skipReason(c *candidate, applied []*candidate, lineQuantities map[string]int) (SkipReason, *uuid.UUID) {
+	for _, other := range applied {
+		if !conflicts(c, other, lineQuantities) {
+			continue
+		}
+		id := other.promotion.ID
+		if c.promotion.Exclusive || other.promotion.Exclusive {
+			return SkipReasonExclusive, &id
+		}
+		return SkipReasonNotStackable, &id
+	}
+	for _, other := range applied {
+		if c.promotion.CanStackWith(other.promotion) {
+			continue
+		}
+		for sku := range c.units {
+			if other.units[sku] > 0 {
+				id := other.promotion.ID
+				return SkipReasonNotStackable, &id
+			}
+		}
+	}
+	// Conflicts only with promotions that ended up giving nothing; the combination without it was better
+	return SkipReasonNotStackable, nil
+}
+
+// skipped builds the skipped entry for a candidate
+func skipped(c *candidate, reason SkipReason, blockedBy *uuid.UUID) SkippedPromotion {
+	return SkippedPromotion{
+		ID:                c.promotion.ID,
+		Type:              c.promotion.Type,
+		Description:       c.promotion.Description,
+		Reason:            reason,
+		BlockedBy:         blockedBy,
+		PotentialDiscount: c.standalone,
+	}
+}
+
+// allocate caps each line discount at the value left on the line and deducts it from remaining
+func allocate(lines []LineDiscount, remaining map[string]money.Money) []LineDiscount {
+	allocations := make([]LineDiscount, 0, len(lines))
+	for _, line := range lines {
+		line.Discount = line.Discount.Min(remaining[line.ProductSKU])
+		if !line.Discount.IsPositive() {
+			continue
+		}
+
+		remaining[line.ProductSKU] = remaining[line.ProductSKU].Sub(line.Discount)
+		allocations = append(allocations, line)
+	}
+	return allocations
+}
+
+// copyLineTotals copies a per-line value map so it can be consumed by allocate
+func copyLineTotals(lineTotals map[string]money.Money) map[string]money.Money {
+	remaining := make(map[string]money.Money, len(lineTotals))
+	for sku, total := range lineTotals {
+		remaining[sku] = total
+	}
+	return remaining
+}
+
+// hasRequiredSKUs checks that every SKU the rule depends on is present in the cart
+func hasRequiredSKUs(rule PromotionRule, skuMap map[string]bool) bool {
+	for _, sku := range rule.RequiredSKUs() {
+		if !skuMap[sku] {
+			return false
+		}
+	}
+	return true
+}
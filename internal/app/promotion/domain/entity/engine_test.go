@@ -0,0 +1,339 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/google/uuid"
+)
+
+// testPromotion builds an active promotion; the description names it in test expectations
+func testPromotion(description string, promotionType PromotionType, rule string) *Promotion {
+	return &Promotion{
+		ID:          uuid.New(),
+		Type:        promotionType,
+		Description: description,
+		Rule:        []byte(rule),
+		Active:      true,
+	}
+}
+
+func cartItem(sku string, quantity int, unitPrice int64) CartItem {
+	return CartItem{
+		ProductID:  uuid.New(),
+		ProductSKU: sku,
+		Quantity:   quantity,
+		UnitPrice:  money.FromMinor(unitPrice),
+	}
+}
+
+// stackWith lets the promotion discount the same units as the others
+func stackWith(promotion *Promotion, others ...*Promotion) *Promotion {
+	for _, other := range others {
+		promotion.StackableWith = append(promotion.StackableWith, other.ID)
+	}
+	return promotion
+}
+
+// exclusive marks the promotion exclusive
+func exclusive(promotion *Promotion) *Promotion {
+	promotion.Exclusive = true
+	return promotion
+}
+
+// withPriority sets the priority of the promotion
+func withPriority(promotion *Promotion, priority int) *Promotion {
+	promotion.Priority = priority
+	return promotion
+}
+
+// appliedDiscount is an applied promotion as a test expects it
+type appliedDiscount struct {
+	description string
+	discount    string
+}
+
+// skippedPromotion is a skipped promotion as a test expects it; blockedBy is a description
+type skippedPromotion struct {
+	description string
+	reason      SkipReason
+	blockedBy   string
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	bulkA := func() *Promotion {
+		return testPromotion("bulk A", BulkDiscount, `{"sku": "A", "min_quantity": 1, "discount_percentage": 10}`)
+	}
+	bulkB := func() *Promotion {
+		return testPromotion("bulk B", BulkDiscount, `{"sku": "B", "min_quantity": 1, "discount_percentage": 10}`)
+	}
+	fiveOff := func() *Promotion {
+		return testPromotion("5 off", CartFixedDiscount, `{"min_subtotal": 0, "discount_amount": 5}`)
+	}
+	freeBWithA := func() *Promotion {
+		return testPromotion("free B with A", BuyOneGetOneFree, `{"trigger_sku": "A", "free_sku": "B", "trigger_quantity": 1, "free_quantity": 1}`)
+	}
+	twoForOneB := func() *Promotion {
+		return testPromotion("2 for 1 B", Buy3Pay2, `{"sku": "B", "min_quantity": 2, "paid_quantity_divisor": 1, "free_quantity_divisor": 1}`)
+	}
+
+	tests := []struct {
+		name        string
+		promotions  func() []*Promotion
+		items       []CartItem
+		wantApplied []appliedDiscount
+		wantSkipped []skippedPromotion
+	}{
+		{
+			name:        "promotions on the same units that do not stack keep the larger discount",
+			promotions:  func() []*Promotion { return []*Promotion{fiveOff(), bulkA()} },
+			items:       []CartItem{cartItem("A", 10, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "10.00"}},
+			wantSkipped: []skippedPromotion{{"5 off", SkipReasonNotStackable, "bulk A"}},
+		},
+		{
+			name: "promotions that stack both discount the same units",
+			promotions: func() []*Promotion {
+				bulk := bulkA()
+				return []*Promotion{bulk, stackWith(fiveOff(), bulk)}
+			},
+			items:       []CartItem{cartItem("A", 10, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "10.00"}, {"5 off", "5.00"}},
+		},
+		{
+			name: "exclusivity wins over stackable_with",
+			promotions: func() []*Promotion {
+				bulk := bulkA()
+				return []*Promotion{bulk, exclusive(stackWith(fiveOff(), bulk))}
+			},
+			items:       []CartItem{cartItem("A", 10, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "10.00"}},
+			wantSkipped: []skippedPromotion{{"5 off", SkipReasonExclusive, "bulk A"}},
+		},
+		{
+			name:        "an exclusive promotion is not combined with one on other lines",
+			promotions:  func() []*Promotion { return []*Promotion{exclusive(bulkA()), bulkB()} },
+			items:       []CartItem{cartItem("A", 1, 5000), cartItem("B", 1, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "5.00"}},
+			wantSkipped: []skippedPromotion{{"bulk B", SkipReasonExclusive, "bulk A"}},
+		},
+		{
+			name:        "promotions on different lines both apply",
+			promotions:  func() []*Promotion { return []*Promotion{bulkA(), bulkB()} },
+			items:       []CartItem{cartItem("A", 1, 5000), cartItem("B", 1, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "5.00"}, {"bulk B", "1.00"}},
+		},
+		{
+			name:        "competing promotions both apply when the line has units for both",
+			promotions:  func() []*Promotion { return []*Promotion{freeBWithA(), twoForOneB()} },
+			items:       []CartItem{cartItem("A", 1, 500), cartItem("B", 3, 1000)},
+			wantApplied: []appliedDiscount{{"free B with A", "10.00"}, {"2 for 1 B", "10.00"}},
+		},
+		{
+			name:        "competing promotions for the same units go to the first on a tie",
+			promotions:  func() []*Promotion { return []*Promotion{freeBWithA(), twoForOneB()} },
+			items:       []CartItem{cartItem("A", 1, 500), cartItem("B", 2, 1000)},
+			wantApplied: []appliedDiscount{{"free B with A", "10.00"}},
+			wantSkipped: []skippedPromotion{{"2 for 1 B", SkipReasonNotStackable, "free B with A"}},
+		},
+		{
+			name: "competing promotions for the same units go to the higher priority on a tie",
+			promotions: func() []*Promotion {
+				return []*Promotion{freeBWithA(), withPriority(twoForOneB(), 1)}
+			},
+			items:       []CartItem{cartItem("A", 1, 500), cartItem("B", 2, 1000)},
+			wantApplied: []appliedDiscount{{"2 for 1 B", "10.00"}},
+			wantSkipped: []skippedPromotion{{"free B with A", SkipReasonNotStackable, "2 for 1 B"}},
+		},
+		{
+			name: "priority does not beat a larger discount",
+			promotions: func() []*Promotion {
+				return []*Promotion{bulkA(), withPriority(fiveOff(), 1)}
+			},
+			items:       []CartItem{cartItem("A", 10, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "10.00"}},
+			wantSkipped: []skippedPromotion{{"5 off", SkipReasonNotStackable, "bulk A"}},
+		},
+		{
+			name: "stacked promotions are applied in priority order and never below zero",
+			promotions: func() []*Promotion {
+				half := testPromotion("half A", BulkDiscount, `{"sku": "A", "min_quantity": 1, "discount_percentage": 50}`)
+				sixty := testPromotion("60 off", CartFixedDiscount, `{"min_subtotal": 0, "discount_amount": 60}`)
+				return []*Promotion{half, withPriority(stackWith(sixty, half), 1)}
+			},
+			items:       []CartItem{cartItem("A", 1, 10000)},
+			wantApplied: []appliedDiscount{{"60 off", "60.00"}, {"half A", "40.00"}},
+		},
+		{
+			name: "a stacked promotion left with nothing to discount is skipped",
+			promotions: func() []*Promotion {
+				bulk := bulkA()
+				everything := testPromotion("100 off", CartFixedDiscount, `{"min_subtotal": 0, "discount_amount": 100}`)
+				return []*Promotion{bulk, withPriority(stackWith(everything, bulk), 1)}
+			},
+			items:       []CartItem{cartItem("A", 1, 10000)},
+			wantApplied: []appliedDiscount{{"100 off", "100.00"}},
+			wantSkipped: []skippedPromotion{{"bulk A", SkipReasonNoRemainingValue, ""}},
+		},
+		{
+			name: "the best combination may leave out the largest promotion",
+			promotions: func() []*Promotion {
+				fifteenOff := testPromotion("15 off", CartFixedDiscount, `{"min_subtotal": 0, "discount_amount": 15}`)
+				return []*Promotion{fifteenOff, bulkA(), bulkB()}
+			},
+			items:       []CartItem{cartItem("A", 1, 10000), cartItem("B", 1, 10000)},
+			wantApplied: []appliedDiscount{{"bulk A", "10.00"}, {"bulk B", "10.00"}},
+			wantSkipped: []skippedPromotion{{"15 off", SkipReasonNotStackable, "bulk A"}},
+		},
+		{
+			name:        "promotions without a discount are neither applied nor skipped",
+			promotions:  func() []*Promotion { return []*Promotion{bulkA(), twoForOneB()} },
+			items:       []CartItem{cartItem("A", 1, 1000), cartItem("B", 1, 1000)},
+			wantApplied: []appliedDiscount{{"bulk A", "1.00"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promotions := tt.promotions()
+			descriptions := make(map[uuid.UUID]string, len(promotions))
+			for _, promotion := range promotions {
+				descriptions[promotion.ID] = promotion.Description
+			}
+
+			result := NewEngine().Evaluate(promotions, tt.items, nil)
+
+			gotApplied := []appliedDiscount{}
+			for _, applied := range result.Promotions {
+				gotApplied = append(gotApplied, appliedDiscount{applied.Description, applied.Discount.String()})
+			}
+			if tt.wantApplied == nil {
+				tt.wantApplied = []appliedDiscount{}
+			}
+			if !reflect.DeepEqual(gotApplied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", gotApplied, tt.wantApplied)
+			}
+
+			gotSkipped := []skippedPromotion{}
+			for _, skipped := range result.Skipped {
+				got := skippedPromotion{description: skipped.Description, reason: skipped.Reason}
+				if skipped.BlockedBy != nil {
+					got.blockedBy = descriptions[*skipped.BlockedBy]
+				}
+				gotSkipped = append(gotSkipped, got)
+			}
+			if tt.wantSkipped == nil {
+				tt.wantSkipped = []skippedPromotion{}
+			}
+			if !reflect.DeepEqual(gotSkipped, tt.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", gotSkipped, tt.wantSkipped)
+			}
+
+			total := money.Zero()
+			for _, discount := range result.LineDiscounts {
+				total = total.Add(discount)
+			}
+			if total != result.TotalDiscount {
+				t.Errorf("line discounts add up to %s, want the total discount %s", total, result.TotalDiscount)
+			}
+			if result.Approximate {
+				t.Error("result is approximate, want an exhaustive search")
+			}
+		})
+	}
+}
+
+func TestEngineEvaluateSearchCap(t *testing.T) {
+	// Forty promotions that all stack on one line use it up after ten, but their standalone
+	// discounts keep every branch above the bound, so the search reaches maxSearchSteps and keeps
+	// the greedy combination
+	promotions := make([]*Promotion, 0, 40)
+	for i := 0; i < 40; i++ {
+		promotions = append(promotions, testPromotion("bulk A", BulkDiscount, `{"sku": "A", "min_quantity": 1, "discount_percentage": 10}`))
+	}
+	for _, promotion := range promotions {
+		stackWith(promotion, promotions...)
+	}
+
+	result := NewEngine().Evaluate(promotions, []CartItem{cartItem("A", 1, 10000)}, nil)
+
+	if !result.Approximate {
+		t.Error("result is exhaustive, want approximate")
+	}
+	if got := result.TotalDiscount.String(); got != "100.00" {
+		t.Errorf("total discount = %s, want 100.00", got)
+	}
+	if len(result.Promotions) != 10 {
+		t.Fatalf("applied %d promotions, want 10", len(result.Promotions))
+	}
+	for i, applied := range result.Promotions {
+		if applied.ID != promotions[i].ID {
+			t.Errorf("applied promotion %d is not the %d. in priority order", i, i)
+		}
+	}
+	if len(result.Skipped) != 30 {
+		t.Fatalf("skipped %d promotions, want 30", len(result.Skipped))
+	}
+	for _, skipped := range result.Skipped {
+		if skipped.Reason != SkipReasonNoRemainingValue || skipped.BlockedBy != nil {
+			t.Errorf("skipped %s as %s, want NO_REMAINING_VALUE", skipped.ID, skipped.Reason)
+		}
+	}
+}
+
+func TestEngineEvaluateBelowSearchCap(t *testing.T) {
+	// Promotions on separate lines are all applied without exploring every subset
+	promotions := make([]*Promotion, 0, 40)
+	items := make([]CartItem, 0, 40)
+	for i := 0; i < 40; i++ {
+		sku := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		promotions = append(promotions, testPromotion(sku, BulkDiscount, `{"sku": "`+sku+`", "min_quantity": 1, "discount_percentage": 10}`))
+		items = append(items, cartItem(sku, 1, 1000))
+	}
+
+	result := NewEngine().Evaluate(promotions, items, nil)
+
+	if result.Approximate {
+		t.Error("result is approximate, want an exhaustive search")
+	}
+	if len(result.Promotions) != len(promotions) || len(result.Skipped) != 0 {
+		t.Errorf("applied %d and skipped %d promotions, want all %d applied", len(result.Promotions), len(result.Skipped), len(promotions))
+	}
+	if got := result.TotalDiscount.String(); got != "40.00" {
+		t.Errorf("total discount = %s, want 40.00", got)
+	}
+}
+
+func TestEngineEvaluateLeavesOutIneligibleAndInactive(t *testing.T) {
+	firstOrder := testPromotion("first order", BulkDiscount, `{"sku": "A", "min_quantity": 1, "discount_percentage": 20}`)
+	firstOrder.Eligibility.FirstOrderOnly = true
+	inactive := testPromotion("inactive", BulkDiscount, `{"sku": "A", "min_quantity": 1, "discount_percentage": 30}`)
+	inactive.Active = false
+	invalid := testPromotion("invalid", BulkDiscount, `{"sku": "A"}`)
+	open := testPromotion("open", BulkDiscount, `{"sku": "A", "min_quantity": 1, "discount_percentage": 10}`)
+	promotions := []*Promotion{firstOrder, inactive, invalid, open}
+	items := []CartItem{cartItem("A", 1, 10000)}
+
+	tests := []struct {
+		name     string
+		customer *Customer
+		want     string
+	}{
+		{name: "without a customer", customer: nil, want: "open"},
+		{name: "returning customer", customer: &Customer{OrderCount: 1}, want: "open"},
+		{name: "new customer", customer: &Customer{OrderCount: 0}, want: "first order"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewEngine().Evaluate(promotions, items, tt.customer)
+			if len(result.Promotions) != 1 || result.Promotions[0].Description != tt.want {
+				t.Errorf("applied %v, want only %q", result.Promotions, tt.want)
+			}
+			if len(result.Invalid) != 1 || result.Invalid[0].ID != invalid.ID {
+				t.Errorf("invalid = %v, want only the promotion with a malformed rule", result.Invalid)
+			}
+		})
+	}
+}
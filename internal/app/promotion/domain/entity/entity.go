@@ -1,10 +1,16 @@
 package entity
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"sort"
 	"time"
 
+	promotionErrors "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/errs"
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // PromotionType defines the type of promotion
@@ -17,6 +23,14 @@ const (
 	Buy3Pay2 PromotionType = "BUY_3_PAY_2"
 	// BulkDiscount is a promotion with a percentage discount for buying in bulk
 	BulkDiscount PromotionType = "BULK_DISCOUNT"
+	// CartPercentageDiscount is a percentage off the whole cart once its subtotal reaches a threshold
+	CartPercentageDiscount PromotionType = "CART_PERCENTAGE_DISCOUNT"
+	// CartFixedDiscount is a fixed amount off the whole cart once its subtotal reaches a threshold
+	CartFixedDiscount PromotionType = "CART_FIXED_DISCOUNT"
+	// TieredDiscount is a percentage discount on a product that grows with the quantity bought
+	TieredDiscount PromotionType = "TIERED_DISCOUNT"
+	// Bundle is a set price for a group of products bought together
+	Bundle PromotionType = "BUNDLE"
 )
 
 // Promotion is the base promotion entity
@@ -26,24 +40,161 @@ type Promotion struct {
 	Description string          `json:"description"`
 	Rule        json.RawMessage `json:"rule,omitempty"`
 	Active      bool            `json:"active"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
-	DeletedAt   *time.Time      `json:"deleted_at,omitempty"`
+	StartsAt    *time.Time      `json:"starts_at,omitempty"`
+	EndsAt      *time.Time      `json:"ends_at,omitempty"`
+
+	StackingRules
+
+	// Eligibility restricts which customers receive the promotion
+	Eligibility Eligibility `json:"eligibility"`
+
+	// MaxRedemptions caps coupon redemptions across every code of the promotion; nil is unlimited
+	MaxRedemptions  *int `json:"max_redemptions,omitempty"`
+	RedemptionCount int  `json:"redemption_count"`
+
+	// Version is the current version of the promotion terms, starting at 1
+	Version int `json:"version"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// StackingRules controls how a promotion combines with other promotions on the same cart
+type StackingRules struct {
+	// Priority orders promotions; higher values are applied first and win ties between
+	// combinations that give the same discount
+	Priority int `json:"priority"`
+
+	// Exclusive promotions are never applied together with any other promotion
+	Exclusive bool `json:"exclusive"`
+
+	// StackableWith lists promotions that may discount the same units as this one
+	StackableWith PromotionIDs `json:"stackable_with,omitempty"`
+}
+
+// CanStackWith reports whether the promotion may discount the same units as other. Either
+// promotion listing the other in stackable_with is enough; exclusivity always wins.
+func (p *Promotion) CanStackWith(other *Promotion) bool {
+	if p.Exclusive || other.Exclusive {
+		return false
+	}
+	return p.StackableWith.Contains(other.ID) || other.StackableWith.Contains(p.ID)
+}
+
+// PromotionIDs is a list of promotion IDs stored as a PostgreSQL uuid array
+type PromotionIDs []uuid.UUID
+
+// Contains reports whether the list includes id
+func (ids PromotionIDs) Contains(id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan implements sql.Scanner
+func (ids *PromotionIDs) Scan(src interface{}) error {
+	var raw pq.StringArray
+	if err := raw.Scan(src); err != nil {
+		return fmt.Errorf("scanning promotion IDs: %w", err)
+	}
+
+	parsed := make(PromotionIDs, 0, len(raw))
+	for _, value := range raw {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return fmt.Errorf("scanning promotion IDs: %w", err)
+		}
+		parsed = append(parsed, id)
+	}
+	*ids = parsed
+	return nil
+}
+
+// Value implements driver.Valuer
+func (ids PromotionIDs) Value() (driver.Value, error) {
+	raw := make(pq.StringArray, 0, len(ids))
+	for _, id := range ids {
+		raw = append(raw, id.String())
+	}
+	return raw.Value()
+}
+
+// WindowStatus describes where the current time falls relative to a promotion's validity window
+type WindowStatus string
+
+const (
+	// WindowStatusScheduled means the promotion has not started yet
+	WindowStatusScheduled WindowStatus = "SCHEDULED"
+	// WindowStatusOpen means the promotion is inside its validity window
+	WindowStatusOpen WindowStatus = "OPEN"
+	// WindowStatusExpired means the promotion's end time has passed
+	WindowStatusExpired WindowStatus = "EXPIRED"
+)
+
+// WindowStatus returns where now falls relative to the promotion's validity window
+func (p *Promotion) WindowStatus(now time.Time) WindowStatus {
+	switch {
+	case !p.HasStarted(now):
+		return WindowStatusScheduled
+	case p.HasExpired(now):
+		return WindowStatusExpired
+	default:
+		return WindowStatusOpen
+	}
+}
+
+// HasStarted reports whether the promotion's start time has been reached. A promotion without a
+// start time has always started.
+func (p *Promotion) HasStarted(now time.Time) bool {
+	return p.StartsAt == nil || !now.Before(*p.StartsAt)
+}
+
+// HasExpired reports whether the promotion's end time has passed. The end time is exclusive.
+func (p *Promotion) HasExpired(now time.Time) bool {
+	return p.EndsAt != nil && !now.Before(*p.EndsAt)
+}
+
+// IsEffective reports whether the promotion is switched on and inside its validity window
+func (p *Promotion) IsEffective(now time.Time) bool {
+	return p.Active && p.HasStarted(now) && !p.HasExpired(now)
 }
 
 // PromotionRule is the interface that all promotion rules must implement
 type PromotionRule interface {
-	// Apply applies the promotion to the cart items and returns the discount amount
-	Apply(items []CartItem) float64
+	// Apply applies the promotion to the cart items and returns the discount allocated to each line
+	Apply(items []CartItem) []LineDiscount
+
+	// RequiredSKUs returns the SKUs that must be in the cart for the promotion to be applicable
+	RequiredSKUs() []string
+}
+
+// UnitClaimer is implemented by rules that use units they do not discount, such as the units that
+// trigger a free item. ClaimedUnits returns every unit the rule uses of each line, keyed by SKU;
+// without it a rule is taken to use the units it discounts and all units of its other required
+// lines.
+type UnitClaimer interface {
+	ClaimedUnits(items []CartItem) map[string]int
+}
+
+// LineDiscount is the part of a promotion discount allocated to a single cart line
+type LineDiscount struct {
+	ProductID  uuid.UUID   `json:"product_id"`
+	ProductSKU string      `json:"product_sku"`
+	Quantity   int         `json:"quantity"`
+	Discount   money.Money `json:"discount"`
 }
 
 // CartItem is a simplified representation of a cart item used for promotion rules
 type CartItem struct {
-	ProductID   uuid.UUID `json:"product_id"`
-	ProductSKU  string    `json:"product_sku"`
-	ProductName string    `json:"product_name"`
-	Quantity    int       `json:"quantity"`
-	UnitPrice   float64   `json:"unit_price"`
+	ProductID   uuid.UUID   `json:"product_id"`
+	ProductSKU  string      `json:"product_sku"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   money.Money `json:"unit_price"`
 }
 
 // BuyOneGetOneFreePromotion represents a promotion where buying one product gets another free
@@ -56,9 +207,39 @@ type BuyOneGetOneFreePromotion struct {
 }
 
 // Apply implements the PromotionRule interface for BuyOneGetOneFreePromotion
-func (p *BuyOneGetOneFreePromotion) Apply(items []CartItem) float64 {
-	if !p.Active {
-		return 0
+func (p *BuyOneGetOneFreePromotion) Apply(items []CartItem) []LineDiscount {
+	_, freeItem, freeCount := p.freeUnits(items)
+	if freeCount <= 0 {
+		return nil
+	}
+
+	return []LineDiscount{{
+		ProductID:  freeItem.ProductID,
+		ProductSKU: freeItem.ProductSKU,
+		Quantity:   freeCount,
+		Discount:   freeItem.UnitPrice.Mul(freeCount),
+	}}
+}
+
+// ClaimedUnits implements the UnitClaimer interface for BuyOneGetOneFreePromotion: the free units
+// and the trigger units that earn them
+func (p *BuyOneGetOneFreePromotion) ClaimedUnits(items []CartItem) map[string]int {
+	triggerItem, freeItem, freeCount := p.freeUnits(items)
+	if freeCount <= 0 {
+		return map[string]int{}
+	}
+
+	triggerSets := (freeCount + p.FreeQuantity - 1) / p.FreeQuantity
+	return map[string]int{
+		triggerItem.ProductSKU: triggerSets * p.TriggerQuantity,
+		freeItem.ProductSKU:    freeCount,
+	}
+}
+
+// freeUnits finds the trigger and free lines and the number of free units they earn
+func (p *BuyOneGetOneFreePromotion) freeUnits(items []CartItem) (*CartItem, *CartItem, int) {
+	if p.TriggerQuantity < 1 || p.FreeQuantity < 1 {
+		return nil, nil, 0
 	}
 
 	var triggerItem, freeItem *CartItem
@@ -71,7 +252,7 @@ func (p *BuyOneGetOneFreePromotion) Apply(items []CartItem) float64 {
 	}
 
 	if triggerItem == nil || freeItem == nil {
-		return 0
+		return nil, nil, 0
 	}
 
 	// Calculate how many free items can be given
@@ -82,8 +263,12 @@ func (p *BuyOneGetOneFreePromotion) Apply(items []CartItem) float64 {
 		freeCount = freeItem.Quantity
 	}
 
-	discount := float64(freeCount) * freeItem.UnitPrice
-	return discount
+	return triggerItem, freeItem, freeCount
+}
+
+// RequiredSKUs implements the PromotionRule interface for BuyOneGetOneFreePromotion
+func (p *BuyOneGetOneFreePromotion) RequiredSKUs() []string {
+	return []string{p.TriggerSKU, p.FreeSKU}
 }
 
 // Buy3Pay2Promotion represents a promotion where buying 3 items pays for only 2
@@ -96,10 +281,38 @@ type Buy3Pay2Promotion struct {
 }
 
 // Apply implements the PromotionRule interface for Buy3Pay2Promotion
-func (p *Buy3Pay2Promotion) Apply(items []CartItem) float64 {
-	if !p.Active {
-		return 0
+func (p *Buy3Pay2Promotion) Apply(items []CartItem) []LineDiscount {
+	targetItem, totalSets := p.sets(items)
+	freeCount := totalSets * p.FreeQuantityDivisor
+
+	if freeCount <= 0 {
+		return nil
+	}
+
+	return []LineDiscount{{
+		ProductID:  targetItem.ProductID,
+		ProductSKU: targetItem.ProductSKU,
+		Quantity:   freeCount,
+		Discount:   targetItem.UnitPrice.Mul(freeCount),
+	}}
+}
+
+// ClaimedUnits implements the UnitClaimer interface for Buy3Pay2Promotion: every unit of the
+// complete sets, paid and free
+func (p *Buy3Pay2Promotion) ClaimedUnits(items []CartItem) map[string]int {
+	targetItem, totalSets := p.sets(items)
+	if totalSets <= 0 || p.FreeQuantityDivisor < 1 {
+		return map[string]int{}
+	}
+	return map[string]int{targetItem.ProductSKU: totalSets * (p.PaidQuantityDivisor + p.FreeQuantityDivisor)}
+}
+
+// sets finds the target line and the number of complete sets it holds
+func (p *Buy3Pay2Promotion) sets(items []CartItem) (*CartItem, int) {
+	if p.PaidQuantityDivisor < 1 || p.FreeQuantityDivisor < 1 {
+		return nil, 0
 	}
+	setSize := p.PaidQuantityDivisor + p.FreeQuantityDivisor
 
 	var targetItem *CartItem
 	for i := range items {
@@ -110,19 +323,17 @@ func (p *Buy3Pay2Promotion) Apply(items []CartItem) float64 {
 	}
 
 	if targetItem == nil || targetItem.Quantity < p.MinQuantity {
-		return 0
+		return nil, 0
 	}
 
-	// Calculate how many sets we have (e.g., how many times we can apply "Buy X Pay Y")
-	// For "Buy 3 Pay 2", each set of 3 items should get a discount of 1 item
-	totalItems := targetItem.Quantity
-	totalSets := totalItems / p.MinQuantity
-
-	// Calculate discount as one free item per complete set
-	// For "Buy 3 Pay 2", that's one free item for every 3 items
-	discount := float64(totalSets) * targetItem.UnitPrice
+	// Calculate how many complete sets we have (e.g., how many times we can apply "Buy X Pay Y")
+	// For "Buy 3 Pay 2", each set of 3 items gets 1 item free; leftover items are paid in full
+	return targetItem, targetItem.Quantity / setSize
+}
 
-	return discount
+// RequiredSKUs implements the PromotionRule interface for Buy3Pay2Promotion
+func (p *Buy3Pay2Promotion) RequiredSKUs() []string {
+	return []string{p.SKU}
 }
 
 // BulkDiscountPromotion represents a promotion with a percentage discount for buying in bulk
@@ -134,9 +345,9 @@ type BulkDiscountPromotion struct {
 }
 
 // Apply implements the PromotionRule interface for BulkDiscountPromotion
-func (p *BulkDiscountPromotion) Apply(items []CartItem) float64 {
-	if !p.Active {
-		return 0
+func (p *BulkDiscountPromotion) Apply(items []CartItem) []LineDiscount {
+	if p.DiscountPercentage <= 0 || p.DiscountPercentage > 100 {
+		return nil
 	}
 
 	var targetItem *CartItem
@@ -148,12 +359,509 @@ func (p *BulkDiscountPromotion) Apply(items []CartItem) float64 {
 	}
 
 	if targetItem == nil || targetItem.Quantity < p.MinQuantity {
-		return 0
+		return nil
 	}
 
-	totalPrice := targetItem.UnitPrice * float64(targetItem.Quantity)
-	discount := totalPrice * (p.DiscountPercentage / 100)
-	return discount
+	// The percentage is taken of the line total and rounded once, so a 10% discount
+	// on 3 x 0.95 is 0.29 rather than three separately rounded 0.10 discounts
+	totalPrice := targetItem.UnitPrice.Mul(targetItem.Quantity)
+	return []LineDiscount{{
+		ProductID:  targetItem.ProductID,
+		ProductSKU: targetItem.ProductSKU,
+		Quantity:   targetItem.Quantity,
+		Discount:   totalPrice.Percent(p.DiscountPercentage),
+	}}
+}
+
+// RequiredSKUs implements the PromotionRule interface for BulkDiscountPromotion
+func (p *BulkDiscountPromotion) RequiredSKUs() []string {
+	return []string{p.SKU}
+}
+
+// DiscountTier is a quantity break of a tiered discount
+type DiscountTier struct {
+	MinQuantity        int     `json:"min_quantity"`
+	DiscountPercentage float64 `json:"discount_percentage"`
+}
+
+// TierProgress shows which tier of a tiered promotion a cart line reached and how many more units
+// unlock the next one
+type TierProgress struct {
+	PromotionID uuid.UUID `json:"promotion_id"`
+	Description string    `json:"description"`
+	ProductSKU  string    `json:"product_sku"`
+	Quantity    int       `json:"quantity"`
+
+	// CurrentTier is the tier reached, nil when the quantity is below the first tier
+	CurrentTier *DiscountTier `json:"current_tier,omitempty"`
+
+	// NextTier is the tier after the current one, nil when the top tier was reached
+	NextTier *DiscountTier `json:"next_tier,omitempty"`
+
+	// UnitsToNextTier is how many more units unlock NextTier, zero at the top tier
+	UnitsToNextTier int `json:"units_to_next_tier"`
+}
+
+// TieredRule is implemented by promotion rules whose discount grows in quantity tiers
+type TieredRule interface {
+	PromotionRule
+
+	// TierProgress returns the tier the cart reached, or nil when the rule's product is not in the cart
+	TierProgress(items []CartItem) *TierProgress
+}
+
+// TieredDiscountPromotion represents a percentage discount on a product that grows with the
+// quantity bought, e.g. 5+ units for 5%, 10+ for 12% and 50+ for 20%
+type TieredDiscountPromotion struct {
+	Promotion
+	SKU   string         `json:"sku"`
+	Tiers []DiscountTier `json:"tiers"`
+}
+
+// Apply implements the PromotionRule interface for TieredDiscountPromotion
+func (p *TieredDiscountPromotion) Apply(items []CartItem) []LineDiscount {
+	targetItem := findItem(items, p.SKU)
+	if targetItem == nil {
+		return nil
+	}
+
+	tier, _ := p.tiersFor(targetItem.Quantity)
+	if tier == nil || tier.DiscountPercentage <= 0 || tier.DiscountPercentage > 100 {
+		return nil
+	}
+
+	// Like a bulk discount, the tier percentage is taken of the whole line and rounded once
+	totalPrice := targetItem.UnitPrice.Mul(targetItem.Quantity)
+	return []LineDiscount{{
+		ProductID:  targetItem.ProductID,
+		ProductSKU: targetItem.ProductSKU,
+		Quantity:   targetItem.Quantity,
+		Discount:   totalPrice.Percent(tier.DiscountPercentage),
+	}}
+}
+
+// RequiredSKUs implements the PromotionRule interface for TieredDiscountPromotion
+func (p *TieredDiscountPromotion) RequiredSKUs() []string {
+	return []string{p.SKU}
+}
+
+// Validate implements the RuleValidator interface for TieredDiscountPromotion. Every tier must need
+// more units and give a larger discount than the one below it.
+func (p *TieredDiscountPromotion) Validate() []FieldError {
+	sorted := make([]DiscountTier, len(p.Tiers))
+	copy(sorted, p.Tiers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinQuantity < sorted[j].MinQuantity
+	})
+
+	var errs []FieldError
+	for i := 1; i < len(sorted); i++ {
+		tier, below := sorted[i], sorted[i-1]
+		switch {
+		case tier.MinQuantity == below.MinQuantity:
+			errs = append(errs, FieldError{
+				Field:   "tiers",
+				Message: fmt.Sprintf("more than one tier starts at quantity %d", tier.MinQuantity),
+			})
+		case tier.DiscountPercentage <= below.DiscountPercentage:
+			errs = append(errs, FieldError{
+				Field:   "tiers",
+				Message: fmt.Sprintf("tier starting at quantity %d must give a larger discount than the tier below it", tier.MinQuantity),
+			})
+		}
+	}
+	return errs
+}
+
+// TierProgress implements the TieredRule interface for TieredDiscountPromotion
+func (p *TieredDiscountPromotion) TierProgress(items []CartItem) *TierProgress {
+	targetItem := findItem(items, p.SKU)
+	if targetItem == nil {
+		return nil
+	}
+
+	current, next := p.tiersFor(targetItem.Quantity)
+	progress := &TierProgress{
+		ProductSKU:  targetItem.ProductSKU,
+		Quantity:    targetItem.Quantity,
+		CurrentTier: current,
+		NextTier:    next,
+	}
+	if next != nil {
+		progress.UnitsToNextTier = next.MinQuantity - targetItem.Quantity
+	}
+	return progress
+}
+
+// tiersFor returns the highest tier reached by quantity and the lowest tier above it. Tiers are
+// validated to be in ascending order on creation, but the lookup does not rely on it.
+func (p *TieredDiscountPromotion) tiersFor(quantity int) (current, next *DiscountTier) {
+	for i := range p.Tiers {
+		tier := &p.Tiers[i]
+		if tier.MinQuantity <= quantity {
+			if current == nil || tier.MinQuantity > current.MinQuantity {
+				current = tier
+			}
+		} else if next == nil || tier.MinQuantity < next.MinQuantity {
+			next = tier
+		}
+	}
+	return current, next
+}
+
+// findItem returns the cart line for a SKU, or nil when the SKU is not in the cart
+func findItem(items []CartItem, sku string) *CartItem {
+	for i := range items {
+		if items[i].ProductSKU == sku {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+// BundleComponent is a product and the number of its units a fixed bundle needs
+type BundleComponent struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// BundlePromotion represents a set price for a group of products bought together. It is either a
+// mix-and-match bundle of any Quantity units drawn from SKUs ("any 3 of A, B, C or D for $99"), or
+// a fixed bundle that needs every one of Components ("A + B together for $X").
+type BundlePromotion struct {
+	Promotion
+	SKUs        []string          `json:"skus,omitempty"`
+	Quantity    int               `json:"quantity,omitempty"`
+	Components  []BundleComponent `json:"components,omitempty"`
+	BundlePrice money.Money       `json:"bundle_price"`
+}
+
+// IsMixAndMatch reports whether the bundle draws its units from a pool of SKUs
+func (p *BundlePromotion) IsMixAndMatch() bool {
+	return len(p.Components) == 0
+}
+
+// Apply implements the PromotionRule interface for BundlePromotion. The bundle discount is the
+// regular price of the bundled units less the bundle price, spread over the bundled lines in
+// proportion to their value so every line keeps a consistent discount and total.
+func (p *BundlePromotion) Apply(items []CartItem) []LineDiscount {
+	if p.BundlePrice.IsNegative() {
+		return nil
+	}
+
+	var used []int
+	var discount money.Money
+	if p.IsMixAndMatch() {
+		used, discount = p.mixAndMatchBundles(items)
+	} else {
+		used, discount = p.fixedBundles(items)
+	}
+	if !discount.IsPositive() {
+		return nil
+	}
+
+	weights := make([]int64, len(items))
+	for i, item := range items {
+		weights[i] = item.UnitPrice.Mul(used[i]).Amount
+	}
+
+	parts := discount.Allocate(weights)
+	lines := make([]LineDiscount, 0, len(items))
+	for i, item := range items {
+		if used[i] == 0 || !parts[i].IsPositive() {
+			continue
+		}
+		lines = append(lines, LineDiscount{
+			ProductID:  item.ProductID,
+			ProductSKU: item.ProductSKU,
+			Quantity:   used[i],
+			Discount:   parts[i],
+		})
+	}
+	return lines
+}
+
+// ClaimedUnits implements the UnitClaimer interface for BundlePromotion: every bundled unit, also
+// those of lines that get no share of the discount
+func (p *BundlePromotion) ClaimedUnits(items []CartItem) map[string]int {
+	units := make(map[string]int)
+	if p.BundlePrice.IsNegative() {
+		return units
+	}
+
+	var used []int
+	var discount money.Money
+	if p.IsMixAndMatch() {
+		used, discount = p.mixAndMatchBundles(items)
+	} else {
+		used, discount = p.fixedBundles(items)
+	}
+	if !discount.IsPositive() {
+		return units
+	}
+
+	for i, item := range items {
+		if used[i] > 0 {
+			units[item.ProductSKU] += used[i]
+		}
+	}
+	return units
+}
+
+// mixAndMatchBundles forms bundles from the most expensive eligible units first, which gives the
+// customer the largest discount: every further bundle is worth no more than the one before it, so
+// bundling stops at the first one that would not save anything. It returns the units bundled from
+// each line and the total discount.
+func (p *BundlePromotion) mixAndMatchBundles(items []CartItem) ([]int, money.Money) {
+	used := make([]int, len(items))
+	discount := money.Zero()
+	if p.Quantity < 1 {
+		return used, discount
+	}
+
+	eligible := make(map[string]bool, len(p.SKUs))
+	for _, sku := range p.SKUs {
+		eligible[sku] = true
+	}
+
+	order := make([]int, 0, len(items))
+	remaining := make([]int, len(items))
+	for i, item := range items {
+		if eligible[item.ProductSKU] && item.Quantity > 0 {
+			order = append(order, i)
+			remaining[i] = item.Quantity
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return items[order[a]].UnitPrice.GreaterThan(items[order[b]].UnitPrice)
+	})
+
+	for {
+		taken := make(map[int]int)
+		value := money.Zero()
+		count := 0
+		for _, i := range order {
+			n := remaining[i]
+			if n > p.Quantity-count {
+				n = p.Quantity - count
+			}
+			if n <= 0 {
+				continue
+			}
+			taken[i] += n
+			value = value.Add(items[i].UnitPrice.Mul(n))
+			count += n
+			if count == p.Quantity {
+				break
+			}
+		}
+
+		if count < p.Quantity || !value.GreaterThan(p.BundlePrice) {
+			return used, discount
+		}
+
+		for i, n := range taken {
+			used[i] += n
+			remaining[i] -= n
+		}
+		discount = discount.Add(value.Sub(p.BundlePrice))
+	}
+}
+
+// fixedBundles forms as many complete bundles of the components as the cart holds. It returns the
+// units bundled from each line and the total discount.
+func (p *BundlePromotion) fixedBundles(items []CartItem) ([]int, money.Money) {
+	used := make([]int, len(items))
+	discount := money.Zero()
+
+	bundles := -1
+	value := money.Zero()
+	indexes := make([]int, len(p.Components))
+	for c, component := range p.Components {
+		if component.Quantity < 1 {
+			return used, discount
+		}
+
+		index := -1
+		for i := range items {
+			if items[i].ProductSKU == component.SKU {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return used, discount
+		}
+
+		indexes[c] = index
+		value = value.Add(items[index].UnitPrice.Mul(component.Quantity))
+		if n := items[index].Quantity / component.Quantity; bundles == -1 || n < bundles {
+			bundles = n
+		}
+	}
+
+	// Every fixed bundle is worth the same, so either all of them save money or none do
+	if bundles <= 0 || !value.GreaterThan(p.BundlePrice) {
+		return used, discount
+	}
+
+	for c, component := range p.Components {
+		used[indexes[c]] += component.Quantity * bundles
+	}
+	return used, value.Sub(p.BundlePrice).Mul(bundles)
+}
+
+// RequiredSKUs implements the PromotionRule interface for BundlePromotion. A fixed bundle needs
+// every component; a mix-and-match bundle needs none of its SKUs in particular.
+func (p *BundlePromotion) RequiredSKUs() []string {
+	skus := make([]string, 0, len(p.Components))
+	for _, component := range p.Components {
+		skus = append(skus, component.SKU)
+	}
+	return skus
+}
+
+// Validate implements the RuleValidator interface for BundlePromotion. A bundle is either a
+// mix-and-match pool of distinct SKUs with a quantity of at least 2, or a fixed list of distinct
+// components adding up to at least 2 units, but not both.
+func (p *BundlePromotion) Validate() []FieldError {
+	switch {
+	case len(p.SKUs) > 0 && len(p.Components) > 0:
+		return []FieldError{{Field: "components", Message: "a bundle has either skus and quantity or components, not both"}}
+	case len(p.SKUs) == 0 && len(p.Components) == 0:
+		return []FieldError{{Field: "skus", Message: "a bundle needs skus and quantity or components"}}
+	}
+
+	var errs []FieldError
+	seen := make(map[string]bool)
+	if p.IsMixAndMatch() {
+		if p.Quantity < 2 {
+			errs = append(errs, FieldError{Field: "quantity", Message: "must be at least 2"})
+		}
+		for i, sku := range p.SKUs {
+			if seen[sku] {
+				errs = append(errs, FieldError{
+					Field:   fmt.Sprintf("skus[%d]", i),
+					Message: fmt.Sprintf("SKU %s is listed more than once", sku),
+				})
+			}
+			seen[sku] = true
+		}
+		return errs
+	}
+
+	units := 0
+	for i, component := range p.Components {
+		if seen[component.SKU] {
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("components[%d].sku", i),
+				Message: fmt.Sprintf("component %s is listed more than once", component.SKU),
+			})
+		}
+		seen[component.SKU] = true
+		units += component.Quantity
+	}
+	if units < 2 {
+		errs = append(errs, FieldError{Field: "components", Message: "a bundle must contain at least 2 units"})
+	}
+	return errs
+}
+
+// CartPercentageDiscountPromotion represents a percentage off the whole cart when the subtotal
+// reaches a threshold, optionally capped at a maximum discount
+type CartPercentageDiscountPromotion struct {
+	Promotion
+	MinSubtotal        money.Money  `json:"min_subtotal"`
+	DiscountPercentage float64      `json:"discount_percentage"`
+	MaxDiscount        *money.Money `json:"max_discount,omitempty"`
+}
+
+// Apply implements the PromotionRule interface for CartPercentageDiscountPromotion
+func (p *CartPercentageDiscountPromotion) Apply(items []CartItem) []LineDiscount {
+	if p.DiscountPercentage <= 0 || p.DiscountPercentage > 100 {
+		return nil
+	}
+
+	subtotal := CartSubtotal(items)
+	if !subtotal.IsPositive() || subtotal.LessThan(p.MinSubtotal) {
+		return nil
+	}
+
+	// The percentage is taken of the subtotal and rounded once before it is capped and spread
+	// over the lines, so the lines always add up to the advertised discount
+	discount := subtotal.Percent(p.DiscountPercentage)
+	if p.MaxDiscount != nil {
+		discount = discount.Min(*p.MaxDiscount)
+	}
+
+	return spreadOverLines(items, discount)
+}
+
+// RequiredSKUs implements the PromotionRule interface for CartPercentageDiscountPromotion.
+// Cart-level promotions do not depend on any particular product.
+func (p *CartPercentageDiscountPromotion) RequiredSKUs() []string {
+	return nil
+}
+
+// CartFixedDiscountPromotion represents a fixed amount off the whole cart when the subtotal
+// reaches a threshold
+type CartFixedDiscountPromotion struct {
+	Promotion
+	MinSubtotal    money.Money `json:"min_subtotal"`
+	DiscountAmount money.Money `json:"discount_amount"`
+}
+
+// Apply implements the PromotionRule interface for CartFixedDiscountPromotion
+func (p *CartFixedDiscountPromotion) Apply(items []CartItem) []LineDiscount {
+	if !p.DiscountAmount.IsPositive() {
+		return nil
+	}
+
+	subtotal := CartSubtotal(items)
+	if !subtotal.IsPositive() || subtotal.LessThan(p.MinSubtotal) {
+		return nil
+	}
+
+	// The cart is never discounted below zero
+	return spreadOverLines(items, p.DiscountAmount.Min(subtotal))
+}
+
+// RequiredSKUs implements the PromotionRule interface for CartFixedDiscountPromotion.
+// Cart-level promotions do not depend on any particular product.
+func (p *CartFixedDiscountPromotion) RequiredSKUs() []string {
+	return nil
+}
+
+// CartSubtotal returns the total price of the items before any discount
+func CartSubtotal(items []CartItem) money.Money {
+	subtotal := money.Zero()
+	for _, item := range items {
+		subtotal = subtotal.Add(item.UnitPrice.Mul(item.Quantity))
+	}
+	return subtotal
+}
+
+// spreadOverLines splits a cart-level discount over the lines in proportion to their totals
+func spreadOverLines(items []CartItem, discount money.Money) []LineDiscount {
+	weights := make([]int64, len(items))
+	for i, item := range items {
+		weights[i] = item.UnitPrice.Mul(item.Quantity).Amount
+	}
+
+	parts := discount.Allocate(weights)
+	lines := make([]LineDiscount, 0, len(items))
+	for i, item := range items {
+		if !parts[i].IsPositive() {
+			continue
+		}
+		lines = append(lines, LineDiscount{
+			ProductID:  item.ProductID,
+			ProductSKU: item.ProductSKU,
+			Quantity:   item.Quantity,
+			Discount:   parts[i],
+		})
+	}
+	return lines
 }
 
 // NewPromotion creates a new promotion
@@ -179,54 +887,61 @@ func NewPromotion(promotionType PromotionType, description string, rule Promotio
 	return promotion, nil
 }
 
-// ParseRule parses the rule from JSON and returns the appropriate rule instance
+// ParseRule checks the rule against the schema of the promotion type and returns the decoded rule.
+// Unknown promotion types return an error wrapping ErrInvalidPromotionType and rules that do not
+// match their schema return a *RuleError listing every invalid field.
 func (p *Promotion) ParseRule() (PromotionRule, error) {
-	var ruleInstance PromotionRule
-	var err error
-
-	switch p.Type {
-	case BuyOneGetOneFree:
-		var rule BuyOneGetOneFreePromotion
-		err = json.Unmarshal(p.Rule, &rule)
-		if err == nil {
-			rule.Active = p.Active
-			ruleInstance = &rule
-		}
-	case Buy3Pay2:
-		var rule Buy3Pay2Promotion
-		err = json.Unmarshal(p.Rule, &rule)
-		if err == nil {
-			rule.Active = p.Active
-			ruleInstance = &rule
-		}
-	case BulkDiscount:
-		var rule BulkDiscountPromotion
-		err = json.Unmarshal(p.Rule, &rule)
-		if err == nil {
-			rule.Active = p.Active
-			ruleInstance = &rule
+	definition, ok := lookupDefinition(p.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", promotionErrors.ErrInvalidPromotionType, p.Type)
+	}
+
+	if fieldErrs := definition.schema.Validate(p.Rule); len(fieldErrs) > 0 {
+		return nil, &RuleError{Type: p.Type, Fields: fieldErrs}
+	}
+
+	rule := definition.factory()
+	if err := json.Unmarshal(p.Rule, rule); err != nil {
+		return nil, err
+	}
+
+	if validator, ok := rule.(RuleValidator); ok {
+		if fieldErrs := validator.Validate(); len(fieldErrs) > 0 {
+			return nil, &RuleError{Type: p.Type, Fields: fieldErrs}
 		}
-	default:
-		return nil, nil
 	}
 
-	return ruleInstance, err
+	return rule, nil
+}
+
+// RuleSKUReferences returns the product SKUs the rule refers to, with the field holding each one
+func (p *Promotion) RuleSKUReferences() []SKUReference {
+	schema, ok := LookupRuleSchema(p.Type)
+	if !ok {
+		return nil
+	}
+	return schema.SKUReferences(p.Rule)
 }
 
 // ApplyToCart applies the promotion to a cart and returns the discount
-func (p *Promotion) ApplyToCart(items []CartItem) (float64, error) {
-	if !p.Active {
-		return 0, nil
+func (p *Promotion) ApplyToCart(items []CartItem) (money.Money, error) {
+	if !p.IsEffective(time.Now()) {
+		return money.Zero(), nil
 	}
 
 	rule, err := p.ParseRule()
 	if err != nil {
-		return 0, err
+		return money.Zero(), err
 	}
 
-	if rule == nil {
-		return 0, nil
-	}
+	return SumLineDiscounts(rule.Apply(items)), nil
+}
 
-	return rule.Apply(items), nil
+// SumLineDiscounts returns the total discount across line discounts
+func SumLineDiscounts(lines []LineDiscount) money.Money {
+	total := money.Zero()
+	for _, line := range lines {
+		total = total.Add(line.Discount)
+	}
+	return total
 }
@@ -0,0 +1,308 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+)
+
+func TestTieredDiscountPromotion(t *testing.T) {
+	// Tiers are listed out of order; the lookup must not rely on their order
+	promotion := &TieredDiscountPromotion{
+		SKU: "A",
+		Tiers: []DiscountTier{
+			{MinQuantity: 10, DiscountPercentage: 12},
+			{MinQuantity: 5, DiscountPercentage: 5},
+			{MinQuantity: 50, DiscountPercentage: 20},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		quantity        int
+		wantCurrent     int
+		wantNext        int
+		unitsToNextTier int
+		discount        string
+	}{
+		{name: "below the first tier", quantity: 3, wantCurrent: 0, wantNext: 5, unitsToNextTier: 2, discount: "0.00"},
+		{name: "one unit below the first tier", quantity: 4, wantCurrent: 0, wantNext: 5, unitsToNextTier: 1, discount: "0.00"},
+		{name: "at the first tier", quantity: 5, wantCurrent: 5, wantNext: 10, unitsToNextTier: 5, discount: "2.50"},
+		{name: "one unit below the second tier", quantity: 9, wantCurrent: 5, wantNext: 10, unitsToNextTier: 1, discount: "4.50"},
+		{name: "at the second tier", quantity: 10, wantCurrent: 10, wantNext: 50, unitsToNextTier: 40, discount: "12.00"},
+		{name: "one unit below the top tier", quantity: 49, wantCurrent: 10, wantNext: 50, unitsToNextTier: 1, discount: "58.80"},
+		{name: "at the top tier", quantity: 50, wantCurrent: 50, wantNext: 0, unitsToNextTier: 0, discount: "100.00"},
+		{name: "above the top tier", quantity: 60, wantCurrent: 50, wantNext: 0, unitsToNextTier: 0, discount: "120.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []CartItem{cartItem("A", tt.quantity, 1000), cartItem("B", 1, 1000)}
+
+			progress := promotion.TierProgress(items)
+			if progress == nil {
+				t.Fatal("TierProgress returned nil for a product in the cart")
+			}
+			if progress.ProductSKU != "A" || progress.Quantity != tt.quantity {
+				t.Errorf("progress is for %d x %s, want %d x A", progress.Quantity, progress.ProductSKU, tt.quantity)
+			}
+			if got := tierMinQuantity(progress.CurrentTier); got != tt.wantCurrent {
+				t.Errorf("current tier starts at %d, want %d", got, tt.wantCurrent)
+			}
+			if got := tierMinQuantity(progress.NextTier); got != tt.wantNext {
+				t.Errorf("next tier starts at %d, want %d", got, tt.wantNext)
+			}
+			if progress.UnitsToNextTier != tt.unitsToNextTier {
+				t.Errorf("units to next tier = %d, want %d", progress.UnitsToNextTier, tt.unitsToNextTier)
+			}
+
+			lines := promotion.Apply(items)
+			if got := SumLineDiscounts(lines).String(); got != tt.discount {
+				t.Errorf("discount = %s, want %s", got, tt.discount)
+			}
+			for _, line := range lines {
+				if line.ProductSKU != "A" || line.Quantity != tt.quantity {
+					t.Errorf("discounted %d x %s, want the whole A line", line.Quantity, line.ProductSKU)
+				}
+			}
+		})
+	}
+
+	if progress := promotion.TierProgress([]CartItem{cartItem("B", 60, 1000)}); progress != nil {
+		t.Errorf("TierProgress = %+v for a cart without the product, want nil", progress)
+	}
+}
+
+func TestEngineEvaluateTierBelowFirstTier(t *testing.T) {
+	// A tiered promotion below its first tier gives no discount but still reports its progress
+	promotion := testPromotion("tiered A", TieredDiscount, `{"sku": "A", "tiers": [{"min_quantity": 5, "discount_percentage": 5}]}`)
+
+	result := NewEngine().Evaluate([]*Promotion{promotion}, []CartItem{cartItem("A", 3, 1000)}, nil)
+
+	if len(result.Promotions) != 0 || len(result.Skipped) != 0 {
+		t.Errorf("applied %d and skipped %d promotions, want none", len(result.Promotions), len(result.Skipped))
+	}
+	if len(result.Tiers) != 1 {
+		t.Fatalf("got %d tier progresses, want 1", len(result.Tiers))
+	}
+	tier := result.Tiers[0]
+	if tier.PromotionID != promotion.ID || tier.CurrentTier != nil || tier.UnitsToNextTier != 2 {
+		t.Errorf("tier progress = %+v, want no current tier and 2 units to the next", tier)
+	}
+}
+
+// tierMinQuantity returns the quantity a tier starts at, or zero without a tier
+func tierMinQuantity(tier *DiscountTier) int {
+	if tier == nil {
+		return 0
+	}
+	return tier.MinQuantity
+}
+
+func TestBundlePromotion(t *testing.T) {
+	mixAndMatch := func(price int64) *BundlePromotion {
+		return &BundlePromotion{SKUs: []string{"A", "B", "C"}, Quantity: 3, BundlePrice: money.FromMinor(price)}
+	}
+	fixed := func(price int64) *BundlePromotion {
+		return &BundlePromotion{
+			Components:  []BundleComponent{{SKU: "A", Quantity: 1}, {SKU: "B", Quantity: 2}},
+			BundlePrice: money.FromMinor(price),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		promotion *BundlePromotion
+		items     []CartItem
+		want      map[string]string
+		wantUnits map[string]int
+	}{
+		{
+			name:      "mix and match bundles the most expensive units first",
+			promotion: mixAndMatch(2500),
+			items:     []CartItem{cartItem("C", 1, 500), cartItem("A", 2, 1500), cartItem("B", 1, 1000), cartItem("D", 5, 9000)},
+			want:      map[string]string{"A": "11.25", "B": "3.75"},
+			wantUnits: map[string]int{"A": 2, "B": 1},
+		},
+		{
+			name:      "mix and match stops at the first bundle that saves nothing",
+			promotion: mixAndMatch(2500),
+			items:     []CartItem{cartItem("A", 2, 1500), cartItem("B", 2, 1000), cartItem("C", 3, 500)},
+			want:      map[string]string{"A": "11.25", "B": "3.75"},
+			wantUnits: map[string]int{"A": 2, "B": 1},
+		},
+		{
+			name:      "mix and match keeps bundling while bundles save money",
+			promotion: mixAndMatch(1000),
+			items:     []CartItem{cartItem("A", 2, 1500), cartItem("B", 2, 1000), cartItem("C", 3, 500)},
+			want:      map[string]string{"A": "20.00", "B": "13.33", "C": "6.67"},
+			wantUnits: map[string]int{"A": 2, "B": 2, "C": 2},
+		},
+		{
+			name:      "mix and match without enough units for a bundle",
+			promotion: mixAndMatch(1000),
+			items:     []CartItem{cartItem("A", 1, 1500), cartItem("B", 1, 1000), cartItem("D", 3, 500)},
+			want:      map[string]string{},
+			wantUnits: map[string]int{},
+		},
+		{
+			name:      "mix and match spreads the remainder of an uneven discount",
+			promotion: mixAndMatch(2000),
+			items:     []CartItem{cartItem("A", 1, 1000), cartItem("B", 1, 1000), cartItem("C", 1, 1000)},
+			want:      map[string]string{"A": "3.34", "B": "3.33", "C": "3.33"},
+			wantUnits: map[string]int{"A": 1, "B": 1, "C": 1},
+		},
+		{
+			name:      "fixed bundle forms as many bundles as every component allows",
+			promotion: fixed(3000),
+			items:     []CartItem{cartItem("A", 3, 2000), cartItem("B", 5, 1000)},
+			want:      map[string]string{"A": "10.00", "B": "10.00"},
+			wantUnits: map[string]int{"A": 2, "B": 4},
+		},
+		{
+			name:      "fixed bundle with a missing component",
+			promotion: fixed(3000),
+			items:     []CartItem{cartItem("A", 3, 2000), cartItem("C", 5, 1000)},
+			want:      map[string]string{},
+			wantUnits: map[string]int{},
+		},
+		{
+			name:      "fixed bundle with too few units of a component",
+			promotion: fixed(3000),
+			items:     []CartItem{cartItem("A", 3, 2000), cartItem("B", 1, 1000)},
+			want:      map[string]string{},
+			wantUnits: map[string]int{},
+		},
+		{
+			name:      "fixed bundle that saves nothing",
+			promotion: fixed(4000),
+			items:     []CartItem{cartItem("A", 1, 2000), cartItem("B", 2, 1000)},
+			want:      map[string]string{},
+			wantUnits: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, line := range tt.promotion.Apply(tt.items) {
+				got[line.ProductSKU] = line.Discount.String()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply = %v, want %v", got, tt.want)
+			}
+
+			if units := tt.promotion.ClaimedUnits(tt.items); !reflect.DeepEqual(units, tt.wantUnits) {
+				t.Errorf("ClaimedUnits = %v, want %v", units, tt.wantUnits)
+			}
+		})
+	}
+
+	if got, want := fixed(3000).RequiredSKUs(), []string{"A", "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fixed bundle RequiredSKUs = %v, want %v", got, want)
+	}
+	if got := mixAndMatch(3000).RequiredSKUs(); len(got) != 0 {
+		t.Errorf("mix and match RequiredSKUs = %v, want none", got)
+	}
+}
+
+func TestCartDiscountPromotions(t *testing.T) {
+	maxDiscount := money.FromMinor(500)
+
+	tests := []struct {
+		name      string
+		promotion PromotionRule
+		items     []CartItem
+		want      []string
+		total     string
+	}{
+		{
+			name:      "percentage is spread over the lines by their totals",
+			promotion: &CartPercentageDiscountPromotion{MinSubtotal: money.FromMinor(5000), DiscountPercentage: 10},
+			items:     []CartItem{cartItem("A", 3, 2000), cartItem("B", 1, 4000)},
+			want:      []string{"6.00", "4.00"},
+			total:     "10.00",
+		},
+		{
+			name:      "remainders add up to the rounded cart discount",
+			promotion: &CartPercentageDiscountPromotion{DiscountPercentage: 10},
+			items:     []CartItem{cartItem("A", 1, 333), cartItem("B", 1, 333), cartItem("C", 1, 334)},
+			want:      []string{"0.33", "0.33", "0.34"},
+			total:     "1.00",
+		},
+		{
+			name:      "a line too small for a cent gets no share",
+			promotion: &CartPercentageDiscountPromotion{DiscountPercentage: 25},
+			items:     []CartItem{cartItem("A", 1, 1999), cartItem("B", 3, 999), cartItem("C", 2, 1)},
+			want:      []string{"5.00", "7.50"},
+			total:     "12.50",
+		},
+		{
+			name:      "percentage below the threshold",
+			promotion: &CartPercentageDiscountPromotion{MinSubtotal: money.FromMinor(5000), DiscountPercentage: 10},
+			items:     []CartItem{cartItem("A", 2, 2000), cartItem("B", 1, 999)},
+			want:      []string{},
+			total:     "0.00",
+		},
+		{
+			name:      "percentage exactly at the threshold",
+			promotion: &CartPercentageDiscountPromotion{MinSubtotal: money.FromMinor(5000), DiscountPercentage: 10},
+			items:     []CartItem{cartItem("A", 2, 2000), cartItem("B", 1, 1000)},
+			want:      []string{"4.00", "1.00"},
+			total:     "5.00",
+		},
+		{
+			name:      "percentage capped at the maximum discount",
+			promotion: &CartPercentageDiscountPromotion{DiscountPercentage: 20, MaxDiscount: &maxDiscount},
+			items:     []CartItem{cartItem("A", 1, 10000), cartItem("B", 1, 5000)},
+			want:      []string{"3.33", "1.67"},
+			total:     "5.00",
+		},
+		{
+			name:      "percentage under the maximum discount",
+			promotion: &CartPercentageDiscountPromotion{DiscountPercentage: 20, MaxDiscount: &maxDiscount},
+			items:     []CartItem{cartItem("A", 1, 1000), cartItem("B", 1, 500)},
+			want:      []string{"2.00", "1.00"},
+			total:     "3.00",
+		},
+		{
+			name:      "fixed amount is spread over the lines by their totals",
+			promotion: &CartFixedDiscountPromotion{MinSubtotal: money.FromMinor(3000), DiscountAmount: money.FromMinor(1000)},
+			items:     []CartItem{cartItem("A", 1, 1000), cartItem("B", 1, 1000), cartItem("C", 1, 1000)},
+			want:      []string{"3.34", "3.33", "3.33"},
+			total:     "10.00",
+		},
+		{
+			name:      "fixed amount never takes the cart below zero",
+			promotion: &CartFixedDiscountPromotion{DiscountAmount: money.FromMinor(5000)},
+			items:     []CartItem{cartItem("A", 2, 1000), cartItem("B", 1, 500)},
+			want:      []string{"20.00", "5.00"},
+			total:     "25.00",
+		},
+		{
+			name:      "fixed amount below the threshold",
+			promotion: &CartFixedDiscountPromotion{MinSubtotal: money.FromMinor(3000), DiscountAmount: money.FromMinor(1000)},
+			items:     []CartItem{cartItem("A", 1, 2999)},
+			want:      []string{},
+			total:     "0.00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := tt.promotion.Apply(tt.items)
+
+			got := make([]string, 0, len(lines))
+			for _, line := range lines {
+				got = append(got, line.Discount.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("line discounts = %v, want %v", got, tt.want)
+			}
+			if total := SumLineDiscounts(lines).String(); total != tt.total {
+				t.Errorf("discount = %s, want %s", total, tt.total)
+			}
+		})
+	}
+}
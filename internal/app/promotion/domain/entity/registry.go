@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"sync"
+)
+
+// RuleFactory creates an empty rule instance that a promotion's JSON rule is decoded into
+type RuleFactory func() PromotionRule
+
+// ruleDefinition is what the registry knows about a promotion type
+type ruleDefinition struct {
+	factory RuleFactory
+	schema  RuleSchema
+}
+
+var (
+	ruleRegistryMu sync.RWMutex
+	ruleRegistry   = make(map[PromotionType]ruleDefinition)
+)
+
+func init() {
+	RegisterRule(BuyOneGetOneFree, func() PromotionRule { return &BuyOneGetOneFreePromotion{} }, buyOneGetOneFreeSchema)
+	RegisterRule(Buy3Pay2, func() PromotionRule { return &Buy3Pay2Promotion{} }, buy3Pay2Schema)
+	RegisterRule(BulkDiscount, func() PromotionRule { return &BulkDiscountPromotion{} }, bulkDiscountSchema)
+	RegisterRule(CartPercentageDiscount, func() PromotionRule { return &CartPercentageDiscountPromotion{} }, cartPercentageDiscountSchema)
+	RegisterRule(CartFixedDiscount, func() PromotionRule { return &CartFixedDiscountPromotion{} }, cartFixedDiscountSchema)
+	RegisterRule(TieredDiscount, func() PromotionRule { return &TieredDiscountPromotion{} }, tieredDiscountSchema)
+	RegisterRule(Bundle, func() PromotionRule { return &BundlePromotion{} }, bundleSchema)
+}
+
+// RegisterRule registers the rule factory and rule schema for a promotion type.
+// New promotion types only need to be registered here to be picked up by the engine.
+func RegisterRule(promotionType PromotionType, factory RuleFactory, schema RuleSchema) {
+	ruleRegistryMu.Lock()
+	defer ruleRegistryMu.Unlock()
+
+	ruleRegistry[promotionType] = ruleDefinition{factory: factory, schema: schema}
+}
+
+// LookupRule returns the rule factory registered for a promotion type
+func LookupRule(promotionType PromotionType) (RuleFactory, bool) {
+	definition, ok := lookupDefinition(promotionType)
+	return definition.factory, ok
+}
+
+// LookupRuleSchema returns the rule schema registered for a promotion type
+func LookupRuleSchema(promotionType PromotionType) (RuleSchema, bool) {
+	definition, ok := lookupDefinition(promotionType)
+	return definition.schema, ok
+}
+
+func lookupDefinition(promotionType PromotionType) (ruleDefinition, bool) {
+	ruleRegistryMu.RLock()
+	defer ruleRegistryMu.RUnlock()
+
+	definition, ok := ruleRegistry[promotionType]
+	return definition, ok
+}
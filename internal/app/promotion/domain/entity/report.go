@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/google/uuid"
+)
+
+// OrderStats summarises the paid orders in a reporting period. Cancelled orders and orders whose
+// payment failed, was refunded or is still pending are not counted.
+type OrderStats struct {
+	OrderCount        int         `json:"order_count"`
+	Revenue           money.Money `json:"revenue"`
+	AverageOrderValue money.Money `json:"average_order_value"`
+}
+
+// NewOrderStats builds order stats from an order count and the revenue of those orders
+func NewOrderStats(orderCount int, revenue money.Money) OrderStats {
+	return OrderStats{
+		OrderCount:        orderCount,
+		Revenue:           revenue,
+		AverageOrderValue: revenue.Div(orderCount),
+	}
+}
+
+// PromotionPerformance is how a promotion did over a reporting period
+type PromotionPerformance struct {
+	PromotionID uuid.UUID     `json:"promotion_id"`
+	Type        PromotionType `json:"type"`
+	Description string        `json:"description"`
+
+	// RedemptionCount is the number of orders placed in the period that the promotion discounted,
+	// whatever became of them afterwards
+	RedemptionCount int `json:"redemption_count"`
+
+	// TotalDiscount is the discount the promotion gave on paid orders
+	TotalDiscount money.Money `json:"total_discount"`
+
+	// OrderStats covers the paid orders the promotion discounted; their revenue is attributed to it
+	OrderStats
+
+	// AverageOrderValueDifference is the average value of these orders minus that of paid orders
+	// without any promotion; zero when either side has no orders
+	AverageOrderValueDifference money.Money `json:"average_order_value_difference"`
+}
+
+// PromotionReport is the performance of promotions over a period, compared with the paid orders
+// that no promotion discounted
+type PromotionReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	Promotions []PromotionPerformance `json:"promotions"`
+
+	// NonPromoted covers the paid orders in the period without any applied promotion
+	NonPromoted OrderStats `json:"non_promoted"`
+}
+
+// NewPromotionReport builds a report and compares every promotion with the non-promoted orders
+func NewPromotionReport(from, to time.Time, promotions []PromotionPerformance, nonPromoted OrderStats) *PromotionReport {
+	for i := range promotions {
+		promotions[i].AverageOrderValueDifference = money.Zero()
+		if promotions[i].OrderCount > 0 && nonPromoted.OrderCount > 0 {
+			promotions[i].AverageOrderValueDifference = promotions[i].AverageOrderValue.Sub(nonPromoted.AverageOrderValue)
+		}
+	}
+	return &PromotionReport{
+		From:        from,
+		To:          to,
+		Promotions:  promotions,
+		NonPromoted: nonPromoted,
+	}
+}
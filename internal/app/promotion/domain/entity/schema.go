@@ -0,0 +1,360 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
+)
+
+// FieldKind is the JSON type a promotion rule field holds
+type FieldKind string
+
+const (
+	// FieldString is a JSON string
+	FieldString FieldKind = "string"
+	// FieldInteger is a JSON number without a fractional part
+	FieldInteger FieldKind = "integer"
+	// FieldNumber is any JSON number
+	FieldNumber FieldKind = "number"
+	// FieldMoney is an amount in major units, given as a JSON number or a decimal string
+	FieldMoney FieldKind = "money"
+	// FieldStringList is a JSON array of strings
+	FieldStringList FieldKind = "string_list"
+	// FieldObjectList is a JSON array of objects described by the field's Fields
+	FieldObjectList FieldKind = "object_list"
+)
+
+// RuleField declares one field of a promotion rule
+type RuleField struct {
+	Name     string
+	Kind     FieldKind
+	Required bool
+
+	// Min and Max bound integer, number and money fields; nil means unbounded
+	Min *float64
+	Max *float64
+
+	// Positive requires integer, number and money fields to be greater than zero
+	Positive bool
+
+	// MinItems is the minimum length of list fields
+	MinItems int
+
+	// SKU marks string fields, and the entries of string lists, that hold product SKUs
+	SKU bool
+
+	// Fields declares the fields of every object in an object list
+	Fields []RuleField
+}
+
+// RuleSchema declares the fields of the rule of a promotion type.
+// Fields outside the schema are ignored.
+type RuleSchema struct {
+	Fields []RuleField
+}
+
+// FieldError is a problem with one field of a promotion rule. Field is the path of the field
+// inside the rule, such as tiers[1].min_quantity.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// RuleError reports every field of a promotion rule that does not match the schema of its type
+type RuleError struct {
+	Type   PromotionType
+	Fields []FieldError
+}
+
+// Error implements the error interface
+func (e *RuleError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Error())
+	}
+	return fmt.Sprintf("invalid %s promotion rule: %s", e.Type, strings.Join(messages, "; "))
+}
+
+// RuleValidator is implemented by rules whose fields depend on each other.
+// Validate is only called once the rule matches its schema.
+type RuleValidator interface {
+	Validate() []FieldError
+}
+
+// SKUReference is a product SKU used by a promotion rule together with the field holding it
+type SKUReference struct {
+	Field string
+	SKU   string
+}
+
+// limit returns a pointer to a schema bound
+func limit(value float64) *float64 {
+	return &value
+}
+
+var (
+	buyOneGetOneFreeSchema = RuleSchema{Fields: []RuleField{
+		{Name: "trigger_sku", Kind: FieldString, Required: true, SKU: true},
+		{Name: "free_sku", Kind: FieldString, Required: true, SKU: true},
+		{Name: "trigger_quantity", Kind: FieldInteger, Required: true, Min: limit(1)},
+		{Name: "free_quantity", Kind: FieldInteger, Required: true, Min: limit(1)},
+	}}
+
+	buy3Pay2Schema = RuleSchema{Fields: []RuleField{
+		{Name: "sku", Kind: FieldString, Required: true, SKU: true},
+		{Name: "min_quantity", Kind: FieldInteger, Required: true, Min: limit(1)},
+		{Name: "paid_quantity_divisor", Kind: FieldInteger, Required: true, Min: limit(1)},
+		{Name: "free_quantity_divisor", Kind: FieldInteger, Required: true, Min: limit(1)},
+	}}
+
+	bulkDiscountSchema = RuleSchema{Fields: []RuleField{
+		{Name: "sku", Kind: FieldString, Required: true, SKU: true},
+		{Name: "min_quantity", Kind: FieldInteger, Required: true, Min: limit(1)},
+		{Name: "discount_percentage", Kind: FieldNumber, Required: true, Positive: true, Max: limit(100)},
+	}}
+
+	cartPercentageDiscountSchema = RuleSchema{Fields: []RuleField{
+		{Name: "min_subtotal", Kind: FieldMoney, Required: true, Min: limit(0)},
+		{Name: "discount_percentage", Kind: FieldNumber, Required: true, Positive: true, Max: limit(100)},
+		{Name: "max_discount", Kind: FieldMoney, Positive: true},
+	}}
+
+	cartFixedDiscountSchema = RuleSchema{Fields: []RuleField{
+		{Name: "min_subtotal", Kind: FieldMoney, Required: true, Min: limit(0)},
+		{Name: "discount_amount", Kind: FieldMoney, Required: true, Positive: true},
+	}}
+
+	tieredDiscountSchema = RuleSchema{Fields: []RuleField{
+		{Name: "sku", Kind: FieldString, Required: true, SKU: true},
+		{Name: "tiers", Kind: FieldObjectList, Required: true, MinItems: 1, Fields: []RuleField{
+			{Name: "min_quantity", Kind: FieldInteger, Required: true, Min: limit(1)},
+			{Name: "discount_percentage", Kind: FieldNumber, Required: true, Positive: true, Max: limit(100)},
+		}},
+	}}
+
+	bundleSchema = RuleSchema{Fields: []RuleField{
+		{Name: "skus", Kind: FieldStringList, SKU: true},
+		{Name: "quantity", Kind: FieldInteger},
+		{Name: "components", Kind: FieldObjectList, Fields: []RuleField{
+			{Name: "sku", Kind: FieldString, Required: true, SKU: true},
+			{Name: "quantity", Kind: FieldInteger, Required: true, Min: limit(1)},
+		}},
+		{Name: "bundle_price", Kind: FieldMoney, Required: true, Positive: true},
+	}}
+)
+
+// Validate checks a JSON rule against the schema and returns one error per invalid field
+func (s RuleSchema) Validate(raw json.RawMessage) []FieldError {
+	object, fieldErr := decodeRuleObject(raw)
+	if fieldErr != nil {
+		return []FieldError{*fieldErr}
+	}
+
+	var errs []FieldError
+	validateFields("", s.Fields, object, &errs)
+	return errs
+}
+
+// SKUReferences returns the product SKUs held by the SKU fields of a JSON rule.
+// Fields that do not match the schema are skipped.
+func (s RuleSchema) SKUReferences(raw json.RawMessage) []SKUReference {
+	object, fieldErr := decodeRuleObject(raw)
+	if fieldErr != nil {
+		return nil
+	}
+
+	var refs []SKUReference
+	collectSKUReferences("", s.Fields, object, &refs)
+	return refs
+}
+
+// decodeRuleObject decodes a JSON rule, keeping numbers as json.Number so integers can be told
+// apart from fractions
+func decodeRuleObject(raw json.RawMessage) (map[string]interface{}, *FieldError) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, &FieldError{Field: "rule", Message: "must be a JSON object"}
+	}
+	return object, nil
+}
+
+// validateFields checks the fields of one JSON object, prefixing field names with path
+func validateFields(path string, fields []RuleField, object map[string]interface{}, errs *[]FieldError) {
+	for _, field := range fields {
+		name := fieldPath(path, field.Name)
+
+		value, ok := object[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				*errs = append(*errs, FieldError{Field: name, Message: "is required"})
+			}
+			continue
+		}
+
+		validateValue(name, field, value, errs)
+	}
+}
+
+// validateValue checks a single present field value against its declaration
+func validateValue(name string, field RuleField, value interface{}, errs *[]FieldError) {
+	fail := func(message string) {
+		*errs = append(*errs, FieldError{Field: name, Message: message})
+	}
+
+	switch field.Kind {
+	case FieldString:
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if strings.TrimSpace(s) == "" {
+			fail("must not be empty")
+		}
+
+	case FieldInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be an integer")
+			return
+		}
+		n, err := number.Int64()
+		if err != nil {
+			fail("must be an integer")
+			return
+		}
+		if message := checkBounds(field, float64(n)); message != "" {
+			fail(message)
+		}
+
+	case FieldNumber:
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		n, err := number.Float64()
+		if err != nil {
+			fail("must be a number")
+			return
+		}
+		if message := checkBounds(field, n); message != "" {
+			fail(message)
+		}
+
+	case FieldMoney:
+		var amount string
+		switch v := value.(type) {
+		case json.Number:
+			amount = v.String()
+		case string:
+			amount = v
+		default:
+			fail("must be an amount")
+			return
+		}
+		m, err := money.Parse(amount)
+		if err != nil {
+			fail("must be an amount")
+			return
+		}
+		if message := checkBounds(field, m.Float64()); message != "" {
+			fail(message)
+		}
+
+	case FieldStringList:
+		list, ok := value.([]interface{})
+		if !ok {
+			fail("must be a list of strings")
+			return
+		}
+		if len(list) < field.MinItems {
+			fail(fmt.Sprintf("must have at least %d entries", field.MinItems))
+		}
+		for i, entry := range list {
+			s, ok := entry.(string)
+			if !ok || strings.TrimSpace(s) == "" {
+				*errs = append(*errs, FieldError{Field: fmt.Sprintf("%s[%d]", name, i), Message: "must be a non-empty string"})
+			}
+		}
+
+	case FieldObjectList:
+		list, ok := value.([]interface{})
+		if !ok {
+			fail("must be a list of objects")
+			return
+		}
+		if len(list) < field.MinItems {
+			fail(fmt.Sprintf("must have at least %d entries", field.MinItems))
+		}
+		for i, entry := range list {
+			entryPath := fmt.Sprintf("%s[%d]", name, i)
+			object, ok := entry.(map[string]interface{})
+			if !ok {
+				*errs = append(*errs, FieldError{Field: entryPath, Message: "must be an object"})
+				continue
+			}
+			validateFields(entryPath, field.Fields, object, errs)
+		}
+	}
+}
+
+// checkBounds returns why a numeric value falls outside the bounds of a field, or an empty string
+func checkBounds(field RuleField, value float64) string {
+	switch {
+	case field.Positive && value <= 0:
+		return "must be greater than zero"
+	case field.Min != nil && value < *field.Min:
+		return fmt.Sprintf("must be at least %g", *field.Min)
+	case field.Max != nil && value > *field.Max:
+		return fmt.Sprintf("must be at most %g", *field.Max)
+	}
+	return ""
+}
+
+// collectSKUReferences gathers the values of SKU fields in one JSON object
+func collectSKUReferences(path string, fields []RuleField, object map[string]interface{}, refs *[]SKUReference) {
+	for _, field := range fields {
+		name := fieldPath(path, field.Name)
+
+		switch value := object[field.Name].(type) {
+		case string:
+			if field.SKU && field.Kind == FieldString && value != "" {
+				*refs = append(*refs, SKUReference{Field: name, SKU: value})
+			}
+		case []interface{}:
+			for i, entry := range value {
+				entryPath := fmt.Sprintf("%s[%d]", name, i)
+				switch entry := entry.(type) {
+				case string:
+					if field.SKU && field.Kind == FieldStringList && entry != "" {
+						*refs = append(*refs, SKUReference{Field: entryPath, SKU: entry})
+					}
+				case map[string]interface{}:
+					if field.Kind == FieldObjectList {
+						collectSKUReferences(entryPath, field.Fields, entry, refs)
+					}
+				}
+			}
+		}
+	}
+}
+
+// fieldPath joins a parent path and a field name
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
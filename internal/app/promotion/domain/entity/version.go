@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PromotionVersion is an immutable snapshot of the terms of a promotion. Every full update adds a
+// version, and checkouts record the version they were priced with, so past orders keep pointing at
+// the terms that applied to them. The active flag is not part of the terms.
+type PromotionVersion struct {
+	PromotionID uuid.UUID       `json:"promotion_id"`
+	Version     int             `json:"version"`
+	Type        PromotionType   `json:"type"`
+	Description string          `json:"description"`
+	Rule        json.RawMessage `json:"rule"`
+	StartsAt    *time.Time      `json:"starts_at,omitempty"`
+	EndsAt      *time.Time      `json:"ends_at,omitempty"`
+
+	StackingRules
+
+	Eligibility    Eligibility `json:"eligibility"`
+	MaxRedemptions *int        `json:"max_redemptions,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// Snapshot returns the current terms of the promotion as a version
+func (p *Promotion) Snapshot() *PromotionVersion {
+	return &PromotionVersion{
+		PromotionID:    p.ID,
+		Version:        p.Version,
+		Type:           p.Type,
+		Description:    p.Description,
+		Rule:           p.Rule,
+		StartsAt:       p.StartsAt,
+		EndsAt:         p.EndsAt,
+		StackingRules:  p.StackingRules,
+		Eligibility:    p.Eligibility,
+		MaxRedemptions: p.MaxRedemptions,
+		CreatedAt:      p.UpdatedAt,
+	}
+}
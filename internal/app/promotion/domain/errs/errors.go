@@ -6,7 +6,20 @@ import "errors"
 var (
 	ErrPromotionNotFound         = errors.New("promotion not found")
 	ErrInvalidPromotionType      = errors.New("invalid promotion type")
+	ErrInvalidPromotionRule      = errors.New("invalid promotion rule")
 	ErrInvalidDiscountPercentage = errors.New("invalid discount percentage")
 	ErrInvalidMinQuantity        = errors.New("invalid minimum quantity")
+	ErrPromotionVersionConflict  = errors.New("promotion was changed by another update")
 	ErrDuplicatePromotion        = errors.New("promotion with this configuration already exists")
+	ErrCouponNotFound            = errors.New("coupon not found")
+	ErrCouponInactive            = errors.New("coupon is not active")
+	ErrCouponExpired             = errors.New("coupon has expired")
+	ErrCouponUsageLimitReached   = errors.New("coupon has reached its usage limit")
+	ErrCouponUserLimitReached    = errors.New("coupon has already been used the maximum number of times by this user")
+	ErrPromotionCapReached       = errors.New("promotion has reached its redemption cap")
+	ErrCouponPromotionInactive   = errors.New("coupon promotion is not running")
+	ErrCouponNotApplicable       = errors.New("coupon does not apply to the items in the cart")
+	ErrDuplicateCouponCode       = errors.New("coupon code already exists")
+	ErrCustomerNotFound          = errors.New("customer not found")
+	ErrInvalidSegment            = errors.New("invalid customer segment")
 )
@@ -2,7 +2,9 @@ package errs
 
 import (
 	"fmt"
+	"net/http"
 
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
 	appErrors "github.com/fanzru/e-commerce-be/pkg/errors"
 )
 
@@ -13,6 +15,7 @@ const (
 	ErrPromotionRuleParsingMsg   = "failed to parse promotion rule"
 	ErrPromotionApplicationMsg   = "failed to apply promotion"
 	ErrPromotionAlreadyExistsMsg = "promotion already exists"
+	ErrCustomerNotFoundMsg       = "customer not found"
 )
 
 // NewPromotionNotFoundError creates a new promotion not found error
@@ -20,6 +23,11 @@ func NewPromotionNotFoundError(id string) error {
 	return appErrors.NewNotFound(fmt.Sprintf("%s: %s", ErrPromotionNotFoundMsg, id))
 }
 
+// NewCustomerNotFoundError creates a new customer not found error
+func NewCustomerNotFoundError(id string) error {
+	return appErrors.NewNotFound(fmt.Sprintf("%s: %s", ErrCustomerNotFoundMsg, id))
+}
+
 // NewInvalidPromotionTypeError creates a new invalid promotion type error
 func NewInvalidPromotionTypeError(promotionType string) error {
 	return appErrors.NewBadRequest(fmt.Sprintf("%s: %s", ErrInvalidPromotionTypeMsg, promotionType))
@@ -30,6 +38,24 @@ func NewPromotionRuleParsingError(promotionType string, err error) error {
 	return appErrors.NewBadRequest(fmt.Sprintf("%s for type %s: %v", ErrPromotionRuleParsingMsg, promotionType, err))
 }
 
+// NewInvalidPromotionRuleError creates a validation error listing every invalid field of a promotion rule
+func NewInvalidPromotionRuleError(promotionType string, fieldErrs []error) error {
+	validationErr := commonErrs.NewValidationError(
+		ErrInvalidPromotionRule,
+		commonErrs.CodeValidationError,
+		fmt.Sprintf("%s for type %s", ErrInvalidPromotionRule, promotionType),
+	)
+	for _, fieldErr := range fieldErrs {
+		validationErr.AddDetail(fieldErr)
+	}
+	return validationErr
+}
+
+// NewUnknownProductSKUError creates a new error for a SKU that does not belong to any product
+func NewUnknownProductSKUError(sku string) error {
+	return appErrors.NewBadRequest(fmt.Sprintf("no product with SKU %s", sku))
+}
+
 // NewPromotionApplicationError creates a new promotion application error
 func NewPromotionApplicationError(promotionID string, err error) error {
 	return appErrors.NewInternalServerError(fmt.Errorf("%s for promotion %s: %w", ErrPromotionApplicationMsg, promotionID, err))
@@ -39,3 +65,42 @@ func NewPromotionApplicationError(promotionID string, err error) error {
 func NewPromotionAlreadyExistsError(description string) error {
 	return appErrors.NewConflict(fmt.Sprintf("%s: %s", ErrPromotionAlreadyExistsMsg, description))
 }
+
+// NewPromotionVersionConflictError creates an error for an update based on a version that is no
+// longer the current one
+func NewPromotionVersionConflictError(id string, expectedVersion int) error {
+	return commonErrs.NewWithData(
+		ErrPromotionVersionConflict,
+		commonErrs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("promotion %s is no longer at version %d; reload it and try again", id, expectedVersion),
+		map[string]interface{}{
+			"expected_version": expectedVersion,
+		},
+	)
+}
+
+// NewCouponNotApplicableError wraps the reason a coupon cannot be redeemed as a promotion_not_applicable error.
+// Both errors.Is(err, commonErrs.ErrPromotionNotApplicable) and errors.Is(err, reason) hold.
+func NewCouponNotApplicableError(code string, reason error) error {
+	return commonErrs.NewWithData(
+		fmt.Errorf("%w: %w", commonErrs.ErrPromotionNotApplicable, reason),
+		commonErrs.ErrPromotionNotApplicable.Code,
+		commonErrs.ErrPromotionNotApplicable.Status,
+		fmt.Sprintf("coupon %s cannot be used: %v", code, reason),
+		map[string]interface{}{
+			"coupon_code": code,
+			"reason":      reason.Error(),
+		},
+	)
+}
+
+// NewDuplicateCouponCodeError creates an error for a coupon code that is already taken
+func NewDuplicateCouponCodeError(code string) error {
+	return commonErrs.New(
+		ErrDuplicateCouponCode,
+		commonErrs.CodeConflict,
+		http.StatusConflict,
+		fmt.Sprintf("%v: %s", ErrDuplicateCouponCode, code),
+	)
+}
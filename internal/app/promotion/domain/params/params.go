@@ -1,6 +1,9 @@
 package params
 
 import (
+	"time"
+
+	"github.com/fanzru/e-commerce-be/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -11,34 +14,142 @@ const (
 	BuyOneGetOneFree PromotionTypeEnum = "BUY_ONE_GET_ONE_FREE"
 	Buy3Pay2         PromotionTypeEnum = "BUY_3_PAY_2"
 	BulkDiscount     PromotionTypeEnum = "BULK_DISCOUNT"
+
+	CartPercentageDiscount PromotionTypeEnum = "CART_PERCENTAGE_DISCOUNT"
+	CartFixedDiscount      PromotionTypeEnum = "CART_FIXED_DISCOUNT"
+	TieredDiscount         PromotionTypeEnum = "TIERED_DISCOUNT"
+	Bundle                 PromotionTypeEnum = "BUNDLE"
 )
 
 // CreateBuyOneGetOneFreeParams defines the parameters for creating a buy one get one free promotion
 type CreateBuyOneGetOneFreeParams struct {
-	Description string `json:"description" binding:"required"`
-	TriggerSKU  string `json:"trigger_sku" binding:"required"`
-	FreeSKU     string `json:"free_sku" binding:"required"`
-	TriggerQty  int    `json:"trigger_quantity" binding:"required,gt=0"`
-	FreeQty     int    `json:"free_quantity" binding:"required,gt=0"`
+	Description    string             `json:"description" binding:"required"`
+	TriggerSKU     string             `json:"trigger_sku" binding:"required"`
+	FreeSKU        string             `json:"free_sku" binding:"required"`
+	TriggerQty     int                `json:"trigger_quantity" binding:"required,gt=0"`
+	FreeQty        int                `json:"free_quantity" binding:"required,gt=0"`
+	StartsAt       *time.Time         `json:"starts_at,omitempty"`
+	EndsAt         *time.Time         `json:"ends_at,omitempty"`
+	MaxRedemptions *int               `json:"max_redemptions,omitempty"`
+	Priority       int                `json:"priority"`
+	Exclusive      bool               `json:"exclusive"`
+	StackableWith  []uuid.UUID        `json:"stackable_with,omitempty"`
+	Eligibility    *EligibilityParams `json:"eligibility,omitempty"`
 }
 
 // CreateBuy3Pay2Params defines the parameters for creating a buy 3 pay 2 promotion
 type CreateBuy3Pay2Params struct {
-	Description         string `json:"description" binding:"required"`
-	SKU                 string `json:"sku" binding:"required"`
-	MinQuantity         int    `json:"min_quantity" binding:"required,gt=0"`
-	PaidQuantityDivisor int    `json:"paid_quantity_divisor" binding:"required,gt=0"`
-	FreeQuantityDivisor int    `json:"free_quantity_divisor" binding:"required,gt=0"`
+	Description         string             `json:"description" binding:"required"`
+	SKU                 string             `json:"sku" binding:"required"`
+	MinQuantity         int                `json:"min_quantity" binding:"required,gt=0"`
+	PaidQuantityDivisor int                `json:"paid_quantity_divisor" binding:"required,gt=0"`
+	FreeQuantityDivisor int                `json:"free_quantity_divisor" binding:"required,gt=0"`
+	StartsAt            *time.Time         `json:"starts_at,omitempty"`
+	EndsAt              *time.Time         `json:"ends_at,omitempty"`
+	MaxRedemptions      *int               `json:"max_redemptions,omitempty"`
+	Priority            int                `json:"priority"`
+	Exclusive           bool               `json:"exclusive"`
+	StackableWith       []uuid.UUID        `json:"stackable_with,omitempty"`
+	Eligibility         *EligibilityParams `json:"eligibility,omitempty"`
 }
 
 // CreateBulkDiscountParams defines the parameters for creating a bulk discount promotion
 type CreateBulkDiscountParams struct {
-	Description        string  `json:"description" binding:"required"`
-	SKU                string  `json:"sku" binding:"required"`
+	Description        string             `json:"description" binding:"required"`
+	SKU                string             `json:"sku" binding:"required"`
+	MinQuantity        int                `json:"min_quantity" binding:"required,gt=0"`
+	DiscountPercentage float64            `json:"discount_percentage" binding:"required,gt=0,lte=100"`
+	StartsAt           *time.Time         `json:"starts_at,omitempty"`
+	EndsAt             *time.Time         `json:"ends_at,omitempty"`
+	MaxRedemptions     *int               `json:"max_redemptions,omitempty"`
+	Priority           int                `json:"priority"`
+	Exclusive          bool               `json:"exclusive"`
+	StackableWith      []uuid.UUID        `json:"stackable_with,omitempty"`
+	Eligibility        *EligibilityParams `json:"eligibility,omitempty"`
+}
+
+// DiscountTierParams defines a quantity break of a tiered discount
+type DiscountTierParams struct {
 	MinQuantity        int     `json:"min_quantity" binding:"required,gt=0"`
 	DiscountPercentage float64 `json:"discount_percentage" binding:"required,gt=0,lte=100"`
 }
 
+// CreateTieredDiscountParams defines the parameters for creating a tiered discount promotion
+type CreateTieredDiscountParams struct {
+	Description    string               `json:"description" binding:"required"`
+	SKU            string               `json:"sku" binding:"required"`
+	Tiers          []DiscountTierParams `json:"tiers" binding:"required,min=1,dive"`
+	StartsAt       *time.Time           `json:"starts_at,omitempty"`
+	EndsAt         *time.Time           `json:"ends_at,omitempty"`
+	MaxRedemptions *int                 `json:"max_redemptions,omitempty"`
+	Priority       int                  `json:"priority"`
+	Exclusive      bool                 `json:"exclusive"`
+	StackableWith  []uuid.UUID          `json:"stackable_with,omitempty"`
+	Eligibility    *EligibilityParams   `json:"eligibility,omitempty"`
+}
+
+// BundleComponentParams defines a product and the number of its units a fixed bundle needs
+type BundleComponentParams struct {
+	SKU      string `json:"sku" binding:"required"`
+	Quantity int    `json:"quantity" binding:"required,gt=0"`
+}
+
+// CreateBundleParams defines the parameters for creating a bundle promotion. Either SKUs and
+// Quantity (mix and match) or Components (fixed bundle) are set.
+type CreateBundleParams struct {
+	Description    string                  `json:"description" binding:"required"`
+	SKUs           []string                `json:"skus,omitempty"`
+	Quantity       int                     `json:"quantity,omitempty"`
+	Components     []BundleComponentParams `json:"components,omitempty" binding:"omitempty,dive"`
+	BundlePrice    money.Money             `json:"bundle_price" binding:"required"`
+	StartsAt       *time.Time              `json:"starts_at,omitempty"`
+	EndsAt         *time.Time              `json:"ends_at,omitempty"`
+	MaxRedemptions *int                    `json:"max_redemptions,omitempty"`
+	Priority       int                     `json:"priority"`
+	Exclusive      bool                    `json:"exclusive"`
+	StackableWith  []uuid.UUID             `json:"stackable_with,omitempty"`
+	Eligibility    *EligibilityParams      `json:"eligibility,omitempty"`
+}
+
+// CreateCartPercentageDiscountParams defines the parameters for creating a percentage off the cart
+// once its subtotal reaches a threshold
+type CreateCartPercentageDiscountParams struct {
+	Description        string             `json:"description" binding:"required"`
+	MinSubtotal        money.Money        `json:"min_subtotal"`
+	DiscountPercentage float64            `json:"discount_percentage" binding:"required,gt=0,lte=100"`
+	MaxDiscount        *money.Money       `json:"max_discount,omitempty"`
+	StartsAt           *time.Time         `json:"starts_at,omitempty"`
+	EndsAt             *time.Time         `json:"ends_at,omitempty"`
+	MaxRedemptions     *int               `json:"max_redemptions,omitempty"`
+	Priority           int                `json:"priority"`
+	Exclusive          bool               `json:"exclusive"`
+	StackableWith      []uuid.UUID        `json:"stackable_with,omitempty"`
+	Eligibility        *EligibilityParams `json:"eligibility,omitempty"`
+}
+
+// CreateCartFixedDiscountParams defines the parameters for creating a fixed amount off the cart
+// once its subtotal reaches a threshold
+type CreateCartFixedDiscountParams struct {
+	Description    string             `json:"description" binding:"required"`
+	MinSubtotal    money.Money        `json:"min_subtotal"`
+	DiscountAmount money.Money        `json:"discount_amount" binding:"required"`
+	StartsAt       *time.Time         `json:"starts_at,omitempty"`
+	EndsAt         *time.Time         `json:"ends_at,omitempty"`
+	MaxRedemptions *int               `json:"max_redemptions,omitempty"`
+	Priority       int                `json:"priority"`
+	Exclusive      bool               `json:"exclusive"`
+	StackableWith  []uuid.UUID        `json:"stackable_with,omitempty"`
+	Eligibility    *EligibilityParams `json:"eligibility,omitempty"`
+}
+
+// EligibilityParams defines the customers a promotion is limited to
+type EligibilityParams struct {
+	FirstOrderOnly   bool         `json:"first_order_only"`
+	Roles            []string     `json:"roles,omitempty" binding:"dive,oneof=admin customer"`
+	Segments         []string     `json:"segments,omitempty" binding:"dive,required,max=100"`
+	MinLifetimeSpend *money.Money `json:"min_lifetime_spend,omitempty"`
+}
+
 // UpdatePromotionStatusParams defines the parameters for updating a promotion status
 type UpdatePromotionStatusParams struct {
 	Active bool `json:"active" binding:"required"`
@@ -46,10 +157,17 @@ type UpdatePromotionStatusParams struct {
 
 // PromotionResponse defines the response structure for a promotion
 type PromotionResponse struct {
-	ID          uuid.UUID         `json:"id"`
-	Type        PromotionTypeEnum `json:"type"`
-	Description string            `json:"description"`
-	Active      bool              `json:"active"`
+	ID             uuid.UUID          `json:"id"`
+	Type           PromotionTypeEnum  `json:"type"`
+	Description    string             `json:"description"`
+	Active         bool               `json:"active"`
+	StartsAt       *time.Time         `json:"starts_at,omitempty"`
+	EndsAt         *time.Time         `json:"ends_at,omitempty"`
+	MaxRedemptions *int               `json:"max_redemptions,omitempty"`
+	Priority       int                `json:"priority"`
+	Exclusive      bool               `json:"exclusive"`
+	StackableWith  []uuid.UUID        `json:"stackable_with,omitempty"`
+	Eligibility    *EligibilityParams `json:"eligibility,omitempty"`
 }
 
 // PromotionListResponse defines the response structure for a list of promotions
@@ -1,14 +1,21 @@
 package port
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/port/genhttp"
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/usecase"
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
+	appmiddleware "github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
 	"github.com/fanzru/e-commerce-be/pkg/errors"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
@@ -76,8 +83,19 @@ func (h *PromotionHandler) ListPromotions(w http.ResponseWriter, r *http.Request
 		active = params.Active
 	}
 
+	var cursor string
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+
+	req, err := pagination.NewRequest(page, limit, cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid cursor"))
+		return
+	}
+
 	// Call the use case
-	promotions, total, err := h.promotionUseCase.List(ctx, page, limit, active)
+	promotions, result, err := h.promotionUseCase.List(ctx, req, active)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -92,19 +110,21 @@ func (h *PromotionHandler) ListPromotions(w http.ResponseWriter, r *http.Request
 			Type:        &promotionType,
 			Description: &promo.Description,
 			Active:      &promo.Active,
-			CreatedAt:   &promo.CreatedAt,
-			UpdatedAt:   &promo.UpdatedAt,
-		}
-	}
+			StartsAt:    promo.StartsAt,
+			EndsAt:      promo.EndsAt,
 
-	meta := genhttp.PaginationMeta{
-		CurrentPage: &page,
-		PerPage:     &limit,
-		Total:       &total,
+			MaxRedemptions:  promo.MaxRedemptions,
+			RedemptionCount: &promo.RedemptionCount,
+			Priority:        &promo.Priority,
+			Exclusive:       &promo.Exclusive,
+			StackableWith:   mapPromotionIDs(promo.StackableWith),
+
+			CreatedAt: &promo.CreatedAt,
+			UpdatedAt: &promo.UpdatedAt,
+		}
 	}
 
-	totalPages := (total + limit - 1) / limit
-	meta.TotalPages = &totalPages
+	meta := mapPaginationMeta(req, result)
 
 	response := genhttp.PromotionListResponse{
 		Code:    "success",
@@ -144,10 +164,43 @@ func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	var promotion *entity.Promotion
-	var err error
+	startsAt, err := parseOptionalTime(requestBody, "starts_at")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	endsAt, err := parseOptionalTime(requestBody, "ends_at")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	maxRedemptions, err := parseOptionalInt(requestBody, "max_redemptions")
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	stacking, err := parseStackingRules(requestBody)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
 
-	// Create the appropriate promotion type based on the request
+	eligibility, err := parseEligibility(requestBody)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	active := true
+	if a, ok := requestBody["active"].(bool); ok {
+		active = a
+	}
+
+	// Build the rule of the requested promotion type
+	var rule entity.PromotionRule
 	switch promotionType {
 	case string(genhttp.PromotionTypeBUYONEGETONEFREE):
 		triggerSku, ok := requestBody["trigger_sku"].(string)
@@ -172,13 +225,13 @@ func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Reques
 			freeQuantity = int(fq)
 		}
 
-		active := true
-		if a, ok := requestBody["active"].(bool); ok {
-			active = a
+		rule = &entity.BuyOneGetOneFreePromotion{
+			TriggerSKU:      triggerSku,
+			FreeSKU:         freeSku,
+			TriggerQuantity: triggerQuantity,
+			FreeQuantity:    freeQuantity,
 		}
 
-		promotion, err = h.promotionUseCase.CreateBuyOneGetOneFree(ctx, description, triggerSku, freeSku, triggerQuantity, freeQuantity, active)
-
 	case string(genhttp.PromotionTypeBUY3PAY2):
 		sku, ok := requestBody["sku"].(string)
 		if !ok {
@@ -201,13 +254,13 @@ func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Reques
 			freeQuantityDivisor = int(fqd)
 		}
 
-		active := true
-		if a, ok := requestBody["active"].(bool); ok {
-			active = a
+		rule = &entity.Buy3Pay2Promotion{
+			SKU:                 sku,
+			MinQuantity:         minQuantity,
+			PaidQuantityDivisor: paidQuantityDivisor,
+			FreeQuantityDivisor: freeQuantityDivisor,
 		}
 
-		promotion, err = h.promotionUseCase.CreateBuy3Pay2(ctx, description, sku, minQuantity, paidQuantityDivisor, freeQuantityDivisor, active)
-
 	case string(genhttp.PromotionTypeBULKDISCOUNT):
 		sku, ok := requestBody["sku"].(string)
 		if !ok {
@@ -225,18 +278,125 @@ func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Reques
 			discountPercentage = dp
 		}
 
-		active := true
-		if a, ok := requestBody["active"].(bool); ok {
-			active = a
+		rule = &entity.BulkDiscountPromotion{
+			SKU:                sku,
+			MinQuantity:        minQuantity,
+			DiscountPercentage: discountPercentage,
+		}
+
+	case string(genhttp.PromotionTypeTIEREDDISCOUNT):
+		sku, ok := requestBody["sku"].(string)
+		if !ok {
+			handleError(w, errors.NewBadRequest("missing sku for tiered discount promotion"))
+			return
+		}
+
+		var tiers []entity.DiscountTier
+		if tiers, err = parseTiers(requestBody); err != nil {
+			handleError(w, err)
+			return
+		}
+
+		rule = &entity.TieredDiscountPromotion{
+			SKU:   sku,
+			Tiers: tiers,
+		}
+
+	case string(genhttp.PromotionTypeBUNDLE):
+		var skus []string
+		var quantity *int
+		var components []entity.BundleComponent
+		var bundlePrice *money.Money
+		if skus, err = parseOptionalStrings(requestBody, "skus"); err != nil {
+			handleError(w, err)
+			return
+		}
+		if quantity, err = parseOptionalInt(requestBody, "quantity"); err != nil {
+			handleError(w, err)
+			return
+		}
+		if components, err = parseBundleComponents(requestBody); err != nil {
+			handleError(w, err)
+			return
+		}
+		if bundlePrice, err = parseOptionalMoney(requestBody, "bundle_price"); err != nil {
+			handleError(w, err)
+			return
+		}
+		if bundlePrice == nil {
+			handleError(w, errors.NewBadRequest("missing bundle_price for bundle promotion"))
+			return
+		}
+
+		bundleQuantity := 0
+		if quantity != nil {
+			bundleQuantity = *quantity
+		}
+
+		rule = &entity.BundlePromotion{
+			SKUs:        skus,
+			Quantity:    bundleQuantity,
+			Components:  components,
+			BundlePrice: *bundlePrice,
+		}
+
+	case string(genhttp.PromotionTypeCARTPERCENTAGEDISCOUNT):
+		var minSubtotal, maxDiscount *money.Money
+		if minSubtotal, err = parseOptionalMoney(requestBody, "min_subtotal"); err != nil {
+			handleError(w, err)
+			return
+		}
+		if maxDiscount, err = parseOptionalMoney(requestBody, "max_discount"); err != nil {
+			handleError(w, err)
+			return
+		}
+
+		discountPercentage, ok := requestBody["discount_percentage"].(float64)
+		if !ok {
+			handleError(w, errors.NewBadRequest("missing discount_percentage for cart percentage discount promotion"))
+			return
 		}
 
-		promotion, err = h.promotionUseCase.CreateBulkDiscount(ctx, description, sku, minQuantity, discountPercentage, active)
+		rule = &entity.CartPercentageDiscountPromotion{
+			MinSubtotal:        moneyOrZero(minSubtotal),
+			DiscountPercentage: discountPercentage,
+			MaxDiscount:        maxDiscount,
+		}
+
+	case string(genhttp.PromotionTypeCARTFIXEDDISCOUNT):
+		var minSubtotal, discountAmount *money.Money
+		if minSubtotal, err = parseOptionalMoney(requestBody, "min_subtotal"); err != nil {
+			handleError(w, err)
+			return
+		}
+		if discountAmount, err = parseOptionalMoney(requestBody, "discount_amount"); err != nil {
+			handleError(w, err)
+			return
+		}
+		if discountAmount == nil {
+			handleError(w, errors.NewBadRequest("missing discount_amount for cart fixed discount promotion"))
+			return
+		}
+
+		rule = &entity.CartFixedDiscountPromotion{
+			MinSubtotal:    moneyOrZero(minSubtotal),
+			DiscountAmount: *discountAmount,
+		}
 
 	default:
 		handleError(w, errors.NewBadRequest("invalid promotion type"))
 		return
 	}
 
+	promotion, err := h.promotionUseCase.CreatePromotion(ctx, entity.PromotionType(promotionType), rule, usecase.PromotionOptions{
+		Description:    description,
+		Active:         active,
+		StartsAt:       startsAt,
+		EndsAt:         endsAt,
+		MaxRedemptions: maxRedemptions,
+		StackingRules:  stacking,
+		Eligibility:    eligibility,
+	})
 	if err != nil {
 		handleError(w, err)
 		return
@@ -245,6 +405,150 @@ func (h *PromotionHandler) CreatePromotion(w http.ResponseWriter, r *http.Reques
 	respondJSON(w, http.StatusCreated, mapPromotionToResponse(promotion))
 }
 
+// UpdatePromotion handles PUT /api/v1/promotions/{id} requests
+func (h *PromotionHandler) UpdatePromotion(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	promotionID, err := uuid.Parse(id.String())
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid promotion ID"))
+		return
+	}
+
+	var requestBody genhttp.UpdatePromotionJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		handleError(w, errors.NewBadRequest("invalid request body"))
+		return
+	}
+
+	rule, err := json.Marshal(requestBody.Rule)
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid promotion rule"))
+		return
+	}
+
+	update := usecase.PromotionUpdate{
+		Type:            entity.PromotionType(requestBody.Type),
+		Description:     requestBody.Description,
+		Rule:            rule,
+		Active:          true,
+		StartsAt:        requestBody.StartsAt,
+		EndsAt:          requestBody.EndsAt,
+		MaxRedemptions:  requestBody.MaxRedemptions,
+		ExpectedVersion: requestBody.Version,
+	}
+	if requestBody.Active != nil {
+		update.Active = *requestBody.Active
+	}
+	if requestBody.Priority != nil {
+		update.Priority = *requestBody.Priority
+	}
+	if requestBody.Exclusive != nil {
+		update.Exclusive = *requestBody.Exclusive
+	}
+	if requestBody.StackableWith != nil {
+		update.StackableWith = append(update.StackableWith, *requestBody.StackableWith...)
+	}
+	if requestBody.Eligibility != nil {
+		update.Eligibility = mapEligibilityRequest(*requestBody.Eligibility)
+	}
+
+	promotion, err := h.promotionUseCase.UpdatePromotion(ctx, promotionID, update)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapPromotionToResponse(promotion))
+}
+
+// ListPromotionVersions handles GET /api/v1/promotions/{id}/versions requests
+func (h *PromotionHandler) ListPromotionVersions(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	promotionID, err := uuid.Parse(id.String())
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid promotion ID"))
+		return
+	}
+
+	versions, err := h.promotionUseCase.ListVersions(ctx, promotionID)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	versionsData := make([]genhttp.PromotionVersion, 0, len(versions))
+	for _, version := range versions {
+		versionsData = append(versionsData, mapPromotionVersion(version))
+	}
+
+	response := genhttp.PromotionVersionListResponse{
+		Code:    "success",
+		Message: "Promotion versions retrieved successfully",
+		Data: struct {
+			Versions *[]genhttp.PromotionVersion `json:"versions,omitempty"`
+		}{
+			Versions: &versionsData,
+		},
+		ServerTime: time.Now(),
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// GetPromotionsReport handles GET /api/v1/promotions/report requests
+func (h *PromotionHandler) GetPromotionsReport(w http.ResponseWriter, r *http.Request, params genhttp.GetPromotionsReportParams) {
+	ctx := r.Context()
+
+	var requestedFormat string
+	if params.Format != nil {
+		requestedFormat = string(*params.Format)
+	}
+	format, err := parseReportFormat(requestedFormat)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	report, err := h.promotionUseCase.GetReport(ctx, nil, params.From, params.To)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondReport(w, report, format)
+}
+
+// GetPromotionReport handles GET /api/v1/promotions/{id}/report requests
+func (h *PromotionHandler) GetPromotionReport(w http.ResponseWriter, r *http.Request, id openapi_types.UUID, params genhttp.GetPromotionReportParams) {
+	ctx := r.Context()
+
+	promotionID, err := uuid.Parse(id.String())
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid promotion ID"))
+		return
+	}
+
+	var requestedFormat string
+	if params.Format != nil {
+		requestedFormat = string(*params.Format)
+	}
+	format, err := parseReportFormat(requestedFormat)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	report, err := h.promotionUseCase.GetReport(ctx, &promotionID, params.From, params.To)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondReport(w, report, format)
+}
+
 // UpdatePromotionStatus handles PATCH /api/v1/promotions/{id} requests
 func (h *PromotionHandler) UpdatePromotionStatus(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
 	ctx := r.Context()
@@ -303,6 +607,148 @@ func (h *PromotionHandler) DeletePromotion(w http.ResponseWriter, r *http.Reques
 	respondJSON(w, http.StatusNoContent, response)
 }
 
+// AddSegmentMember handles PUT /api/v1/segments/{segment}/members/{user_id} requests
+func (h *PromotionHandler) AddSegmentMember(w http.ResponseWriter, r *http.Request, segment string, userId openapi_types.UUID) {
+	ctx := r.Context()
+
+	userID, err := uuid.Parse(userId.String())
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid user ID"))
+		return
+	}
+
+	if err := h.promotionUseCase.AddCustomerToSegment(ctx, userID, segment); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.StandardResponse{
+		Code:       "success",
+		Message:    "User added to segment successfully",
+		ServerTime: time.Now(),
+		Data:       map[string]interface{}{},
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// RemoveSegmentMember handles DELETE /api/v1/segments/{segment}/members/{user_id} requests
+func (h *PromotionHandler) RemoveSegmentMember(w http.ResponseWriter, r *http.Request, segment string, userId openapi_types.UUID) {
+	ctx := r.Context()
+
+	userID, err := uuid.Parse(userId.String())
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid user ID"))
+		return
+	}
+
+	if err := h.promotionUseCase.RemoveCustomerFromSegment(ctx, userID, segment); err != nil {
+		handleError(w, err)
+		return
+	}
+
+	response := genhttp.StandardResponse{
+		Code:       "success",
+		Message:    "User removed from segment successfully",
+		ServerTime: time.Now(),
+		Data:       map[string]interface{}{},
+	}
+
+	respondJSON(w, http.StatusNoContent, response)
+}
+
+// CreatePromotionCoupon handles POST /api/v1/promotions/{id}/coupons requests
+func (h *PromotionHandler) CreatePromotionCoupon(w http.ResponseWriter, r *http.Request, id openapi_types.UUID) {
+	ctx := r.Context()
+
+	promotionID, err := uuid.Parse(id.String())
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid promotion ID"))
+		return
+	}
+
+	var requestBody genhttp.CreatePromotionCouponJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		handleError(w, errors.NewBadRequest("invalid request body"))
+		return
+	}
+
+	coupon, err := h.promotionUseCase.CreateCoupon(
+		ctx,
+		promotionID,
+		requestBody.Code,
+		requestBody.MaxRedemptions,
+		requestBody.MaxRedemptionsPerUser,
+		requestBody.ExpiresAt,
+	)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, mapCouponToResponse(coupon))
+}
+
+// PreviewPromotion handles POST /api/v1/promotions/preview requests
+func (h *PromotionHandler) PreviewPromotion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var requestBody genhttp.PreviewPromotionJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		handleError(w, errors.NewBadRequest("invalid request body"))
+		return
+	}
+
+	rule, err := json.Marshal(requestBody.Promotion.Rule)
+	if err != nil {
+		handleError(w, errors.NewBadRequest("invalid promotion rule"))
+		return
+	}
+
+	draft := usecase.PromotionDraft{
+		Type: entity.PromotionType(requestBody.Promotion.Type),
+		Rule: rule,
+	}
+	if requestBody.Promotion.Description != nil {
+		draft.Description = *requestBody.Promotion.Description
+	}
+	if requestBody.Promotion.Priority != nil {
+		draft.Priority = *requestBody.Promotion.Priority
+	}
+	if requestBody.Promotion.Exclusive != nil {
+		draft.Exclusive = *requestBody.Promotion.Exclusive
+	}
+	if requestBody.Promotion.StackableWith != nil {
+		draft.StackableWith = append(draft.StackableWith, *requestBody.Promotion.StackableWith...)
+	}
+	if requestBody.Promotion.Eligibility != nil {
+		draft.Eligibility = mapEligibilityRequest(*requestBody.Promotion.Eligibility)
+	}
+
+	var basket []usecase.PreviewItem
+	if requestBody.Items != nil {
+		for _, item := range *requestBody.Items {
+			basket = append(basket, usecase.PreviewItem{
+				SKU:      item.Sku,
+				Quantity: item.Quantity,
+			})
+		}
+	}
+
+	includeActive := true
+	if requestBody.IncludeActivePromotions != nil {
+		includeActive = *requestBody.IncludeActivePromotions
+	}
+
+	preview, err := h.promotionUseCase.PreviewPromotion(ctx, draft, basket, requestBody.UserId, includeActive)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, mapPreviewToResponse(preview))
+}
+
 // Helper functions
 
 // mapPromotionToResponse maps a promotion entity to a promotion response
@@ -313,8 +759,19 @@ func mapPromotionToResponse(promotion *entity.Promotion) genhttp.PromotionRespon
 		Type:        &promotionType,
 		Description: &promotion.Description,
 		Active:      &promotion.Active,
-		CreatedAt:   &promotion.CreatedAt,
-		UpdatedAt:   &promotion.UpdatedAt,
+		StartsAt:    promotion.StartsAt,
+		EndsAt:      promotion.EndsAt,
+
+		MaxRedemptions:  promotion.MaxRedemptions,
+		RedemptionCount: &promotion.RedemptionCount,
+		Priority:        &promotion.Priority,
+		Exclusive:       &promotion.Exclusive,
+		StackableWith:   mapPromotionIDs(promotion.StackableWith),
+		Eligibility:     mapEligibility(promotion.Eligibility),
+		Version:         &promotion.Version,
+
+		CreatedAt: &promotion.CreatedAt,
+		UpdatedAt: &promotion.UpdatedAt,
 	}
 
 	return genhttp.PromotionResponse{
@@ -325,6 +782,559 @@ func mapPromotionToResponse(promotion *entity.Promotion) genhttp.PromotionRespon
 	}
 }
 
+// mapPromotionVersion maps a promotion version entity to its API representation
+func mapPromotionVersion(version *entity.PromotionVersion) genhttp.PromotionVersion {
+	promotionType := string(version.Type)
+	versionData := genhttp.PromotionVersion{
+		PromotionId:    &version.PromotionID,
+		Version:        &version.Version,
+		Type:           &promotionType,
+		Description:    &version.Description,
+		StartsAt:       version.StartsAt,
+		EndsAt:         version.EndsAt,
+		MaxRedemptions: version.MaxRedemptions,
+		Priority:       &version.Priority,
+		Exclusive:      &version.Exclusive,
+		StackableWith:  mapPromotionIDs(version.StackableWith),
+		Eligibility:    mapEligibility(version.Eligibility),
+		CreatedAt:      &version.CreatedAt,
+	}
+
+	var rule map[string]interface{}
+	if err := json.Unmarshal(version.Rule, &rule); err == nil {
+		versionData.Rule = &rule
+	}
+
+	return versionData
+}
+
+// mapCouponToResponse maps a coupon entity to a coupon response
+func mapCouponToResponse(coupon *entity.Coupon) genhttp.CouponResponse {
+	couponData := genhttp.Coupon{
+		Id:                    &coupon.ID,
+		Code:                  &coupon.Code,
+		PromotionId:           &coupon.PromotionID,
+		MaxRedemptions:        coupon.MaxRedemptions,
+		MaxRedemptionsPerUser: coupon.MaxRedemptionsPerUser,
+		RedemptionCount:       &coupon.RedemptionCount,
+		ExpiresAt:             coupon.ExpiresAt,
+		Active:                &coupon.Active,
+		CreatedAt:             &coupon.CreatedAt,
+		UpdatedAt:             &coupon.UpdatedAt,
+	}
+
+	return genhttp.CouponResponse{
+		Code:       "success",
+		Message:    "Coupon created successfully",
+		Data:       couponData,
+		ServerTime: time.Now(),
+	}
+}
+
+// mapPreviewToResponse maps a promotion preview to a preview response
+func mapPreviewToResponse(preview *usecase.PromotionPreview) genhttp.PromotionPreviewResponse {
+	lines := make([]genhttp.PreviewLine, len(preview.Lines))
+	for i, line := range preview.Lines {
+		lines[i] = genhttp.PreviewLine{
+			ProductId:   line.ProductID,
+			ProductSku:  line.ProductSKU,
+			ProductName: line.ProductName,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice.Float64(),
+			Subtotal:    line.Subtotal.Float64(),
+			Discount:    line.Discount.Float64(),
+			Total:       line.Total.Float64(),
+		}
+	}
+
+	applied := make([]genhttp.PreviewAppliedPromotion, len(preview.Discounts))
+	for i, promo := range preview.Discounts {
+		allocations := make([]genhttp.PreviewAllocation, len(promo.Allocations))
+		for j, allocation := range promo.Allocations {
+			allocations[j] = genhttp.PreviewAllocation{
+				ProductSku: allocation.ProductSKU,
+				Quantity:   allocation.Quantity,
+				Discount:   allocation.Discount.Float64(),
+			}
+		}
+
+		applied[i] = genhttp.PreviewAppliedPromotion{
+			PromotionId: promo.PromotionID,
+			Type:        promo.PromotionType,
+			Description: promo.Description,
+			Discount:    promo.Discount.Float64(),
+			Allocations: allocations,
+		}
+	}
+
+	skipped := make([]genhttp.PreviewSkippedPromotion, len(preview.Skipped))
+	for i, promo := range preview.Skipped {
+		skipped[i] = genhttp.PreviewSkippedPromotion{
+			PromotionId:       promo.PromotionID,
+			Type:              promo.PromotionType,
+			Description:       promo.Description,
+			Reason:            promo.Reason,
+			BlockedBy:         promo.BlockedBy,
+			PotentialDiscount: promo.PotentialDiscount.Float64(),
+		}
+	}
+
+	var approximate *bool
+	if preview.Approximate {
+		approximate = &preview.Approximate
+	}
+
+	return genhttp.PromotionPreviewResponse{
+		Code:    "success",
+		Message: "Promotion preview calculated successfully",
+		Data: genhttp.PromotionPreview{
+			DraftPromotionId:  preview.DraftPromotionID,
+			Lines:             lines,
+			AppliedPromotions: applied,
+			SkippedPromotions: skipped,
+			Subtotal:          preview.Subtotal.Float64(),
+			TotalDiscount:     preview.TotalDiscount.Float64(),
+			Total:             preview.Total.Float64(),
+			Approximate:       approximate,
+		},
+		ServerTime: time.Now(),
+	}
+}
+
+// Report formats
+const (
+	reportFormatJSON = "json"
+	reportFormatCSV  = "csv"
+)
+
+// reportCSVHeader is the header row of a CSV promotion report
+var reportCSVHeader = []string{
+	"promotion_id",
+	"type",
+	"description",
+	"from",
+	"to",
+	"redemption_count",
+	"order_count",
+	"total_discount",
+	"revenue",
+	"average_order_value",
+	"average_order_value_difference",
+	"non_promoted_order_count",
+	"non_promoted_revenue",
+	"non_promoted_average_order_value",
+}
+
+// parseReportFormat checks the format query parameter of a report, defaulting to JSON
+func parseReportFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return reportFormatJSON, nil
+	case reportFormatJSON, reportFormatCSV:
+		return format, nil
+	default:
+		return "", errors.NewBadRequest(fmt.Sprintf("unsupported report format %q, use json or csv", format))
+	}
+}
+
+// respondReport sends a promotion report in the requested format
+func respondReport(w http.ResponseWriter, report *entity.PromotionReport, format string) {
+	if format == reportFormatCSV {
+		respondReportCSV(w, report)
+		return
+	}
+	respondJSON(w, http.StatusOK, mapReportToResponse(report))
+}
+
+// respondReportCSV streams a promotion report as CSV, one row per promotion
+func respondReportCSV(w http.ResponseWriter, report *entity.PromotionReport) {
+	filename := fmt.Sprintf("promotion-report-%s-%s.csv", report.From.Format("20060102"), report.To.Format("20060102"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(reportCSVHeader); err != nil {
+		appmiddleware.Logger.Error("Failed to write report CSV", "error", err.Error())
+		return
+	}
+
+	nonPromoted := report.NonPromoted
+	for _, performance := range report.Promotions {
+		record := []string{
+			performance.PromotionID.String(),
+			string(performance.Type),
+			performance.Description,
+			report.From.Format(time.RFC3339),
+			report.To.Format(time.RFC3339),
+			strconv.Itoa(performance.RedemptionCount),
+			strconv.Itoa(performance.OrderCount),
+			performance.TotalDiscount.String(),
+			performance.Revenue.String(),
+			performance.AverageOrderValue.String(),
+			performance.AverageOrderValueDifference.String(),
+			strconv.Itoa(nonPromoted.OrderCount),
+			nonPromoted.Revenue.String(),
+			nonPromoted.AverageOrderValue.String(),
+		}
+		if err := writer.Write(record); err != nil {
+			appmiddleware.Logger.Error("Failed to write report CSV", "error", err.Error())
+			return
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		appmiddleware.Logger.Error("Failed to write report CSV", "error", err.Error())
+	}
+}
+
+// mapReportToResponse maps a promotion report to its JSON response
+func mapReportToResponse(report *entity.PromotionReport) genhttp.PromotionReportResponse {
+	promotions := make([]genhttp.PromotionPerformance, len(report.Promotions))
+	for i, performance := range report.Promotions {
+		promotions[i] = genhttp.PromotionPerformance{
+			PromotionId:                 performance.PromotionID,
+			Type:                        string(performance.Type),
+			Description:                 performance.Description,
+			RedemptionCount:             performance.RedemptionCount,
+			TotalDiscount:               performance.TotalDiscount.Float64(),
+			OrderCount:                  performance.OrderCount,
+			Revenue:                     performance.Revenue.Float64(),
+			AverageOrderValue:           performance.AverageOrderValue.Float64(),
+			AverageOrderValueDifference: performance.AverageOrderValueDifference.Float64(),
+		}
+	}
+
+	return genhttp.PromotionReportResponse{
+		Code:    "success",
+		Message: "Promotion report retrieved successfully",
+		Data: genhttp.PromotionReport{
+			From:       report.From,
+			To:         report.To,
+			Promotions: promotions,
+			NonPromoted: genhttp.ReportOrderStats{
+				OrderCount:        report.NonPromoted.OrderCount,
+				Revenue:           report.NonPromoted.Revenue.Float64(),
+				AverageOrderValue: report.NonPromoted.AverageOrderValue.Float64(),
+			},
+		},
+		ServerTime: time.Now(),
+	}
+}
+
+// mapPromotionIDs maps promotion IDs to the response representation
+func mapPromotionIDs(ids entity.PromotionIDs) *[]openapi_types.UUID {
+	mapped := make([]openapi_types.UUID, len(ids))
+	copy(mapped, ids)
+	return &mapped
+}
+
+// mapPaginationMeta maps the page returned for a request; the current page is only known in
+// page-number mode and the page count only when the total was counted
+func mapPaginationMeta(req pagination.Request, result pagination.Result) genhttp.PaginationMeta {
+	meta := genhttp.PaginationMeta{
+		PerPage:    &req.Limit,
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
+		PrevCursor: result.PrevCursor,
+	}
+	if req.Cursor == nil {
+		meta.CurrentPage = &req.Page
+	}
+	if result.Total != nil {
+		totalPages := (*result.Total + req.Limit - 1) / req.Limit
+		meta.TotalPages = &totalPages
+	}
+	return meta
+}
+
+// parseStackingRules reads the optional priority, exclusive and stackable_with fields from a decoded request body
+func parseStackingRules(requestBody map[string]interface{}) (entity.StackingRules, error) {
+	var stacking entity.StackingRules
+
+	priority, err := parseOptionalInt(requestBody, "priority")
+	if err != nil {
+		return stacking, err
+	}
+	if priority != nil {
+		stacking.Priority = *priority
+	}
+
+	if value, ok := requestBody["exclusive"]; ok && value != nil {
+		exclusive, ok := value.(bool)
+		if !ok {
+			return stacking, errors.NewBadRequest("exclusive must be a boolean")
+		}
+		stacking.Exclusive = exclusive
+	}
+
+	if value, ok := requestBody["stackable_with"]; ok && value != nil {
+		list, ok := value.([]interface{})
+		if !ok {
+			return stacking, errors.NewBadRequest("stackable_with must be a list of promotion IDs")
+		}
+		for _, item := range list {
+			str, ok := item.(string)
+			if !ok {
+				return stacking, errors.NewBadRequest("stackable_with must be a list of promotion IDs")
+			}
+			id, err := uuid.Parse(str)
+			if err != nil {
+				return stacking, errors.NewBadRequest(fmt.Sprintf("invalid promotion ID in stackable_with: %s", str))
+			}
+			stacking.StackableWith = append(stacking.StackableWith, id)
+		}
+	}
+
+	return stacking, nil
+}
+
+// parseEligibility reads the optional eligibility object from a decoded request body
+func parseEligibility(requestBody map[string]interface{}) (entity.Eligibility, error) {
+	var eligibility entity.Eligibility
+
+	value, ok := requestBody["eligibility"]
+	if !ok || value == nil {
+		return eligibility, nil
+	}
+	eligibilityBody, ok := value.(map[string]interface{})
+	if !ok {
+		return eligibility, errors.NewBadRequest("eligibility must be an object")
+	}
+
+	if value, ok := eligibilityBody["first_order_only"]; ok && value != nil {
+		firstOrderOnly, ok := value.(bool)
+		if !ok {
+			return eligibility, errors.NewBadRequest("first_order_only must be a boolean")
+		}
+		eligibility.FirstOrderOnly = firstOrderOnly
+	}
+
+	roles, err := parseOptionalStrings(eligibilityBody, "roles")
+	if err != nil {
+		return eligibility, err
+	}
+	eligibility.Roles = roles
+
+	segments, err := parseOptionalStrings(eligibilityBody, "segments")
+	if err != nil {
+		return eligibility, err
+	}
+	eligibility.Segments = segments
+
+	minLifetimeSpend, err := parseOptionalMoney(eligibilityBody, "min_lifetime_spend")
+	if err != nil {
+		return eligibility, err
+	}
+	eligibility.MinLifetimeSpend = minLifetimeSpend
+
+	return eligibility, nil
+}
+
+// mapEligibilityRequest maps the eligibility of a typed request body to the entity
+func mapEligibilityRequest(request genhttp.PromotionEligibility) entity.Eligibility {
+	var eligibility entity.Eligibility
+	if request.FirstOrderOnly != nil {
+		eligibility.FirstOrderOnly = *request.FirstOrderOnly
+	}
+	if request.Roles != nil {
+		eligibility.Roles = append(eligibility.Roles, *request.Roles...)
+	}
+	if request.Segments != nil {
+		eligibility.Segments = append(eligibility.Segments, *request.Segments...)
+	}
+	if request.MinLifetimeSpend != nil {
+		minLifetimeSpend := money.FromFloat(*request.MinLifetimeSpend)
+		eligibility.MinLifetimeSpend = &minLifetimeSpend
+	}
+	return eligibility
+}
+
+// mapEligibility maps an eligibility entity to its API representation
+func mapEligibility(eligibility entity.Eligibility) *genhttp.PromotionEligibility {
+	mapped := genhttp.PromotionEligibility{
+		FirstOrderOnly: &eligibility.FirstOrderOnly,
+	}
+	if len(eligibility.Roles) > 0 {
+		roles := append([]string(nil), eligibility.Roles...)
+		mapped.Roles = &roles
+	}
+	if len(eligibility.Segments) > 0 {
+		segments := append([]string(nil), eligibility.Segments...)
+		mapped.Segments = &segments
+	}
+	if eligibility.MinLifetimeSpend != nil {
+		minLifetimeSpend := eligibility.MinLifetimeSpend.Float64()
+		mapped.MinLifetimeSpend = &minLifetimeSpend
+	}
+	return &mapped
+}
+
+// parseTiers reads the tiers of a tiered discount from a decoded request body
+func parseTiers(requestBody map[string]interface{}) ([]entity.DiscountTier, error) {
+	list, ok := requestBody["tiers"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, errors.NewBadRequest("missing tiers for tiered discount promotion")
+	}
+
+	tiers := make([]entity.DiscountTier, 0, len(list))
+	for _, item := range list {
+		tierBody, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.NewBadRequest("each tier must be an object with min_quantity and discount_percentage")
+		}
+
+		minQuantity, err := parseOptionalInt(tierBody, "min_quantity")
+		if err != nil {
+			return nil, err
+		}
+		discountPercentage, ok := tierBody["discount_percentage"].(float64)
+		if minQuantity == nil || !ok {
+			return nil, errors.NewBadRequest("each tier must be an object with min_quantity and discount_percentage")
+		}
+
+		tiers = append(tiers, entity.DiscountTier{
+			MinQuantity:        *minQuantity,
+			DiscountPercentage: discountPercentage,
+		})
+	}
+
+	return tiers, nil
+}
+
+// parseBundleComponents reads the optional components of a fixed bundle from a decoded request body
+func parseBundleComponents(requestBody map[string]interface{}) ([]entity.BundleComponent, error) {
+	value, ok := requestBody["components"]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.NewBadRequest("components must be a list of objects with sku and quantity")
+	}
+
+	components := make([]entity.BundleComponent, 0, len(list))
+	for _, item := range list {
+		componentBody, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.NewBadRequest("components must be a list of objects with sku and quantity")
+		}
+
+		sku, ok := componentBody["sku"].(string)
+		if !ok {
+			return nil, errors.NewBadRequest("components must be a list of objects with sku and quantity")
+		}
+
+		quantity, err := parseOptionalInt(componentBody, "quantity")
+		if err != nil {
+			return nil, err
+		}
+
+		component := entity.BundleComponent{SKU: sku, Quantity: 1}
+		if quantity != nil {
+			component.Quantity = *quantity
+		}
+		components = append(components, component)
+	}
+
+	return components, nil
+}
+
+// parseOptionalStrings reads an optional list of strings from a decoded request body
+func parseOptionalStrings(requestBody map[string]interface{}, key string) ([]string, error) {
+	value, ok := requestBody[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.NewBadRequest(fmt.Sprintf("%s must be a list of strings", key))
+	}
+
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, errors.NewBadRequest(fmt.Sprintf("%s must be a list of strings", key))
+		}
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+// parseOptionalInt reads an optional whole number from a decoded request body
+func parseOptionalInt(requestBody map[string]interface{}, key string) (*int, error) {
+	value, ok := requestBody[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	number, ok := value.(float64)
+	if !ok || number != float64(int(number)) {
+		return nil, errors.NewBadRequest(fmt.Sprintf("%s must be a whole number", key))
+	}
+
+	parsed := int(number)
+	return &parsed, nil
+}
+
+// parseOptionalMoney reads an optional amount in major units, given as a number or a decimal string,
+// from a decoded request body
+func parseOptionalMoney(requestBody map[string]interface{}, key string) (*money.Money, error) {
+	value, ok := requestBody[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	var parsed money.Money
+	switch v := value.(type) {
+	case float64:
+		parsed = money.FromFloat(v)
+	case string:
+		amount, err := money.Parse(v)
+		if err != nil {
+			return nil, errors.NewBadRequest(fmt.Sprintf("%s must be an amount", key))
+		}
+		parsed = amount
+	default:
+		return nil, errors.NewBadRequest(fmt.Sprintf("%s must be an amount", key))
+	}
+
+	return &parsed, nil
+}
+
+// moneyOrZero returns the amount, or zero when it was not given
+func moneyOrZero(amount *money.Money) money.Money {
+	if amount == nil {
+		return money.Zero()
+	}
+	return *amount
+}
+
+// parseOptionalTime reads an optional RFC 3339 timestamp from a decoded request body
+func parseOptionalTime(requestBody map[string]interface{}, key string) (*time.Time, error) {
+	value, ok := requestBody[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return nil, errors.NewBadRequest(fmt.Sprintf("%s must be an RFC 3339 timestamp", key))
+	}
+
+	parsed, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("%s must be an RFC 3339 timestamp", key))
+	}
+
+	return &parsed, nil
+}
+
 // respondJSON sends a JSON response
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -340,6 +1350,13 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 
 // handleError handles errors and sends appropriate HTTP responses
 func handleError(w http.ResponseWriter, err error) {
+	// Errors carrying an application code (e.g. promotion_expired) keep their code and data, and
+	// validation errors keep their field details
+	if commonErrs.IsAppError(err) || commonErrs.IsValidationError(err) {
+		appmiddleware.RespondWithError(w, err)
+		return
+	}
+
 	var status int
 	var message string
 
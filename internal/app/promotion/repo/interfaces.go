@@ -2,8 +2,10 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -12,12 +14,21 @@ type PromotionRepository interface {
 	// GetByID retrieves a promotion by its ID
 	GetByID(ctx context.Context, id uuid.UUID) (*entity.Promotion, error)
 
-	// List retrieves a list of promotions with pagination and filtering
-	List(ctx context.Context, page, limit int, active *bool) ([]*entity.Promotion, int, error)
+	// List retrieves a page of promotions, newest first, by page number or cursor
+	List(ctx context.Context, req pagination.Request, active *bool) ([]*entity.Promotion, pagination.Result, error)
 
-	// Create creates a new promotion
+	// Create creates a new promotion together with its first version. Callers run it in a
+	// transaction so the two are saved together.
 	Create(ctx context.Context, promotion *entity.Promotion) error
 
+	// Update replaces the terms and status of a promotion and records the new terms as the next
+	// version. It fails with ErrPromotionVersionConflict when the stored version is no longer
+	// expectedVersion. Callers run it in a transaction so the promotion and version are saved together.
+	Update(ctx context.Context, promotion *entity.Promotion, expectedVersion int) error
+
+	// ListVersions retrieves every version of a promotion, newest first
+	ListVersions(ctx context.Context, promotionID uuid.UUID) ([]*entity.PromotionVersion, error)
+
 	// UpdateStatus updates a promotion's active status
 	UpdateStatus(ctx context.Context, id uuid.UUID, active bool) error
 
@@ -27,6 +38,51 @@ type PromotionRepository interface {
 	// GetByType retrieves promotions by type
 	GetByType(ctx context.Context, promotionType entity.PromotionType) ([]*entity.Promotion, error)
 
-	// GetActive retrieves all active promotions
+	// GetActive retrieves all active promotions whose validity window includes the current time,
+	// excluding promotions that are only unlocked by a coupon
 	GetActive(ctx context.Context) ([]*entity.Promotion, error)
+
+	// GetOutsideWindow retrieves active promotions that have not started yet or that expired after
+	// endedAfter
+	GetOutsideWindow(ctx context.Context, endedAfter time.Time) ([]*entity.Promotion, error)
+
+	// GetPerformance aggregates the orders placed between from (inclusive) and to (exclusive) per
+	// applied promotion, optionally for a single promotion
+	GetPerformance(ctx context.Context, from, to time.Time, promotionID *uuid.UUID) ([]entity.PromotionPerformance, error)
+
+	// GetNonPromotedOrderStats aggregates the paid orders placed between from (inclusive) and to
+	// (exclusive) that no promotion discounted
+	GetNonPromotedOrderStats(ctx context.Context, from, to time.Time) (entity.OrderStats, error)
+}
+
+// CouponRepository defines the interface for coupon repository
+type CouponRepository interface {
+	// Create creates a new coupon
+	Create(ctx context.Context, coupon *entity.Coupon) error
+
+	// GetByCode retrieves a coupon by its code
+	GetByCode(ctx context.Context, code string) (*entity.Coupon, error)
+
+	// GetByID retrieves a coupon by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*entity.Coupon, error)
+
+	// CountUserRedemptions counts how many times a user has redeemed a coupon
+	CountUserRedemptions(ctx context.Context, couponID, userID uuid.UUID) (int, error)
+
+	// Redeem records the redemption of a coupon by a checkout, enforcing the coupon, per-user and
+	// promotion limits. It should run inside the transaction that creates the checkout.
+	Redeem(ctx context.Context, coupon *entity.Coupon, userID, checkoutID uuid.UUID) error
+}
+
+// CustomerRepository defines the interface for the customer context used by promotion eligibility
+type CustomerRepository interface {
+	// GetCustomer loads the role, segments and order history of a user. Inside a transaction the
+	// user stays locked until it ends.
+	GetCustomer(ctx context.Context, userID uuid.UUID) (*entity.Customer, error)
+
+	// AddToSegment adds a user to a named segment
+	AddToSegment(ctx context.Context, userID uuid.UUID, segment string) error
+
+	// RemoveFromSegment removes a user from a named segment
+	RemoveFromSegment(ctx context.Context, userID uuid.UUID, segment string) error
 }
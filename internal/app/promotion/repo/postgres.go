@@ -10,9 +10,22 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
 	domainErrors "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// promotionKeyset orders promotion listings newest first
+var promotionKeyset = pagination.Keyset{
+	Name: "newest",
+	Columns: []pagination.Column{
+		{Expr: "promotions.created_at", Desc: true},
+		{Expr: "promotions.id", Desc: true},
+	},
+}
+
 // PromotionPostgresRepository implements PromotionRepository using PostgreSQL
 type PromotionPostgresRepository struct {
 	db *sql.DB
@@ -35,18 +48,28 @@ func (r *PromotionPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 	startTime := time.Now()
 
 	query := `
-		SELECT id, type, description, rule, active, created_at, updated_at
+		SELECT id, type, description, rule, active, starts_at, ends_at, max_redemptions, redemption_count,
+		       priority, exclusive, stackable_with, eligibility, version, created_at, updated_at
 		FROM promotions
 		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	var promotion entity.Promotion
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&promotion.ID,
 		&promotion.Type,
 		&promotion.Description,
 		&promotion.Rule,
 		&promotion.Active,
+		&promotion.StartsAt,
+		&promotion.EndsAt,
+		&promotion.MaxRedemptions,
+		&promotion.RedemptionCount,
+		&promotion.Priority,
+		&promotion.Exclusive,
+		&promotion.StackableWith,
+		&promotion.Eligibility,
+		&promotion.Version,
 		&promotion.CreatedAt,
 		&promotion.UpdatedAt,
 	)
@@ -69,12 +92,13 @@ func (r *PromotionPostgresRepository) GetByID(ctx context.Context, id uuid.UUID)
 	return &promotion, nil
 }
 
-// List retrieves a list of promotions with pagination and filtering
-func (r *PromotionPostgresRepository) List(ctx context.Context, page, limit int, active *bool) ([]*entity.Promotion, int, error) {
+// List retrieves a page of promotions, newest first, with filtering
+func (r *PromotionPostgresRepository) List(ctx context.Context, req pagination.Request, active *bool) ([]*entity.Promotion, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "PromotionRepository.List",
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	if active != nil {
 		logger = logger.With("active", *active)
@@ -82,78 +106,101 @@ func (r *PromotionPostgresRepository) List(ctx context.Context, page, limit int,
 	logger.Debug("Listing promotions with filters")
 	startTime := time.Now()
 
-	offset := (page - 1) * limit
-
 	// Base query for filtering
 	whereClause := "WHERE deleted_at IS NULL"
 	args := []interface{}{}
-	argPos := 1
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
 	// Add filters if provided
 	if active != nil {
-		whereClause += fmt.Sprintf(" AND active = $%d", argPos)
-		args = append(args, *active)
-		argPos++
+		whereClause += " AND active = " + arg(*active)
 	}
 
-	// Count total matches first
-	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM promotions %s`, whereClause)
+	// Count total matches first, when asked for
 	var total int
-	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if req.IncludeTotal {
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM promotions %s`, whereClause)
+		err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+		if err != nil {
+			logger.Error("Failed to count promotions", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("error counting promotions: %w", err)
+		}
+	}
+
+	afterCursor, err := promotionKeyset.Where(req, arg)
 	if err != nil {
-		logger.Error("Failed to count promotions", "error", err.Error())
-		return nil, 0, fmt.Errorf("error counting promotions: %w", err)
+		logger.Warn("Invalid input: cursor", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+	if afterCursor != "" {
+		whereClause += " AND " + afterCursor
 	}
 
 	// Now fetch the actual data with pagination
 	query := fmt.Sprintf(`
-		SELECT id, type, description, rule, active, created_at, updated_at
+		SELECT id, type, description, rule, active, starts_at, ends_at, max_redemptions, redemption_count,
+		       priority, exclusive, stackable_with, eligibility, version, created_at, updated_at, %s
 		FROM promotions
 		%s
-		ORDER BY created_at DESC
-		LIMIT $%d OFFSET $%d
-	`, whereClause, argPos, argPos+1)
-
-	args = append(args, limit, offset)
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, promotionKeyset.Select(), whereClause, promotionKeyset.OrderBy(req), arg(req.FetchLimit()), arg(req.Offset()))
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		logger.Error("Failed to query promotions", "error", err.Error())
-		return nil, 0, fmt.Errorf("error querying promotions: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error querying promotions: %w", err)
 	}
 	defer rows.Close()
 
 	promotions := []*entity.Promotion{}
+	keys := [][]*string{}
 	for rows.Next() {
 		var promotion entity.Promotion
-		err := rows.Scan(
+		keyDest, rowKeys := promotionKeyset.ScanKeys()
+		err := rows.Scan(append([]interface{}{
 			&promotion.ID,
 			&promotion.Type,
 			&promotion.Description,
 			&promotion.Rule,
 			&promotion.Active,
+			&promotion.StartsAt,
+			&promotion.EndsAt,
+			&promotion.MaxRedemptions,
+			&promotion.RedemptionCount,
+			&promotion.Priority,
+			&promotion.Exclusive,
+			&promotion.StackableWith,
+			&promotion.Eligibility,
+			&promotion.Version,
 			&promotion.CreatedAt,
 			&promotion.UpdatedAt,
-		)
+		}, keyDest...)...)
 		if err != nil {
 			logger.Error("Failed to scan promotion row", "error", err.Error())
-			return nil, 0, fmt.Errorf("error scanning promotion row: %w", err)
+			return nil, pagination.Result{}, fmt.Errorf("error scanning promotion row: %w", err)
 		}
 		promotions = append(promotions, &promotion)
+		keys = append(keys, rowKeys)
 	}
 
 	if err = rows.Err(); err != nil {
 		logger.Error("Failed to iterate promotion rows", "error", err.Error())
-		return nil, 0, fmt.Errorf("error iterating promotion rows: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error iterating promotion rows: %w", err)
 	}
 
+	promotions, result := pagination.Page(promotions, keys, req, promotionKeyset, total)
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully listed promotions",
 		"total_count", total,
 		"returned_count", len(promotions),
 		"duration_ms", duration.Milliseconds())
 
-	return promotions, total, nil
+	return promotions, result, nil
 }
 
 // Create creates a new promotion
@@ -171,19 +218,30 @@ func (r *PromotionPostgresRepository) Create(ctx context.Context, promotion *ent
 		promotion.ID = uuid.New()
 	}
 
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
 	query := `
-		INSERT INTO promotions (id, type, description, rule, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-		RETURNING created_at, updated_at
+		INSERT INTO promotions (id, type, description, rule, active, starts_at, ends_at, max_redemptions,
+		                        priority, exclusive, stackable_with, eligibility, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 1, NOW(), NOW())
+		RETURNING version, created_at, updated_at
 	`
 
-	err := r.db.QueryRowContext(ctx, query,
+	err := queryable.QueryRowContext(ctx, query,
 		promotion.ID,
 		promotion.Type,
 		promotion.Description,
 		promotion.Rule,
 		promotion.Active,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.MaxRedemptions,
+		promotion.Priority,
+		promotion.Exclusive,
+		promotion.StackableWith,
+		promotion.Eligibility,
 	).Scan(
+		&promotion.Version,
 		&promotion.CreatedAt,
 		&promotion.UpdatedAt,
 	)
@@ -193,6 +251,11 @@ func (r *PromotionPostgresRepository) Create(ctx context.Context, promotion *ent
 		return fmt.Errorf("error creating promotion: %w", err)
 	}
 
+	if err := insertVersion(ctx, queryable, promotion.Snapshot()); err != nil {
+		logger.Error("Failed to record promotion version", "error", err.Error())
+		return err
+	}
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully created promotion",
 		"promotion_id", promotion.ID.String(),
@@ -202,6 +265,171 @@ func (r *PromotionPostgresRepository) Create(ctx context.Context, promotion *ent
 	return nil
 }
 
+// Update replaces the terms and status of a promotion and records the new terms as the next
+// version. The update only succeeds while the stored version is still expectedVersion.
+func (r *PromotionPostgresRepository) Update(ctx context.Context, promotion *entity.Promotion, expectedVersion int) error {
+	logger := middleware.Logger.With(
+		"method", "PromotionRepository.Update",
+		"promotion_id", promotion.ID.String(),
+		"expected_version", expectedVersion,
+	)
+	logger.Debug("Updating promotion")
+	startTime := time.Now()
+
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
+	query := `
+		UPDATE promotions
+		SET type = $1, description = $2, rule = $3, active = $4, starts_at = $5, ends_at = $6,
+		    max_redemptions = $7, priority = $8, exclusive = $9, stackable_with = $10, eligibility = $11,
+		    version = version + 1, updated_at = NOW()
+		WHERE id = $12 AND version = $13 AND deleted_at IS NULL
+		RETURNING version, updated_at
+	`
+
+	err := queryable.QueryRowContext(ctx, query,
+		promotion.Type,
+		promotion.Description,
+		promotion.Rule,
+		promotion.Active,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.MaxRedemptions,
+		promotion.Priority,
+		promotion.Exclusive,
+		promotion.StackableWith,
+		promotion.Eligibility,
+		promotion.ID,
+		expectedVersion,
+	).Scan(
+		&promotion.Version,
+		&promotion.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Tell a deleted promotion apart from one that was updated concurrently
+			var exists bool
+			existsQuery := `SELECT EXISTS (SELECT 1 FROM promotions WHERE id = $1 AND deleted_at IS NULL)`
+			if err := queryable.QueryRowContext(ctx, existsQuery, promotion.ID).Scan(&exists); err != nil {
+				logger.Error("Failed to check promotion", "error", err.Error())
+				return fmt.Errorf("error checking promotion: %w", err)
+			}
+			if !exists {
+				logger.Warn("Promotion not found", "error", "ErrPromotionNotFound")
+				return domainErrors.ErrPromotionNotFound
+			}
+			logger.Warn("Promotion version changed", "error", "ErrPromotionVersionConflict")
+			return domainErrors.ErrPromotionVersionConflict
+		}
+		logger.Error("Failed to update promotion", "error", err.Error())
+		return fmt.Errorf("error updating promotion: %w", err)
+	}
+
+	if err := insertVersion(ctx, queryable, promotion.Snapshot()); err != nil {
+		logger.Error("Failed to record promotion version", "error", err.Error())
+		return err
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully updated promotion",
+		"version", promotion.Version,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// ListVersions retrieves every version of a promotion, newest first
+func (r *PromotionPostgresRepository) ListVersions(ctx context.Context, promotionID uuid.UUID) ([]*entity.PromotionVersion, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionRepository.ListVersions",
+		"promotion_id", promotionID.String(),
+	)
+	logger.Debug("Listing promotion versions")
+	startTime := time.Now()
+
+	query := `
+		SELECT promotion_id, version, type, description, rule, starts_at, ends_at, max_redemptions,
+		       priority, exclusive, stackable_with, eligibility, created_at
+		FROM promotion_versions
+		WHERE promotion_id = $1
+		ORDER BY version DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, promotionID)
+	if err != nil {
+		logger.Error("Failed to query promotion versions", "error", err.Error())
+		return nil, fmt.Errorf("error querying promotion versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*entity.PromotionVersion{}
+	for rows.Next() {
+		var version entity.PromotionVersion
+		err := rows.Scan(
+			&version.PromotionID,
+			&version.Version,
+			&version.Type,
+			&version.Description,
+			&version.Rule,
+			&version.StartsAt,
+			&version.EndsAt,
+			&version.MaxRedemptions,
+			&version.Priority,
+			&version.Exclusive,
+			&version.StackableWith,
+			&version.Eligibility,
+			&version.CreatedAt,
+		)
+		if err != nil {
+			logger.Error("Failed to scan promotion version row", "error", err.Error())
+			return nil, fmt.Errorf("error scanning promotion version row: %w", err)
+		}
+		versions = append(versions, &version)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate promotion version rows", "error", err.Error())
+		return nil, fmt.Errorf("error iterating promotion version rows: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully listed promotion versions",
+		"count", len(versions),
+		"duration_ms", duration.Milliseconds())
+
+	return versions, nil
+}
+
+// insertVersion records a promotion version in the caller's transaction that changed the promotion
+func insertVersion(ctx context.Context, tx persistence.Queryable, version *entity.PromotionVersion) error {
+	query := `
+		INSERT INTO promotion_versions (promotion_id, version, type, description, rule, starts_at, ends_at,
+		                                max_redemptions, priority, exclusive, stackable_with, eligibility, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		version.PromotionID,
+		version.Version,
+		version.Type,
+		version.Description,
+		version.Rule,
+		version.StartsAt,
+		version.EndsAt,
+		version.MaxRedemptions,
+		version.Priority,
+		version.Exclusive,
+		version.StackableWith,
+		version.Eligibility,
+		version.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting promotion version: %w", err)
+	}
+	return nil
+}
+
 // UpdateStatus updates a promotion's active status
 func (r *PromotionPostgresRepository) UpdateStatus(ctx context.Context, id uuid.UUID, active bool) error {
 	logger := middleware.Logger.With(
@@ -281,7 +509,8 @@ func (r *PromotionPostgresRepository) GetByType(ctx context.Context, promotionTy
 	startTime := time.Now()
 
 	query := `
-		SELECT id, type, description, active, created_at, updated_at
+		SELECT id, type, description, active, starts_at, ends_at, max_redemptions, redemption_count,
+		       priority, exclusive, stackable_with, eligibility, version, created_at, updated_at
 		FROM promotions
 		WHERE type = $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC
@@ -302,6 +531,15 @@ func (r *PromotionPostgresRepository) GetByType(ctx context.Context, promotionTy
 			&promotion.Type,
 			&promotion.Description,
 			&promotion.Active,
+			&promotion.StartsAt,
+			&promotion.EndsAt,
+			&promotion.MaxRedemptions,
+			&promotion.RedemptionCount,
+			&promotion.Priority,
+			&promotion.Exclusive,
+			&promotion.StackableWith,
+			&promotion.Eligibility,
+			&promotion.Version,
 			&promotion.CreatedAt,
 			&promotion.UpdatedAt,
 		)
@@ -325,13 +563,18 @@ func (r *PromotionPostgresRepository) GetByType(ctx context.Context, promotionTy
 	return promotions, nil
 }
 
-// GetActive retrieves all active promotions
+// GetActive retrieves all active promotions whose validity window includes the current time.
+// Promotions unlocked by a coupon are excluded because they only apply to carts carrying a code.
 func (r *PromotionPostgresRepository) GetActive(ctx context.Context) ([]*entity.Promotion, error) {
 	query := `
-		SELECT id, type, description, rule, active, created_at, updated_at
+		SELECT id, type, description, rule, active, starts_at, ends_at, max_redemptions, redemption_count,
+		       priority, exclusive, stackable_with, eligibility, version, created_at, updated_at
 		FROM promotions
 		WHERE active = true AND deleted_at IS NULL
-		ORDER BY created_at DESC
+		  AND (starts_at IS NULL OR starts_at <= NOW())
+		  AND (ends_at IS NULL OR ends_at > NOW())
+		  AND NOT EXISTS (SELECT 1 FROM coupons c WHERE c.promotion_id = promotions.id)
+		ORDER BY priority DESC, created_at DESC
 	`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -349,6 +592,15 @@ func (r *PromotionPostgresRepository) GetActive(ctx context.Context) ([]*entity.
 			&promotion.Description,
 			&promotion.Rule,
 			&promotion.Active,
+			&promotion.StartsAt,
+			&promotion.EndsAt,
+			&promotion.MaxRedemptions,
+			&promotion.RedemptionCount,
+			&promotion.Priority,
+			&promotion.Exclusive,
+			&promotion.StackableWith,
+			&promotion.Eligibility,
+			&promotion.Version,
 			&promotion.CreatedAt,
 			&promotion.UpdatedAt,
 		)
@@ -364,3 +616,521 @@ func (r *PromotionPostgresRepository) GetActive(ctx context.Context) ([]*entity.
 
 	return promotions, nil
 }
+
+// GetOutsideWindow retrieves active promotions that have not started yet or that expired after
+// endedAfter. Promotions that ended earlier are no longer news and are left out, so the result
+// does not grow with every promotion that was never switched off.
+func (r *PromotionPostgresRepository) GetOutsideWindow(ctx context.Context, endedAfter time.Time) ([]*entity.Promotion, error) {
+	query := `
+		SELECT id, type, description, rule, active, starts_at, ends_at, max_redemptions, redemption_count,
+		       priority, exclusive, stackable_with, eligibility, version, created_at, updated_at
+		FROM promotions
+		WHERE active = true AND deleted_at IS NULL
+		  AND (starts_at > NOW() OR (ends_at <= NOW() AND ends_at > $1))
+		  AND NOT EXISTS (SELECT 1 FROM coupons c WHERE c.promotion_id = promotions.id)
+		ORDER BY COALESCE(starts_at, ends_at)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, endedAfter)
+	if err != nil {
+		return nil, fmt.Errorf("error querying promotions outside their window: %w", err)
+	}
+	defer rows.Close()
+
+	promotions := []*entity.Promotion{}
+	for rows.Next() {
+		var promotion entity.Promotion
+		err := rows.Scan(
+			&promotion.ID,
+			&promotion.Type,
+			&promotion.Description,
+			&promotion.Rule,
+			&promotion.Active,
+			&promotion.StartsAt,
+			&promotion.EndsAt,
+			&promotion.MaxRedemptions,
+			&promotion.RedemptionCount,
+			&promotion.Priority,
+			&promotion.Exclusive,
+			&promotion.StackableWith,
+			&promotion.Eligibility,
+			&promotion.Version,
+			&promotion.CreatedAt,
+			&promotion.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning promotion row: %w", err)
+		}
+		promotions = append(promotions, &promotion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating promotion rows: %w", err)
+	}
+
+	return promotions, nil
+}
+
+// paidOrderCondition selects the checkouts that count as sales in promotion reports
+const paidOrderCondition = `c.payment_status = 'PAID' AND c.status <> 'CANCELLED'`
+
+// GetPerformance aggregates the orders placed between from (inclusive) and to (exclusive) per
+// applied promotion. Redemptions count every order the promotion discounted; the discount, order
+// count and revenue only count paid orders.
+func (r *PromotionPostgresRepository) GetPerformance(ctx context.Context, from, to time.Time, promotionID *uuid.UUID) ([]entity.PromotionPerformance, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionRepository.GetPerformance",
+		"from", from,
+		"to", to,
+	)
+	if promotionID != nil {
+		logger = logger.With("promotion_id", promotionID.String())
+	}
+	logger.Debug("Aggregating promotion performance")
+	startTime := time.Now()
+
+	whereClause := "WHERE pa.status = 'APPLIED' AND c.created_at >= $1 AND c.created_at < $2"
+	args := []interface{}{from, to}
+	if promotionID != nil {
+		whereClause += " AND pa.promotion_id = $3"
+		args = append(args, *promotionID)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT p.id, p.type, p.description,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE %[1]s),
+		       COALESCE(SUM(pa.discount) FILTER (WHERE %[1]s), 0),
+		       COALESCE(SUM(c.total) FILTER (WHERE %[1]s), 0)
+		FROM promotion_applied pa
+		JOIN checkouts c ON c.id = pa.checkout_id
+		JOIN promotions p ON p.id = pa.promotion_id
+		%[2]s
+		GROUP BY p.id, p.type, p.description
+		ORDER BY 6 DESC, p.description
+	`, paidOrderCondition, whereClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Error("Failed to query promotion performance", "error", err.Error())
+		return nil, fmt.Errorf("error querying promotion performance: %w", err)
+	}
+	defer rows.Close()
+
+	performances := []entity.PromotionPerformance{}
+	for rows.Next() {
+		var performance entity.PromotionPerformance
+		var orderCount int
+		var revenue money.Money
+		err := rows.Scan(
+			&performance.PromotionID,
+			&performance.Type,
+			&performance.Description,
+			&performance.RedemptionCount,
+			&orderCount,
+			&performance.TotalDiscount,
+			&revenue,
+		)
+		if err != nil {
+			logger.Error("Failed to scan promotion performance row", "error", err.Error())
+			return nil, fmt.Errorf("error scanning promotion performance row: %w", err)
+		}
+		performance.OrderStats = entity.NewOrderStats(orderCount, revenue)
+		performances = append(performances, performance)
+	}
+
+	if err = rows.Err(); err != nil {
+		logger.Error("Failed to iterate promotion performance rows", "error", err.Error())
+		return nil, fmt.Errorf("error iterating promotion performance rows: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully aggregated promotion performance",
+		"count", len(performances),
+		"duration_ms", duration.Milliseconds())
+
+	return performances, nil
+}
+
+// GetNonPromotedOrderStats aggregates the paid orders placed between from (inclusive) and to
+// (exclusive) that no promotion discounted
+func (r *PromotionPostgresRepository) GetNonPromotedOrderStats(ctx context.Context, from, to time.Time) (entity.OrderStats, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionRepository.GetNonPromotedOrderStats",
+		"from", from,
+		"to", to,
+	)
+	logger.Debug("Aggregating non-promoted orders")
+	startTime := time.Now()
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*), COALESCE(SUM(c.total), 0)
+		FROM checkouts c
+		WHERE %s AND c.created_at >= $1 AND c.created_at < $2
+		  AND NOT EXISTS (
+		      SELECT 1 FROM promotion_applied pa WHERE pa.checkout_id = c.id AND pa.status = 'APPLIED'
+		  )
+	`, paidOrderCondition)
+
+	var orderCount int
+	var revenue money.Money
+	if err := r.db.QueryRowContext(ctx, query, from, to).Scan(&orderCount, &revenue); err != nil {
+		logger.Error("Failed to query non-promoted orders", "error", err.Error())
+		return entity.OrderStats{}, fmt.Errorf("error querying non-promoted orders: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully aggregated non-promoted orders",
+		"order_count", orderCount,
+		"duration_ms", duration.Milliseconds())
+
+	return entity.NewOrderStats(orderCount, revenue), nil
+}
+
+// CouponPostgresRepository implements CouponRepository using PostgreSQL
+type CouponPostgresRepository struct {
+	db *sql.DB
+}
+
+// NewCouponRepository creates a new coupon repository
+func NewCouponRepository(db *sql.DB) CouponRepository {
+	return &CouponPostgresRepository{
+		db: db,
+	}
+}
+
+// Create creates a new coupon
+func (r *CouponPostgresRepository) Create(ctx context.Context, coupon *entity.Coupon) error {
+	logger := middleware.Logger.With(
+		"method", "CouponRepository.Create",
+		"promotion_id", coupon.PromotionID.String(),
+		"code", coupon.Code,
+	)
+	logger.Debug("Creating new coupon")
+	startTime := time.Now()
+
+	// Check if code already exists
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM coupons WHERE code = $1", coupon.Code).Scan(&count)
+	if err != nil {
+		logger.Error("Failed to check coupon code existence", "error", err.Error())
+		return fmt.Errorf("error checking coupon code existence: %w", err)
+	}
+
+	if count > 0 {
+		logger.Warn("Coupon code already exists", "error", "ErrDuplicateCouponCode")
+		return domainErrors.ErrDuplicateCouponCode
+	}
+
+	query := `
+		INSERT INTO coupons (id, code, promotion_id, max_redemptions, max_redemptions_per_user, expires_at, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		coupon.ID,
+		coupon.Code,
+		coupon.PromotionID,
+		coupon.MaxRedemptions,
+		coupon.MaxRedemptionsPerUser,
+		coupon.ExpiresAt,
+		coupon.Active,
+		coupon.CreatedAt,
+		coupon.UpdatedAt,
+	)
+	if err != nil {
+		logger.Error("Failed to create coupon", "error", err.Error())
+		return fmt.Errorf("error creating coupon: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created coupon",
+		"coupon_id", coupon.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// GetByCode retrieves a coupon by its code
+func (r *CouponPostgresRepository) GetByCode(ctx context.Context, code string) (*entity.Coupon, error) {
+	return r.getOne(ctx, "CouponRepository.GetByCode", "code = $1", entity.NormalizeCouponCode(code))
+}
+
+// GetByID retrieves a coupon by its ID
+func (r *CouponPostgresRepository) GetByID(ctx context.Context, id uuid.UUID) (*entity.Coupon, error) {
+	return r.getOne(ctx, "CouponRepository.GetByID", "id = $1", id)
+}
+
+// getOne retrieves a single coupon matching the given condition
+func (r *CouponPostgresRepository) getOne(ctx context.Context, method, condition string, arg interface{}) (*entity.Coupon, error) {
+	logger := middleware.Logger.With(
+		"method", method,
+		"lookup", arg,
+	)
+	logger.Debug("Fetching coupon")
+	startTime := time.Now()
+
+	query := fmt.Sprintf(`
+		SELECT id, code, promotion_id, max_redemptions, max_redemptions_per_user, redemption_count,
+		       expires_at, active, created_at, updated_at
+		FROM coupons
+		WHERE %s
+	`, condition)
+
+	var coupon entity.Coupon
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, arg).Scan(
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.PromotionID,
+		&coupon.MaxRedemptions,
+		&coupon.MaxRedemptionsPerUser,
+		&coupon.RedemptionCount,
+		&coupon.ExpiresAt,
+		&coupon.Active,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Coupon not found", "error", "ErrCouponNotFound")
+			return nil, domainErrors.ErrCouponNotFound
+		}
+		logger.Error("Failed to query coupon", "error", err.Error())
+		return nil, fmt.Errorf("error querying coupon: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Successfully retrieved coupon",
+		"coupon_id", coupon.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return &coupon, nil
+}
+
+// CountUserRedemptions counts how many times a user has redeemed a coupon
+func (r *CouponPostgresRepository) CountUserRedemptions(ctx context.Context, couponID, userID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = $1 AND user_id = $2`
+
+	var count int
+	err := persistence.QueryableFromContext(ctx, r.db).QueryRowContext(ctx, query, couponID, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting coupon redemptions: %w", err)
+	}
+
+	return count, nil
+}
+
+// Redeem records the redemption of a coupon by a checkout. The counters are incremented with
+// conditional updates so concurrent checkouts cannot push a coupon or promotion past its limit.
+func (r *CouponPostgresRepository) Redeem(ctx context.Context, coupon *entity.Coupon, userID, checkoutID uuid.UUID) error {
+	logger := middleware.Logger.With(
+		"method", "CouponRepository.Redeem",
+		"coupon_id", coupon.ID.String(),
+		"user_id", userID.String(),
+		"checkout_id", checkoutID.String(),
+	)
+	logger.Debug("Redeeming coupon")
+	startTime := time.Now()
+
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
+	// Claim a use of the coupon; the row lock also serialises the per-user check below
+	couponQuery := `
+		UPDATE coupons
+		SET redemption_count = redemption_count + 1, updated_at = NOW()
+		WHERE id = $1 AND (max_redemptions IS NULL OR redemption_count < max_redemptions)
+		RETURNING redemption_count
+	`
+	if err := queryable.QueryRowContext(ctx, couponQuery, coupon.ID).Scan(&coupon.RedemptionCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Coupon usage limit reached", "error", "ErrCouponUsageLimitReached")
+			return domainErrors.ErrCouponUsageLimitReached
+		}
+		logger.Error("Failed to update coupon redemption count", "error", err.Error())
+		return fmt.Errorf("error updating coupon redemption count: %w", err)
+	}
+
+	if coupon.MaxRedemptionsPerUser != nil {
+		var userRedemptions int
+		countQuery := `SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = $1 AND user_id = $2`
+		if err := queryable.QueryRowContext(ctx, countQuery, coupon.ID, userID).Scan(&userRedemptions); err != nil {
+			logger.Error("Failed to count user redemptions", "error", err.Error())
+			return fmt.Errorf("error counting coupon redemptions: %w", err)
+		}
+		if userRedemptions >= *coupon.MaxRedemptionsPerUser {
+			logger.Warn("Coupon per-user limit reached", "error", "ErrCouponUserLimitReached")
+			return domainErrors.ErrCouponUserLimitReached
+		}
+	}
+
+	promotionQuery := `
+		UPDATE promotions
+		SET redemption_count = redemption_count + 1, updated_at = NOW()
+		WHERE id = $1 AND (max_redemptions IS NULL OR redemption_count < max_redemptions)
+		RETURNING id
+	`
+	var promotionID uuid.UUID
+	if err := queryable.QueryRowContext(ctx, promotionQuery, coupon.PromotionID).Scan(&promotionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Promotion redemption cap reached", "error", "ErrPromotionCapReached")
+			return domainErrors.ErrPromotionCapReached
+		}
+		logger.Error("Failed to update promotion redemption count", "error", err.Error())
+		return fmt.Errorf("error updating promotion redemption count: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO coupon_redemptions (id, coupon_id, promotion_id, user_id, checkout_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+	`
+	_, err := queryable.ExecContext(ctx, insertQuery, uuid.New(), coupon.ID, coupon.PromotionID, userID, checkoutID)
+	if err != nil {
+		logger.Error("Failed to record coupon redemption", "error", err.Error())
+		return fmt.Errorf("error recording coupon redemption: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully redeemed coupon",
+		"redemption_count", coupon.RedemptionCount,
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// CustomerPostgresRepository implements CustomerRepository using PostgreSQL
+type CustomerPostgresRepository struct {
+	db *sql.DB
+}
+
+// NewCustomerRepository creates a new customer repository
+func NewCustomerRepository(db *sql.DB) CustomerRepository {
+	return &CustomerPostgresRepository{
+		db: db,
+	}
+}
+
+// GetCustomer loads the role, segments and order history of a user. Orders that were cancelled
+// or whose payment failed or was refunded do not count as previous orders, and only paid orders
+// add to the lifetime spend.
+func (r *CustomerPostgresRepository) GetCustomer(ctx context.Context, userID uuid.UUID) (*entity.Customer, error) {
+	logger := middleware.Logger.With(
+		"method", "CustomerRepository.GetCustomer",
+		"user_id", userID.String(),
+	)
+	logger.Debug("Fetching customer")
+	startTime := time.Now()
+
+	query := `
+		SELECT u.role,
+		       COALESCE((SELECT array_agg(s.segment ORDER BY s.segment) FROM user_segments s WHERE s.user_id = u.id), '{}'),
+		       (SELECT COUNT(*) FROM checkouts c
+		        WHERE c.user_id = u.id AND c.status <> 'CANCELLED' AND c.payment_status NOT IN ('FAILED', 'REFUNDED')),
+		       (SELECT COALESCE(SUM(c.total), 0) FROM checkouts c
+		        WHERE c.user_id = u.id AND c.status <> 'CANCELLED' AND c.payment_status = 'PAID')
+		FROM users u
+		WHERE u.id = $1 AND u.deleted_at IS NULL
+	`
+
+	queryable := persistence.QueryableFromContext(ctx, r.db)
+
+	// Inside a transaction the user's row is locked first, so concurrent checkouts of one user take
+	// turns. The order history is read by a later statement and sees the orders committed while
+	// this one waited, which keeps first-order promotions from applying twice.
+	if persistence.TxFromContext(ctx) != nil {
+		_, err := queryable.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID)
+		if err != nil {
+			logger.Error("Failed to lock customer", "error", err.Error())
+			return nil, fmt.Errorf("error locking customer: %w", err)
+		}
+	}
+
+	customer := entity.Customer{UserID: userID}
+	var segments pq.StringArray
+	err := queryable.QueryRowContext(ctx, query, userID).Scan(
+		&customer.Role,
+		&segments,
+		&customer.OrderCount,
+		&customer.LifetimeSpend,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("Customer not found", "error", "ErrCustomerNotFound")
+			return nil, domainErrors.ErrCustomerNotFound
+		}
+		logger.Error("Failed to query customer", "error", err.Error())
+		return nil, fmt.Errorf("error querying customer: %w", err)
+	}
+	customer.Segments = segments
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully retrieved customer",
+		"role", customer.Role,
+		"segment_count", len(customer.Segments),
+		"order_count", customer.OrderCount,
+		"duration_ms", duration.Milliseconds())
+
+	return &customer, nil
+}
+
+// AddToSegment adds a user to a named segment. Adding a user that is already in the segment
+// has no effect.
+func (r *CustomerPostgresRepository) AddToSegment(ctx context.Context, userID uuid.UUID, segment string) error {
+	logger := middleware.Logger.With(
+		"method", "CustomerRepository.AddToSegment",
+		"user_id", userID.String(),
+		"segment", segment,
+	)
+	logger.Debug("Adding user to segment")
+	startTime := time.Now()
+
+	var exists bool
+	existsQuery := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)`
+	if err := r.db.QueryRowContext(ctx, existsQuery, userID).Scan(&exists); err != nil {
+		logger.Error("Failed to check user", "error", err.Error())
+		return fmt.Errorf("error checking user: %w", err)
+	}
+	if !exists {
+		logger.Warn("Customer not found", "error", "ErrCustomerNotFound")
+		return domainErrors.ErrCustomerNotFound
+	}
+
+	query := `
+		INSERT INTO user_segments (user_id, segment, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id, segment) DO NOTHING
+	`
+	if _, err := r.db.ExecContext(ctx, query, userID, segment); err != nil {
+		logger.Error("Failed to add user to segment", "error", err.Error())
+		return fmt.Errorf("error adding user to segment: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully added user to segment",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// RemoveFromSegment removes a user from a named segment. Removing a user that is not in the
+// segment has no effect.
+func (r *CustomerPostgresRepository) RemoveFromSegment(ctx context.Context, userID uuid.UUID, segment string) error {
+	logger := middleware.Logger.With(
+		"method", "CustomerRepository.RemoveFromSegment",
+		"user_id", userID.String(),
+		"segment", segment,
+	)
+	logger.Debug("Removing user from segment")
+	startTime := time.Now()
+
+	query := `DELETE FROM user_segments WHERE user_id = $1 AND segment = $2`
+	if _, err := r.db.ExecContext(ctx, query, userID, segment); err != nil {
+		logger.Error("Failed to remove user from segment", "error", err.Error())
+		return fmt.Errorf("error removing user from segment: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully removed user from segment",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
@@ -5,27 +5,66 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	cartEntity "github.com/fanzru/e-commerce-be/internal/app/cart/domain/entity"
+	cartRepo "github.com/fanzru/e-commerce-be/internal/app/cart/repo"
+	productEntity "github.com/fanzru/e-commerce-be/internal/app/product/domain/entity"
+	productRepo "github.com/fanzru/e-commerce-be/internal/app/product/repo"
 	promotionEntity "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
+	promotionErrors "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/app/promotion/repo"
+	userEntity "github.com/fanzru/e-commerce-be/internal/app/user/domain/entity"
+	commonErrs "github.com/fanzru/e-commerce-be/internal/common/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/persistence"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
 // Ensure promotionUseCase implements PromotionUseCase
 var _ PromotionUseCase = (*promotionUseCase)(nil)
 
+// maxSegmentNameLength is the length of the user_segments.segment column
+const maxSegmentNameLength = 100
+
+// defaultReportPeriod is how far back a report goes when no start is given
+const defaultReportPeriod = 30 * 24 * time.Hour
+
+// expiredNoticePeriod is how long after its end a promotion is still listed in a cart's notices
+const expiredNoticePeriod = 7 * 24 * time.Hour
+
 // promotionUseCase implements the PromotionUseCase interface
 type promotionUseCase struct {
-	repo repo.PromotionRepository
+	repo         repo.PromotionRepository
+	couponRepo   repo.CouponRepository
+	customerRepo repo.CustomerRepository
+	productRepo  productRepo.ProductRepository
+	cartRepo     cartRepo.CartRepository
+	txManager    *persistence.TransactionManager
+	engine       *promotionEntity.Engine
 }
 
 // NewPromotionUseCase creates a new instance of promotionUseCase
-func NewPromotionUseCase(repo repo.PromotionRepository) PromotionUseCase {
+func NewPromotionUseCase(
+	repo repo.PromotionRepository,
+	couponRepo repo.CouponRepository,
+	customerRepo repo.CustomerRepository,
+	productRepo productRepo.ProductRepository,
+	cartRepo cartRepo.CartRepository,
+	txManager *persistence.TransactionManager,
+) PromotionUseCase {
 	return &promotionUseCase{
-		repo: repo,
+		repo:         repo,
+		couponRepo:   couponRepo,
+		customerRepo: customerRepo,
+		productRepo:  productRepo,
+		cartRepo:     cartRepo,
+		txManager:    txManager,
+		engine:       promotionEntity.NewEngine(),
 	}
 }
 
@@ -58,12 +97,13 @@ func (u *promotionUseCase) GetByID(ctx context.Context, id uuid.UUID) (*promotio
 	return promotion, nil
 }
 
-// List retrieves a list of promotions with pagination and filtering
-func (u *promotionUseCase) List(ctx context.Context, page, limit int, active *bool) ([]*promotionEntity.Promotion, int, error) {
+// List retrieves a page of promotions, by page number or cursor
+func (u *promotionUseCase) List(ctx context.Context, req pagination.Request, active *bool) ([]*promotionEntity.Promotion, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "PromotionUseCase.List",
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	if active != nil {
 		logger = logger.With("active", *active)
@@ -71,74 +111,62 @@ func (u *promotionUseCase) List(ctx context.Context, page, limit int, active *bo
 	logger.Info("Listing promotions with filters")
 	startTime := time.Now()
 
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 {
-		limit = 10
-	}
-
-	promotions, total, err := u.repo.List(ctx, page, limit, active)
+	promotions, page, err := u.repo.List(ctx, req, active)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			logger.Warn("Invalid input: cursor", "error", err.Error())
+			return nil, pagination.Result{}, commonErrs.NewBadRequest("invalid cursor")
+		}
 		logger.Error("Failed to list promotions", "error", err.Error())
-		return nil, 0, fmt.Errorf("error listing promotions: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("error listing promotions: %w", err)
 	}
 
 	duration := time.Since(startTime)
 	logger.Info("Successfully listed promotions",
-		"total", total,
 		"returned", len(promotions),
 		"duration_ms", duration.Milliseconds())
 
-	return promotions, total, nil
+	return promotions, page, nil
 }
 
-// CreateBuyOneGetOneFree creates a new BuyOneGetOneFree promotion
-func (u *promotionUseCase) CreateBuyOneGetOneFree(
+// CreatePromotion creates a promotion of any registered type from its rule and options
+func (u *promotionUseCase) CreatePromotion(
 	ctx context.Context,
-	description string,
-	triggerSKU string,
-	freeSKU string,
-	triggerQuantity int,
-	freeQuantity int,
-	active bool,
+	promotionType promotionEntity.PromotionType,
+	rule promotionEntity.PromotionRule,
+	options PromotionOptions,
 ) (*promotionEntity.Promotion, error) {
 	logger := middleware.Logger.With(
-		"method", "PromotionUseCase.CreateBuyOneGetOneFree",
-		"trigger_sku", triggerSKU,
-		"free_sku", freeSKU,
-		"trigger_quantity", triggerQuantity,
-		"free_quantity", freeQuantity,
+		"method", "PromotionUseCase.CreatePromotion",
+		"promotion_type", promotionType,
 	)
-	logger.Info("Creating BuyOneGetOneFree promotion")
+	logger.Info("Creating promotion")
 	startTime := time.Now()
 
-	if description == "" {
+	if options.Description == "" {
 		logger.Warn("Invalid input: Empty description", "error", "ErrInvalidInput")
 		return nil, errors.New("description is required")
 	}
-	if triggerSKU == "" {
-		logger.Warn("Invalid input: Empty trigger SKU", "error", "ErrInvalidInput")
-		return nil, errors.New("trigger SKU is required")
+	if err := validateWindow(options.StartsAt, options.EndsAt); err != nil {
+		logger.Warn("Invalid input: Invalid validity window", "error", err.Error())
+		return nil, err
 	}
-	if freeSKU == "" {
-		logger.Warn("Invalid input: Empty free SKU", "error", "ErrInvalidInput")
-		return nil, errors.New("free SKU is required")
+	if options.MaxRedemptions != nil && *options.MaxRedemptions < 1 {
+		logger.Warn("Invalid input: Invalid max redemptions", "error", "ErrInvalidInput")
+		return nil, errors.New("max redemptions must be greater than zero")
 	}
-	if triggerQuantity < 1 {
-		logger.Warn("Invalid input: Invalid trigger quantity", "error", "ErrInvalidInput")
-		return nil, errors.New("trigger quantity must be greater than zero")
+	if err := u.validateStacking(ctx, options.StackingRules); err != nil {
+		logger.Warn("Invalid input: Invalid stacking rules", "error", err.Error())
+		return nil, err
 	}
-	if freeQuantity < 1 {
-		logger.Warn("Invalid input: Invalid free quantity", "error", "ErrInvalidInput")
-		return nil, errors.New("free quantity must be greater than zero")
+	if err := validateEligibility(options.Eligibility); err != nil {
+		logger.Warn("Invalid input: Invalid eligibility", "error", err.Error())
+		return nil, err
 	}
 
-	rule := promotionEntity.BuyOneGetOneFreePromotion{
-		TriggerSKU:      triggerSKU,
-		FreeSKU:         freeSKU,
-		TriggerQuantity: triggerQuantity,
-		FreeQuantity:    freeQuantity,
+	// Tiers are stored in ascending order of quantity
+	if tiered, ok := rule.(*promotionEntity.TieredDiscountPromotion); ok {
+		tiered.Tiers = sortTiers(tiered.Tiers)
 	}
 
 	ruleJSON, err := json.Marshal(rule)
@@ -149,175 +177,169 @@ func (u *promotionUseCase) CreateBuyOneGetOneFree(
 
 	promotion := &promotionEntity.Promotion{
 		ID:          uuid.New(),
-		Type:        promotionEntity.BuyOneGetOneFree,
-		Description: description,
+		Type:        promotionType,
+		Description: options.Description,
 		Rule:        ruleJSON,
-		Active:      active,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Active:      options.Active,
+		StartsAt:    options.StartsAt,
+		EndsAt:      options.EndsAt,
+
+		StackingRules:  options.StackingRules,
+		Eligibility:    options.Eligibility,
+		MaxRedemptions: options.MaxRedemptions,
+
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
 	}
 
-	err = u.repo.Create(ctx, promotion)
+	if err := u.validateRule(ctx, promotion); err != nil {
+		logger.Warn("Invalid input: Invalid promotion rule", "error", err.Error())
+		return nil, err
+	}
+
+	// The promotion and its first version are saved together
+	err = u.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		return u.repo.Create(txCtx, promotion)
+	})
 	if err != nil {
 		logger.Error("Failed to create promotion", "error", err.Error())
 		return nil, fmt.Errorf("failed to create promotion: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Info("Successfully created BuyOneGetOneFree promotion",
+	logger.Info("Successfully created promotion",
 		"promotion_id", promotion.ID.String(),
 		"duration_ms", duration.Milliseconds())
 
 	return promotion, nil
 }
 
-// CreateBuy3Pay2 creates a new Buy3Pay2 promotion
-func (u *promotionUseCase) CreateBuy3Pay2(
-	ctx context.Context,
-	description string,
-	sku string,
-	minQuantity int,
-	paidQuantityDivisor int,
-	freeQuantityDivisor int,
-	active bool,
-) (*promotionEntity.Promotion, error) {
+// UpdatePromotion replaces the terms of a promotion and records them as a new version
+func (u *promotionUseCase) UpdatePromotion(ctx context.Context, id uuid.UUID, update PromotionUpdate) (*promotionEntity.Promotion, error) {
 	logger := middleware.Logger.With(
-		"method", "PromotionUseCase.CreateBuy3Pay2",
-		"sku", sku,
-		"min_quantity", minQuantity,
-		"paid_quantity_divisor", paidQuantityDivisor,
-		"free_quantity_divisor", freeQuantityDivisor,
+		"method", "PromotionUseCase.UpdatePromotion",
+		"promotion_id", id.String(),
+		"promotion_type", update.Type,
 	)
-	logger.Info("Creating Buy3Pay2 promotion")
+	logger.Info("Updating promotion")
 	startTime := time.Now()
 
-	if description == "" {
+	if id == uuid.Nil {
+		logger.Warn("Invalid promotion ID", "error", "ErrInvalidInput")
+		return nil, errors.New("invalid promotion ID")
+	}
+	if update.Description == "" {
 		logger.Warn("Invalid input: Empty description", "error", "ErrInvalidInput")
-		return nil, errors.New("description is required")
+		return nil, commonErrs.NewBadRequest("description is required")
 	}
-	if sku == "" {
-		logger.Warn("Invalid input: Empty SKU", "error", "ErrInvalidInput")
-		return nil, errors.New("SKU is required")
+	if err := validateWindow(update.StartsAt, update.EndsAt); err != nil {
+		logger.Warn("Invalid input: Invalid validity window", "error", err.Error())
+		return nil, err
 	}
-	if minQuantity < 1 {
-		logger.Warn("Invalid input: Invalid minimum quantity", "error", "ErrInvalidInput")
-		return nil, errors.New("minimum quantity must be greater than zero")
+	if update.MaxRedemptions != nil && *update.MaxRedemptions < 1 {
+		logger.Warn("Invalid input: Invalid max redemptions", "error", "ErrInvalidInput")
+		return nil, commonErrs.NewBadRequest("max redemptions must be greater than zero")
 	}
-	if paidQuantityDivisor < 1 {
-		logger.Warn("Invalid input: Invalid paid quantity divisor", "error", "ErrInvalidInput")
-		return nil, errors.New("paid quantity divisor must be greater than zero")
+	if update.StackableWith.Contains(id) {
+		logger.Warn("Invalid input: Promotion stackable with itself", "error", "ErrInvalidInput")
+		return nil, commonErrs.NewBadRequest("a promotion cannot be stackable with itself")
 	}
-	if freeQuantityDivisor < 1 {
-		logger.Warn("Invalid input: Invalid free quantity divisor", "error", "ErrInvalidInput")
-		return nil, errors.New("free quantity divisor must be greater than zero")
+	if err := u.validateStacking(ctx, update.StackingRules); err != nil {
+		logger.Warn("Invalid input: Invalid stacking rules", "error", err.Error())
+		return nil, err
 	}
-
-	rule := promotionEntity.Buy3Pay2Promotion{
-		SKU:                 sku,
-		MinQuantity:         minQuantity,
-		PaidQuantityDivisor: paidQuantityDivisor,
-		FreeQuantityDivisor: freeQuantityDivisor,
+	if err := validateEligibility(update.Eligibility); err != nil {
+		logger.Warn("Invalid input: Invalid eligibility", "error", err.Error())
+		return nil, err
 	}
 
-	ruleJSON, err := json.Marshal(rule)
-	if err != nil {
-		logger.Error("Failed to marshal promotion rule", "error", err.Error())
-		return nil, fmt.Errorf("failed to marshal promotion rule: %w", err)
-	}
+	// The new terms and their version are saved together, against the version read here
+	var promotion promotionEntity.Promotion
+	err := u.txManager.RunInTransaction(ctx, func(txCtx context.Context) error {
+		current, err := u.repo.GetByID(txCtx, id)
+		if err != nil {
+			if errors.Is(err, promotionErrors.ErrPromotionNotFound) {
+				return promotionErrors.NewPromotionNotFoundError(id.String())
+			}
+			logger.Error("Failed to get promotion", "error", err.Error())
+			return fmt.Errorf("error getting promotion: %w", err)
+		}
 
-	promotion := &promotionEntity.Promotion{
-		ID:          uuid.New(),
-		Type:        promotionEntity.Buy3Pay2,
-		Description: description,
-		Rule:        ruleJSON,
-		Active:      active,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
+		expectedVersion := current.Version
+		if update.ExpectedVersion != nil {
+			expectedVersion = *update.ExpectedVersion
+		}
+
+		promotion = *current
+		promotion.Type = update.Type
+		promotion.Description = update.Description
+		promotion.Rule = update.Rule
+		promotion.Active = update.Active
+		promotion.StartsAt = update.StartsAt
+		promotion.EndsAt = update.EndsAt
+		promotion.MaxRedemptions = update.MaxRedemptions
+		promotion.StackingRules = update.StackingRules
+		promotion.Eligibility = update.Eligibility
 
-	err = u.repo.Create(ctx, promotion)
+		if err := u.validateRule(txCtx, &promotion); err != nil {
+			logger.Warn("Invalid input: Invalid promotion rule", "error", err.Error())
+			return err
+		}
+
+		err = u.repo.Update(txCtx, &promotion, expectedVersion)
+		if err != nil {
+			switch {
+			case errors.Is(err, promotionErrors.ErrPromotionNotFound):
+				return promotionErrors.NewPromotionNotFoundError(id.String())
+			case errors.Is(err, promotionErrors.ErrPromotionVersionConflict):
+				return promotionErrors.NewPromotionVersionConflictError(id.String(), expectedVersion)
+			}
+			logger.Error("Failed to update promotion", "error", err.Error())
+			return fmt.Errorf("error updating promotion: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		logger.Error("Failed to create promotion", "error", err.Error())
-		return nil, fmt.Errorf("failed to create promotion: %w", err)
+		return nil, err
 	}
 
 	duration := time.Since(startTime)
-	logger.Info("Successfully created Buy3Pay2 promotion",
-		"promotion_id", promotion.ID.String(),
+	logger.Info("Successfully updated promotion",
+		"version", promotion.Version,
 		"duration_ms", duration.Milliseconds())
 
-	return promotion, nil
+	return &promotion, nil
 }
 
-// CreateBulkDiscount creates a new BulkDiscount promotion
-func (u *promotionUseCase) CreateBulkDiscount(
-	ctx context.Context,
-	description string,
-	sku string,
-	minQuantity int,
-	discountPercentage float64,
-	active bool,
-) (*promotionEntity.Promotion, error) {
+// ListVersions returns every version of a promotion, newest first
+func (u *promotionUseCase) ListVersions(ctx context.Context, id uuid.UUID) ([]*promotionEntity.PromotionVersion, error) {
 	logger := middleware.Logger.With(
-		"method", "PromotionUseCase.CreateBulkDiscount",
-		"sku", sku,
-		"min_quantity", minQuantity,
-		"discount_percentage", discountPercentage,
+		"method", "PromotionUseCase.ListVersions",
+		"promotion_id", id.String(),
 	)
-	logger.Info("Creating BulkDiscount promotion")
+	logger.Info("Listing promotion versions")
 	startTime := time.Now()
 
-	if description == "" {
-		logger.Warn("Invalid input: Empty description", "error", "ErrInvalidInput")
-		return nil, errors.New("description is required")
-	}
-	if sku == "" {
-		logger.Warn("Invalid input: Empty SKU", "error", "ErrInvalidInput")
-		return nil, errors.New("SKU is required")
-	}
-	if minQuantity < 1 {
-		logger.Warn("Invalid input: Invalid minimum quantity", "error", "ErrInvalidInput")
-		return nil, errors.New("minimum quantity must be greater than zero")
-	}
-	if discountPercentage <= 0 || discountPercentage > 100 {
-		logger.Warn("Invalid input: Invalid discount percentage", "error", "ErrInvalidInput")
-		return nil, errors.New("discount percentage must be between 0 and 100")
-	}
-
-	rule := promotionEntity.BulkDiscountPromotion{
-		SKU:                sku,
-		MinQuantity:        minQuantity,
-		DiscountPercentage: discountPercentage,
-	}
-
-	ruleJSON, err := json.Marshal(rule)
-	if err != nil {
-		logger.Error("Failed to marshal promotion rule", "error", err.Error())
-		return nil, fmt.Errorf("failed to marshal promotion rule: %w", err)
-	}
-
-	promotion := &promotionEntity.Promotion{
-		ID:          uuid.New(),
-		Type:        promotionEntity.BulkDiscount,
-		Description: description,
-		Rule:        ruleJSON,
-		Active:      active,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	if _, err := u.repo.GetByID(ctx, id); err != nil {
+		if errors.Is(err, promotionErrors.ErrPromotionNotFound) {
+			return nil, promotionErrors.NewPromotionNotFoundError(id.String())
+		}
+		logger.Error("Failed to get promotion", "error", err.Error())
+		return nil, fmt.Errorf("error getting promotion: %w", err)
 	}
 
-	err = u.repo.Create(ctx, promotion)
+	versions, err := u.repo.ListVersions(ctx, id)
 	if err != nil {
-		logger.Error("Failed to create promotion", "error", err.Error())
-		return nil, fmt.Errorf("failed to create promotion: %w", err)
+		logger.Error("Failed to list promotion versions", "error", err.Error())
+		return nil, fmt.Errorf("error listing promotion versions: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Info("Successfully created BulkDiscount promotion",
-		"promotion_id", promotion.ID.String(),
+	logger.Info("Successfully listed promotion versions",
+		"count", len(versions),
 		"duration_ms", duration.Milliseconds())
 
-	return promotion, nil
+	return versions, nil
 }
 
 // UpdateStatus updates a promotion's active status
@@ -335,6 +357,19 @@ func (u *promotionUseCase) UpdateStatus(ctx context.Context, id uuid.UUID, activ
 		return errors.New("invalid promotion ID")
 	}
 
+	// An expired promotion would never apply again, so switching it back on is refused
+	if active {
+		promotion, err := u.repo.GetByID(ctx, id)
+		if err != nil {
+			logger.Error("Failed to get promotion", "error", err.Error())
+			return fmt.Errorf("error getting promotion: %w", err)
+		}
+		if promotion.HasExpired(time.Now()) {
+			logger.Warn("Cannot activate expired promotion", "error", "ErrPromotionExpired", "ends_at", promotion.EndsAt)
+			return commonErrs.ErrPromotionExpired
+		}
+	}
+
 	err := u.repo.UpdateStatus(ctx, id, active)
 	if err != nil {
 		logger.Error("Failed to update promotion status", "error", err.Error())
@@ -376,9 +411,19 @@ func (u *promotionUseCase) Delete(ctx context.Context, id uuid.UUID) error {
 }
 
 // ApplyPromotions applies promotions to a cart and returns the discounts
-func (u *promotionUseCase) ApplyPromotions(ctx context.Context, cart *cartEntity.CartInfo) ([]PromotionDiscount, float64, error) {
+func (u *promotionUseCase) ApplyPromotions(ctx context.Context, cart *cartEntity.CartInfo) ([]PromotionDiscount, money.Money, error) {
+	evaluation, err := u.EvaluatePromotions(ctx, cart)
+	if err != nil {
+		return nil, money.Zero(), err
+	}
+	return evaluation.Discounts, evaluation.TotalDiscount, nil
+}
+
+// EvaluatePromotions applies promotions to a cart and returns the applied discounts together with
+// the promotions that were skipped and why
+func (u *promotionUseCase) EvaluatePromotions(ctx context.Context, cart *cartEntity.CartInfo) (*PromotionEvaluation, error) {
 	logger := middleware.Logger.With(
-		"method", "PromotionUseCase.ApplyPromotions",
+		"method", "PromotionUseCase.EvaluatePromotions",
 		"user_id", cart.UserID.String(),
 	)
 	logger.Info("Applying promotions to cart")
@@ -386,47 +431,712 @@ func (u *promotionUseCase) ApplyPromotions(ctx context.Context, cart *cartEntity
 
 	if cart == nil || len(cart.Items) == 0 {
 		logger.Info("Cart is empty, no promotions applied")
-		return nil, 0, nil
+		return &PromotionEvaluation{TotalDiscount: money.Zero()}, nil
 	}
 
 	// Get all active promotions
 	promotions, err := u.repo.GetActive(ctx)
 	if err != nil {
 		logger.Error("Failed to get active promotions", "error", err.Error())
-		return nil, 0, fmt.Errorf("failed to get active promotions: %w", err)
+		return nil, fmt.Errorf("failed to get active promotions: %w", err)
+	}
+
+	// A coupon unlocks its promotion for this cart only; an invalid code is skipped so the cart
+	// still shows the automatic promotions
+	if cart.CouponCode != "" {
+		coupon, err := u.ValidateCoupon(ctx, cart.CouponCode, cart.UserID)
+		if err != nil {
+			logger.Warn("Skipping coupon that cannot be redeemed",
+				"coupon_code", cart.CouponCode,
+				"error", err.Error())
+		} else {
+			promotion, err := u.repo.GetByID(ctx, coupon.PromotionID)
+			if err != nil {
+				logger.Error("Failed to get coupon promotion", "error", err.Error())
+				return nil, fmt.Errorf("failed to get coupon promotion: %w", err)
+			}
+			promotions = append(promotions, promotion)
+		}
 	}
 
 	if len(promotions) == 0 {
 		logger.Info("No active promotions found")
-		return nil, 0, nil
+		return &PromotionEvaluation{TotalDiscount: money.Zero()}, nil
+	}
+
+	customer, err := u.getCustomer(ctx, cart.UserID)
+	if err != nil {
+		logger.Error("Failed to get customer", "error", err.Error())
+		return nil, err
 	}
 
 	// Convert cart items to promotion cart items
 	promotionItems := promotionEntity.ConvertCartToPromotionItems(cart.Items)
 
-	// Get applicable promotions
-	applicablePromotions := promotionEntity.GetApplicablePromotions(promotions, promotionItems)
+	// Run the shared promotion engine, the same one used at checkout
+	result := u.engine.Evaluate(promotions, promotionItems, customer)
+	for _, invalid := range result.Invalid {
+		logger.Error("Promotion with an invalid rule was not applied",
+			"promotion_id", invalid.ID.String(),
+			"promotion_type", invalid.Type,
+			"error", invalid.Error)
+	}
+	if result.Approximate {
+		logger.Warn("Promotion search was cut short; the best combination found so far was applied",
+			"active_promotions", len(promotions))
+	}
+
+	evaluation := newPromotionEvaluation(result)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully processed promotions for cart",
+		"applicable_promotions", len(evaluation.Discounts),
+		"skipped_promotions", len(evaluation.Skipped),
+		"active_promotions", len(promotions),
+		"total_discount", result.TotalDiscount.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return evaluation, nil
+}
+
+// PreviewPromotion runs a draft promotion through the promotion engine against a basket or a user's
+// cart. Nothing is saved: the draft gets a throwaway ID and no coupon is redeemed.
+func (u *promotionUseCase) PreviewPromotion(
+	ctx context.Context,
+	draft PromotionDraft,
+	basket []PreviewItem,
+	userID *uuid.UUID,
+	includeActive bool,
+) (*PromotionPreview, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.PreviewPromotion",
+		"promotion_type", draft.Type,
+		"basket_size", len(basket),
+		"include_active", includeActive,
+	)
+	logger.Info("Previewing draft promotion")
+	startTime := time.Now()
+
+	if _, ok := promotionEntity.LookupRule(draft.Type); !ok {
+		logger.Warn("Invalid input: Unknown promotion type", "error", "ErrInvalidPromotionType")
+		return nil, promotionErrors.NewInvalidPromotionTypeError(string(draft.Type))
+	}
+
+	description := draft.Description
+	if description == "" {
+		description = "Draft promotion"
+	}
+
+	if err := validateEligibility(draft.Eligibility); err != nil {
+		logger.Warn("Invalid input: Invalid eligibility", "error", err.Error())
+		return nil, err
+	}
+
+	promotion := &promotionEntity.Promotion{
+		ID:            uuid.New(),
+		Type:          draft.Type,
+		Description:   description,
+		Rule:          draft.Rule,
+		Active:        true,
+		StackingRules: draft.StackingRules,
+		Eligibility:   draft.Eligibility,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if err := u.validateRule(ctx, promotion); err != nil {
+		logger.Warn("Invalid input: Invalid promotion rule", "error", err.Error())
+		return nil, err
+	}
+
+	var items []promotionEntity.CartItem
+	var customer *promotionEntity.Customer
+	switch {
+	case userID != nil && len(basket) > 0:
+		logger.Warn("Invalid input: Both basket and user given", "error", "ErrInvalidInput")
+		return nil, commonErrs.NewBadRequest("give either items or user_id, not both")
+	case userID != nil:
+		cart, err := u.cartRepo.GetCartInfo(ctx, *userID)
+		if err != nil {
+			logger.Error("Failed to get cart", "error", err.Error(), "user_id", userID.String())
+			return nil, fmt.Errorf("error getting cart: %w", err)
+		}
+		items = promotionEntity.ConvertCartToPromotionItems(cart.Items)
+		customer, err = u.getCustomer(ctx, *userID)
+		if err != nil {
+			logger.Error("Failed to get customer", "error", err.Error(), "user_id", userID.String())
+			return nil, err
+		}
+	case len(basket) > 0:
+		var err error
+		items, err = u.basketItems(ctx, basket)
+		if err != nil {
+			logger.Warn("Invalid input: Invalid basket", "error", err.Error())
+			return nil, err
+		}
+		// A basket belongs to nobody, so only the draft's rule is previewed
+		promotion.Eligibility = promotionEntity.Eligibility{}
+	default:
+		logger.Warn("Invalid input: No basket or user given", "error", "ErrInvalidInput")
+		return nil, commonErrs.NewBadRequest("give items or user_id to preview the promotion against")
+	}
+
+	promotions := []*promotionEntity.Promotion{}
+	if includeActive {
+		active, err := u.repo.GetActive(ctx)
+		if err != nil {
+			logger.Error("Failed to get active promotions", "error", err.Error())
+			return nil, fmt.Errorf("failed to get active promotions: %w", err)
+		}
+		promotions = append(promotions, active...)
+	}
+	promotions = append(promotions, promotion)
+
+	// The same engine the cart and checkout use
+	result := u.engine.Evaluate(promotions, items, customer)
+	if result.Approximate {
+		logger.Warn("Promotion search was cut short; the best combination found so far was applied",
+			"active_promotions", len(promotions))
+	}
+
+	preview := &PromotionPreview{
+		DraftPromotionID:    promotion.ID,
+		Lines:               make([]PreviewLine, 0, len(items)),
+		PromotionEvaluation: *newPromotionEvaluation(result),
+		Subtotal:            money.Zero(),
+	}
+	for _, item := range items {
+		subtotal := item.UnitPrice.Mul(item.Quantity)
+		discount := result.LineDiscounts[item.ProductSKU]
+		preview.Lines = append(preview.Lines, PreviewLine{
+			ProductID:   item.ProductID,
+			ProductSKU:  item.ProductSKU,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Subtotal:    subtotal,
+			Discount:    discount,
+			Total:       subtotal.Sub(discount),
+		})
+		preview.Subtotal = preview.Subtotal.Add(subtotal)
+	}
+	preview.Total = preview.Subtotal.Sub(preview.TotalDiscount)
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully previewed draft promotion",
+		"draft_promotion_id", promotion.ID.String(),
+		"applied_promotions", len(preview.Discounts),
+		"skipped_promotions", len(preview.Skipped),
+		"total_discount", preview.TotalDiscount.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return preview, nil
+}
+
+// basketItems prices a hypothetical basket with the current product prices, merging repeated SKUs
+func (u *promotionUseCase) basketItems(ctx context.Context, basket []PreviewItem) ([]promotionEntity.CartItem, error) {
+	quantities := make(map[string]int, len(basket))
+	skus := make([]string, 0, len(basket))
+	for _, item := range basket {
+		if item.Quantity < 1 {
+			return nil, commonErrs.NewBadRequest(fmt.Sprintf("quantity for SKU %s must be greater than zero", item.SKU))
+		}
+		if _, seen := quantities[item.SKU]; !seen {
+			skus = append(skus, item.SKU)
+		}
+		quantities[item.SKU] += item.Quantity
+	}
+
+	products, err := u.productRepo.GetBySKUs(ctx, skus)
+	if err != nil {
+		return nil, fmt.Errorf("error getting products: %w", err)
+	}
+	productsBySKU := make(map[string]*productEntity.Product, len(products))
+	for _, product := range products {
+		productsBySKU[product.SKU] = product
+	}
 
-	// Convert to PromotionDiscount format for response
-	discounts := make([]PromotionDiscount, 0, len(applicablePromotions))
-	for _, promo := range applicablePromotions {
+	items := make([]promotionEntity.CartItem, 0, len(skus))
+	for _, sku := range skus {
+		product, ok := productsBySKU[sku]
+		if !ok {
+			return nil, promotionErrors.NewUnknownProductSKUError(sku)
+		}
+		if product.HasVariants() {
+			return nil, commonErrs.NewBadRequest(fmt.Sprintf("product %s has variants; use the SKU of one of its variants", sku))
+		}
+		items = append(items, promotionEntity.CartItem{
+			ProductID:   product.ID,
+			ProductSKU:  product.SKU,
+			ProductName: product.Name,
+			Quantity:    quantities[sku],
+			UnitPrice:   product.Price,
+		})
+	}
+	return items, nil
+}
+
+// newPromotionEvaluation converts an engine result to the use case representation
+func newPromotionEvaluation(result *promotionEntity.EvaluationResult) *PromotionEvaluation {
+	discounts := make([]PromotionDiscount, 0, len(result.Promotions))
+	for _, promo := range result.Promotions {
 		discounts = append(discounts, PromotionDiscount{
 			PromotionID:   promo.ID,
 			PromotionType: string(promo.Type),
 			Description:   promo.Description,
 			Discount:      promo.Discount,
+			Allocations:   promo.Allocations,
+		})
+	}
+
+	skipped := make([]SkippedPromotion, 0, len(result.Skipped))
+	for _, promo := range result.Skipped {
+		skipped = append(skipped, SkippedPromotion{
+			PromotionID:       promo.ID,
+			PromotionType:     string(promo.Type),
+			Description:       promo.Description,
+			Reason:            string(promo.Reason),
+			BlockedBy:         promo.BlockedBy,
+			PotentialDiscount: promo.PotentialDiscount,
+		})
+	}
+
+	return &PromotionEvaluation{
+		Discounts:     discounts,
+		Skipped:       skipped,
+		TotalDiscount: result.TotalDiscount,
+		Tiers:         result.Tiers,
+		Approximate:   result.Approximate,
+	}
+}
+
+// CreateCoupon creates a coupon code that unlocks a promotion
+func (u *promotionUseCase) CreateCoupon(
+	ctx context.Context,
+	promotionID uuid.UUID,
+	code string,
+	maxRedemptions *int,
+	maxRedemptionsPerUser *int,
+	expiresAt *time.Time,
+) (*promotionEntity.Coupon, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.CreateCoupon",
+		"promotion_id", promotionID.String(),
+		"code", code,
+	)
+	logger.Info("Creating coupon")
+	startTime := time.Now()
+
+	if promotionID == uuid.Nil {
+		logger.Warn("Invalid promotion ID", "error", "ErrInvalidInput")
+		return nil, errors.New("invalid promotion ID")
+	}
+	if promotionEntity.NormalizeCouponCode(code) == "" {
+		logger.Warn("Invalid input: Empty code", "error", "ErrInvalidInput")
+		return nil, errors.New("code is required")
+	}
+	if maxRedemptions != nil && *maxRedemptions < 1 {
+		logger.Warn("Invalid input: Invalid max redemptions", "error", "ErrInvalidInput")
+		return nil, errors.New("max redemptions must be greater than zero")
+	}
+	if maxRedemptionsPerUser != nil && *maxRedemptionsPerUser < 1 {
+		logger.Warn("Invalid input: Invalid max redemptions per user", "error", "ErrInvalidInput")
+		return nil, errors.New("max redemptions per user must be greater than zero")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		logger.Warn("Invalid input: Coupon already expired", "error", "ErrInvalidInput")
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	if _, err := u.repo.GetByID(ctx, promotionID); err != nil {
+		logger.Error("Failed to get promotion", "error", err.Error())
+		return nil, fmt.Errorf("error getting promotion: %w", err)
+	}
+
+	coupon := promotionEntity.NewCoupon(promotionID, code, maxRedemptions, maxRedemptionsPerUser, expiresAt)
+
+	err := u.couponRepo.Create(ctx, coupon)
+	if err != nil {
+		if errors.Is(err, promotionErrors.ErrDuplicateCouponCode) {
+			logger.Warn("Coupon code already exists", "error", "ErrDuplicateCouponCode")
+			return nil, promotionErrors.NewDuplicateCouponCodeError(coupon.Code)
+		}
+		logger.Error("Failed to create coupon", "error", err.Error())
+		return nil, fmt.Errorf("failed to create coupon: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully created coupon",
+		"coupon_id", coupon.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return coupon, nil
+}
+
+// ValidateCoupon checks that a user can redeem a coupon code and returns the coupon
+func (u *promotionUseCase) ValidateCoupon(ctx context.Context, code string, userID uuid.UUID) (*promotionEntity.Coupon, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.ValidateCoupon",
+		"code", code,
+		"user_id", userID.String(),
+	)
+	logger.Debug("Validating coupon")
+	startTime := time.Now()
+
+	coupon, err := u.couponRepo.GetByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, promotionErrors.ErrCouponNotFound) {
+			return nil, promotionErrors.NewCouponNotApplicableError(code, err)
+		}
+		logger.Error("Failed to get coupon", "error", err.Error())
+		return nil, fmt.Errorf("error getting coupon: %w", err)
+	}
+
+	promotion, err := u.repo.GetByID(ctx, coupon.PromotionID)
+	if err != nil && !errors.Is(err, promotionErrors.ErrPromotionNotFound) {
+		logger.Error("Failed to get coupon promotion", "error", err.Error())
+		return nil, fmt.Errorf("error getting coupon promotion: %w", err)
+	}
+
+	userRedemptions, err := u.couponRepo.CountUserRedemptions(ctx, coupon.ID, userID)
+	if err != nil {
+		logger.Error("Failed to count coupon redemptions", "error", err.Error())
+		return nil, fmt.Errorf("error counting coupon redemptions: %w", err)
+	}
+
+	if err := coupon.CheckRedeemable(promotion, userRedemptions, time.Now()); err != nil {
+		logger.Info("Coupon cannot be redeemed", "reason", err.Error())
+		return nil, promotionErrors.NewCouponNotApplicableError(coupon.Code, err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Coupon is redeemable",
+		"coupon_id", coupon.ID.String(),
+		"duration_ms", duration.Milliseconds())
+
+	return coupon, nil
+}
+
+// GetReport returns the performance of promotions over a period compared with the orders without
+// promotions
+func (u *promotionUseCase) GetReport(ctx context.Context, promotionID *uuid.UUID, from, to *time.Time) (*promotionEntity.PromotionReport, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.GetReport",
+	)
+	if promotionID != nil {
+		logger = logger.With("promotion_id", promotionID.String())
+	}
+	logger.Info("Building promotion report")
+	startTime := time.Now()
+
+	periodEnd := time.Now()
+	if to != nil {
+		periodEnd = *to
+	}
+	periodStart := periodEnd.Add(-defaultReportPeriod)
+	if from != nil {
+		periodStart = *from
+	}
+	if !periodStart.Before(periodEnd) {
+		logger.Warn("Invalid input: Empty report period", "error", "ErrInvalidInput")
+		return nil, commonErrs.NewBadRequest("from must be before to")
+	}
+
+	var promotion *promotionEntity.Promotion
+	if promotionID != nil {
+		var err error
+		promotion, err = u.repo.GetByID(ctx, *promotionID)
+		if err != nil {
+			if errors.Is(err, promotionErrors.ErrPromotionNotFound) {
+				return nil, promotionErrors.NewPromotionNotFoundError(promotionID.String())
+			}
+			logger.Error("Failed to get promotion", "error", err.Error())
+			return nil, fmt.Errorf("error getting promotion: %w", err)
+		}
+	}
+
+	performances, err := u.repo.GetPerformance(ctx, periodStart, periodEnd, promotionID)
+	if err != nil {
+		logger.Error("Failed to get promotion performance", "error", err.Error())
+		return nil, fmt.Errorf("error getting promotion performance: %w", err)
+	}
+
+	// A promotion that was never applied in the period still gets a row
+	if promotion != nil && len(performances) == 0 {
+		performances = append(performances, promotionEntity.PromotionPerformance{
+			PromotionID:   promotion.ID,
+			Type:          promotion.Type,
+			Description:   promotion.Description,
+			TotalDiscount: money.Zero(),
+			OrderStats:    promotionEntity.NewOrderStats(0, money.Zero()),
 		})
 	}
 
-	// Calculate total discount
-	totalDiscount := promotionEntity.CalculateTotalDiscount(applicablePromotions)
+	nonPromoted, err := u.repo.GetNonPromotedOrderStats(ctx, periodStart, periodEnd)
+	if err != nil {
+		logger.Error("Failed to get non-promoted orders", "error", err.Error())
+		return nil, fmt.Errorf("error getting non-promoted orders: %w", err)
+	}
+
+	report := promotionEntity.NewPromotionReport(periodStart, periodEnd, performances, nonPromoted)
 
 	duration := time.Since(startTime)
-	logger.Info("Successfully processed promotions for cart",
-		"applicable_promotions", len(discounts),
-		"active_promotions", len(discounts)-0, // Count of promotions with actual discounts
-		"total_discount", totalDiscount,
+	logger.Info("Successfully built promotion report",
+		"from", periodStart,
+		"to", periodEnd,
+		"promotion_count", len(report.Promotions),
+		"duration_ms", duration.Milliseconds())
+
+	return report, nil
+}
+
+// AddCustomerToSegment adds a user to a named customer segment
+func (u *promotionUseCase) AddCustomerToSegment(ctx context.Context, userID uuid.UUID, segment string) error {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.AddCustomerToSegment",
+		"user_id", userID.String(),
+		"segment", segment,
+	)
+	logger.Info("Adding customer to segment")
+	startTime := time.Now()
+
+	if err := validateSegmentName(segment); err != nil {
+		logger.Warn("Invalid input: Invalid segment name", "error", err.Error())
+		return err
+	}
+
+	if err := u.customerRepo.AddToSegment(ctx, userID, segment); err != nil {
+		if errors.Is(err, promotionErrors.ErrCustomerNotFound) {
+			return promotionErrors.NewCustomerNotFoundError(userID.String())
+		}
+		logger.Error("Failed to add customer to segment", "error", err.Error())
+		return fmt.Errorf("error adding customer to segment: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully added customer to segment",
 		"duration_ms", duration.Milliseconds())
 
-	return discounts, totalDiscount, nil
+	return nil
+}
+
+// RemoveCustomerFromSegment removes a user from a named customer segment
+func (u *promotionUseCase) RemoveCustomerFromSegment(ctx context.Context, userID uuid.UUID, segment string) error {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.RemoveCustomerFromSegment",
+		"user_id", userID.String(),
+		"segment", segment,
+	)
+	logger.Info("Removing customer from segment")
+	startTime := time.Now()
+
+	if err := validateSegmentName(segment); err != nil {
+		logger.Warn("Invalid input: Invalid segment name", "error", err.Error())
+		return err
+	}
+
+	if err := u.customerRepo.RemoveFromSegment(ctx, userID, segment); err != nil {
+		logger.Error("Failed to remove customer from segment", "error", err.Error())
+		return fmt.Errorf("error removing customer from segment: %w", err)
+	}
+
+	duration := time.Since(startTime)
+	logger.Info("Successfully removed customer from segment",
+		"duration_ms", duration.Milliseconds())
+
+	return nil
+}
+
+// GetPromotionNotices returns promotions for the cart's items that have not started yet or have
+// expired within the last expiredNoticePeriod
+func (u *promotionUseCase) GetPromotionNotices(ctx context.Context, cart *cartEntity.CartInfo) ([]PromotionNotice, error) {
+	logger := middleware.Logger.With(
+		"method", "PromotionUseCase.GetPromotionNotices",
+	)
+	logger.Debug("Getting promotion notices for cart")
+	startTime := time.Now()
+
+	if cart == nil || len(cart.Items) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	promotions, err := u.repo.GetOutsideWindow(ctx, now.Add(-expiredNoticePeriod))
+	if err != nil {
+		logger.Error("Failed to get promotions outside their window", "error", err.Error())
+		return nil, fmt.Errorf("failed to get promotions outside their window: %w", err)
+	}
+
+	skuMap := promotionEntity.BuildSKUMap(promotionEntity.ConvertCartToPromotionItems(cart.Items))
+
+	notices := make([]PromotionNotice, 0)
+	for _, promotion := range promotions {
+		if !promotionEntity.IsPromotionApplicableToCart(promotion, skuMap) {
+			continue
+		}
+
+		status := promotion.WindowStatus(now)
+		if status == promotionEntity.WindowStatusOpen {
+			continue
+		}
+
+		notices = append(notices, PromotionNotice{
+			PromotionID:   promotion.ID,
+			PromotionType: string(promotion.Type),
+			Description:   promotion.Description,
+			Status:        status,
+			StartsAt:      promotion.StartsAt,
+			EndsAt:        promotion.EndsAt,
+		})
+	}
+
+	duration := time.Since(startTime)
+	logger.Debug("Successfully retrieved promotion notices",
+		"notice_count", len(notices),
+		"duration_ms", duration.Milliseconds())
+
+	return notices, nil
+}
+
+// validateStacking checks that every promotion listed in stackable_with exists
+func (u *promotionUseCase) validateStacking(ctx context.Context, stacking promotionEntity.StackingRules) error {
+	if stacking.Exclusive && len(stacking.StackableWith) > 0 {
+		return errors.New("an exclusive promotion cannot be stackable with other promotions")
+	}
+	for _, id := range stacking.StackableWith {
+		if _, err := u.repo.GetByID(ctx, id); err != nil {
+			if errors.Is(err, promotionErrors.ErrPromotionNotFound) {
+				return fmt.Errorf("stackable_with references unknown promotion %s", id)
+			}
+			return fmt.Errorf("error getting stackable promotion: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateEligibility checks that the roles of an eligibility are known user roles, that segment
+// names are not blank and that the lifetime spend is not negative
+func validateEligibility(eligibility promotionEntity.Eligibility) error {
+	for _, role := range eligibility.Roles {
+		if role != string(userEntity.RoleAdmin) && role != string(userEntity.RoleCustomer) {
+			return commonErrs.NewBadRequest(fmt.Sprintf("eligibility references unknown role %q", role))
+		}
+	}
+	for _, segment := range eligibility.Segments {
+		if err := validateSegmentName(segment); err != nil {
+			return err
+		}
+	}
+	if eligibility.MinLifetimeSpend != nil && eligibility.MinLifetimeSpend.IsNegative() {
+		return commonErrs.NewBadRequest("min lifetime spend cannot be negative")
+	}
+	return nil
+}
+
+// validateSegmentName checks that a segment name fits the user_segments table
+func validateSegmentName(segment string) error {
+	if strings.TrimSpace(segment) == "" {
+		return commonErrs.NewBadRequest("segment name is required")
+	}
+	if len(segment) > maxSegmentNameLength {
+		return commonErrs.NewBadRequest(fmt.Sprintf("segment name must be at most %d characters", maxSegmentNameLength))
+	}
+	return nil
+}
+
+// getCustomer loads the eligibility context of the user a cart belongs to. Anonymous carts and
+// unknown users have no customer, so only promotions open to everyone apply to them.
+func (u *promotionUseCase) getCustomer(ctx context.Context, userID uuid.UUID) (*promotionEntity.Customer, error) {
+	if userID == uuid.Nil {
+		return nil, nil
+	}
+	customer, err := u.customerRepo.GetCustomer(ctx, userID)
+	if err != nil {
+		if errors.Is(err, promotionErrors.ErrCustomerNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting customer: %w", err)
+	}
+	return customer, nil
+}
+
+// sortTiers returns the tiers of a tiered discount ordered by minimum quantity
+func sortTiers(tiers []promotionEntity.DiscountTier) []promotionEntity.DiscountTier {
+	sorted := make([]promotionEntity.DiscountTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinQuantity < sorted[j].MinQuantity
+	})
+	return sorted
+}
+
+// validateRule checks a promotion rule against the schema of its type and checks that every SKU it
+// refers to belongs to a product. Every problem is reported against the rule field it comes from.
+func (u *promotionUseCase) validateRule(ctx context.Context, promotion *promotionEntity.Promotion) error {
+	_, err := promotion.ParseRule()
+
+	var ruleErr *promotionEntity.RuleError
+	switch {
+	case errors.As(err, &ruleErr):
+		fieldErrs := make([]error, 0, len(ruleErr.Fields))
+		for _, fieldErr := range ruleErr.Fields {
+			fieldErrs = append(fieldErrs, fieldErr)
+		}
+		return promotionErrors.NewInvalidPromotionRuleError(string(promotion.Type), fieldErrs)
+	case errors.Is(err, promotionErrors.ErrInvalidPromotionType):
+		return promotionErrors.NewInvalidPromotionTypeError(string(promotion.Type))
+	case err != nil:
+		return promotionErrors.NewPromotionRuleParsingError(string(promotion.Type), err)
+	}
+
+	refs := promotion.RuleSKUReferences()
+	if len(refs) == 0 {
+		return nil
+	}
+
+	skus := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		skus = append(skus, ref.SKU)
+	}
+	products, err := u.productRepo.GetBySKUs(ctx, skus)
+	if err != nil {
+		return fmt.Errorf("error getting rule products: %w", err)
+	}
+
+	known := make(map[string]*productEntity.Product, len(products))
+	for _, product := range products {
+		known[product.SKU] = product
+	}
+
+	var fieldErrs []error
+	for _, ref := range refs {
+		product, ok := known[ref.SKU]
+		switch {
+		case !ok:
+			fieldErrs = append(fieldErrs, promotionEntity.FieldError{
+				Field:   ref.Field,
+				Message: fmt.Sprintf("no product with SKU %s", ref.SKU),
+			})
+		case product.HasVariants():
+			// Carts hold variants, so a rule on the parent SKU would never match
+			fieldErrs = append(fieldErrs, promotionEntity.FieldError{
+				Field:   ref.Field,
+				Message: fmt.Sprintf("product %s has variants; use the SKUs of its variants", ref.SKU),
+			})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return promotionErrors.NewInvalidPromotionRuleError(string(promotion.Type), fieldErrs)
+	}
+	return nil
+}
+
+// validateWindow checks a promotion validity window supplied on creation
+func validateWindow(startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if endsAt != nil && !endsAt.After(time.Now()) {
+		return commonErrs.ErrPromotionExpired
+	}
+	return nil
 }
@@ -2,18 +2,135 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	cartEntity "github.com/fanzru/e-commerce-be/internal/app/cart/domain/entity"
 	promotionEntity "github.com/fanzru/e-commerce-be/internal/app/promotion/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/money"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
 // PromotionDiscount represents a promotion applied to a cart with discount amount
 type PromotionDiscount struct {
-	PromotionID   uuid.UUID `json:"promotion_id"`
-	PromotionType string    `json:"promotion_type"`
-	Description   string    `json:"description"`
-	Discount      float64   `json:"discount"`
+	PromotionID   uuid.UUID   `json:"promotion_id"`
+	PromotionType string      `json:"promotion_type"`
+	Description   string      `json:"description"`
+	Discount      money.Money `json:"discount"`
+
+	// Allocations is the discount split across the cart lines the promotion applied to
+	Allocations []promotionEntity.LineDiscount `json:"allocations,omitempty"`
+}
+
+// SkippedPromotion is a promotion that applies to the cart on its own but was left out of the
+// combination that gives the best price
+type SkippedPromotion struct {
+	PromotionID       uuid.UUID   `json:"promotion_id"`
+	PromotionType     string      `json:"promotion_type"`
+	Description       string      `json:"description"`
+	Reason            string      `json:"reason"`
+	BlockedBy         *uuid.UUID  `json:"blocked_by,omitempty"`
+	PotentialDiscount money.Money `json:"potential_discount"`
+}
+
+// PromotionEvaluation is the outcome of applying promotions to a cart
+type PromotionEvaluation struct {
+	Discounts     []PromotionDiscount `json:"discounts"`
+	Skipped       []SkippedPromotion  `json:"skipped,omitempty"`
+	TotalDiscount money.Money         `json:"total_discount"`
+
+	// Tiers is the progress through each tiered promotion for a product in the cart
+	Tiers []promotionEntity.TierProgress `json:"tiers,omitempty"`
+
+	// Approximate is set when the engine stopped searching before it had compared every
+	// combination of promotions; a better combination may exist
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// PromotionDraft is an unsaved promotion to preview
+type PromotionDraft struct {
+	Type        promotionEntity.PromotionType `json:"type"`
+	Description string                        `json:"description"`
+	Rule        json.RawMessage               `json:"rule"`
+
+	promotionEntity.StackingRules
+
+	Eligibility promotionEntity.Eligibility `json:"eligibility"`
+}
+
+// PromotionOptions are the terms of a new promotion besides its rule
+type PromotionOptions struct {
+	Description    string
+	Active         bool
+	StartsAt       *time.Time
+	EndsAt         *time.Time
+	MaxRedemptions *int
+
+	promotionEntity.StackingRules
+
+	Eligibility promotionEntity.Eligibility
+}
+
+// PromotionUpdate is the complete set of terms a promotion is replaced with
+type PromotionUpdate struct {
+	Type           promotionEntity.PromotionType `json:"type"`
+	Description    string                        `json:"description"`
+	Rule           json.RawMessage               `json:"rule"`
+	Active         bool                          `json:"active"`
+	StartsAt       *time.Time                    `json:"starts_at,omitempty"`
+	EndsAt         *time.Time                    `json:"ends_at,omitempty"`
+	MaxRedemptions *int                          `json:"max_redemptions,omitempty"`
+
+	promotionEntity.StackingRules
+
+	Eligibility promotionEntity.Eligibility `json:"eligibility"`
+
+	// ExpectedVersion rejects the update when the promotion has moved past the version the caller
+	// read; nil updates the current version
+	ExpectedVersion *int `json:"version,omitempty"`
+}
+
+// PreviewItem is a line of a hypothetical basket to preview a promotion against
+type PreviewItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// PreviewLine is a basket line with the discount the previewed promotions give it
+type PreviewLine struct {
+	ProductID   uuid.UUID   `json:"product_id"`
+	ProductSKU  string      `json:"product_sku"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   money.Money `json:"unit_price"`
+	Subtotal    money.Money `json:"subtotal"`
+	Discount    money.Money `json:"discount"`
+	Total       money.Money `json:"total"`
+}
+
+// PromotionPreview is the outcome of running a draft promotion, together with the active promotions
+// when requested, against a basket
+type PromotionPreview struct {
+	// DraftPromotionID is the throwaway ID the draft was given, to find it among the results
+	DraftPromotionID uuid.UUID     `json:"draft_promotion_id"`
+	Lines            []PreviewLine `json:"lines"`
+
+	PromotionEvaluation
+
+	Subtotal money.Money `json:"subtotal"`
+	Total    money.Money `json:"total"`
+}
+
+// PromotionNotice tells the shopper about a promotion for items in their cart that is outside its
+// validity window, either because it has not started yet or because it has expired
+type PromotionNotice struct {
+	PromotionID   uuid.UUID                    `json:"promotion_id"`
+	PromotionType string                       `json:"promotion_type"`
+	Description   string                       `json:"description"`
+	Status        promotionEntity.WindowStatus `json:"status"`
+	StartsAt      *time.Time                   `json:"starts_at,omitempty"`
+	EndsAt        *time.Time                   `json:"ends_at,omitempty"`
 }
 
 // PromotionUseCase defines the interface for promotion use cases
@@ -21,40 +138,24 @@ type PromotionUseCase interface {
 	// GetByID retrieves a promotion by its ID
 	GetByID(ctx context.Context, id uuid.UUID) (*promotionEntity.Promotion, error)
 
-	// List retrieves a list of promotions with pagination and filtering
-	List(ctx context.Context, page, limit int, active *bool) ([]*promotionEntity.Promotion, int, error)
+	// List retrieves a page of promotions, newest first, by page number or cursor
+	List(ctx context.Context, req pagination.Request, active *bool) ([]*promotionEntity.Promotion, pagination.Result, error)
 
-	// Create creates a new promotion
-	CreateBuyOneGetOneFree(
+	// CreatePromotion creates a promotion of the given type. The rule is checked against the schema
+	// of the type and every SKU it mentions must belong to a product.
+	CreatePromotion(
 		ctx context.Context,
-		description string,
-		triggerSKU string,
-		freeSKU string,
-		triggerQuantity int,
-		freeQuantity int,
-		active bool,
+		promotionType promotionEntity.PromotionType,
+		rule promotionEntity.PromotionRule,
+		options PromotionOptions,
 	) (*promotionEntity.Promotion, error)
 
-	// Create creates a new Buy3Pay2 promotion
-	CreateBuy3Pay2(
-		ctx context.Context,
-		description string,
-		sku string,
-		minQuantity int,
-		paidQuantityDivisor int,
-		freeQuantityDivisor int,
-		active bool,
-	) (*promotionEntity.Promotion, error)
+	// UpdatePromotion replaces the terms of a promotion and records them as a new version. Earlier
+	// versions are kept for the checkouts that were priced with them.
+	UpdatePromotion(ctx context.Context, id uuid.UUID, update PromotionUpdate) (*promotionEntity.Promotion, error)
 
-	// Create creates a new BulkDiscount promotion
-	CreateBulkDiscount(
-		ctx context.Context,
-		description string,
-		sku string,
-		minQuantity int,
-		discountPercentage float64,
-		active bool,
-	) (*promotionEntity.Promotion, error)
+	// ListVersions returns every version of a promotion, newest first
+	ListVersions(ctx context.Context, id uuid.UUID) ([]*promotionEntity.PromotionVersion, error)
 
 	// UpdateStatus updates a promotion's active status
 	UpdateStatus(ctx context.Context, id uuid.UUID, active bool) error
@@ -62,6 +163,50 @@ type PromotionUseCase interface {
 	// Delete deletes a promotion
 	Delete(ctx context.Context, id uuid.UUID) error
 
-	// ApplyPromotions applies promotions to a cart and returns the discounts
-	ApplyPromotions(ctx context.Context, cart *cartEntity.CartInfo) ([]PromotionDiscount, float64, error)
+	// ApplyPromotions applies promotions to a cart and returns the discounts, including the promotion
+	// unlocked by the cart's coupon code when it is still redeemable
+	ApplyPromotions(ctx context.Context, cart *cartEntity.CartInfo) ([]PromotionDiscount, money.Money, error)
+
+	// CreateCoupon creates a coupon code that unlocks a promotion
+	CreateCoupon(
+		ctx context.Context,
+		promotionID uuid.UUID,
+		code string,
+		maxRedemptions *int,
+		maxRedemptionsPerUser *int,
+		expiresAt *time.Time,
+	) (*promotionEntity.Coupon, error)
+
+	// ValidateCoupon checks that a user can redeem a coupon code and returns the coupon
+	ValidateCoupon(ctx context.Context, code string, userID uuid.UUID) (*promotionEntity.Coupon, error)
+
+	// EvaluatePromotions applies promotions to a cart and returns the applied discounts together with
+	// the promotions that were skipped and why
+	EvaluatePromotions(ctx context.Context, cart *cartEntity.CartInfo) (*PromotionEvaluation, error)
+
+	// PreviewPromotion runs a draft promotion through the promotion engine against either a basket
+	// of SKUs or a user's cart, together with the active promotions when includeActive is set.
+	// A user's cart is evaluated with the user's eligibility; a basket has no customer, so the
+	// draft's eligibility is ignored and restricted active promotions are left out. Nothing is saved.
+	PreviewPromotion(
+		ctx context.Context,
+		draft PromotionDraft,
+		basket []PreviewItem,
+		userID *uuid.UUID,
+		includeActive bool,
+	) (*PromotionPreview, error)
+
+	// GetReport returns the performance of the promotions applied to orders placed between from
+	// (inclusive) and to (exclusive), or of a single promotion when promotionID is set, compared with
+	// the orders without promotions. A missing to is now and a missing from is 30 days before to.
+	GetReport(ctx context.Context, promotionID *uuid.UUID, from, to *time.Time) (*promotionEntity.PromotionReport, error)
+
+	// AddCustomerToSegment adds a user to a named customer segment
+	AddCustomerToSegment(ctx context.Context, userID uuid.UUID, segment string) error
+
+	// RemoveCustomerFromSegment removes a user from a named customer segment
+	RemoveCustomerFromSegment(ctx context.Context, userID uuid.UUID, segment string) error
+
+	// GetPromotionNotices returns promotions for the cart's items that have not started yet or have expired
+	GetPromotionNotices(ctx context.Context, cart *cartEntity.CartInfo) ([]PromotionNotice, error)
 }
@@ -2,14 +2,17 @@ package port
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/fanzru/e-commerce-be/internal/app/user/domain/entity"
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/params"
 	"github.com/fanzru/e-commerce-be/internal/app/user/port/genhttp"
 	"github.com/fanzru/e-commerce-be/internal/app/user/usecase"
 	"github.com/fanzru/e-commerce-be/pkg/formatter"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
@@ -219,6 +222,95 @@ func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// ListUsers handles GET /users requests
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request, params genhttp.ListUsersParams) {
+	ctx := r.Context()
+
+	// Set default values if not provided
+	page := 1
+	limit := 10
+	var cursor string
+	var role *entity.UserRole
+
+	if params.Page != nil {
+		page = *params.Page
+	}
+
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+
+	if params.Role != nil {
+		userRole := entity.UserRole(*params.Role)
+		role = &userRole
+	}
+
+	req, err := pagination.NewRequest(page, limit, cursor, params.IncludeTotal)
+	if err != nil {
+		handleError(w, formatter.NewHTTPError(http.StatusBadRequest, "Invalid cursor"))
+		return
+	}
+
+	// Call use case
+	users, result, err := h.userUseCase.ListUsers(ctx, req, role)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			handleError(w, formatter.NewHTTPError(http.StatusBadRequest, "Invalid cursor"))
+			return
+		}
+		handleError(w, err)
+		return
+	}
+
+	// Create response
+	usersData := make([]genhttp.User, len(users))
+	for i, user := range users {
+		id := user.ID
+		email := openapi_types.Email(user.Email)
+		name := user.Name
+		userRole := genhttp.UserRole(user.Role)
+		createdAt := user.CreatedAt
+		updatedAt := user.UpdatedAt
+
+		usersData[i] = genhttp.User{
+			Id:        &id,
+			Email:     &email,
+			Name:      &name,
+			Role:      &userRole,
+			CreatedAt: &createdAt,
+			UpdatedAt: &updatedAt,
+		}
+	}
+
+	meta := genhttp.PaginationMeta{
+		PerPage:    &req.Limit,
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
+		PrevCursor: result.PrevCursor,
+	}
+	if req.Cursor == nil {
+		meta.CurrentPage = &req.Page
+	}
+	if result.Total != nil {
+		totalPages := (*result.Total + req.Limit - 1) / req.Limit
+		meta.TotalPages = &totalPages
+	}
+
+	response := genhttp.UserListResponse{
+		Code:       "SUCCESS",
+		Message:    "Users retrieved successfully",
+		ServerTime: time.Now(),
+	}
+	response.Data.Users = &usersData
+	response.Data.Meta = &meta
+
+	respondJSON(w, http.StatusOK, response)
+}
+
 // Helper functions
 
 // handleError handles errors and sends appropriate HTTP responses
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/entity"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -24,8 +25,9 @@ type UserRepository interface {
 	// Delete soft-deletes a user
 	Delete(ctx context.Context, id uuid.UUID) error
 
-	// List lists users with pagination and filters
-	List(ctx context.Context, page, limit int, role *entity.UserRole) ([]*entity.User, int, error)
+	// List lists a page of users, newest first, with filters; it returns
+	// pagination.ErrInvalidCursor for a cursor of another listing
+	List(ctx context.Context, req pagination.Request, role *entity.UserRole) ([]*entity.User, pagination.Result, error)
 }
 
 // TokenRepository defines the interface for token repositories
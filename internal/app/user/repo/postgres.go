@@ -10,6 +10,7 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/entity"
 	userErrs "github.com/fanzru/e-commerce-be/internal/app/user/domain/errs"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -18,6 +19,15 @@ type userRepository struct {
 	db *sql.DB
 }
 
+// userKeyset orders user lists newest first
+var userKeyset = pagination.Keyset{
+	Name: "newest",
+	Columns: []pagination.Column{
+		{Expr: "users.created_at", Desc: true},
+		{Expr: "users.id", Desc: true},
+	},
+}
+
 // NewUserRepository creates a new PostgreSQL user repository
 func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{
@@ -207,12 +217,13 @@ func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-// List lists users with pagination and filters
-func (r *userRepository) List(ctx context.Context, page, limit int, role *entity.UserRole) ([]*entity.User, int, error) {
+// List lists a page of users, newest first, with filters
+func (r *userRepository) List(ctx context.Context, req pagination.Request, role *entity.UserRole) ([]*entity.User, pagination.Result, error) {
 	logger := middleware.Logger.With(
 		"method", "UserRepository.List",
-		"page", page,
-		"limit", limit,
+		"page", req.Page,
+		"limit", req.Limit,
+		"cursor", req.Cursor != nil,
 	)
 	if role != nil {
 		logger = logger.With("role", *role)
@@ -220,55 +231,60 @@ func (r *userRepository) List(ctx context.Context, page, limit int, role *entity
 	logger.Debug("Listing users with filters")
 	startTime := time.Now()
 
-	// Calculate offset for pagination
-	offset := (page - 1) * limit
-
 	// Build query with optional role filter
-	countQuery := "SELECT COUNT(*) FROM users WHERE deleted_at IS NULL"
-	listQuery := `
-		SELECT id, email, password, name, role, created_at, updated_at, NULL as deleted_at
-		FROM users
-		WHERE deleted_at IS NULL
-	`
+	whereClause := "WHERE deleted_at IS NULL"
 	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
 	if role != nil {
-		countQuery += " AND role = $1"
-		listQuery += " AND role = $1"
-		args = append(args, *role)
+		whereClause += " AND role = " + arg(*role)
 	}
 
-	// Add pagination to list query
-	listQuery += " ORDER BY created_at DESC"
-	if len(args) > 0 {
-		listQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
-		args = append(args, limit, offset)
-	} else {
-		listQuery += " LIMIT $1 OFFSET $2"
-		args = append(args, limit, offset)
+	// Get total count, when asked for
+	var total int
+	if req.IncludeTotal {
+		err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users "+whereClause, args...).Scan(&total)
+		if err != nil {
+			logger.Error("Failed to count users", "error", err.Error())
+			return nil, pagination.Result{}, fmt.Errorf("failed to count users: %w", err)
+		}
 	}
 
-	// Get total count
-	var total int
-	err := r.db.QueryRowContext(ctx, countQuery, args[:len(args)-2]...).Scan(&total)
+	afterCursor, err := userKeyset.Where(req, arg)
 	if err != nil {
-		logger.Error("Failed to count users", "error", err.Error())
-		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+		logger.Warn("Invalid input: cursor", "error", err.Error())
+		return nil, pagination.Result{}, err
+	}
+	if afterCursor != "" {
+		whereClause += " AND " + afterCursor
 	}
 
+	listQuery := fmt.Sprintf(`
+		SELECT id, email, password, name, role, created_at, updated_at, NULL as deleted_at, %s
+		FROM users
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, userKeyset.Select(), whereClause, userKeyset.OrderBy(req), arg(req.FetchLimit()), arg(req.Offset()))
+
 	// Get users
 	rows, err := r.db.QueryContext(ctx, listQuery, args...)
 	if err != nil {
 		logger.Error("Failed to query users", "error", err.Error())
-		return nil, 0, fmt.Errorf("failed to query users: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("failed to query users: %w", err)
 	}
 	defer rows.Close()
 
 	// Scan users
 	users := []*entity.User{}
+	keys := [][]*string{}
 	for rows.Next() {
 		user := &entity.User{}
 		var deletedAt sql.NullTime
-		err := rows.Scan(
+		keyDest, rowKeys := userKeyset.ScanKeys()
+		err := rows.Scan(append([]interface{}{
 			&user.ID,
 			&user.Email,
 			&user.Password,
@@ -277,29 +293,32 @@ func (r *userRepository) List(ctx context.Context, page, limit int, role *entity
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&deletedAt,
-		)
+		}, keyDest...)...)
 		if err != nil {
 			logger.Error("Failed to scan user row", "error", err.Error())
-			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+			return nil, pagination.Result{}, fmt.Errorf("failed to scan user: %w", err)
 		}
 		if deletedAt.Valid {
 			user.DeletedAt = &deletedAt.Time
 		}
 		users = append(users, user)
+		keys = append(keys, rowKeys)
 	}
 
 	if err = rows.Err(); err != nil {
 		logger.Error("Failed to iterate user rows", "error", err.Error())
-		return nil, 0, fmt.Errorf("failed to iterate user rows: %w", err)
+		return nil, pagination.Result{}, fmt.Errorf("failed to iterate user rows: %w", err)
 	}
 
+	users, result := pagination.Page(users, keys, req, userKeyset, total)
+
 	duration := time.Since(startTime)
 	logger.Info("Successfully listed users",
 		"total_count", total,
 		"returned_count", len(users),
 		"duration_ms", duration.Milliseconds())
 
-	return users, total, nil
+	return users, result, nil
 }
 
 func (r *userRepository) scanUser(ctx context.Context, query string, args ...interface{}) (*entity.User, error) {
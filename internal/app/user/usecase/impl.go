@@ -10,6 +10,7 @@ import (
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/params"
 	"github.com/fanzru/e-commerce-be/internal/app/user/repo"
 	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -220,14 +221,14 @@ func (uc *UserUseCaseImpl) DeleteUser(ctx context.Context, id uuid.UUID) error {
 }
 
 // ListUsers lists users with pagination and filters
-func (uc *UserUseCaseImpl) ListUsers(ctx context.Context, page, limit int, role *entity.UserRole) ([]*entity.User, int, error) {
+func (uc *UserUseCaseImpl) ListUsers(ctx context.Context, req pagination.Request, role *entity.UserRole) ([]*entity.User, pagination.Result, error) {
 	// Use the List method from interface
-	users, count, err := uc.userRepo.List(ctx, page, limit, role)
+	users, result, err := uc.userRepo.List(ctx, req, role)
 	if err != nil {
-		return nil, 0, err
+		return nil, pagination.Result{}, err
 	}
 
-	return users, count, nil
+	return users, result, nil
 }
 
 // ValidateToken validates and extracts claims from a token
@@ -5,6 +5,7 @@ import (
 
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/entity"
 	"github.com/fanzru/e-commerce-be/internal/app/user/domain/params"
+	"github.com/fanzru/e-commerce-be/pkg/pagination"
 	"github.com/google/uuid"
 )
 
@@ -38,7 +39,7 @@ type UserUseCase interface {
 	DeleteUser(ctx context.Context, id uuid.UUID) error
 
 	// ListUsers lists users with pagination and filters
-	ListUsers(ctx context.Context, page, limit int, role *entity.UserRole) ([]*entity.User, int, error)
+	ListUsers(ctx context.Context, req pagination.Request, role *entity.UserRole) ([]*entity.User, pagination.Result, error)
 
 	// ValidateToken validates and extracts claims from a token
 	ValidateToken(token string) (*params.TokenClaims, error)
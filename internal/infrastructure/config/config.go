@@ -13,6 +13,46 @@ type Config struct {
 	ServerPort int
 	Database   DatabaseConfig
 	JWT        JWTConfig
+	Checkout   CheckoutConfig
+	Product    ProductConfig
+	Storage    StorageConfig
+	Pagination PaginationConfig
+}
+
+// PaginationConfig holds list pagination configuration
+type PaginationConfig struct {
+	// CursorSecretKey signs the cursors handed to clients; it defaults to the JWT secret key
+	CursorSecretKey string
+}
+
+// StorageConfig holds blob storage configuration for uploaded files
+type StorageConfig struct {
+	// Driver is local or s3
+	Driver string
+
+	// PublicURL is the address blobs are fetched from, such as a CDN; by default the application
+	// serves them under /media
+	PublicURL string
+
+	LocalDir string
+
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UsePathStyle    bool
+}
+
+// ProductConfig holds product configuration
+type ProductConfig struct {
+	PriceScheduleIntervalSeconds int
+}
+
+// CheckoutConfig holds checkout configuration
+type CheckoutConfig struct {
+	ReservationTTLMinutes           int
+	ReservationSweepIntervalSeconds int
 }
 
 // JWTConfig holds JWT configuration
@@ -52,6 +92,27 @@ func Load() (*Config, error) {
 	jwtSecretKey := getEnv("JWT_SECRET_KEY", "your-secret-key-change-in-production")
 	jwtExpirationHours := getEnvInt("JWT_EXPIRATION_HOURS", 24)
 
+	// Pagination configuration
+	cursorSecretKey := getEnv("CURSOR_SECRET_KEY", jwtSecretKey)
+
+	// Checkout configuration
+	checkoutReservationTTLMinutes := getEnvInt("CHECKOUT_RESERVATION_TTL_MINUTES", 15)
+	checkoutReservationSweepIntervalSeconds := getEnvInt("CHECKOUT_RESERVATION_SWEEP_INTERVAL_SECONDS", 60)
+
+	// Product configuration
+	productPriceScheduleIntervalSeconds := getEnvInt("PRODUCT_PRICE_SCHEDULE_INTERVAL_SECONDS", 60)
+
+	// Storage configuration
+	storageDriver := getEnv("STORAGE_DRIVER", "local")
+	storagePublicURL := getEnv("STORAGE_PUBLIC_URL", "/media")
+	storageLocalDir := getEnv("STORAGE_LOCAL_DIR", "./data/media")
+	s3Endpoint := getEnv("S3_ENDPOINT", "https://s3.amazonaws.com")
+	s3Region := getEnv("S3_REGION", "us-east-1")
+	s3Bucket := getEnv("S3_BUCKET", "")
+	s3AccessKeyID := getEnv("S3_ACCESS_KEY_ID", "")
+	s3SecretAccessKey := getEnv("S3_SECRET_ACCESS_KEY", "")
+	s3UsePathStyle := getEnvBool("S3_USE_PATH_STYLE", false)
+
 	// Database configuration
 	dbHost := getEnv("DB_HOST", "localhost")
 	dbPort := getEnvInt("DB_PORT", 5432)
@@ -78,6 +139,27 @@ func Load() (*Config, error) {
 			SecretKey:       jwtSecretKey,
 			ExpirationHours: jwtExpirationHours,
 		},
+		Checkout: CheckoutConfig{
+			ReservationTTLMinutes:           checkoutReservationTTLMinutes,
+			ReservationSweepIntervalSeconds: checkoutReservationSweepIntervalSeconds,
+		},
+		Product: ProductConfig{
+			PriceScheduleIntervalSeconds: productPriceScheduleIntervalSeconds,
+		},
+		Storage: StorageConfig{
+			Driver:            storageDriver,
+			PublicURL:         storagePublicURL,
+			LocalDir:          storageLocalDir,
+			S3Endpoint:        s3Endpoint,
+			S3Region:          s3Region,
+			S3Bucket:          s3Bucket,
+			S3AccessKeyID:     s3AccessKeyID,
+			S3SecretAccessKey: s3SecretAccessKey,
+			S3UsePathStyle:    s3UsePathStyle,
+		},
+		Pagination: PaginationConfig{
+			CursorSecretKey: cursorSecretKey,
+		},
 	}, nil
 }
 
@@ -104,3 +186,18 @@ func getEnvInt(key string, defaultValue int) int {
 
 	return intValue
 }
+
+// getEnvBool gets an environment variable as a boolean or returns the default value
+func getEnvBool(key string, defaultValue bool) bool {
+	strValue := getEnv(key, "")
+	if strValue == "" {
+		return defaultValue
+	}
+
+	boolValue, err := strconv.ParseBool(strValue)
+	if err != nil {
+		return defaultValue
+	}
+
+	return boolValue
+}
@@ -127,14 +127,24 @@ func TxFromContext(ctx context.Context) *sql.Tx {
 	return nil
 }
 
-// GetQueryable returns either the transaction from context or the database
-func (m *TransactionManager) GetQueryable(ctx context.Context) interface {
+// Queryable is the common query interface of *sql.DB and *sql.Tx
+type Queryable interface {
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
-} {
+}
+
+// GetQueryable returns either the transaction from context or the database
+func (m *TransactionManager) GetQueryable(ctx context.Context) Queryable {
+	return QueryableFromContext(ctx, m.db)
+}
+
+// QueryableFromContext returns the transaction from context, or db when the call is not
+// part of a transaction. Repositories holding only a *sql.DB use it to join a transaction
+// started by RunInTransaction.
+func QueryableFromContext(ctx context.Context, db *sql.DB) Queryable {
 	if tx := TxFromContext(ctx); tx != nil {
 		return tx
 	}
-	return m.db
+	return db
 }
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrBlobNotFound is returned when no blob is stored under a key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrInvalidBlobKey is returned for a key that is empty, absolute or escapes the store
+var ErrInvalidBlobKey = errors.New("invalid blob key")
+
+// BlobStore stores binary objects, such as product images, under slash-separated keys
+type BlobStore interface {
+	// Put stores size bytes read from body under key, replacing any blob stored there
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+
+	// Get opens the blob stored under key; the caller closes its body
+	Get(ctx context.Context, key string) (*Blob, error)
+
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// URL returns the address clients fetch the blob stored under key from
+	URL(key string) string
+}
+
+// Blob is an opened blob
+type Blob struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// ValidateKey checks that a key is a clean relative path that stays inside the store
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %q", ErrInvalidBlobKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+)
+
+// NewHandler serves the blobs of a store by key, taken from the request path with any prefix
+// already stripped. Keys are never reused for different content, so responses may be cached for
+// good.
+func NewHandler(store BlobStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		if ValidateKey(key) != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		blob, err := store.Get(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, ErrBlobNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			middleware.Logger.Error("Failed to get blob", "key", key, "error", err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer blob.Body.Close()
+
+		w.Header().Set("Content-Type", blob.ContentType)
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if blob.Size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(blob.Size, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodGet {
+			if _, err := io.Copy(w, blob.Body); err != nil {
+				middleware.Logger.Warn("Failed to send blob", "key", key, "error", err.Error())
+			}
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+)
+
+var _ BlobStore = (*LocalBlobStore)(nil)
+
+// LocalBlobStore implements BlobStore on the local filesystem. The content type of a blob is
+// derived from the extension of its key, so keys should carry one.
+type LocalBlobStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalBlobStore creates a blob store under the root directory, creating the directory when
+// missing. Blob URLs are the key appended to baseURL, where the blobs must be served from.
+func NewLocalBlobStore(root, baseURL string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating blob directory: %w", err)
+	}
+	return &LocalBlobStore{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so readers never see a
+// partial blob
+func (s *LocalBlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	logger := middleware.Logger.With(
+		"method", "LocalBlobStore.Put",
+		"key", key,
+		"size", size,
+	)
+
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	filename := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		logger.Error("Failed to create blob directory", "error", err.Error())
+		return fmt.Errorf("error creating blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		logger.Error("Failed to create blob file", "error", err.Error())
+		return fmt.Errorf("error creating blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.Error("Failed to write blob file", "error", err.Error())
+		return fmt.Errorf("error writing blob file: %w", err)
+	}
+	if written != size {
+		logger.Error("Blob size mismatch", "written", written)
+		return fmt.Errorf("error writing blob file: wrote %d of %d bytes", written, size)
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		logger.Error("Failed to move blob file into place", "error", err.Error())
+		return fmt.Errorf("error moving blob file: %w", err)
+	}
+
+	logger.Debug("Stored blob")
+	return nil
+}
+
+// Get opens the blob file
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (*Blob, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, fmt.Errorf("error opening blob file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading blob file: %w", err)
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, ErrBlobNotFound
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &Blob{
+		Body:        file,
+		ContentType: contentType,
+		Size:        info.Size(),
+	}, nil
+}
+
+// Delete removes the blob file
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		middleware.Logger.Error("Failed to delete blob file", "key", key, "error", err.Error())
+		return fmt.Errorf("error deleting blob file: %w", err)
+	}
+	return nil
+}
+
+// URL returns the key appended to the base URL
+func (s *LocalBlobStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// path returns the file of a validated key
+func (s *LocalBlobStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fanzru/e-commerce-be/internal/infrastructure/middleware"
+)
+
+var _ BlobStore = (*S3BlobStore)(nil)
+
+// s3RequestTimeout bounds a single request to the object store
+const s3RequestTimeout = 60 * time.Second
+
+// S3Config holds the settings of an S3-compatible object store
+type S3Config struct {
+	// Endpoint is the scheme and host of the store, such as https://s3.eu-west-1.amazonaws.com or
+	// http://localhost:9000 for MinIO
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// UsePathStyle addresses the bucket in the path instead of the host name, which MinIO and most
+	// self-hosted stores need
+	UsePathStyle bool
+}
+
+// S3BlobStore implements BlobStore on an S3-compatible object store, signing requests with AWS
+// Signature Version 4
+type S3BlobStore struct {
+	cfg      S3Config
+	endpoint *url.URL
+	baseURL  string
+	client   *http.Client
+}
+
+// NewS3BlobStore creates a blob store in a bucket. Blob URLs are the key appended to baseURL: a
+// public bucket or CDN address, or the address the application serves the blobs from.
+func NewS3BlobStore(cfg S3Config, baseURL string) (*S3BlobStore, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, fmt.Errorf("S3 bucket and region are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 access key ID and secret access key are required")
+	}
+
+	return &S3BlobStore{
+		cfg:      cfg,
+		endpoint: endpoint,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		client:   &http.Client{Timeout: s3RequestTimeout},
+	}, nil
+}
+
+// Put uploads the blob with a PutObject request
+func (s *S3BlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	logger := middleware.Logger.With(
+		"method", "S3BlobStore.Put",
+		"key", key,
+		"size", size,
+	)
+
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		logger.Error("Failed to put object", "error", err.Error())
+		return fmt.Errorf("error putting object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := responseError(resp)
+		logger.Error("Failed to put object", "error", err.Error())
+		return fmt.Errorf("error putting object: %w", err)
+	}
+
+	logger.Debug("Stored blob")
+	return nil
+}
+
+// Get downloads the blob with a GetObject request
+func (s *S3BlobStore) Get(ctx context.Context, key string) (*Blob, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting object: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &Blob{
+			Body:        resp.Body,
+			ContentType: resp.Header.Get("Content-Type"),
+			Size:        resp.ContentLength,
+		}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("error getting object: %w", responseError(resp))
+	}
+}
+
+// Delete removes the blob with a DeleteObject request, which succeeds for a missing object too
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		middleware.Logger.Error("Failed to delete object", "key", key, "error", err.Error())
+		return fmt.Errorf("error deleting object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		err := responseError(resp)
+		middleware.Logger.Error("Failed to delete object", "key", key, "error", err.Error())
+		return fmt.Errorf("error deleting object: %w", err)
+	}
+	return nil
+}
+
+// URL returns the key appended to the base URL
+func (s *S3BlobStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// newRequest creates a request for the object under key
+func (s *S3BlobStore) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	objectURL := *s.endpoint
+	objectPath := "/" + escapePath(key)
+	if s.cfg.UsePathStyle {
+		objectPath = "/" + escapePath(s.cfg.Bucket) + objectPath
+	} else {
+		objectURL.Host = s.cfg.Bucket + "." + objectURL.Host
+	}
+	objectURL.Path = ""
+	objectURL.RawPath = ""
+	objectURL.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String()+objectPath, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating object request: %w", err)
+	}
+	return req, nil
+}
+
+// sign adds the Signature Version 4 headers to a request. The payload is left unsigned, so a
+// body can be streamed without hashing it first.
+func (s *S3BlobStore) sign(req *http.Request, now time.Time) {
+	s.signWithPayloadHash(req, "UNSIGNED-PAYLOAD", now)
+}
+
+// signWithPayloadHash adds the Signature Version 4 headers to a request whose payload has the
+// given hash. Every header already set on the request is signed along with the host.
+func (s *S3BlobStore) signWithPayloadHash(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hashedRequest[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name and then value, as Signature Version 4
+// expects
+func canonicalQuery(query url.Values) string {
+	type param struct{ name, value string }
+	params := make([]param, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, param{escapeQuery(name), escapeQuery(value)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// escapeQuery percent-encodes a query parameter name or value, slashes included
+func escapeQuery(value string) string {
+	return strings.ReplaceAll(escapePath(value), "/", "%2F")
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath percent-encodes every byte of a key except unreserved characters and slashes, as
+// Signature Version 4 expects
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// responseError describes a failed response with the start of its error document
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1 @@
+ALTER TABLE checkouts DROP COLUMN IF EXISTS inventory_restocked_at;
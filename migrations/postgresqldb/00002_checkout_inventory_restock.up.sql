@@ -0,0 +1,2 @@
+ALTER TABLE checkouts ADD COLUMN inventory_restocked_at timestamptz NULL;
+COMMENT ON COLUMN public.checkouts.inventory_restocked_at IS 'When the ordered quantities were returned to product inventory after cancellation or payment failure; NULL if never restocked';
@@ -0,0 +1,19 @@
+CREATE TABLE inventory_reservations (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	checkout_id uuid NOT NULL,
+	product_id uuid NOT NULL,
+	quantity int4 NOT NULL,
+	status varchar(20) DEFAULT 'RESERVED'::character varying NOT NULL, -- Reservation status: RESERVED, COMMITTED, RELEASED
+	expires_at timestamptz NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT inventory_reservations_pkey PRIMARY KEY (id),
+	CONSTRAINT inventory_reservations_quantity_check CHECK (quantity > 0),
+	CONSTRAINT inventory_reservations_checkout_id_fkey FOREIGN KEY (checkout_id) REFERENCES checkouts(id) ON DELETE CASCADE,
+	CONSTRAINT inventory_reservations_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(id)
+);
+CREATE INDEX idx_inventory_reservations_checkout_id ON public.inventory_reservations USING btree (checkout_id);
+CREATE INDEX idx_inventory_reservations_active ON public.inventory_reservations USING btree (product_id, expires_at) WHERE status = 'RESERVED';
+CREATE INDEX idx_inventory_reservations_expiry ON public.inventory_reservations USING btree (expires_at) WHERE status = 'RESERVED';
+COMMENT ON TABLE public.inventory_reservations IS 'Stock held for pending checkouts until they are paid, cancelled or the hold expires';
+COMMENT ON COLUMN public.inventory_reservations.status IS 'Reservation status: RESERVED, COMMITTED, RELEASED';
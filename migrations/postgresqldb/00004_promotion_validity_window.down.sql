@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_promotions_validity_window;
+ALTER TABLE promotions DROP CONSTRAINT IF EXISTS promotions_validity_window_check;
+ALTER TABLE promotions DROP COLUMN IF EXISTS ends_at;
+ALTER TABLE promotions DROP COLUMN IF EXISTS starts_at;
@@ -0,0 +1,6 @@
+ALTER TABLE promotions ADD COLUMN starts_at timestamptz NULL;
+ALTER TABLE promotions ADD COLUMN ends_at timestamptz NULL;
+ALTER TABLE promotions ADD CONSTRAINT promotions_validity_window_check CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at);
+CREATE INDEX idx_promotions_validity_window ON public.promotions USING btree (starts_at, ends_at) WHERE active = true AND deleted_at IS NULL;
+COMMENT ON COLUMN public.promotions.starts_at IS 'When the promotion starts applying; NULL applies immediately';
+COMMENT ON COLUMN public.promotions.ends_at IS 'When the promotion stops applying (exclusive); NULL never expires';
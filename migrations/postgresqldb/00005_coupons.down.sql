@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS cart_coupons;
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupons;
+ALTER TABLE promotions DROP COLUMN IF EXISTS redemption_count;
+ALTER TABLE promotions DROP COLUMN IF EXISTS max_redemptions;
@@ -0,0 +1,51 @@
+ALTER TABLE promotions ADD COLUMN max_redemptions int4 NULL;
+ALTER TABLE promotions ADD COLUMN redemption_count int4 DEFAULT 0 NOT NULL;
+COMMENT ON COLUMN public.promotions.max_redemptions IS 'Global cap on coupon redemptions across every code of the promotion; NULL is unlimited';
+COMMENT ON COLUMN public.promotions.redemption_count IS 'Number of coupon redemptions recorded against the promotion';
+
+CREATE TABLE coupons (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	code varchar(64) NOT NULL, -- Stored upper-case; codes are matched case-insensitively
+	promotion_id uuid NOT NULL,
+	max_redemptions int4 NULL,
+	max_redemptions_per_user int4 NULL,
+	redemption_count int4 DEFAULT 0 NOT NULL,
+	expires_at timestamptz NULL,
+	active bool DEFAULT true NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT coupons_pkey PRIMARY KEY (id),
+	CONSTRAINT coupons_code_key UNIQUE (code),
+	CONSTRAINT coupons_max_redemptions_check CHECK (max_redemptions IS NULL OR max_redemptions > 0),
+	CONSTRAINT coupons_max_redemptions_per_user_check CHECK (max_redemptions_per_user IS NULL OR max_redemptions_per_user > 0),
+	CONSTRAINT coupons_promotion_id_fkey FOREIGN KEY (promotion_id) REFERENCES promotions(id)
+);
+CREATE INDEX idx_coupons_promotion_id ON public.coupons USING btree (promotion_id);
+COMMENT ON TABLE public.coupons IS 'Codes that unlock a promotion; promotions with coupons are never applied automatically';
+
+CREATE TABLE coupon_redemptions (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	coupon_id uuid NOT NULL,
+	promotion_id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	checkout_id uuid NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT coupon_redemptions_pkey PRIMARY KEY (id),
+	CONSTRAINT coupon_redemptions_checkout_id_key UNIQUE (checkout_id),
+	CONSTRAINT coupon_redemptions_coupon_id_fkey FOREIGN KEY (coupon_id) REFERENCES coupons(id),
+	CONSTRAINT coupon_redemptions_promotion_id_fkey FOREIGN KEY (promotion_id) REFERENCES promotions(id),
+	CONSTRAINT coupon_redemptions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT coupon_redemptions_checkout_id_fkey FOREIGN KEY (checkout_id) REFERENCES checkouts(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_coupon_redemptions_coupon_user ON public.coupon_redemptions USING btree (coupon_id, user_id);
+COMMENT ON TABLE public.coupon_redemptions IS 'One row per checkout that redeemed a coupon';
+
+CREATE TABLE cart_coupons (
+	user_id uuid NOT NULL,
+	coupon_id uuid NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT cart_coupons_pkey PRIMARY KEY (user_id),
+	CONSTRAINT cart_coupons_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT cart_coupons_coupon_id_fkey FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE
+);
+COMMENT ON TABLE public.cart_coupons IS 'The coupon code applied to a user''s cart, redeemed at checkout';
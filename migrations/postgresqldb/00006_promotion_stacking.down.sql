@@ -0,0 +1,6 @@
+ALTER TABLE promotion_applied DROP CONSTRAINT IF EXISTS promotion_applied_status_check;
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS skip_reason;
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS status;
+ALTER TABLE promotions DROP COLUMN IF EXISTS stackable_with;
+ALTER TABLE promotions DROP COLUMN IF EXISTS exclusive;
+ALTER TABLE promotions DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,12 @@
+ALTER TABLE promotions ADD COLUMN priority int4 DEFAULT 0 NOT NULL;
+ALTER TABLE promotions ADD COLUMN exclusive bool DEFAULT false NOT NULL;
+ALTER TABLE promotions ADD COLUMN stackable_with uuid[] DEFAULT '{}' NOT NULL;
+COMMENT ON COLUMN public.promotions.priority IS 'Higher priorities are applied first and win ties between equally good combinations';
+COMMENT ON COLUMN public.promotions.exclusive IS 'An exclusive promotion is never combined with any other promotion';
+COMMENT ON COLUMN public.promotions.stackable_with IS 'Promotions that may discount the same units as this one';
+
+ALTER TABLE promotion_applied ADD COLUMN status varchar(20) DEFAULT 'APPLIED' NOT NULL;
+ALTER TABLE promotion_applied ADD COLUMN skip_reason varchar(32) NULL;
+ALTER TABLE promotion_applied ADD CONSTRAINT promotion_applied_status_check CHECK (status IN ('APPLIED', 'SKIPPED'));
+COMMENT ON COLUMN public.promotion_applied.status IS 'APPLIED promotions discounted the checkout; SKIPPED ones applied on their own but lost to a better combination';
+COMMENT ON COLUMN public.promotion_applied.skip_reason IS 'Why a SKIPPED promotion was left out: EXCLUSIVE, NOT_STACKABLE or NO_REMAINING_VALUE';
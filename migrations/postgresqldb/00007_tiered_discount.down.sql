@@ -0,0 +1,4 @@
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS units_to_next_tier;
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS next_tier_min_quantity;
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS tier_discount_percentage;
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS tier_min_quantity;
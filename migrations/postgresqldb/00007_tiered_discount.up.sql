@@ -0,0 +1,8 @@
+ALTER TABLE promotion_applied ADD COLUMN tier_min_quantity int4 NULL;
+ALTER TABLE promotion_applied ADD COLUMN tier_discount_percentage numeric(5, 2) NULL;
+ALTER TABLE promotion_applied ADD COLUMN next_tier_min_quantity int4 NULL;
+ALTER TABLE promotion_applied ADD COLUMN units_to_next_tier int4 NULL;
+COMMENT ON COLUMN public.promotion_applied.tier_min_quantity IS 'Units needed for the tier a TIERED_DISCOUNT promotion reached';
+COMMENT ON COLUMN public.promotion_applied.tier_discount_percentage IS 'Discount percentage of the tier a TIERED_DISCOUNT promotion reached';
+COMMENT ON COLUMN public.promotion_applied.next_tier_min_quantity IS 'Units needed for the next tier, NULL at the top tier';
+COMMENT ON COLUMN public.promotion_applied.units_to_next_tier IS 'How many more units would have unlocked the next tier';
@@ -0,0 +1,4 @@
+ALTER TABLE promotion_applied DROP CONSTRAINT IF EXISTS promotion_applied_promotion_version_fkey;
+ALTER TABLE promotion_applied DROP COLUMN IF EXISTS promotion_version;
+DROP TABLE IF EXISTS promotion_versions;
+ALTER TABLE promotions DROP COLUMN IF EXISTS version;
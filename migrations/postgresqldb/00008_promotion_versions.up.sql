@@ -0,0 +1,32 @@
+ALTER TABLE promotions ADD COLUMN version int4 DEFAULT 1 NOT NULL;
+COMMENT ON COLUMN public.promotions.version IS 'Current version of the promotion terms; every full update adds a row to promotion_versions';
+
+CREATE TABLE promotion_versions (
+	promotion_id uuid NOT NULL,
+	version int4 NOT NULL,
+	"type" varchar(50) NOT NULL,
+	description text NOT NULL,
+	"rule" jsonb NOT NULL,
+	starts_at timestamptz NULL,
+	ends_at timestamptz NULL,
+	max_redemptions int4 NULL,
+	priority int4 DEFAULT 0 NOT NULL,
+	exclusive bool DEFAULT false NOT NULL,
+	stackable_with uuid[] DEFAULT '{}' NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT promotion_versions_pkey PRIMARY KEY (promotion_id, version),
+	CONSTRAINT promotion_versions_promotion_id_fkey FOREIGN KEY (promotion_id) REFERENCES promotions(id)
+);
+COMMENT ON TABLE public.promotion_versions IS 'Immutable snapshots of promotion terms; rows are only ever inserted';
+
+INSERT INTO promotion_versions (promotion_id, version, "type", description, "rule", starts_at, ends_at,
+                                max_redemptions, priority, exclusive, stackable_with, created_at)
+SELECT id, version, "type", description, "rule", starts_at, ends_at,
+       max_redemptions, priority, exclusive, stackable_with, COALESCE(updated_at, CURRENT_TIMESTAMP)
+FROM promotions;
+
+ALTER TABLE promotion_applied ADD COLUMN promotion_version int4 NULL;
+UPDATE promotion_applied SET promotion_version = 1;
+ALTER TABLE promotion_applied ADD CONSTRAINT promotion_applied_promotion_version_fkey
+	FOREIGN KEY (promotion_id, promotion_version) REFERENCES promotion_versions(promotion_id, version);
+COMMENT ON COLUMN public.promotion_applied.promotion_version IS 'Version of the promotion terms the checkout was priced with';
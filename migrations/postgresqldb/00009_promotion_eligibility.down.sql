@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS user_segments;
+ALTER TABLE promotion_versions DROP COLUMN IF EXISTS eligibility;
+ALTER TABLE promotions DROP COLUMN IF EXISTS eligibility;
@@ -0,0 +1,14 @@
+ALTER TABLE promotions ADD COLUMN eligibility jsonb DEFAULT '{}'::jsonb NOT NULL;
+COMMENT ON COLUMN public.promotions.eligibility IS 'Customer predicates that must all hold for the promotion to apply: first_order_only, roles, segments, min_lifetime_spend; empty applies to everyone';
+
+ALTER TABLE promotion_versions ADD COLUMN eligibility jsonb DEFAULT '{}'::jsonb NOT NULL;
+
+CREATE TABLE user_segments (
+	user_id uuid NOT NULL,
+	segment varchar(100) NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT user_segments_pkey PRIMARY KEY (user_id, segment),
+	CONSTRAINT user_segments_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_user_segments_segment ON public.user_segments USING btree (segment);
+COMMENT ON TABLE public.user_segments IS 'Named customer segments a user belongs to, used by promotion eligibility';
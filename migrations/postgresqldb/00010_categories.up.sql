@@ -0,0 +1,25 @@
+CREATE TABLE categories (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	parent_id uuid NULL, -- NULL for a top-level category
+	name varchar(255) NOT NULL,
+	slug varchar(100) NOT NULL, -- Lower-case letters, digits and hyphens; used in catalog URLs and filters
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT categories_pkey PRIMARY KEY (id),
+	CONSTRAINT categories_slug_key UNIQUE (slug),
+	CONSTRAINT categories_parent_check CHECK (parent_id IS NULL OR parent_id <> id),
+	CONSTRAINT categories_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES categories(id)
+);
+CREATE INDEX idx_categories_parent_id ON public.categories USING btree (parent_id);
+COMMENT ON TABLE public.categories IS 'Catalog category tree; a category can only be deleted once it has no children';
+
+CREATE TABLE product_categories (
+	product_id uuid NOT NULL,
+	category_id uuid NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT product_categories_pkey PRIMARY KEY (product_id, category_id),
+	CONSTRAINT product_categories_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	CONSTRAINT product_categories_category_id_fkey FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_product_categories_category_id ON public.product_categories USING btree (category_id);
+COMMENT ON TABLE public.product_categories IS 'Links products to the categories they are listed in';
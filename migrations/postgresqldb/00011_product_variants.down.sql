@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_products_variant_option_values;
+DROP INDEX IF EXISTS idx_products_parent_id;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_parent_id_fkey;
+ALTER TABLE products DROP COLUMN IF EXISTS price_override;
+ALTER TABLE products DROP COLUMN IF EXISTS option_values;
+ALTER TABLE products DROP COLUMN IF EXISTS options;
+ALTER TABLE products DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,11 @@
+ALTER TABLE products ADD COLUMN parent_id uuid NULL;
+ALTER TABLE products ADD COLUMN options jsonb DEFAULT '[]'::jsonb NOT NULL;
+ALTER TABLE products ADD COLUMN option_values jsonb DEFAULT '{}'::jsonb NOT NULL;
+ALTER TABLE products ADD COLUMN price_override numeric(10, 2) NULL;
+ALTER TABLE products ADD CONSTRAINT products_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES products(id);
+CREATE INDEX idx_products_parent_id ON public.products USING btree (parent_id);
+CREATE UNIQUE INDEX idx_products_variant_option_values ON public.products USING btree (parent_id, option_values) WHERE parent_id IS NOT NULL AND deleted_at IS NULL;
+COMMENT ON COLUMN public.products.parent_id IS 'Parent product of a variant; NULL for a standalone or parent product';
+COMMENT ON COLUMN public.products.options IS 'Option definitions of a parent product, e.g. [{"name":"size","values":["S","M"]}]; a parent is sold through its variants';
+COMMENT ON COLUMN public.products.option_values IS 'Value of every parent option for a variant, keyed by option name';
+COMMENT ON COLUMN public.products.price_override IS 'Price of a variant that does not follow its parent; price holds the effective price either way';
@@ -0,0 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products ADD COLUMN description text DEFAULT '' NOT NULL;
+ALTER TABLE products ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+	setweight(to_tsvector('simple'::regconfig, sku), 'A') ||
+	setweight(to_tsvector('english'::regconfig, name), 'A') ||
+	setweight(to_tsvector('english'::regconfig, description), 'B')
+) STORED;
+CREATE INDEX idx_products_search_vector ON public.products USING gin (search_vector);
+CREATE INDEX idx_products_name_trgm ON public.products USING gin (name gin_trgm_ops);
+COMMENT ON COLUMN public.products.description IS 'Long product description shown on the product page and searched with the name';
+COMMENT ON COLUMN public.products.search_vector IS 'Full-text document of the SKU and name (weight A) and description (weight B)';
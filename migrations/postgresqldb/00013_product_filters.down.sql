@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_checkout_items_product_id;
+DROP INDEX IF EXISTS idx_products_option_values;
+DROP INDEX IF EXISTS idx_products_created_at;
+DROP INDEX IF EXISTS idx_products_price;
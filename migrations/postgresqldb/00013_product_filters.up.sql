@@ -0,0 +1,4 @@
+CREATE INDEX idx_products_price ON public.products USING btree (price) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_created_at ON public.products USING btree (created_at) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_option_values ON public.products USING gin (option_values) WHERE parent_id IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX idx_checkout_items_product_id ON public.checkout_items USING btree (product_id);
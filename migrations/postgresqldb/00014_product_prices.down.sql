@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS product_price_at(uuid, timestamptz);
+DROP TABLE IF EXISTS product_prices;
@@ -0,0 +1,37 @@
+CREATE TABLE product_prices (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	product_id uuid NOT NULL,
+	price numeric(10, 2) NOT NULL,
+	effective_from timestamptz NOT NULL,
+	effective_to timestamptz NULL, -- NULL until a later price replaces it
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT product_prices_pkey PRIMARY KEY (id),
+	CONSTRAINT product_prices_period_check CHECK (effective_to IS NULL OR effective_to > effective_from),
+	CONSTRAINT product_prices_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_product_prices_product_id ON public.product_prices USING btree (product_id, effective_from);
+COMMENT ON TABLE public.product_prices IS 'Price history and scheduled prices of standalone and parent products; of the prices covering a moment, the one starting last is in effect';
+
+-- History starts with the current prices
+INSERT INTO product_prices (product_id, price, effective_from)
+SELECT id, price, NOW() FROM products WHERE parent_id IS NULL AND deleted_at IS NULL;
+
+-- product_price_at returns the price of a product in effect at a moment. A variant without a price
+-- override follows its parent's prices. Without a price covering the moment it falls back to the
+-- price column.
+CREATE FUNCTION product_price_at(target_id uuid, at timestamptz) RETURNS numeric(10, 2)
+LANGUAGE sql STABLE AS $$
+	SELECT COALESCE(
+		(
+			SELECT pp.price FROM product_prices pp
+			WHERE pp.product_id = CASE WHEN p.parent_id IS NOT NULL AND p.price_override IS NULL THEN p.parent_id ELSE p.id END
+				AND pp.effective_from <= at
+				AND (pp.effective_to IS NULL OR pp.effective_to > at)
+			ORDER BY pp.effective_from DESC, pp.created_at DESC
+			LIMIT 1
+		),
+		p.price
+	)
+	FROM products p
+	WHERE p.id = target_id
+$$;
@@ -0,0 +1,18 @@
+CREATE TABLE product_images (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	product_id uuid NOT NULL,
+	"position" int4 NOT NULL,
+	blob_key varchar(255) NOT NULL,
+	thumbnail_key varchar(255) NOT NULL,
+	content_type varchar(50) NOT NULL,
+	width int4 NOT NULL,
+	height int4 NOT NULL,
+	size_bytes int8 NOT NULL,
+	alt_text varchar(255) DEFAULT '' NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT product_images_pkey PRIMARY KEY (id),
+	CONSTRAINT product_images_position_check CHECK ("position" >= 0),
+	CONSTRAINT product_images_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_product_images_product_id ON public.product_images USING btree (product_id, "position");
+COMMENT ON TABLE public.product_images IS 'Images of standalone and parent products in display order; the files live in the blob store under blob_key and thumbnail_key';
@@ -0,0 +1,19 @@
+CREATE TABLE product_reviews (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	product_id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	rating int2 NOT NULL,
+	body text DEFAULT '' NOT NULL,
+	status varchar(20) DEFAULT 'PUBLISHED' NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT product_reviews_pkey PRIMARY KEY (id),
+	CONSTRAINT product_reviews_product_id_user_id_key UNIQUE (product_id, user_id),
+	CONSTRAINT product_reviews_rating_check CHECK (rating BETWEEN 1 AND 5),
+	CONSTRAINT product_reviews_status_check CHECK (status IN ('PUBLISHED', 'HIDDEN')),
+	CONSTRAINT product_reviews_product_id_fkey FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	CONSTRAINT product_reviews_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_product_reviews_product_id ON public.product_reviews USING btree (product_id, status, created_at DESC);
+CREATE INDEX idx_product_reviews_created_at ON public.product_reviews USING btree (created_at DESC, id DESC);
+COMMENT ON TABLE public.product_reviews IS 'Ratings and reviews of standalone and parent products, one per customer; only published reviews are shown and counted in the rating';
@@ -0,0 +1,3 @@
+ALTER TABLE promotion_applied DROP CONSTRAINT IF EXISTS promotion_applied_status_check;
+ALTER TABLE promotion_applied ADD CONSTRAINT promotion_applied_status_check CHECK (status IN ('APPLIED', 'SKIPPED', 'NOT_REACHED'));
+COMMENT ON COLUMN public.promotion_applied.status IS 'APPLIED promotions discounted the checkout; SKIPPED ones applied on their own but lost to a better combination; NOT_REACHED tiered promotions had fewer units than their first tier';
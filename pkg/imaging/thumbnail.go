@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Thumbnail scales an image down to fit within maxWidth by maxHeight, keeping its aspect ratio.
+// An image that already fits is copied at its own size, never enlarged.
+//
+// Every thumbnail pixel is the average of the source pixels it covers, which keeps fine detail
+// from aliasing the way sampling single pixels would. The source is converted one band of rows
+// at a time, so memory stays proportional to its width rather than its area.
+func Thumbnail(src image.Image, maxWidth, maxHeight int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	width, height := Fit(srcWidth, srcHeight, maxWidth, maxHeight)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == 0 || height == 0 {
+		return dst
+	}
+
+	// A destination row covers at most this many source rows
+	bandHeight := (srcHeight + height - 1) / height
+	band := image.NewRGBA(image.Rect(0, 0, srcWidth, bandHeight))
+
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * srcHeight / height
+		y1 := (dy + 1) * srcHeight / height
+		rows := y1 - y0
+
+		// Premultiplied alpha, so transparent pixels do not darken their neighbours
+		draw.Draw(band, image.Rect(0, 0, srcWidth, rows), src, image.Pt(bounds.Min.X, bounds.Min.Y+y0), draw.Src)
+
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * srcWidth / width
+			x1 := (dx + 1) * srcWidth / width
+
+			var r, g, b, a uint64
+			for y := 0; y < rows; y++ {
+				row := band.Pix[y*band.Stride+x0*4 : y*band.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r += uint64(row[i])
+					g += uint64(row[i+1])
+					b += uint64(row[i+2])
+					a += uint64(row[i+3])
+				}
+			}
+
+			n := uint64(rows * (x1 - x0))
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8((r + n/2) / n)
+			dst.Pix[i+1] = uint8((g + n/2) / n)
+			dst.Pix[i+2] = uint8((b + n/2) / n)
+			dst.Pix[i+3] = uint8((a + n/2) / n)
+		}
+	}
+
+	return dst
+}
+
+// Fit returns the largest size with the aspect ratio of width by height that fits within
+// maxWidth by maxHeight, or the size itself when it already fits. Neither side is rounded down
+// to zero; an empty size or limit gives zero by zero.
+func Fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= 0 || height <= 0 || maxWidth <= 0 || maxHeight <= 0 {
+		return 0, 0
+	}
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+
+	// Scale by the tighter of the two limits
+	if width*maxHeight > height*maxWidth {
+		return maxWidth, max(1, height*maxWidth/width)
+	}
+	return max(1, width*maxHeight/height), maxHeight
+}
@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height         int
+		maxWidth, maxHeight   int
+		wantWidth, wantHeight int
+	}{
+		{name: "exact fit", width: 400, height: 300, maxWidth: 400, maxHeight: 300, wantWidth: 400, wantHeight: 300},
+		{name: "smaller is kept", width: 200, height: 100, maxWidth: 400, maxHeight: 300, wantWidth: 200, wantHeight: 100},
+		{name: "wide image", width: 1600, height: 900, maxWidth: 400, maxHeight: 400, wantWidth: 400, wantHeight: 225},
+		{name: "tall image", width: 900, height: 1600, maxWidth: 400, maxHeight: 400, wantWidth: 225, wantHeight: 400},
+		{name: "same aspect ratio", width: 800, height: 600, maxWidth: 400, maxHeight: 300, wantWidth: 400, wantHeight: 300},
+		{name: "height limits a wide box", width: 1000, height: 1000, maxWidth: 800, maxHeight: 200, wantWidth: 200, wantHeight: 200},
+		{name: "rounds down", width: 1000, height: 333, maxWidth: 100, maxHeight: 100, wantWidth: 100, wantHeight: 33},
+		{name: "one pixel wide", width: 1, height: 5000, maxWidth: 400, maxHeight: 400, wantWidth: 1, wantHeight: 400},
+		{name: "one pixel high", width: 5000, height: 1, maxWidth: 400, maxHeight: 400, wantWidth: 400, wantHeight: 1},
+		{name: "one pixel", width: 1, height: 1, maxWidth: 400, maxHeight: 400, wantWidth: 1, wantHeight: 1},
+		{name: "thin side is not rounded to zero", width: 3000, height: 2, maxWidth: 300, maxHeight: 300, wantWidth: 300, wantHeight: 1},
+		{name: "zero width", width: 0, height: 100, maxWidth: 400, maxHeight: 400},
+		{name: "zero height", width: 100, height: 0, maxWidth: 400, maxHeight: 400},
+		{name: "negative size", width: -10, height: 100, maxWidth: 400, maxHeight: 400},
+		{name: "zero limit", width: 100, height: 100, maxWidth: 0, maxHeight: 400},
+		{name: "zero limits", width: 100, height: 100, maxWidth: 0, maxHeight: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := Fit(tt.width, tt.height, tt.maxWidth, tt.maxHeight)
+			if width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("Fit(%d, %d, %d, %d) = %dx%d, want %dx%d",
+					tt.width, tt.height, tt.maxWidth, tt.maxHeight, width, height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// A checkerboard of black and white pixels averages to grey
+	checker := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if (x+y)%2 == 0 {
+				checker.Set(x, y, color.White)
+			} else {
+				checker.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	thumb := Thumbnail(checker, 2, 2)
+	if got := thumb.Bounds(); got != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("thumbnail bounds = %v, want 2x2", got)
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if got, want := thumb.RGBAAt(x, y), (color.RGBA{128, 128, 128, 255}); got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestThumbnailKeepsSmallImages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(2, 1, color.RGBA{10, 20, 30, 255})
+
+	thumb := Thumbnail(src, 10, 10)
+	if got := thumb.Bounds(); got != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("thumbnail bounds = %v, want 3x2", got)
+	}
+	if got, want := thumb.RGBAAt(2, 1), (color.RGBA{10, 20, 30, 255}); got != want {
+		t.Errorf("pixel (2, 1) = %v, want %v", got, want)
+	}
+}
+
+func TestThumbnailOfOnePixelWideImage(t *testing.T) {
+	// A 1x8 column, red at the top half and blue below
+	src := image.NewRGBA(image.Rect(0, 0, 1, 8))
+	for y := 0; y < 8; y++ {
+		c := color.RGBA{255, 0, 0, 255}
+		if y >= 4 {
+			c = color.RGBA{0, 0, 255, 255}
+		}
+		src.Set(0, y, c)
+	}
+
+	thumb := Thumbnail(src, 4, 2)
+	if got := thumb.Bounds(); got != image.Rect(0, 0, 1, 2) {
+		t.Fatalf("thumbnail bounds = %v, want 1x2", got)
+	}
+	if got, want := thumb.RGBAAt(0, 0), (color.RGBA{255, 0, 0, 255}); got != want {
+		t.Errorf("top pixel = %v, want %v", got, want)
+	}
+	if got, want := thumb.RGBAAt(0, 1), (color.RGBA{0, 0, 255, 255}); got != want {
+		t.Errorf("bottom pixel = %v, want %v", got, want)
+	}
+}
+
+func TestThumbnailOfSubImage(t *testing.T) {
+	// The source does not start at the origin; only its own pixels count
+	canvas := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			canvas.Set(x, y, color.Black)
+		}
+	}
+	for y := 4; y < 8; y++ {
+		for x := 4; x < 8; x++ {
+			canvas.Set(x, y, color.White)
+		}
+	}
+
+	thumb := Thumbnail(canvas.SubImage(image.Rect(4, 4, 8, 8)), 1, 1)
+	if got, want := thumb.RGBAAt(0, 0), (color.RGBA{255, 255, 255, 255}); got != want {
+		t.Errorf("pixel = %v, want %v", got, want)
+	}
+}
+
+func TestThumbnailOfEmptyImage(t *testing.T) {
+	if got := Thumbnail(image.NewRGBA(image.Rect(0, 0, 0, 0)), 10, 10).Bounds(); !got.Empty() {
+		t.Errorf("thumbnail of an empty image = %v, want empty", got)
+	}
+	if got := Thumbnail(image.NewRGBA(image.Rect(0, 0, 4, 4)), 0, 0).Bounds(); !got.Empty() {
+		t.Errorf("thumbnail within zero by zero = %v, want empty", got)
+	}
+}
@@ -0,0 +1,390 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency used when none is stored alongside an amount.
+// Prices in the database are numeric(10,2) columns without a currency column.
+const DefaultCurrency = "USD"
+
+// minorUnits is the number of minor units (cents) per major unit
+const minorUnits = 100
+
+// Money is an exact monetary amount held in minor units (e.g. cents) of a currency.
+//
+// Rounding rules:
+//   - Parsing and FromFloat round half away from zero to the nearest minor unit.
+//   - Percent rounds half away from zero once, on the final minor-unit result.
+//   - Allocate splits an amount by weights using the largest-remainder method,
+//     so the parts always add up exactly to the original amount.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New creates a Money value from minor units in the given currency
+func New(amount int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// FromMinor creates a Money value from minor units in the default currency
+func FromMinor(amount int64) Money {
+	return New(amount, DefaultCurrency)
+}
+
+// FromFloat converts a decimal amount in major units to Money in the default currency.
+// It is meant for values arriving over the wire; internal arithmetic should stay in Money.
+func FromFloat(amount float64) Money {
+	return FromMinor(int64(math.Round(amount * minorUnits)))
+}
+
+// Zero returns a zero amount in the default currency
+func Zero() Money {
+	return FromMinor(0)
+}
+
+// maxExponent bounds the exponent accepted by Parse; amounts beyond it overflow or round to zero
+const maxExponent = 20
+
+// Parse parses a decimal string such as "12.34" into Money in the default currency.
+// Exponent forms such as "1e2" or "1.5E-1", which JSON numbers may use, are accepted too.
+func Parse(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Money{}, fmt.Errorf("money: empty amount")
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	whole, frac, err := splitDecimal(value)
+	if err != nil {
+		return Money{}, err
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("money: invalid amount %q: %w", value, err)
+	}
+
+	var minor int64
+	if frac != "" {
+		// Keep two digits and round half away from zero on the third
+		padded := frac + "00"
+		minor, _ = strconv.ParseInt(padded[:2], 10, 64)
+		if padded[2] >= '5' {
+			minor++
+		}
+	}
+
+	amount := major*minorUnits + minor
+	if negative {
+		amount = -amount
+	}
+
+	return FromMinor(amount), nil
+}
+
+// splitDecimal splits an unsigned decimal, optionally with an exponent, into the digits before and
+// after the decimal point
+func splitDecimal(value string) (string, string, error) {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(value), "e")
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return "", "", fmt.Errorf("money: invalid amount %q", value)
+	}
+	if !hasExponent {
+		return whole, frac, nil
+	}
+
+	shift, err := strconv.Atoi(exponent)
+	if err != nil || shift > maxExponent || shift < -maxExponent {
+		return "", "", fmt.Errorf("money: invalid amount %q", value)
+	}
+
+	// Move the decimal point by the exponent, padding with zeros where it runs past the digits
+	digits := whole + frac
+	point := len(whole) + shift
+	if point < 0 {
+		digits = strings.Repeat("0", -point) + digits
+		point = 0
+	}
+	if point > len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	return digits[:point], digits[point:], nil
+}
+
+// isDigits reports whether s holds only ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) Money {
+	currency := m.mustMatch(other)
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) Money {
+	currency := m.mustMatch(other)
+	return Money{Amount: m.Amount - other.Amount, Currency: currency}
+}
+
+// Mul returns m multiplied by a quantity
+func (m Money) Mul(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.currency()}
+}
+
+// Div returns m divided by n, rounded half away from zero to the nearest minor
+// unit. Dividing by zero returns zero.
+func (m Money) Div(n int) Money {
+	if n == 0 {
+		return Money{Currency: m.currency()}
+	}
+	return Money{Amount: divRound(m.Amount, int64(n)), Currency: m.currency()}
+}
+
+// Percent returns the given percentage of m, e.g. Percent(10) is 10% of m.
+// The percentage is taken to two decimal places (basis points) and the result
+// is rounded half away from zero to the nearest minor unit.
+func (m Money) Percent(percentage float64) Money {
+	basisPoints := int64(math.Round(percentage * 100))
+	return Money{Amount: divRound(m.Amount*basisPoints, 100*100), Currency: m.currency()}
+}
+
+// Allocate splits m into parts proportional to the given weights using the
+// largest-remainder method. The parts always sum to m exactly.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+
+	var totalWeight int64
+	for _, w := range weights {
+		if w > 0 {
+			totalWeight += w
+		}
+	}
+
+	currency := m.currency()
+	for i := range parts {
+		parts[i] = Money{Currency: currency}
+	}
+	if totalWeight == 0 || len(weights) == 0 {
+		return parts
+	}
+
+	remainders := make([]int64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		parts[i].Amount = m.Amount * w / totalWeight
+		remainders[i] = m.Amount * w % totalWeight
+		allocated += parts[i].Amount
+	}
+
+	// Hand out the leftover minor units one at a time to the largest remainders,
+	// preferring earlier lines on ties so the result is deterministic
+	leftover := m.Amount - allocated
+	step := int64(1)
+	if leftover < 0 {
+		step = -1
+		leftover = -leftover
+	}
+	for ; leftover > 0; leftover-- {
+		best := -1
+		for i, w := range weights {
+			if w <= 0 {
+				continue
+			}
+			if best == -1 || abs(remainders[i]) > abs(remainders[best]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			break
+		}
+		parts[best].Amount += step
+		remainders[best] = 0
+	}
+
+	return parts
+}
+
+// Min returns the smaller of m and other
+func (m Money) Min(other Money) Money {
+	m.mustMatch(other)
+	if other.Amount < m.Amount {
+		return other
+	}
+	return m
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative reports whether the amount is less than zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// GreaterThan reports whether m is greater than other
+func (m Money) GreaterThan(other Money) bool {
+	m.mustMatch(other)
+	return m.Amount > other.Amount
+}
+
+// LessThan reports whether m is less than other
+func (m Money) LessThan(other Money) bool {
+	m.mustMatch(other)
+	return m.Amount < other.Amount
+}
+
+// Float64 returns the amount in major units. Use it only at the edges
+// (HTTP responses, logging), never for further arithmetic.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / minorUnits
+}
+
+// String formats the amount as a decimal string in major units, e.g. "12.34"
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorUnits, amount%minorUnits)
+}
+
+// MarshalJSON encodes the amount as a JSON number in major units, e.g. 12.34
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or string in major units
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw json.Number
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("money: invalid JSON amount %s", string(data))
+		}
+		raw = json.Number(s)
+	}
+
+	parsed, err := Parse(raw.String())
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for numeric columns
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Zero()
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = FromMinor(v * minorUnits)
+		return nil
+	case float64:
+		*m = FromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+}
+
+// Value implements driver.Valuer, storing the amount as a decimal string
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// currency returns the currency, falling back to the default for zero values
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// mustMatch returns the shared currency of m and other. Mixing currencies is a
+// programming error, so it panics rather than silently producing a wrong total.
+func (m Money) mustMatch(other Money) string {
+	a, b := m.currency(), other.currency()
+	if a != b {
+		panic(fmt.Sprintf("money: currency mismatch %s != %s", a, b))
+	}
+	return a
+}
+
+// divRound divides a by b rounding half away from zero
+func divRound(a, b int64) int64 {
+	q := a / b
+	r := a % b
+	if abs(r)*2 >= abs(b) {
+		if (a < 0) != (b < 0) {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// abs returns the absolute value of an int64
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
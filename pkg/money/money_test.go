@@ -0,0 +1,234 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "12.34", want: 1234},
+		{in: "12.344", want: 1234},
+		{in: "12.345", want: 1235},
+		{in: "-12.345", want: -1235},
+		{in: "  7  ", want: 700},
+		{in: "+1", want: 100},
+		{in: ".5", want: 50},
+		{in: "5.", want: 500},
+		{in: "0.005", want: 1},
+		{in: "-0.005", want: -1},
+		{in: "0.004", want: 0},
+		{in: "1e2", want: 10000},
+		{in: "1.5E-1", want: 15},
+		{in: "-2.5e1", want: -2500},
+		{in: "1.2345e2", want: 12345},
+		{in: "5e-3", want: 1},
+		{in: "1e-3", want: 0},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "--5", wantErr: true},
+		{in: "-+5", wantErr: true},
+		{in: "1e", wantErr: true},
+		{in: "e5", wantErr: true},
+		{in: "1e99", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.in, err)
+			continue
+		}
+		if got.Amount != tt.want || got.Currency != DefaultCurrency {
+			t.Errorf("Parse(%q) = %d %s, want %d %s", tt.in, got.Amount, got.Currency, tt.want, DefaultCurrency)
+		}
+	}
+}
+
+func TestUnmarshalJSONExponent(t *testing.T) {
+	var got struct {
+		Price Money `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 1.999e1}`), &got); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if got.Price.Amount != 1999 {
+		t.Errorf("Unmarshal = %d, want 1999", got.Price.Amount)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     int64
+		percentage float64
+		want       int64
+	}{
+		{name: "exact", amount: 10000, percentage: 10, want: 1000},
+		{name: "rounds down", amount: 1994, percentage: 10, want: 199},
+		{name: "half rounds away from zero", amount: 1995, percentage: 10, want: 200},
+		{name: "negative half rounds away from zero", amount: -1995, percentage: 10, want: -200},
+		{name: "fractional percentage", amount: 1000, percentage: 12.5, want: 125},
+		{name: "basis points", amount: 333, percentage: 33.33, want: 111},
+		{name: "zero percent", amount: 1234, percentage: 0, want: 0},
+		{name: "hundred percent", amount: 1234, percentage: 100, want: 1234},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromMinor(tt.amount).Percent(tt.percentage); got.Amount != tt.want {
+				t.Errorf("Percent(%v) of %d = %d, want %d", tt.percentage, tt.amount, got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+// TestBulkDiscountDoesNotDrift covers the 10% BULK_DISCOUNT case that drifted by a cent with
+// float64: the discount is rounded once and the discounted total adds back up to the subtotal
+func TestBulkDiscountDoesNotDrift(t *testing.T) {
+	tests := []struct {
+		price    string
+		quantity int
+		discount string
+		total    string
+	}{
+		{price: "19.99", quantity: 3, discount: "6.00", total: "53.97"},
+		{price: "10.05", quantity: 1, discount: "1.01", total: "9.04"},
+		{price: "0.35", quantity: 3, discount: "0.11", total: "0.94"},
+		{price: "33.33", quantity: 7, discount: "23.33", total: "209.98"},
+	}
+
+	for _, tt := range tests {
+		price, err := Parse(tt.price)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.price, err)
+		}
+
+		subtotal := price.Mul(tt.quantity)
+		discount := subtotal.Percent(10)
+		total := subtotal.Sub(discount)
+
+		if discount.String() != tt.discount || total.String() != tt.total {
+			t.Errorf("10%% of %d x %s = %s off, %s total; want %s off, %s total",
+				tt.quantity, tt.price, discount, total, tt.discount, tt.total)
+		}
+		if !total.Add(discount).Sub(subtotal).IsZero() {
+			t.Errorf("discount %s and total %s do not add up to %s", discount, total, subtotal)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{name: "even split", amount: 90, weights: []int64{1, 1, 1}, want: []int64{30, 30, 30}},
+		{name: "leftover goes to earlier line on ties", amount: 100, weights: []int64{1, 1, 1}, want: []int64{34, 33, 33}},
+		{name: "leftover goes to largest remainder", amount: 1000, weights: []int64{1, 2}, want: []int64{333, 667}},
+		{name: "proportional to line totals", amount: 600, weights: []int64{5997, 1005}, want: []int64{514, 86}},
+		{name: "negative amount", amount: -100, weights: []int64{1, 1, 1}, want: []int64{-34, -33, -33}},
+		{name: "zero and negative weights get nothing", amount: 100, weights: []int64{0, 3, -1, 1}, want: []int64{0, 75, 0, 25}},
+		{name: "no weight", amount: 100, weights: []int64{0, 0}, want: []int64{0, 0}},
+		{name: "no lines", amount: 100, weights: nil, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := FromMinor(tt.amount).Allocate(tt.weights)
+			if len(parts) != len(tt.want) {
+				t.Fatalf("Allocate returned %d parts, want %d", len(parts), len(tt.want))
+			}
+
+			var sum int64
+			for i, part := range parts {
+				if part.Amount != tt.want[i] {
+					t.Errorf("part %d = %d, want %d", i, part.Amount, tt.want[i])
+				}
+				sum += part.Amount
+			}
+
+			var totalWeight int64
+			for _, w := range tt.weights {
+				if w > 0 {
+					totalWeight += w
+				}
+			}
+			if totalWeight > 0 && sum != tt.amount {
+				t.Errorf("parts add up to %d, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestDivRound(t *testing.T) {
+	tests := []struct {
+		a, b, want int64
+	}{
+		{a: 6, b: 3, want: 2},
+		{a: 4, b: 3, want: 1},
+		{a: 5, b: 3, want: 2},
+		{a: 7, b: 2, want: 4},
+		{a: -7, b: 2, want: -4},
+		{a: 7, b: -2, want: -4},
+		{a: -7, b: -2, want: 4},
+		{a: -4, b: 3, want: -1},
+		{a: -5, b: 3, want: -2},
+		{a: 0, b: 5, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := divRound(tt.a, tt.b); got != tt.want {
+			t.Errorf("divRound(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		amount int64
+		n      int
+		want   int64
+	}{
+		{amount: 1000, n: 3, want: 333},
+		{amount: 200, n: 3, want: 67},
+		{amount: -200, n: 3, want: -67},
+		{amount: 5, n: 2, want: 3},
+		{amount: -5, n: 2, want: -3},
+		{amount: 1234, n: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := FromMinor(tt.amount).Div(tt.n); got.Amount != tt.want {
+			t.Errorf("Div(%d) of %d = %d, want %d", tt.n, tt.amount, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMinCurrencyMismatch(t *testing.T) {
+	if got := FromMinor(500).Min(FromMinor(300)); got.Amount != 300 {
+		t.Errorf("Min = %d, want 300", got.Amount)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Min of different currencies did not panic")
+		}
+	}()
+	New(500, "USD").Min(New(300, "EUR"))
+}
@@ -0,0 +1,306 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultLimit is the page size used when none is requested
+const DefaultLimit = 10
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the listing it is used with
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// signatureSize is the number of bytes of the HMAC-SHA256 kept in a cursor
+const signatureSize = 16
+
+// signingKey authenticates the cursors handed to clients. It is random until SetSigningKey is
+// called, so cursors then only work against the process that issued them.
+var signingKey = randomKey()
+
+// SetSigningKey sets the key cursors are signed with. Instances serving the same clients must
+// share it. It is meant to be called once at start-up, before any cursor is issued.
+func SetSigningKey(key []byte) {
+	signingKey = append([]byte(nil), key...)
+}
+
+// randomKey returns a fresh signing key
+func randomKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("pagination: generating cursor signing key: %v", err))
+	}
+	return key
+}
+
+// Cursor is a position in an ordered listing: the sort key values of a row. A forward cursor
+// selects the rows after that row, a backward one the rows before it. Clients receive cursors as
+// opaque strings signed by the server, so the key values they carry can be used in queries as they
+// are.
+type Cursor struct {
+	// Order names the keyset the cursor was issued for
+	Order string `json:"o"`
+
+	// Keys are the key values of the row as text; nil stands for NULL
+	Keys     []*string `json:"k"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+// Encode returns the opaque, signed form of the cursor
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(payload))
+}
+
+// DecodeCursor parses the opaque form of a cursor, rejecting cursors whose signature does not
+// match their content
+func DecodeCursor(encoded string) (*Cursor, error) {
+	payload, signature, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || len(cursor.Keys) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// sign returns the signature of the encoded content of a cursor
+func sign(payload string) []byte {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)[:signatureSize]
+}
+
+// Request selects a page of a listing, by cursor when one is set and by page number otherwise
+type Request struct {
+	Page   int
+	Limit  int
+	Cursor *Cursor
+
+	// IncludeTotal asks for the number of rows in the whole listing, which costs a count query
+	IncludeTotal bool
+}
+
+// NewRequest builds a request from query parameters. An empty cursor selects page-number mode.
+// Without includeTotal the total is counted in page-number mode only.
+func NewRequest(page, limit int, cursor string, includeTotal *bool) (Request, error) {
+	req := Request{
+		Page:         page,
+		Limit:        limit,
+		IncludeTotal: cursor == "",
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.Limit < 1 {
+		req.Limit = DefaultLimit
+	}
+	if includeTotal != nil {
+		req.IncludeTotal = *includeTotal
+	}
+
+	if cursor != "" {
+		decoded, err := DecodeCursor(cursor)
+		if err != nil {
+			return Request{}, err
+		}
+		req.Cursor = decoded
+	}
+	return req, nil
+}
+
+// Offset is the number of rows skipped in page-number mode; cursors skip rows by their keys
+func (r Request) Offset() int {
+	if r.Cursor != nil {
+		return 0
+	}
+	return (r.Page - 1) * r.Limit
+}
+
+// FetchLimit is the number of rows to fetch: one more than the page, to tell whether there is
+// another page
+func (r Request) FetchLimit() int {
+	return r.Limit + 1
+}
+
+// Result describes the page returned for a request. Cursors are nil when there is no page in
+// their direction, and Total is nil unless it was requested.
+type Result struct {
+	Total      *int
+	NextCursor *string
+	PrevCursor *string
+}
+
+// Column is a sort key of a keyset
+type Column struct {
+	// Expr is the SQL expression of the key; it is compared with cursor values and cast to text
+	// to build cursors. It may be NULL, which sorts after every value as in Postgres.
+	Expr string
+	Desc bool
+}
+
+// Keyset is the ordering of a listing. Its columns together must be unique, e.g. a timestamp
+// followed by the ID, so every row has a distinct position.
+type Keyset struct {
+	Name    string
+	Columns []Column
+}
+
+// Select returns the select list of the key columns as text, to be scanned with ScanKeys
+func (k Keyset) Select() string {
+	exprs := make([]string, len(k.Columns))
+	for i, column := range k.Columns {
+		exprs[i] = "(" + column.Expr + ")::text"
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// ScanKeys returns scan destinations for the columns of Select and the key values they fill; a
+// NULL key is left nil
+func (k Keyset) ScanKeys() ([]interface{}, []*string) {
+	keys := make([]*string, len(k.Columns))
+	dest := make([]interface{}, len(keys))
+	for i := range keys {
+		dest[i] = &keys[i]
+	}
+	return dest, keys
+}
+
+// Where returns the condition selecting the rows beyond the request's cursor, or an empty string
+// in page-number mode; arg adds a query argument and returns its placeholder
+func (k Keyset) Where(req Request, arg func(interface{}) string) (string, error) {
+	cursor := req.Cursor
+	if cursor == nil {
+		return "", nil
+	}
+	if cursor.Order != k.Name || len(cursor.Keys) != len(k.Columns) {
+		return "", ErrInvalidCursor
+	}
+
+	placeholders := make([]string, len(cursor.Keys))
+	for i, key := range cursor.Keys {
+		if key != nil {
+			placeholders[i] = arg(*key)
+		}
+	}
+
+	// (a > x) OR (a = x AND b > y) OR ..., with the comparison of each key following its
+	// direction. NULL sorts after every value in ascending order and before them in descending
+	// order, so it needs IS NULL tests of its own.
+	alternatives := make([]string, 0, len(k.Columns))
+	for i, column := range k.Columns {
+		beyond := beyondKey(column.Expr, placeholders[i], cursor.Keys[i] == nil, column.Desc == cursor.Backward)
+		if beyond == "" {
+			continue
+		}
+
+		terms := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			if cursor.Keys[j] == nil {
+				terms = append(terms, fmt.Sprintf("(%s) IS NULL", k.Columns[j].Expr))
+			} else {
+				terms = append(terms, fmt.Sprintf("(%s) = %s", k.Columns[j].Expr, placeholders[j]))
+			}
+		}
+		terms = append(terms, beyond)
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	if len(alternatives) == 0 {
+		return "FALSE", nil
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", nil
+}
+
+// beyondKey returns the condition selecting the values of a key that come after the cursor's value
+// in the order rows are fetched in, or an empty string when no value does
+func beyondKey(expr, placeholder string, null, ascending bool) string {
+	switch {
+	case ascending && null:
+		return ""
+	case ascending:
+		return fmt.Sprintf("((%s) > %s OR (%s) IS NULL)", expr, placeholder, expr)
+	case null:
+		return fmt.Sprintf("(%s) IS NOT NULL", expr)
+	default:
+		return fmt.Sprintf("(%s) < %s", expr, placeholder)
+	}
+}
+
+// OrderBy returns the ORDER BY expressions to fetch the rows of a request in; a backward cursor
+// fetches in reverse and Page restores the order
+func (k Keyset) OrderBy(req Request) string {
+	backward := req.Cursor != nil && req.Cursor.Backward
+	exprs := make([]string, len(k.Columns))
+	for i, column := range k.Columns {
+		direction := "ASC"
+		if column.Desc != backward {
+			direction = "DESC"
+		}
+		exprs[i] = column.Expr + " " + direction
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// Page trims the rows fetched for a request, restores the listing order and builds the cursors
+// of the page. keys holds the key values of every row, as scanned with ScanKeys; total is
+// reported when the request asked for it.
+func Page[T any](rows []T, keys [][]*string, req Request, keyset Keyset, total int) ([]T, Result) {
+	hasMore := len(rows) > req.Limit
+	if hasMore {
+		rows = rows[:req.Limit]
+		keys = keys[:req.Limit]
+	}
+
+	backward := req.Cursor != nil && req.Cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	result := Result{}
+	if req.IncludeTotal {
+		result.Total = &total
+	}
+	if len(rows) == 0 {
+		return rows, result
+	}
+
+	// A backward cursor was issued from a row after this page, and a forward one from a row
+	// before it
+	hasNext := hasMore
+	hasPrev := req.Page > 1
+	if req.Cursor != nil {
+		hasNext = hasMore || backward
+		hasPrev = hasMore || !backward
+	}
+
+	if hasNext {
+		next := Cursor{Order: keyset.Name, Keys: keys[len(keys)-1]}.Encode()
+		result.NextCursor = &next
+	}
+	if hasPrev {
+		prev := Cursor{Order: keyset.Name, Keys: keys[0], Backward: true}.Encode()
+		result.PrevCursor = &prev
+	}
+	return rows, result
+}
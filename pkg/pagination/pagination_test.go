@@ -0,0 +1,398 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func key(s string) *string {
+	return &s
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{Order: "newest", Keys: []*string{key("2024-01-02 03:04:05+00"), nil, key("a.b")}, Backward: true}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor returned error: %v", err)
+	}
+	if !reflect.DeepEqual(*decoded, cursor) {
+		t.Errorf("DecodeCursor = %+v, want %+v", *decoded, cursor)
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	encoded := Cursor{Order: "newest", Keys: []*string{key("2024-01-02"), key("42")}}.Encode()
+	payload, signature, _ := strings.Cut(encoded, ".")
+
+	// The same signature over other key values, such as a value that does not fit the column
+	forged := Cursor{Order: "newest", Keys: []*string{key("not a date"), key("42")}}.Encode()
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "changed keys", encoded: forgedPayload + "." + signature},
+		{name: "changed signature", encoded: payload + "." + strings.Repeat("A", len(signature))},
+		{name: "no signature", encoded: payload},
+		{name: "empty signature", encoded: payload + "."},
+		{name: "signature not base64", encoded: payload + ".!!"},
+		{name: "garbage", encoded: "not-a-cursor"},
+		{name: "empty", encoded: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.encoded); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", tt.encoded, err)
+			}
+		})
+	}
+}
+
+func TestSetSigningKey(t *testing.T) {
+	previous := signingKey
+	defer func() { signingKey = previous }()
+
+	SetSigningKey([]byte("first"))
+	encoded := Cursor{Order: "newest", Keys: []*string{key("1")}}.Encode()
+	if _, err := DecodeCursor(encoded); err != nil {
+		t.Fatalf("DecodeCursor with the signing key returned error: %v", err)
+	}
+
+	SetSigningKey([]byte("second"))
+	if _, err := DecodeCursor(encoded); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("DecodeCursor with another key error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	req, err := NewRequest(0, 0, "", nil)
+	if err != nil {
+		t.Fatalf("NewRequest returned error: %v", err)
+	}
+	if req.Page != 1 || req.Limit != DefaultLimit || req.Cursor != nil || !req.IncludeTotal {
+		t.Errorf("NewRequest without parameters = %+v", req)
+	}
+
+	cursor := Cursor{Order: "newest", Keys: []*string{key("1")}}.Encode()
+	req, err = NewRequest(3, 5, cursor, nil)
+	if err != nil {
+		t.Fatalf("NewRequest returned error: %v", err)
+	}
+	if req.Cursor == nil || req.IncludeTotal || req.Offset() != 0 || req.FetchLimit() != 6 {
+		t.Errorf("NewRequest with a cursor = %+v", req)
+	}
+
+	if _, err := NewRequest(1, 5, cursor+"x", nil); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("NewRequest with an altered cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestKeysetWhere(t *testing.T) {
+	keyset := Keyset{
+		Name: "newest",
+		Columns: []Column{
+			{Expr: "t.created_at", Desc: true},
+			{Expr: "t.id"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		cursor   *Cursor
+		want     string
+		wantArgs []interface{}
+		wantErr  error
+	}{
+		{
+			name: "page-number mode",
+		},
+		{
+			name:     "forward",
+			cursor:   &Cursor{Order: "newest", Keys: []*string{key("2024"), key("7")}},
+			want:     "(((t.created_at) < $1) OR ((t.created_at) = $1 AND ((t.id) > $2 OR (t.id) IS NULL)))",
+			wantArgs: []interface{}{"2024", "7"},
+		},
+		{
+			name:     "backward",
+			cursor:   &Cursor{Order: "newest", Keys: []*string{key("2024"), key("7")}, Backward: true},
+			want:     "((((t.created_at) > $1 OR (t.created_at) IS NULL)) OR ((t.created_at) = $1 AND (t.id) < $2))",
+			wantArgs: []interface{}{"2024", "7"},
+		},
+		{
+			name:     "forward from a NULL key",
+			cursor:   &Cursor{Order: "newest", Keys: []*string{nil, key("7")}},
+			want:     "(((t.created_at) IS NOT NULL) OR ((t.created_at) IS NULL AND ((t.id) > $1 OR (t.id) IS NULL)))",
+			wantArgs: []interface{}{"7"},
+		},
+		{
+			name:     "backward from a NULL key",
+			cursor:   &Cursor{Order: "newest", Keys: []*string{nil, key("7")}, Backward: true},
+			want:     "(((t.created_at) IS NULL AND (t.id) < $1))",
+			wantArgs: []interface{}{"7"},
+		},
+		{
+			name:   "backward from NULL keys",
+			cursor: &Cursor{Order: "newest", Keys: []*string{nil, nil}, Backward: true},
+			want:   "(((t.created_at) IS NULL AND (t.id) IS NOT NULL))",
+		},
+		{
+			name:    "cursor of another sort",
+			cursor:  &Cursor{Order: "name_asc", Keys: []*string{key("2024"), key("7")}},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:    "cursor with other keys",
+			cursor:  &Cursor{Order: "newest", Keys: []*string{key("2024")}},
+			wantErr: ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []interface{}
+			arg := func(value interface{}) string {
+				args = append(args, value)
+				return fmt.Sprintf("$%d", len(args))
+			}
+
+			got, err := keyset.Where(Request{Limit: 10, Cursor: tt.cursor}, arg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Where error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Where = %s\nwant    %s", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Where args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestKeysetWhereNothingBeyond(t *testing.T) {
+	keyset := Keyset{Name: "oldest", Columns: []Column{{Expr: "t.created_at"}}}
+	req := Request{Limit: 10, Cursor: &Cursor{Order: "oldest", Keys: []*string{nil}}}
+
+	got, err := keyset.Where(req, func(interface{}) string { return "$1" })
+	if err != nil {
+		t.Fatalf("Where returned error: %v", err)
+	}
+	if got != "FALSE" {
+		t.Errorf("Where after the last NULL key = %s, want FALSE", got)
+	}
+}
+
+func TestKeysetOrderBy(t *testing.T) {
+	keyset := Keyset{Name: "newest", Columns: []Column{{Expr: "t.created_at", Desc: true}, {Expr: "t.id"}}}
+
+	if got, want := keyset.OrderBy(Request{Limit: 10}), "t.created_at DESC, t.id ASC"; got != want {
+		t.Errorf("OrderBy forward = %s, want %s", got, want)
+	}
+	backward := Request{Limit: 10, Cursor: &Cursor{Order: "newest", Backward: true}}
+	if got, want := keyset.OrderBy(backward), "t.created_at ASC, t.id DESC"; got != want {
+		t.Errorf("OrderBy backward = %s, want %s", got, want)
+	}
+}
+
+// pageRow is a row of the listing used to test Page: ordered by score, highest first with NULL
+// scores before every other as in Postgres, then by ID
+type pageRow struct {
+	id    int
+	score *int
+}
+
+var pageKeyset = Keyset{Name: "score", Columns: []Column{{Expr: "score", Desc: true}, {Expr: "id"}}}
+
+func (r pageRow) keys() []*string {
+	keys := []*string{nil, key(strconv.Itoa(r.id))}
+	if r.score != nil {
+		keys[0] = key(strconv.Itoa(*r.score))
+	}
+	return keys
+}
+
+// before reports whether r comes before other in the listing order
+func (r pageRow) before(other pageRow) bool {
+	switch {
+	case r.score == nil && other.score != nil:
+		return true
+	case r.score != nil && other.score == nil:
+		return false
+	case r.score != nil && *r.score != *other.score:
+		return *r.score > *other.score
+	}
+	return r.id < other.id
+}
+
+// fetchPage does what a repository does for a request: select the rows beyond the cursor in the
+// order of the request, fetch one more than the limit and hand them to Page
+func fetchPage(t *testing.T, rows []pageRow, req Request) ([]pageRow, Result) {
+	t.Helper()
+
+	ordered := append([]pageRow(nil), rows...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].before(ordered[j]) })
+
+	var at *pageRow
+	if req.Cursor != nil {
+		if req.Cursor.Order != pageKeyset.Name {
+			t.Fatalf("cursor of order %q", req.Cursor.Order)
+		}
+		row := pageRow{}
+		if req.Cursor.Keys[0] != nil {
+			score, _ := strconv.Atoi(*req.Cursor.Keys[0])
+			row.score = &score
+		}
+		row.id, _ = strconv.Atoi(*req.Cursor.Keys[1])
+		at = &row
+
+		if req.Cursor.Backward {
+			for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+				ordered[i], ordered[j] = ordered[j], ordered[i]
+			}
+		}
+	}
+
+	fetched := []pageRow{}
+	keys := [][]*string{}
+	skipped := 0
+	for _, row := range ordered {
+		if at != nil {
+			beyond := at.before(row)
+			if req.Cursor.Backward {
+				beyond = row.before(*at)
+			}
+			if !beyond {
+				continue
+			}
+		}
+		if skipped < req.Offset() {
+			skipped++
+			continue
+		}
+		if len(fetched) == req.FetchLimit() {
+			break
+		}
+		fetched = append(fetched, row)
+		keys = append(keys, row.keys())
+	}
+
+	return Page(fetched, keys, req, pageKeyset, len(rows))
+}
+
+func pageIDs(rows []pageRow) []int {
+	ids := make([]int, len(rows))
+	for i, row := range rows {
+		ids[i] = row.id
+	}
+	return ids
+}
+
+func cursorRequest(t *testing.T, encoded string, limit int) Request {
+	t.Helper()
+
+	req, err := NewRequest(1, limit, encoded, nil)
+	if err != nil {
+		t.Fatalf("NewRequest returned error: %v", err)
+	}
+	return req
+}
+
+func TestPageRoundTrip(t *testing.T) {
+	rows := make([]pageRow, 0, 23)
+	for id := 1; id <= 23; id++ {
+		row := pageRow{id: id}
+		// Scores repeat so the ID has to break ties, and every fifth row has none
+		if id%5 != 0 {
+			score := id % 4
+			row.score = &score
+		}
+		rows = append(rows, row)
+	}
+
+	all := append([]pageRow(nil), rows...)
+	sort.Slice(all, func(i, j int) bool { return all[i].before(all[j]) })
+	const limit = 5
+
+	// Forward from the first page in page-number mode, following next cursors to the end
+	var forward [][]int
+	page, result := fetchPage(t, rows, Request{Page: 1, Limit: limit, IncludeTotal: true})
+	if result.PrevCursor != nil {
+		t.Error("first page has a previous cursor")
+	}
+	if result.Total == nil || *result.Total != len(rows) {
+		t.Errorf("first page total = %v, want %d", result.Total, len(rows))
+	}
+	forward = append(forward, pageIDs(page))
+	last := result
+	for result.NextCursor != nil {
+		page, result = fetchPage(t, rows, cursorRequest(t, *result.NextCursor, limit))
+		if result.PrevCursor == nil {
+			t.Errorf("page %d has no previous cursor", len(forward)+1)
+		}
+		if result.Total != nil {
+			t.Errorf("page %d reports a total in cursor mode", len(forward)+1)
+		}
+		forward = append(forward, pageIDs(page))
+		last = result
+		if len(forward) > len(rows) {
+			t.Fatal("next cursors do not reach the end")
+		}
+	}
+
+	var seen []int
+	for _, ids := range forward {
+		seen = append(seen, ids...)
+	}
+	if want := pageIDs(all); !reflect.DeepEqual(seen, want) {
+		t.Fatalf("forward pages = %v, want %v", seen, want)
+	}
+	if got := len(forward[len(forward)-1]); got != len(rows)%limit {
+		t.Errorf("last page has %d rows, want %d", got, len(rows)%limit)
+	}
+
+	// Backward from the last page, following previous cursors to the start
+	result = last
+	for i := len(forward) - 2; i >= 0; i-- {
+		if result.PrevCursor == nil {
+			t.Fatalf("page %d has no previous cursor", i+2)
+		}
+		page, result = fetchPage(t, rows, cursorRequest(t, *result.PrevCursor, limit))
+		if got := pageIDs(page); !reflect.DeepEqual(got, forward[i]) {
+			t.Errorf("page %d going back = %v, want %v", i+1, got, forward[i])
+		}
+		if result.NextCursor == nil {
+			t.Errorf("page %d going back has no next cursor", i+1)
+		}
+	}
+	if result.PrevCursor != nil {
+		t.Error("first page reached going back has a previous cursor")
+	}
+}
+
+func TestPagePageNumberMode(t *testing.T) {
+	rows := make([]pageRow, 0, 12)
+	for id := 1; id <= 12; id++ {
+		rows = append(rows, pageRow{id: id})
+	}
+
+	page, result := fetchPage(t, rows, Request{Page: 2, Limit: 5})
+	if got, want := pageIDs(page), []int{6, 7, 8, 9, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("page 2 = %v, want %v", got, want)
+	}
+	if result.NextCursor == nil || result.PrevCursor == nil {
+		t.Error("middle page is missing a cursor")
+	}
+
+	page, result = fetchPage(t, rows, Request{Page: 4, Limit: 5})
+	if len(page) != 0 || result.NextCursor != nil || result.PrevCursor != nil {
+		t.Errorf("page past the end = %v with cursors %+v", pageIDs(page), result)
+	}
+}
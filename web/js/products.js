@@ -4,11 +4,12 @@
 
 // Product functions
 const products = {
-  // Get all products
-  async getAll() {
+  // Get all products, narrowed and ordered by the sidebar filters when given
+  async getAll(filters) {
     try {
       console.log("Fetching products from API...");
-      const response = await fetchApi("/products");
+      const query = filters ? productFilters.toQueryString(filters) : "";
+      const response = await fetchApi(`/products${query}`);
       console.log("Raw API response for products:", response);
 
       // Adaptive handling for various response formats
@@ -32,7 +33,12 @@ const products = {
         return {
           data: {
             products: response.data.products,
+            total: response.data.total,
+            next_cursor: response.data.next_cursor,
+            prev_cursor: response.data.prev_cursor,
           },
+          // Facet counts for the filter sidebar
+          meta: response.meta,
         };
       }
 
@@ -65,6 +71,245 @@ const products = {
   },
 };
 
+// Filter sidebar: keeps the selected filters and renders the facet counts of the last listing
+const productFilters = {
+  state: {
+    sort: "newest",
+    category: "",
+    minPrice: null,
+    maxPrice: null,
+    inStock: false,
+    // Option name -> selected values
+    attributes: {},
+  },
+
+  sortLabels: {
+    newest: "Newest",
+    price_asc: "Price: low to high",
+    price_desc: "Price: high to low",
+    name_asc: "Name: A to Z",
+    name_desc: "Name: Z to A",
+    popularity: "Most popular",
+    rating: "Top rated",
+  },
+
+  // Build the listing query string of a filter state
+  toQueryString(filters) {
+    const params = new URLSearchParams();
+    if (filters.sort && filters.sort !== "newest") {
+      params.set("sort", filters.sort);
+    }
+    if (filters.category) {
+      params.set("category", filters.category);
+    }
+    if (filters.minPrice !== null && filters.minPrice !== undefined) {
+      params.set("min_price", filters.minPrice);
+    }
+    if (filters.maxPrice !== null && filters.maxPrice !== undefined) {
+      params.set("max_price", filters.maxPrice);
+    }
+    if (filters.inStock) {
+      params.set("in_stock", "true");
+    }
+    Object.entries(filters.attributes || {}).forEach(([name, values]) => {
+      values.forEach((value) => params.append("attribute", `${name}:${value}`));
+    });
+
+    const query = params.toString();
+    return query ? `?${query}` : "";
+  },
+
+  hasSelection() {
+    const state = this.state;
+    return (
+      !!state.category ||
+      state.minPrice !== null ||
+      state.maxPrice !== null ||
+      state.inStock ||
+      Object.keys(state.attributes).length > 0
+    );
+  },
+
+  reset() {
+    this.state = {
+      sort: this.state.sort,
+      category: "",
+      minPrice: null,
+      maxPrice: null,
+      inStock: false,
+      attributes: {},
+    };
+  },
+
+  toggleAttribute(name, value) {
+    const selected = this.state.attributes[name] || [];
+    const values = selected.includes(value)
+      ? selected.filter((candidate) => candidate !== value)
+      : [...selected, value];
+    if (values.length) {
+      this.state.attributes[name] = values;
+    } else {
+      delete this.state.attributes[name];
+    }
+  },
+
+  // A price range is the last that was clicked, or none when it is clicked again
+  togglePriceRange(min, max) {
+    if (this.state.minPrice === min && this.state.maxPrice === max) {
+      this.state.minPrice = null;
+      this.state.maxPrice = null;
+      return;
+    }
+    this.state.minPrice = min;
+    this.state.maxPrice = max;
+  },
+
+  priceRangeLabel(range) {
+    if (range.max === undefined || range.max === null) {
+      return `${formatPrice(range.min)} and up`;
+    }
+    return `${formatPrice(range.min)} - ${formatPrice(range.max)}`;
+  },
+
+  // Render the sidebar into container; onChange reloads the listing after a selection
+  render(container, facets, onChange) {
+    if (!container) return;
+    const state = this.state;
+    facets = facets || {};
+
+    const option = (label, count, selected, attrs) => `
+      <label class="flex items-center justify-between py-1 text-sm cursor-pointer ${
+        count === 0 && !selected ? "text-gray-400" : "text-gray-700"
+      }">
+        <span class="flex items-center">
+          <input type="checkbox" class="mr-2" ${selected ? "checked" : ""} ${attrs} />
+          ${label}
+        </span>
+        <span class="text-xs text-gray-500">${count}</span>
+      </label>`;
+
+    const section = (title, body) =>
+      body
+        ? `<div class="mb-6">
+            <h3 class="text-sm font-semibold text-gray-800 uppercase mb-2">${title}</h3>
+            ${body}
+          </div>`
+        : "";
+
+    const sortOptions = Object.entries(this.sortLabels)
+      .map(
+        ([value, label]) =>
+          `<option value="${value}" ${state.sort === value ? "selected" : ""}>${label}</option>`
+      )
+      .join("");
+
+    const categories = (facets.categories || [])
+      .map((category) =>
+        option(
+          category.name,
+          category.count,
+          state.category === category.slug,
+          `data-filter="category" data-value="${category.slug}"`
+        )
+      )
+      .join("");
+
+    // Price ranges without products are left out unless selected
+    const prices = (facets.prices || [])
+      .filter(
+        (range) =>
+          range.count > 0 ||
+          (state.minPrice === range.min && state.maxPrice === (range.max ?? null))
+      )
+      .map((range) =>
+        option(
+          this.priceRangeLabel(range),
+          range.count,
+          state.minPrice === range.min && state.maxPrice === (range.max ?? null),
+          `data-filter="price" data-min="${range.min}" data-max="${range.max ?? ""}"`
+        )
+      )
+      .join("");
+
+    const stock = facets.stock
+      ? option(
+          "In stock only",
+          facets.stock.in_stock,
+          state.inStock,
+          'data-filter="in_stock"'
+        )
+      : "";
+
+    const attributes = (facets.attributes || [])
+      .map((attribute) =>
+        section(
+          attribute.name,
+          attribute.values
+            .map((value) =>
+              option(
+                value.value,
+                value.count,
+                (state.attributes[attribute.name] || []).includes(value.value),
+                `data-filter="attribute" data-name="${attribute.name}" data-value="${value.value}"`
+              )
+            )
+            .join("")
+        )
+      )
+      .join("");
+
+    container.innerHTML = `
+      ${section(
+        "Sort by",
+        `<select id="product-sort" class="w-full border border-gray-300 rounded-md p-2 text-sm">${sortOptions}</select>`
+      )}
+      ${section("Category", categories)}
+      ${section("Price", prices)}
+      ${section("Availability", stock)}
+      ${attributes}
+      ${
+        this.hasSelection()
+          ? '<button id="clear-product-filters" class="w-full text-sm text-blue-600 hover:text-blue-800">Clear filters</button>'
+          : ""
+      }
+    `;
+
+    container.querySelector("#product-sort")?.addEventListener("change", (event) => {
+      state.sort = event.target.value;
+      onChange();
+    });
+
+    container.querySelector("#clear-product-filters")?.addEventListener("click", () => {
+      this.reset();
+      onChange();
+    });
+
+    container.querySelectorAll("input[data-filter]").forEach((input) => {
+      input.addEventListener("change", () => {
+        const data = input.dataset;
+        switch (data.filter) {
+          case "category":
+            state.category = state.category === data.value ? "" : data.value;
+            break;
+          case "price":
+            this.togglePriceRange(
+              parseFloat(data.min),
+              data.max === "" ? null : parseFloat(data.max)
+            );
+            break;
+          case "in_stock":
+            state.inStock = !state.inStock;
+            break;
+          case "attribute":
+            this.toggleAttribute(data.name, data.value);
+            break;
+        }
+        onChange();
+      });
+    });
+  },
+};
+
 // Initialize products page
 async function initProductsPage() {
   const productGrid = document.querySelector(".product-grid");
@@ -75,9 +320,15 @@ async function initProductsPage() {
     '<div class="loading-spinner">Loading products...</div>';
 
   try {
-    const response = await products.getAll();
+    const response = await products.getAll(productFilters.state);
     console.log("Products API response:", response);
 
+    productFilters.render(
+      document.querySelector(".product-filters"),
+      response?.meta?.facets,
+      initProductsPage
+    );
+
     // Handle different response structures
     let productList = [];
 
@@ -135,8 +386,34 @@ async function initProductsPage() {
         const inventory =
           product.inventory_quantity || product.stock || product.inventory || 0;
         const id = product.id || product.product_id || "";
+        // The first image is the main one
+        const mainImage = (product.images || [])[0];
         const imageUrl =
-          product.image_url || product.image || "img/product-placeholder.svg";
+          (mainImage && mainImage.thumbnail_url) ||
+          product.image_url ||
+          product.image ||
+          "img/product-placeholder.svg";
+        // The average is left out until a product has a review
+        const rating = product.review_count
+          ? `<p class="product-rating">&#9733; ${parseFloat(product.average_rating).toFixed(1)} (${product.review_count})</p>`
+          : "";
+
+        // A product with options is bought through one of its variants
+        const variants = product.variants || [];
+        const variantSelect = variants.length
+          ? `<select class="variant-select" data-product-id="${id}">
+              ${variants
+                .map((variant) => {
+                  const label = (product.options || [])
+                    .map((option) => variant.option_values?.[option.name])
+                    .filter(Boolean)
+                    .join(" / ");
+                  const soldOut = variant.inventory > 0 ? "" : " (sold out)";
+                  return `<option value="${variant.id}" ${soldOut ? "disabled" : ""}>${label} - $${parseFloat(variant.price).toFixed(2)}${soldOut}</option>`;
+                })
+                .join("")}
+            </select>`
+          : "";
 
         return `
       <div class="product-card">
@@ -146,7 +423,9 @@ async function initProductsPage() {
         <div class="product-info">
           <h3 class="product-name">${name}</h3>
           <p class="product-price">$${parseFloat(price).toFixed(2)}</p>
+          ${rating}
           <p class="product-inventory">In stock: ${inventory}</p>
+          ${variantSelect}
           <button class="btn btn-block add-to-cart" data-product-id="${id}">Add to Cart</button>
         </div>
       </div>
@@ -162,7 +441,13 @@ async function initProductsPage() {
           return;
         }
 
-        const productId = button.dataset.productId;
+        // Add the chosen variant rather than the parent product
+        const variantSelect = document.querySelector(
+          `.variant-select[data-product-id="${button.dataset.productId}"]`
+        );
+        const productId = variantSelect
+          ? variantSelect.value
+          : button.dataset.productId;
         const originalText = button.textContent;
 
         // Show loading state